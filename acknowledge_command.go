@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/service-config"
+)
+
+func acknowledgeCommand(args []string) int {
+	serviceConfig := service_config.New()
+
+	flags := flag.NewFlagSet("acknowledge", flag.ContinueOnError)
+	targetName := flags.String("target", "", "Name of the target, if the config lists Targets")
+	if code := parseFlags(flags, serviceConfig, args); code != exitOK {
+		return code
+	}
+	logger := newStderrLogger()
+
+	config, code := readConfig(serviceConfig, logger)
+	if code != exitOK {
+		return code
+	}
+	config, code = selectTarget(config, *targetName)
+	if code != exitOK {
+		return code
+	}
+
+	db, _, code := connect(config, logger)
+	if db != nil {
+		defer db.Close()
+	}
+	if code != exitOK {
+		return code
+	}
+
+	circuitBreakerRepo := database.NewCircuitBreakerRepo(config.DBName, db, logger)
+	err := circuitBreakerRepo.Setup()
+	if err != nil {
+		return fail(logger, "Failed to set up circuit breaker table", err, exitConnectionFailed)
+	}
+
+	tripped, reason, err := circuitBreakerRepo.Tripped()
+	if err != nil {
+		return fail(logger, "Failed to read circuit breaker", err, exitConnectionFailed)
+	}
+	if !tripped {
+		fmt.Fprintln(os.Stdout, "Circuit breaker is not tripped")
+		return exitOK
+	}
+
+	_, err = circuitBreakerRepo.Acknowledge()
+	if err != nil {
+		return fail(logger, "Failed to acknowledge circuit breaker", err, exitConnectionFailed)
+	}
+	logger.Info("Circuit breaker acknowledged", lager.Data{"Reason": reason})
+	fmt.Fprintf(os.Stdout, "Acknowledged circuit breaker trip: %s\n", reason)
+	return exitOK
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/service-config"
+)
+
+func checkConfigCommand(args []string) int {
+	serviceConfig := service_config.New()
+
+	flags := flag.NewFlagSet("check-config", flag.ContinueOnError)
+	targetName := flags.String("target", "", "Name of the target to check, if the config lists Targets; all by default")
+	if code := parseFlags(flags, serviceConfig, args); code != exitOK {
+		return code
+	}
+	logger := newStderrLogger()
+
+	config, code := readConfig(serviceConfig, logger)
+	if code != exitOK {
+		return code
+	}
+	fmt.Fprintln(os.Stdout, "Config is valid")
+
+	if len(config.Targets) == 0 || *targetName != "" {
+		config, code = selectTarget(config, *targetName)
+		if code != exitOK {
+			return code
+		}
+		return checkTarget(config, logger)
+	}
+
+	// Every target is checked, even after one fails.
+	for i, target := range config.TargetConfigs() {
+		name := config.Targets[i].Name
+		fmt.Fprintf(os.Stdout, "Checking target '%s'\n", name)
+		targetCode := checkTarget(target, logger.Session(name))
+		if targetCode != exitOK {
+			fmt.Fprintf(os.Stderr, "Target '%s' failed its checks\n", name)
+			code = targetCode
+		}
+	}
+	return code
+}
+
+// checkTarget checks the connections and privileges of a single target.
+func checkTarget(config config.Config, logger lager.Logger) int {
+	db, server, code := connect(config, logger)
+	if db != nil {
+		defer db.Close()
+	}
+	if code != exitOK {
+		return code
+	}
+	fmt.Fprintf(os.Stdout, "Connected to %s\n", server)
+
+	strategy, err := database.NewStrategy(config.EnforcementStrategy, server, logger)
+	if err != nil {
+		return fail(logger, "Invalid enforcement strategy", err, exitInvalidConfig)
+	}
+	fmt.Fprintf(os.Stdout, "Enforcement strategy '%s' is supported\n", strategy.Name())
+
+	brokers, disabled, code := detectBrokers(config, db, logger)
+	if code != exitOK {
+		return code
+	}
+	for _, feature := range disabled {
+		fmt.Fprintf(os.Stdout, "Disabled %s\n", feature)
+	}
+
+	// The account is checked even if the enforcer skips the preflight check.
+	checked := config
+	checked.Preflight = ""
+	missing, code := preflight(checked, brokers, server, db, logger)
+	if code != exitOK {
+		return code
+	}
+	if len(missing) > 0 {
+		return exitPreflightFailed
+	}
+	fmt.Fprintln(os.Stdout, "Enforcer account has the privileges it needs")
+
+	measurementDB, measurementServer, code := connectMeasurement(config, db, server, logger)
+	if measurementDB != nil && measurementDB != db {
+		defer measurementDB.Close()
+	}
+	if code != exitOK {
+		return code
+	}
+	if !config.Measurement.IsEmpty() {
+		fmt.Fprintf(os.Stdout, "Connected to measurement node %s\n", measurementServer)
+
+		lag, err := database.NewReplicaLag(measurementServer, measurementDB, logger).Lag()
+		if err != nil {
+			return fail(logger, "Failed to check replica lag", err, exitConnectionFailed)
+		}
+		if lag > config.MaxLag() {
+			fmt.Fprintf(os.Stderr, "Measurement node lags %s behind, more than %s\n", lag, config.MaxLag())
+			return exitFailed
+		}
+		fmt.Fprintf(os.Stdout, "Measurement node lags %s behind\n", lag)
+	}
+
+	nodes, code := connectClusterNodes(config, logger)
+	for _, node := range nodes {
+		defer node.DB.Close()
+	}
+	if code != exitOK {
+		return code
+	}
+	unreachable := 0
+	for _, node := range nodes {
+		err := node.DB.Ping()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cluster node '%s' cannot be reached: %s\n", node.Address, err.Error())
+			unreachable++
+			continue
+		}
+		fmt.Fprintf(os.Stdout, "Reached cluster node '%s'\n", node.Address)
+	}
+	if unreachable > 0 {
+		return exitConnectionFailed
+	}
+
+	for _, broker := range brokers {
+		instances, err := database.NewInstanceRepo(broker, server, measurementDB, logger).All()
+		if err != nil {
+			return fail(logger, "Failed to read the broker database", err, exitConnectionFailed)
+		}
+		fmt.Fprintf(os.Stdout, "Broker database '%s' has %d service instances\n", broker.DBName, len(instances))
+	}
+
+	if len(config.BrokerDBNames) > 0 {
+		duplicates, err := database.NewClaimRepo(brokers, server, measurementDB, logger).Duplicates()
+		if err != nil {
+			return fail(logger, "Failed to read the broker databases", err, exitConnectionFailed)
+		}
+		for dbName, brokers := range duplicates {
+			fmt.Fprintf(os.Stderr, "Database '%s' is claimed by brokers %s and is not enforced\n", dbName, strings.Join(brokers, ", "))
+		}
+		if len(duplicates) > 0 {
+			return exitFailed
+		}
+		fmt.Fprintln(os.Stdout, "No database is claimed by more than one broker")
+	}
+
+	return exitOK
+}
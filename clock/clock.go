@@ -4,6 +4,7 @@ import "time"
 
 type Clock interface {
 	After(time.Duration) <-chan time.Time
+	Now() time.Time
 }
 
 type clock struct{}
@@ -15,3 +16,7 @@ func DefaultClock() Clock {
 func (this clock) After(interval time.Duration) <-chan time.Time {
 	return time.After(interval)
 }
+
+func (this clock) Now() time.Time {
+	return time.Now()
+}
@@ -17,6 +17,12 @@ type FakeClock struct {
 	afterReturns struct {
 		result1 <-chan time.Time
 	}
+	NowStub        func() time.Time
+	nowMutex       sync.RWMutex
+	nowArgsForCall []struct{}
+	nowReturns     struct {
+		result1 time.Time
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -54,11 +60,38 @@ func (fake *FakeClock) AfterReturns(result1 <-chan time.Time) {
 	}{result1}
 }
 
+func (fake *FakeClock) Now() time.Time {
+	fake.nowMutex.Lock()
+	fake.nowArgsForCall = append(fake.nowArgsForCall, struct{}{})
+	fake.recordInvocation("Now", []interface{}{})
+	fake.nowMutex.Unlock()
+	if fake.NowStub != nil {
+		return fake.NowStub()
+	} else {
+		return fake.nowReturns.result1
+	}
+}
+
+func (fake *FakeClock) NowCallCount() int {
+	fake.nowMutex.RLock()
+	defer fake.nowMutex.RUnlock()
+	return len(fake.nowArgsForCall)
+}
+
+func (fake *FakeClock) NowReturns(result1 time.Time) {
+	fake.NowStub = nil
+	fake.nowReturns = struct {
+		result1 time.Time
+	}{result1}
+}
+
 func (fake *FakeClock) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.afterMutex.RLock()
 	defer fake.afterMutex.RUnlock()
+	fake.nowMutex.RLock()
+	defer fake.nowMutex.RUnlock()
 	return fake.invocations
 }
 
@@ -3,33 +3,855 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/validator.v2"
 )
 
+const (
+	TLSModeSkip    = "skip"
+	TLSModePrefer  = "prefer"
+	TLSModeRequire = "require"
+)
+
+const (
+	StrategyRevokeWrites   = "revoke-writes"
+	StrategyAccountLock    = "account-lock"
+	StrategyMaxConnections = "max-connections"
+	StrategyNotifyOnly     = "notify-only"
+)
+
+const (
+	PreflightWarn   = "warn"
+	PreflightRefuse = "refuse"
+	PreflightSkip   = "skip"
+)
+
+const (
+	QuotaUnitMB    = "MB"
+	QuotaUnitBytes = "bytes"
+)
+
 type Config struct {
-	Host           string   `yaml:"Host" validate:"nonzero"`
-	Port           int      `yaml:"Port" validate:"nonzero"`
-	User           string   `yaml:"User" validate:"nonzero"`
-	Password       string   `yaml:"Password"`
-	IgnoredUsers   []string `yaml:"IgnoredUsers"`
-	DBName         string   `yaml:"DBName" validate:"nonzero"`
-	PauseInSeconds int      `yaml:"PauseInSeconds" validate:"min=1"`
+	Host                     string                  `yaml:"Host"`
+	Port                     int                     `yaml:"Port"`
+	Socket                   string                  `yaml:"Socket"`
+	User                     string                  `yaml:"User" validate:"nonzero"`
+	Password                 string                  `yaml:"Password"`
+	PasswordFile             string                  `yaml:"PasswordFile"`
+	PasswordEnv              string                  `yaml:"PasswordEnv"`
+	IgnoredUsers             []string                `yaml:"IgnoredUsers"`
+	DBName                   string                  `yaml:"DBName" validate:"nonzero"`
+	BrokerDBNames            []string                `yaml:"BrokerDBNames"`
+	BrokerSchemas            map[string]BrokerSchema `yaml:"BrokerSchemas"`
+	PauseInSeconds           int                     `yaml:"PauseInSeconds" validate:"min=1"`
+	TLS                      TLSConfig               `yaml:"TLS"`
+	Measurement              Measurement             `yaml:"Measurement"`
+	ClusterNodes             []string                `yaml:"ClusterNodes"`
+	DSNParams                map[string]string       `yaml:"DSNParams"`
+	MaxOpenConnections       int                     `yaml:"MaxOpenConnections" validate:"min=0"`
+	MaxIdleConnections       int                     `yaml:"MaxIdleConnections" validate:"min=0"`
+	ConnMaxLifetimeInSeconds int                     `yaml:"ConnMaxLifetimeInSeconds" validate:"min=0"`
+	EnforcementStrategy      string                  `yaml:"EnforcementStrategy"`
+	ThrottleTiers            []ThrottleTier          `yaml:"ThrottleTiers"`
+	ConnectionLimits         ConnectionLimits        `yaml:"ConnectionLimits"`
+	ObjectQuotas             bool                    `yaml:"ObjectQuotas"`
+	Webhooks                 []Webhook               `yaml:"Webhooks"`
+	WarningThresholds        []float64               `yaml:"WarningThresholds"`
+	MetricsPort              int                     `yaml:"MetricsPort" validate:"min=0"`
+	PublishUsage             bool                    `yaml:"PublishUsage"`
+	Preflight                string                  `yaml:"Preflight"`
+	CircuitBreaker           CircuitBreaker          `yaml:"CircuitBreaker"`
+	UsageHistory             UsageHistory            `yaml:"UsageHistory"`
+	LargestTables            int                     `yaml:"LargestTables" validate:"min=0"`
+	Reclaim                  Reclaim                 `yaml:"Reclaim"`
+	Targets                  []Target                `yaml:"Targets"`
+}
+
+// Target is one of several MySQL deployments enforced by the same process,
+// each by its own enforcer with its own schedule. Unset connection settings,
+// credentials, IgnoredUsers, DBName and PauseInSeconds default to those at the
+// top level of the config, and all other settings are shared. BrokerDBNames
+// goes with DBName. Measurement and ClusterNodes describe a single
+// deployment, so they are never shared.
+type Target struct {
+	Name           string      `yaml:"Name"`
+	Host           string      `yaml:"Host"`
+	Port           int         `yaml:"Port"`
+	Socket         string      `yaml:"Socket"`
+	User           string      `yaml:"User"`
+	Password       string      `yaml:"Password"`
+	PasswordFile   string      `yaml:"PasswordFile"`
+	PasswordEnv    string      `yaml:"PasswordEnv"`
+	IgnoredUsers   []string    `yaml:"IgnoredUsers"`
+	DBName         string      `yaml:"DBName"`
+	BrokerDBNames  []string    `yaml:"BrokerDBNames"`
+	PauseInSeconds int         `yaml:"PauseInSeconds"`
+	Measurement    Measurement `yaml:"Measurement"`
+	ClusterNodes   []string    `yaml:"ClusterNodes"`
+}
+
+// TLSConfig describes how the connection to MySQL is encrypted.
+// Mode defaults to "skip", which connects without TLS.
+type TLSConfig struct {
+	Mode       string `yaml:"Mode"`
+	CACertFile string `yaml:"CACertFile"`
+	CertFile   string `yaml:"CertFile"`
+	KeyFile    string `yaml:"KeyFile"`
+	ServerName string `yaml:"ServerName"`
+}
+
+// Measurement is a replica or dedicated Galera node which usage and privileges
+// are read from, keeping the heaviest queries off the node serving traffic.
+// Grants, revokes and kills still run against Host. Port defaults to Port, and
+// the credentials and TLS settings are those of Host. Enforcement is skipped
+// while a replica lags more than MaxLagInSeconds (default PauseInSeconds) behind.
+type Measurement struct {
+	Host            string `yaml:"Host"`
+	Port            int    `yaml:"Port" validate:"min=0"`
+	Socket          string `yaml:"Socket"`
+	MaxLagInSeconds int    `yaml:"MaxLagInSeconds" validate:"min=0"`
+}
+
+// IsEmpty is true if usage and privileges are read from Host.
+func (m Measurement) IsEmpty() bool {
+	return m.Host == "" && m.Socket == ""
+}
+
+// MeasurementConfig returns the config for connecting to the measurement
+// node, or the config itself if there is none.
+func (c Config) MeasurementConfig() Config {
+	if c.Measurement.IsEmpty() {
+		return c
+	}
+
+	measurement := c
+	measurement.Host = c.Measurement.Host
+	measurement.Socket = c.Measurement.Socket
+	if c.Measurement.Port != 0 {
+		measurement.Port = c.Measurement.Port
+	}
+	return measurement
+}
+
+// MaxLag returns how far the measurement node may lag behind Host.
+func (c Config) MaxLag() time.Duration {
+	seconds := c.Measurement.MaxLagInSeconds
+	if seconds == 0 {
+		seconds = c.PauseInSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// ClusterNodeConfigs returns the configs for connecting to each of
+// ClusterNodes, given as "host" or "host:port", with the port defaulting to
+// Port. The credentials and TLS settings are those of Host.
+func (c Config) ClusterNodeConfigs() []Config {
+	var configs []Config
+	for _, address := range c.ClusterNodes {
+		node := c
+		node.Host, node.Port, _ = parseNodeAddress(address, c.Port)
+		node.Socket = ""
+		node.Measurement = Measurement{}
+		node.ClusterNodes = nil
+		configs = append(configs, node)
+	}
+	return configs
+}
+
+// TargetConfigs returns the config of each of Targets, or the config itself
+// if there are none.
+func (c Config) TargetConfigs() []Config {
+	if len(c.Targets) == 0 {
+		return []Config{c}
+	}
+
+	var configs []Config
+	for _, target := range c.Targets {
+		configs = append(configs, c.targetConfig(target))
+	}
+	return configs
+}
+
+func (c Config) targetConfig(target Target) Config {
+	config := c
+	config.Targets = nil
+	config.Measurement = target.Measurement
+	config.ClusterNodes = target.ClusterNodes
+
+	// The address and the password are only inherited as a whole, so a
+	// target setting a Socket does not also get the Host of another target.
+	if target.Host != "" || target.Port != 0 || target.Socket != "" {
+		config.Host = target.Host
+		config.Port = target.Port
+		config.Socket = target.Socket
+	}
+	if target.Password != "" || target.PasswordFile != "" || target.PasswordEnv != "" {
+		config.Password = target.Password
+		config.PasswordFile = target.PasswordFile
+		config.PasswordEnv = target.PasswordEnv
+	}
+	if target.User != "" {
+		config.User = target.User
+	}
+	if target.IgnoredUsers != nil {
+		config.IgnoredUsers = target.IgnoredUsers
+	}
+	if target.DBName != "" {
+		config.DBName = target.DBName
+		config.BrokerDBNames = target.BrokerDBNames
+	}
+	if target.PauseInSeconds != 0 {
+		config.PauseInSeconds = target.PauseInSeconds
+	}
+	return config
+}
+
+// TargetConfig returns the config of the target with the given name.
+func (c Config) TargetConfig(name string) (Config, bool) {
+	for _, target := range c.Targets {
+		if target.Name == name {
+			return c.targetConfig(target), true
+		}
+	}
+	return Config{}, false
+}
+
+// TargetNames returns the names of Targets.
+func (c Config) TargetNames() []string {
+	var names []string
+	for _, target := range c.Targets {
+		names = append(names, target.Name)
+	}
+	return names
+}
+
+func parseNodeAddress(address string, defaultPort int) (string, int, error) {
+	if !strings.Contains(address, ":") {
+		return address, defaultPort, nil
+	}
+
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port '%s'", portString)
+	}
+	return host, port, nil
+}
+
+// ThrottleTier limits the writes of the users of an instance once it uses
+// PercentOfQuota of its quota.
+type ThrottleTier struct {
+	PercentOfQuota    float64 `yaml:"PercentOfQuota"`
+	MaxUpdatesPerHour int     `yaml:"MaxUpdatesPerHour"`
+}
+
+// ConnectionLimits caps the connections of every binding user of an instance.
+// The limit of an instance, keyed by its guid, takes precedence over that of
+// its plan, keyed by plan guid, which takes precedence over Default. Zero
+// leaves the connection limit of a user alone.
+type ConnectionLimits struct {
+	Default   int            `yaml:"Default"`
+	Plans     map[string]int `yaml:"Plans"`
+	Instances map[string]int `yaml:"Instances"`
+}
+
+// IsEmpty is true if no connection limit is configured.
+func (l ConnectionLimits) IsEmpty() bool {
+	return l.Default == 0 && len(l.Plans) == 0 && len(l.Instances) == 0
+}
+
+// CircuitBreaker limits how many instances may be newly restricted in one
+// enforcement cycle, as a number or a percentage of all instances. Exceeding
+// either limit stops restricting until an operator acknowledges. Zero disables a limit.
+type CircuitBreaker struct {
+	MaxRestrictions        int     `yaml:"MaxRestrictions"`
+	MaxRestrictionsPercent float64 `yaml:"MaxRestrictionsPercent"`
+}
+
+// IsEmpty is true if no limit is configured.
+func (b CircuitBreaker) IsEmpty() bool {
+	return b.MaxRestrictions == 0 && b.MaxRestrictionsPercent == 0
+}
+
+// UsageHistory records a usage sample per instance, at most every
+// SampleIntervalInSeconds (default every cycle), and keeps samples for
+// RetentionInDays (default 30). Growth forecasts are computed from the history.
+type UsageHistory struct {
+	Enabled                 bool `yaml:"Enabled"`
+	SampleIntervalInSeconds int  `yaml:"SampleIntervalInSeconds" validate:"min=0"`
+	RetentionInDays         int  `yaml:"RetentionInDays" validate:"min=0"`
+}
+
+// Retention returns how long samples are kept.
+func (h UsageHistory) Retention() time.Duration {
+	days := h.RetentionInDays
+	if days == 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Reclaim rebuilds fragmented tables of over-quota instances, so that space
+// freed by deleted rows is given back, during the daily UTC window from
+// WindowStart to WindowEnd ("15:04"). Only tables with at least MinDataFreeMB
+// free (default 100) and of at most MaxTableSizeMB (default 1024) are rebuilt,
+// each at most once per window. No rebuild is started after MaxSecondsPerCycle
+// (default 600) of a cycle, or that would take the tables rebuilt in a cycle
+// past MaxMBPerCycle (default 4096).
+type Reclaim struct {
+	Enabled            bool    `yaml:"Enabled"`
+	WindowStart        string  `yaml:"WindowStart"`
+	WindowEnd          string  `yaml:"WindowEnd"`
+	MinDataFreeMB      float64 `yaml:"MinDataFreeMB" validate:"min=0"`
+	MaxTableSizeMB     float64 `yaml:"MaxTableSizeMB" validate:"min=0"`
+	MaxSecondsPerCycle int     `yaml:"MaxSecondsPerCycle" validate:"min=0"`
+	MaxMBPerCycle      float64 `yaml:"MaxMBPerCycle" validate:"min=0"`
+}
+
+// InWindow is true if t lies within the reclaim window. A window whose end
+// is before its start spans midnight.
+func (r Reclaim) InWindow(t time.Time) bool {
+	start, err := parseTimeOfDay(r.WindowStart)
+	if err != nil {
+		return false
+	}
+	end, err := parseTimeOfDay(r.WindowEnd)
+	if err != nil {
+		return false
+	}
+
+	t = t.UTC()
+	timeOfDay := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if start <= end {
+		return start <= timeOfDay && timeOfDay < end
+	}
+	return timeOfDay >= start || timeOfDay < end
+}
+
+// WindowStartedAt returns when the window t lies in started. It is only
+// meaningful if InWindow(t).
+func (r Reclaim) WindowStartedAt(t time.Time) time.Time {
+	start, err := parseTimeOfDay(r.WindowStart)
+	if err != nil {
+		return t
+	}
+
+	t = t.UTC()
+	startedAt := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Add(start)
+	if startedAt.After(t) {
+		startedAt = startedAt.AddDate(0, 0, -1)
+	}
+	return startedAt
+}
+
+// MinDataFree returns the free space a table needs to be rebuilt.
+func (r Reclaim) MinDataFree() float64 {
+	if r.MinDataFreeMB == 0 {
+		return 100
+	}
+	return r.MinDataFreeMB
+}
+
+// MaxTableSize returns the size of the largest table rebuilt.
+func (r Reclaim) MaxTableSize() float64 {
+	if r.MaxTableSizeMB == 0 {
+		return 1024
+	}
+	return r.MaxTableSizeMB
+}
+
+// SizeBudget returns the size of the tables rebuilt in a cycle, in MB.
+func (r Reclaim) SizeBudget() float64 {
+	if r.MaxMBPerCycle == 0 {
+		return 4096
+	}
+	return r.MaxMBPerCycle
+}
+
+// Budget returns how long rebuilds may be started for in a cycle.
+func (r Reclaim) Budget() time.Duration {
+	seconds := r.MaxSecondsPerCycle
+	if seconds == 0 {
+		seconds = 600
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Webhook receives a JSON event for every enforcement transition. Requests are
+// signed with HMAC-SHA256 if a Secret is given. Failed requests are retried
+// with exponential backoff, up to MaxAttempts attempts in total (default 3).
+// Events are queued and delivered in the background; once QueueSize events
+// (default 100) wait for delivery, further events are dropped.
+type Webhook struct {
+	URL              string `yaml:"URL"`
+	Secret           string `yaml:"Secret"`
+	MaxAttempts      int    `yaml:"MaxAttempts"`
+	TimeoutInSeconds int    `yaml:"TimeoutInSeconds"`
+	QueueSize        int    `yaml:"QueueSize"`
+}
+
+// BrokerDBs returns the broker databases whose instances are enforced,
+// DBName first. The tables of the enforcer itself live in DBName.
+func (c Config) BrokerDBs() []string {
+	return append([]string{c.DBName}, c.BrokerDBNames...)
+}
+
+// BrokerSchema maps the tables and columns of a broker database to those of
+// cf-mysql-broker, which any name left unset defaults to. With PlansTable,
+// the quota and object quota columns are read from the plan of an instance,
+// where PlanKeyColumn (default guid) matches PlanGUIDColumn. QuotaUnit is
+// "MB" (default) or "bytes".
+type BrokerSchema struct {
+	InstancesTable     string `yaml:"InstancesTable"`
+	IDColumn           string `yaml:"IDColumn"`
+	GUIDColumn         string `yaml:"GUIDColumn"`
+	DBNameColumn       string `yaml:"DBNameColumn"`
+	PlanGUIDColumn     string `yaml:"PlanGUIDColumn"`
+	QuotaColumn        string `yaml:"QuotaColumn"`
+	QuotaUnit          string `yaml:"QuotaUnit"`
+	MaxTablesColumn    string `yaml:"MaxTablesColumn"`
+	MaxRoutinesColumn  string `yaml:"MaxRoutinesColumn"`
+	PlansTable         string `yaml:"PlansTable"`
+	PlanKeyColumn      string `yaml:"PlanKeyColumn"`
+	ReadOnlyUsersTable string `yaml:"ReadOnlyUsersTable"`
+	GranteeColumn      string `yaml:"GranteeColumn"`
+}
+
+// DefaultBrokerSchema is the schema of cf-mysql-broker.
+var DefaultBrokerSchema = BrokerSchema{
+	InstancesTable:     "service_instances",
+	IDColumn:           "id",
+	GUIDColumn:         "guid",
+	DBNameColumn:       "db_name",
+	PlanGUIDColumn:     "plan_guid",
+	QuotaColumn:        "max_storage_mb",
+	QuotaUnit:          QuotaUnitMB,
+	MaxTablesColumn:    "max_tables",
+	MaxRoutinesColumn:  "max_routines",
+	ReadOnlyUsersTable: "read_only_users",
+	GranteeColumn:      "grantee",
+}
+
+// Resolved returns the schema with every unset name set to its default.
+func (s BrokerSchema) Resolved() BrokerSchema {
+	resolved := s
+	defaults := DefaultBrokerSchema
+	for _, field := range []struct {
+		value    *string
+		fallback string
+	}{
+		{&resolved.InstancesTable, defaults.InstancesTable},
+		{&resolved.IDColumn, defaults.IDColumn},
+		{&resolved.GUIDColumn, defaults.GUIDColumn},
+		{&resolved.DBNameColumn, defaults.DBNameColumn},
+		{&resolved.PlanGUIDColumn, defaults.PlanGUIDColumn},
+		{&resolved.QuotaColumn, defaults.QuotaColumn},
+		{&resolved.QuotaUnit, defaults.QuotaUnit},
+		{&resolved.MaxTablesColumn, defaults.MaxTablesColumn},
+		{&resolved.MaxRoutinesColumn, defaults.MaxRoutinesColumn},
+		{&resolved.ReadOnlyUsersTable, defaults.ReadOnlyUsersTable},
+		{&resolved.GranteeColumn, defaults.GranteeColumn},
+	} {
+		if *field.value == "" {
+			*field.value = field.fallback
+		}
+	}
+	if resolved.PlansTable != "" && resolved.PlanKeyColumn == "" {
+		resolved.PlanKeyColumn = "guid"
+	}
+	return resolved
+}
+
+// IsDefault is true if the schema is that of cf-mysql-broker.
+func (s BrokerSchema) IsDefault() bool {
+	return s.Resolved() == DefaultBrokerSchema
+}
+
+// SchemaOf returns the schema of a broker database, the cf-mysql-broker
+// schema unless BrokerSchemas maps it.
+func (c Config) SchemaOf(brokerDBName string) BrokerSchema {
+	return c.BrokerSchemas[brokerDBName].Resolved()
+}
+
+// LargestTableCount returns how many of the largest tables of a violator are
+// captured when it is restricted, LargestTables or 5 by default.
+func (c Config) LargestTableCount() int {
+	if c.LargestTables == 0 {
+		return 5
+	}
+	return c.LargestTables
+}
+
+// LowestWarningThreshold returns the lowest of WarningThresholds, or zero if
+// there are none.
+func (c Config) LowestWarningThreshold() float64 {
+	var lowest float64
+	for _, threshold := range c.WarningThresholds {
+		if lowest == 0 || threshold < lowest {
+			lowest = threshold
+		}
+	}
+	return lowest
 }
 
 func (c Config) Validate() error {
+	if len(c.Targets) > 0 {
+		return c.validateTargets()
+	}
+
 	err := validator.Validate(c)
 	var errString string
 	if err != nil {
 		errString = formatErrorString(err)
 	}
 
+	errString += c.validateAddress()
+	errString += c.validateMeasurement()
+	errString += c.validateClusterNodes()
+	errString += c.validatePasswordSource()
+	errString += c.validateBrokerDBNames()
+	errString += c.validateBrokerSchemas()
+	errString += c.validateDSNParams()
+	errString += c.validateEnforcementStrategy()
+	errString += c.validatePreflight()
+	errString += c.validateThrottleTiers()
+	errString += c.validateConnectionLimits()
+	errString += c.validateWebhooks()
+	errString += c.validateWarningThresholds()
+	errString += c.validateCircuitBreaker()
+	errString += c.validateReclaim()
+	errString += c.TLS.validate()
+
+	if len(errString) > 0 {
+		return errors.New(fmt.Sprintf("Validation errors: %s\n", errString))
+	}
+	return nil
+}
+
+// validateTargets validates the config of each target, as the settings at
+// the top level may be incomplete on their own.
+func (c Config) validateTargets() error {
+	var errString string
+	if !c.Measurement.IsEmpty() {
+		errString += "Measurement : must be specified per target when Targets are specified\n"
+	}
+	if len(c.ClusterNodes) > 0 {
+		errString += "ClusterNodes : must be specified per target when Targets are specified\n"
+	}
+
+	names := map[string]bool{}
+	for i, target := range c.Targets {
+		prefix := fmt.Sprintf("Targets[%d].", i)
+		if target.Name == "" {
+			errString += prefix + "Name : zero value\n"
+		} else if names[target.Name] {
+			errString += fmt.Sprintf("%sName : duplicate name '%s'\n", prefix, target.Name)
+		}
+		names[target.Name] = true
+
+		err := c.targetConfig(target).Validate()
+		if err != nil {
+			message := strings.TrimRight(strings.TrimPrefix(err.Error(), "Validation errors: "), "\n")
+			for _, line := range strings.Split(message, "\n") {
+				errString += prefix + line + "\n"
+			}
+		}
+	}
+
 	if len(errString) > 0 {
 		return errors.New(fmt.Sprintf("Validation errors: %s\n", errString))
 	}
 	return nil
 }
 
+// validateAddress requires either a Socket or both Host and Port.
+func (c Config) validateAddress() string {
+	if c.Socket != "" {
+		return ""
+	}
+
+	var errsString string
+	if c.Host == "" {
+		errsString += "Host : zero value, and no Socket specified\n"
+	}
+	if c.Port == 0 {
+		errsString += "Port : zero value, and no Socket specified\n"
+	}
+	return errsString
+}
+
+// validateMeasurement allows either a Host or a Socket for the measurement node.
+func (c Config) validateMeasurement() string {
+	if c.Measurement.Host != "" && c.Measurement.Socket != "" {
+		return "Measurement.Host : must not be specified together with Measurement.Socket\n"
+	}
+	if c.Measurement.IsEmpty() && (c.Measurement.Port != 0 || c.Measurement.MaxLagInSeconds != 0) {
+		return "Measurement.Host : zero value, and no Measurement.Socket specified\n"
+	}
+	return ""
+}
+
+func (c Config) validateClusterNodes() string {
+	var errsString string
+	for i, address := range c.ClusterNodes {
+		host, port, err := parseNodeAddress(address, c.Port)
+		if err != nil {
+			errsString += fmt.Sprintf("ClusterNodes[%d] : %s\n", i, err.Error())
+		} else if host == "" {
+			errsString += fmt.Sprintf("ClusterNodes[%d] : zero value\n", i)
+		} else if port == 0 {
+			errsString += fmt.Sprintf("ClusterNodes[%d] : no port, and no Port specified\n", i)
+		}
+	}
+	return errsString
+}
+
+// validatePasswordSource allows at most one of Password, PasswordFile and PasswordEnv.
+func (c Config) validatePasswordSource() string {
+	sources := 0
+	for _, source := range []string{c.Password, c.PasswordFile, c.PasswordEnv} {
+		if source != "" {
+			sources++
+		}
+	}
+
+	if sources > 1 {
+		return "Password : only one of Password, PasswordFile and PasswordEnv may be specified\n"
+	}
+	return ""
+}
+
+// validateBrokerDBNames rejects empty and repeated broker databases.
+func (c Config) validateBrokerDBNames() string {
+	var errsString string
+	seen := map[string]bool{c.DBName: true}
+	for i, name := range c.BrokerDBNames {
+		if name == "" {
+			errsString += fmt.Sprintf("BrokerDBNames[%d] : zero value\n", i)
+		} else if seen[name] {
+			errsString += fmt.Sprintf("BrokerDBNames[%d] : duplicate broker database '%s'\n", i, name)
+		}
+		seen[name] = true
+	}
+	return errsString
+}
+
+// validateBrokerSchemas requires each schema to belong to a broker database
+// and to have a known quota unit.
+func (c Config) validateBrokerSchemas() string {
+	brokerDBs := map[string]bool{}
+	for _, brokerDBName := range c.BrokerDBs() {
+		brokerDBs[brokerDBName] = true
+	}
+
+	names := make([]string, 0, len(c.BrokerSchemas))
+	for name := range c.BrokerSchemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errsString string
+	for _, name := range names {
+		if !brokerDBs[name] {
+			errsString += fmt.Sprintf("BrokerSchemas[%s] : not DBName or one of BrokerDBNames\n", name)
+		}
+		switch c.BrokerSchemas[name].QuotaUnit {
+		case "", QuotaUnitMB, QuotaUnitBytes:
+		default:
+			errsString += fmt.Sprintf("BrokerSchemas[%s].QuotaUnit : must be '%s' or '%s'\n", name, QuotaUnitMB, QuotaUnitBytes)
+		}
+	}
+	return errsString
+}
+
+// validateDSNParams checks the driver parameters that the enforcer itself
+// depends on. Other parameters are passed through to the driver unchanged.
+// maxAllowedPacket is rejected until the vendored driver, which reads the
+// server's max_allowed_packet on connect, is updated to one that supports it.
+func (c Config) validateDSNParams() string {
+	var errsString string
+	for name, value := range c.DSNParams {
+		switch name {
+		case "":
+			errsString += "DSNParams : parameter names must not be empty\n"
+		case "timeout", "readTimeout", "writeTimeout":
+			if _, err := time.ParseDuration(value); err != nil {
+				errsString += fmt.Sprintf("DSNParams.%s : %s\n", name, err.Error())
+			}
+		case "tls":
+			errsString += "DSNParams.tls : use the TLS section instead\n"
+		case "maxAllowedPacket":
+			errsString += "DSNParams.maxAllowedPacket : not supported by the MySQL driver in use, the server's max_allowed_packet is used\n"
+		}
+	}
+	return errsString
+}
+
+func (c Config) validateEnforcementStrategy() string {
+	switch c.EnforcementStrategy {
+	case "", StrategyRevokeWrites, StrategyAccountLock, StrategyMaxConnections, StrategyNotifyOnly:
+		return ""
+	}
+	return fmt.Sprintf(
+		"EnforcementStrategy : must be one of '%s', '%s', '%s' or '%s'\n",
+		StrategyRevokeWrites, StrategyAccountLock, StrategyMaxConnections, StrategyNotifyOnly,
+	)
+}
+
+func (c Config) validatePreflight() string {
+	switch c.Preflight {
+	case "", PreflightWarn, PreflightRefuse, PreflightSkip:
+		return ""
+	}
+	return fmt.Sprintf(
+		"Preflight : must be one of '%s', '%s' or '%s'\n",
+		PreflightWarn, PreflightRefuse, PreflightSkip,
+	)
+}
+
+func (c Config) validateThrottleTiers() string {
+	var errsString string
+	for i, tier := range c.ThrottleTiers {
+		if tier.PercentOfQuota <= 0 || tier.PercentOfQuota >= 100 {
+			errsString += fmt.Sprintf("ThrottleTiers[%d].PercentOfQuota : must be between 0 and 100\n", i)
+		}
+		if tier.MaxUpdatesPerHour < 1 {
+			errsString += fmt.Sprintf("ThrottleTiers[%d].MaxUpdatesPerHour : less than min\n", i)
+		}
+
+		for _, other := range c.ThrottleTiers[:i] {
+			if other.PercentOfQuota == tier.PercentOfQuota {
+				errsString += fmt.Sprintf("ThrottleTiers[%d].PercentOfQuota : duplicate tier at %g%%\n", i, tier.PercentOfQuota)
+			} else if (other.PercentOfQuota < tier.PercentOfQuota) != (other.MaxUpdatesPerHour > tier.MaxUpdatesPerHour) {
+				errsString += fmt.Sprintf("ThrottleTiers[%d].MaxUpdatesPerHour : must decrease as PercentOfQuota increases\n", i)
+			}
+		}
+	}
+	return errsString
+}
+
+// validateConnectionLimits rejects negative limits, and a limit of 1 with the
+// max-connections strategy, which uses it to mark restricted users.
+func (c Config) validateConnectionLimits() string {
+	var errsString string
+	validateLimit := func(field string, limit int) {
+		if limit < 0 {
+			errsString += fmt.Sprintf("%s : less than min\n", field)
+		} else if limit == 1 && c.EnforcementStrategy == StrategyMaxConnections {
+			errsString += fmt.Sprintf("%s : 1 is reserved for the '%s' enforcement strategy\n", field, StrategyMaxConnections)
+		}
+	}
+
+	validateLimit("ConnectionLimits.Default", c.ConnectionLimits.Default)
+	for guid, limit := range c.ConnectionLimits.Plans {
+		validateLimit(fmt.Sprintf("ConnectionLimits.Plans.%s", guid), limit)
+	}
+	for guid, limit := range c.ConnectionLimits.Instances {
+		validateLimit(fmt.Sprintf("ConnectionLimits.Instances.%s", guid), limit)
+	}
+	return errsString
+}
+
+func (c Config) validateWebhooks() string {
+	var errsString string
+	for i, webhook := range c.Webhooks {
+		u, err := url.Parse(webhook.URL)
+		if err != nil {
+			errsString += fmt.Sprintf("Webhooks[%d].URL : %s\n", i, err.Error())
+		} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errsString += fmt.Sprintf("Webhooks[%d].URL : must be an http or https URL\n", i)
+		}
+		if webhook.MaxAttempts < 0 {
+			errsString += fmt.Sprintf("Webhooks[%d].MaxAttempts : less than min\n", i)
+		}
+		if webhook.TimeoutInSeconds < 0 {
+			errsString += fmt.Sprintf("Webhooks[%d].TimeoutInSeconds : less than min\n", i)
+		}
+		if webhook.QueueSize < 0 {
+			errsString += fmt.Sprintf("Webhooks[%d].QueueSize : less than min\n", i)
+		}
+	}
+	return errsString
+}
+
+func (c Config) validateWarningThresholds() string {
+	var errsString string
+	for i, threshold := range c.WarningThresholds {
+		if threshold <= 0 || threshold >= 100 {
+			errsString += fmt.Sprintf("WarningThresholds[%d] : must be between 0 and 100\n", i)
+		}
+		for _, other := range c.WarningThresholds[:i] {
+			if other == threshold {
+				errsString += fmt.Sprintf("WarningThresholds[%d] : duplicate threshold %g%%\n", i, threshold)
+			}
+		}
+	}
+	return errsString
+}
+
+func (c Config) validateCircuitBreaker() string {
+	var errsString string
+	if c.CircuitBreaker.MaxRestrictions < 0 {
+		errsString += "CircuitBreaker.MaxRestrictions : less than min\n"
+	}
+	if c.CircuitBreaker.MaxRestrictionsPercent < 0 || c.CircuitBreaker.MaxRestrictionsPercent > 100 {
+		errsString += "CircuitBreaker.MaxRestrictionsPercent : must be between 0 and 100\n"
+	}
+	return errsString
+}
+
+// validateReclaim requires a window when reclaiming is enabled.
+func (c Config) validateReclaim() string {
+	if !c.Reclaim.Enabled {
+		return ""
+	}
+
+	var errsString string
+	if _, err := parseTimeOfDay(c.Reclaim.WindowStart); err != nil {
+		errsString += "Reclaim.WindowStart : must be a time of day like '02:00'\n"
+	}
+	if _, err := parseTimeOfDay(c.Reclaim.WindowEnd); err != nil {
+		errsString += "Reclaim.WindowEnd : must be a time of day like '05:00'\n"
+	}
+	if errsString == "" && c.Reclaim.WindowStart == c.Reclaim.WindowEnd {
+		errsString += "Reclaim.WindowEnd : must differ from WindowStart\n"
+	}
+	return errsString
+}
+
+func (t TLSConfig) validate() string {
+	var errsString string
+
+	switch t.Mode {
+	case "", TLSModeSkip, TLSModePrefer, TLSModeRequire:
+	default:
+		errsString += fmt.Sprintf("TLS.Mode : must be one of '%s', '%s' or '%s'\n", TLSModeSkip, TLSModePrefer, TLSModeRequire)
+	}
+
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		errsString += "TLS.CertFile : must be specified together with TLS.KeyFile\n"
+	}
+
+	return errsString
+}
+
 func formatErrorString(err error) string {
 	errs := err.(validator.ErrorMap)
 	var errsString string
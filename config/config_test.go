@@ -1,6 +1,8 @@
 package config_test
 
 import (
+	"time"
+
 	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
 
 	. "github.com/onsi/ginkgo"
@@ -52,6 +54,83 @@ var _ = Describe("Config", func() {
 			})
 		})
 
+		Context("when Host and Port are not specified but Socket is", func() {
+			BeforeEach(func() {
+				config.Host = ""
+				config.Port = 0
+				config.Socket = "/var/run/mysqld/mysqld.sock"
+			})
+
+			It("does not return a validation error", func() {
+				err := config.Validate()
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when DSNParams are specified", func() {
+			BeforeEach(func() {
+				config.DSNParams = map[string]string{
+					"timeout":      "5s",
+					"readTimeout":  "30s",
+					"writeTimeout": "30s",
+					"charset":      "utf8mb4",
+				}
+			})
+
+			It("does not return a validation error", func() {
+				err := config.Validate()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			Context("when a timeout is not a duration", func() {
+				BeforeEach(func() {
+					config.DSNParams["readTimeout"] = "thirty"
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("DSNParams.readTimeout"))
+				})
+			})
+
+			Context("when tls is specified", func() {
+				BeforeEach(func() {
+					config.DSNParams["tls"] = "true"
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("DSNParams.tls"))
+				})
+			})
+		})
+
+		Context("when MaxOpenConnections is negative", func() {
+			BeforeEach(func() {
+				config.MaxOpenConnections = -1
+			})
+
+			It("returns a validation error", func() {
+				err := config.Validate()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("MaxOpenConnections"))
+			})
+		})
+
+		Context("when ConnMaxLifetimeInSeconds is negative", func() {
+			BeforeEach(func() {
+				config.ConnMaxLifetimeInSeconds = -1
+			})
+
+			It("returns a validation error", func() {
+				err := config.Validate()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("ConnMaxLifetimeInSeconds"))
+			})
+		})
+
 		Context("when User is not specified", func() {
 			BeforeEach(func() {
 				config.User = ""
@@ -64,6 +143,30 @@ var _ = Describe("Config", func() {
 			})
 		})
 
+		Context("when PasswordFile is specified instead of Password", func() {
+			BeforeEach(func() {
+				config.Password = ""
+				config.PasswordFile = "/path/to/password"
+			})
+
+			It("does not return a validation error", func() {
+				err := config.Validate()
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when more than one password source is specified", func() {
+			BeforeEach(func() {
+				config.PasswordEnv = "QUOTA_ENFORCER_PASSWORD"
+			})
+
+			It("returns a validation error", func() {
+				err := config.Validate()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("PasswordEnv"))
+			})
+		})
+
 		Context("when IgnoredUsers is not specified", func() {
 			BeforeEach(func() {
 				config.IgnoredUsers = []string{}
@@ -111,5 +214,680 @@ var _ = Describe("Config", func() {
 			})
 		})
 
+		Context("when EnforcementStrategy is set to a known strategy", func() {
+			It("does not return a validation error", func() {
+				for _, strategy := range []string{"revoke-writes", "account-lock", "max-connections", "notify-only"} {
+					config.EnforcementStrategy = strategy
+					err := config.Validate()
+					Expect(err).ToNot(HaveOccurred())
+				}
+			})
+		})
+
+		Context("when EnforcementStrategy is set to an unknown strategy", func() {
+			BeforeEach(func() {
+				config.EnforcementStrategy = "drop-database"
+			})
+
+			It("returns a validation error", func() {
+				err := config.Validate()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("EnforcementStrategy"))
+			})
+		})
+
+		Context("when UsageHistory has a negative interval or retention", func() {
+			BeforeEach(func() {
+				config.UsageHistory = UsageHistory{Enabled: true, SampleIntervalInSeconds: -1, RetentionInDays: -1}
+			})
+
+			It("returns a validation error", func() {
+				err := config.Validate()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("SampleIntervalInSeconds"))
+				Expect(err.Error()).To(ContainSubstring("RetentionInDays"))
+			})
+		})
+
+		Context("when CircuitBreaker limits are specified", func() {
+			BeforeEach(func() {
+				config.CircuitBreaker = CircuitBreaker{MaxRestrictions: 10, MaxRestrictionsPercent: 5}
+			})
+
+			It("does not return a validation error", func() {
+				err := config.Validate()
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when CircuitBreaker limits are out of range", func() {
+			BeforeEach(func() {
+				config.CircuitBreaker = CircuitBreaker{MaxRestrictions: -1, MaxRestrictionsPercent: 101}
+			})
+
+			It("returns a validation error for each limit", func() {
+				err := config.Validate()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("CircuitBreaker.MaxRestrictions : less than min"))
+				Expect(err.Error()).To(ContainSubstring("CircuitBreaker.MaxRestrictionsPercent : must be between 0 and 100"))
+			})
+		})
+
+		Context("when Preflight is set to a known mode", func() {
+			It("does not return a validation error", func() {
+				for _, mode := range []string{"warn", "refuse", "skip"} {
+					config.Preflight = mode
+					err := config.Validate()
+					Expect(err).ToNot(HaveOccurred())
+				}
+			})
+		})
+
+		Context("when Preflight is set to an unknown mode", func() {
+			BeforeEach(func() {
+				config.Preflight = "maybe"
+			})
+
+			It("returns a validation error", func() {
+				err := config.Validate()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Preflight"))
+			})
+		})
+
+		Context("when a Measurement node is specified", func() {
+			BeforeEach(func() {
+				config.Host = "fake-primary"
+				config.Port = 3306
+				config.PauseInSeconds = 30
+				config.Measurement = Measurement{Host: "fake-replica"}
+			})
+
+			It("does not return a validation error", func() {
+				err := config.Validate()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("connects to the measurement node with the settings of Host", func() {
+				measurement := config.MeasurementConfig()
+				Expect(measurement.Host).To(Equal("fake-replica"))
+				Expect(measurement.Port).To(Equal(3306))
+				Expect(measurement.User).To(Equal(config.User))
+				Expect(config.Host).To(Equal("fake-primary"))
+			})
+
+			It("allows it to lag by PauseInSeconds", func() {
+				Expect(config.MaxLag()).To(Equal(30 * time.Second))
+
+				config.Measurement.MaxLagInSeconds = 5
+				Expect(config.MaxLag()).To(Equal(5 * time.Second))
+			})
+
+			Context("with both a Host and a Socket", func() {
+				BeforeEach(func() {
+					config.Measurement.Socket = "/fake/socket"
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Measurement.Host : must not be specified together with Measurement.Socket"))
+				})
+			})
+		})
+
+		Context("when no Measurement node is specified", func() {
+			It("measures on Host", func() {
+				Expect(config.MeasurementConfig()).To(Equal(config))
+			})
+
+			Context("but a Measurement setting is", func() {
+				BeforeEach(func() {
+					config.Measurement.MaxLagInSeconds = 5
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Measurement.Host : zero value, and no Measurement.Socket specified"))
+				})
+			})
+		})
+
+		Context("when ClusterNodes are specified", func() {
+			BeforeEach(func() {
+				config.Host = "fake-proxy"
+				config.Port = 3306
+				config.ClusterNodes = []string{"fake-node-0", "fake-node-1:3307", "[::1]:3308"}
+			})
+
+			It("does not return a validation error", func() {
+				err := config.Validate()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("connects to each node with the settings of Host", func() {
+				nodes := config.ClusterNodeConfigs()
+				Expect(nodes).To(HaveLen(3))
+				Expect(nodes[0].Host).To(Equal("fake-node-0"))
+				Expect(nodes[0].Port).To(Equal(3306))
+				Expect(nodes[0].User).To(Equal(config.User))
+				Expect(nodes[1].Host).To(Equal("fake-node-1"))
+				Expect(nodes[1].Port).To(Equal(3307))
+				Expect(nodes[2].Host).To(Equal("::1"))
+				Expect(nodes[2].Port).To(Equal(3308))
+			})
+
+			Context("when an address is invalid", func() {
+				BeforeEach(func() {
+					config.ClusterNodes = []string{"fake-node-0:port", ":3306"}
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("ClusterNodes[0] : invalid port 'port'"))
+					Expect(err.Error()).To(ContainSubstring("ClusterNodes[1] : zero value"))
+				})
+			})
+		})
+
+		Context("when BrokerDBNames are specified", func() {
+			BeforeEach(func() {
+				config.BrokerDBNames = []string{"fake-other-broker", "fake-third-broker"}
+			})
+
+			It("does not return a validation error", func() {
+				err := config.Validate()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("enforces every broker database, DBName first", func() {
+				Expect(config.BrokerDBs()).To(Equal([]string{"fake-db-name", "fake-other-broker", "fake-third-broker"}))
+			})
+
+			Context("when a broker database is empty or repeated", func() {
+				BeforeEach(func() {
+					config.BrokerDBNames = []string{"", "fake-db-name"}
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("BrokerDBNames[0] : zero value"))
+					Expect(err.Error()).To(ContainSubstring("BrokerDBNames[1] : duplicate broker database 'fake-db-name'"))
+				})
+			})
+		})
+
+		Context("when BrokerSchemas are specified", func() {
+			BeforeEach(func() {
+				config.BrokerDBNames = []string{"fake-other-broker"}
+				config.BrokerSchemas = map[string]BrokerSchema{
+					"fake-other-broker": {InstancesTable: "instances", QuotaColumn: "storage_bytes", QuotaUnit: QuotaUnitBytes},
+				}
+			})
+
+			It("does not return a validation error", func() {
+				err := config.Validate()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns the schema of each broker database, with defaults for unmapped names", func() {
+				Expect(config.SchemaOf("fake-db-name")).To(Equal(DefaultBrokerSchema))
+
+				schema := config.SchemaOf("fake-other-broker")
+				Expect(schema.InstancesTable).To(Equal("instances"))
+				Expect(schema.QuotaColumn).To(Equal("storage_bytes"))
+				Expect(schema.DBNameColumn).To(Equal("db_name"))
+				Expect(schema.IsDefault()).To(BeFalse())
+			})
+
+			Context("when a schema names an unknown broker database or quota unit", func() {
+				BeforeEach(func() {
+					config.BrokerSchemas = map[string]BrokerSchema{
+						"fake-unknown-broker": {},
+						"fake-other-broker":   {QuotaUnit: "GB"},
+					}
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("BrokerSchemas[fake-unknown-broker] : not DBName or one of BrokerDBNames"))
+					Expect(err.Error()).To(ContainSubstring("BrokerSchemas[fake-other-broker].QuotaUnit : must be 'MB' or 'bytes'"))
+				})
+			})
+		})
+
+		Context("when Targets are specified", func() {
+			BeforeEach(func() {
+				config.Host = ""
+				config.Port = 0
+				config.DBName = ""
+				config.Targets = []Target{
+					{Name: "fake-target-0", Host: "fake-host-0", Port: 3306, DBName: "fake-broker-0"},
+					{Name: "fake-target-1", Socket: "/fake.sock", User: "fake-user-1", Password: "fake-password-1", IgnoredUsers: []string{}, DBName: "fake-broker-1", PauseInSeconds: 30},
+				}
+			})
+
+			It("does not return a validation error", func() {
+				err := config.Validate()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("defaults the settings of each target to those at the top level", func() {
+				targets := config.TargetConfigs()
+				Expect(targets).To(HaveLen(2))
+
+				Expect(targets[0].Host).To(Equal("fake-host-0"))
+				Expect(targets[0].Port).To(Equal(3306))
+				Expect(targets[0].User).To(Equal("fake-user"))
+				Expect(targets[0].Password).To(Equal("fake-password"))
+				Expect(targets[0].IgnoredUsers).To(Equal([]string{"fake-ignored-user"}))
+				Expect(targets[0].DBName).To(Equal("fake-broker-0"))
+				Expect(targets[0].PauseInSeconds).To(Equal(1))
+				Expect(targets[0].Targets).To(BeEmpty())
+
+				Expect(targets[1].Socket).To(Equal("/fake.sock"))
+				Expect(targets[1].User).To(Equal("fake-user-1"))
+				Expect(targets[1].Password).To(Equal("fake-password-1"))
+				Expect(targets[1].IgnoredUsers).To(BeEmpty())
+				Expect(targets[1].PauseInSeconds).To(Equal(30))
+			})
+
+			It("finds a target by name", func() {
+				target, ok := config.TargetConfig("fake-target-1")
+				Expect(ok).To(BeTrue())
+				Expect(target.DBName).To(Equal("fake-broker-1"))
+
+				_, ok = config.TargetConfig("fake-unknown-target")
+				Expect(ok).To(BeFalse())
+				Expect(config.TargetNames()).To(Equal([]string{"fake-target-0", "fake-target-1"}))
+			})
+
+			Context("when a target is incomplete", func() {
+				BeforeEach(func() {
+					config.Targets[0].Host = ""
+					config.Targets[1].DBName = ""
+				})
+
+				It("returns a validation error for the target", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Targets[0].Host : zero value"))
+					Expect(err.Error()).To(ContainSubstring("Targets[1].DBName : zero value"))
+				})
+			})
+
+			Context("when names are missing or duplicated", func() {
+				BeforeEach(func() {
+					config.Targets = append(config.Targets, Target{Name: "fake-target-0", Host: "fake-host-2", Port: 3306, DBName: "fake-broker-2"})
+					config.Targets[1].Name = ""
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Targets[1].Name : zero value"))
+					Expect(err.Error()).To(ContainSubstring("Targets[2].Name : duplicate name 'fake-target-0'"))
+				})
+			})
+
+			Context("when a Measurement node is specified at the top level", func() {
+				BeforeEach(func() {
+					config.Measurement.Host = "fake-replica"
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Measurement : must be specified per target"))
+				})
+			})
+		})
+
+		Context("when no Targets are specified", func() {
+			It("enforces the config itself", func() {
+				Expect(config.TargetConfigs()).To(Equal([]Config{config}))
+			})
+		})
+
+		Context("when LargestTables is not specified", func() {
+			It("captures the 5 largest tables", func() {
+				Expect(config.LargestTableCount()).To(Equal(5))
+			})
+		})
+
+		Context("when LargestTables is specified", func() {
+			BeforeEach(func() {
+				config.LargestTables = 10
+			})
+
+			It("captures that many tables", func() {
+				Expect(config.LargestTableCount()).To(Equal(10))
+			})
+
+			Context("when it is negative", func() {
+				BeforeEach(func() {
+					config.LargestTables = -1
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("LargestTables"))
+				})
+			})
+		})
+
+		Context("when Reclaim is enabled", func() {
+			BeforeEach(func() {
+				config.Reclaim = Reclaim{Enabled: true, WindowStart: "22:00", WindowEnd: "04:00"}
+			})
+
+			It("does not return a validation error", func() {
+				err := config.Validate()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			Context("without a window", func() {
+				BeforeEach(func() {
+					config.Reclaim.WindowStart = ""
+					config.Reclaim.WindowEnd = "25:00"
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Reclaim.WindowStart : must be a time of day like '02:00'"))
+					Expect(err.Error()).To(ContainSubstring("Reclaim.WindowEnd : must be a time of day like '05:00'"))
+				})
+			})
+
+			Context("with an empty window", func() {
+				BeforeEach(func() {
+					config.Reclaim.WindowEnd = "22:00"
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Reclaim.WindowEnd : must differ from WindowStart"))
+				})
+			})
+		})
+
+		Context("when WarningThresholds are specified", func() {
+			BeforeEach(func() {
+				config.WarningThresholds = []float64{80, 90}
+			})
+
+			It("does not return a validation error", func() {
+				err := config.Validate()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns the lowest threshold", func() {
+				config.WarningThresholds = []float64{90, 75, 80}
+				Expect(config.LowestWarningThreshold()).To(Equal(75.0))
+			})
+
+			Context("when a threshold is not below the quota", func() {
+				BeforeEach(func() {
+					config.WarningThresholds[1] = 100
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("WarningThresholds[1] : must be between 0 and 100"))
+				})
+			})
+
+			Context("when a threshold is duplicated", func() {
+				BeforeEach(func() {
+					config.WarningThresholds[1] = 80
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("WarningThresholds[1] : duplicate threshold"))
+				})
+			})
+		})
+
+		Context("when Webhooks are specified", func() {
+			BeforeEach(func() {
+				config.Webhooks = []Webhook{{URL: "https://example.com/events", Secret: "fake-secret"}}
+			})
+
+			It("does not return a validation error", func() {
+				err := config.Validate()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			Context("when the URL is not an http URL", func() {
+				BeforeEach(func() {
+					config.Webhooks[0].URL = "example.com/events"
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Webhooks[0].URL : must be an http or https URL"))
+				})
+			})
+
+			Context("when MaxAttempts is negative", func() {
+				BeforeEach(func() {
+					config.Webhooks[0].MaxAttempts = -1
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Webhooks[0].MaxAttempts : less than min"))
+				})
+			})
+
+			Context("when QueueSize is negative", func() {
+				BeforeEach(func() {
+					config.Webhooks[0].QueueSize = -1
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Webhooks[0].QueueSize : less than min"))
+				})
+			})
+		})
+
+		Context("when ConnectionLimits are specified", func() {
+			BeforeEach(func() {
+				config.ConnectionLimits = ConnectionLimits{
+					Default:   10,
+					Plans:     map[string]int{"fake-plan-guid": 1},
+					Instances: map[string]int{"fake-instance-guid": 0},
+				}
+			})
+
+			It("does not return a validation error", func() {
+				err := config.Validate()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			Context("when a limit is negative", func() {
+				BeforeEach(func() {
+					config.ConnectionLimits.Instances["fake-instance-guid"] = -1
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("ConnectionLimits.Instances.fake-instance-guid : less than min"))
+				})
+			})
+
+			Context("when a limit of 1 is used with the max-connections strategy", func() {
+				BeforeEach(func() {
+					config.EnforcementStrategy = StrategyMaxConnections
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("ConnectionLimits.Plans.fake-plan-guid : 1 is reserved"))
+				})
+			})
+		})
+
+		Context("when ThrottleTiers are specified", func() {
+			BeforeEach(func() {
+				config.ThrottleTiers = []ThrottleTier{
+					{PercentOfQuota: 90, MaxUpdatesPerHour: 1000},
+					{PercentOfQuota: 95, MaxUpdatesPerHour: 100},
+				}
+			})
+
+			It("does not return a validation error", func() {
+				err := config.Validate()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			Context("when a tier is at or above the quota", func() {
+				BeforeEach(func() {
+					config.ThrottleTiers[1].PercentOfQuota = 100
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("ThrottleTiers[1].PercentOfQuota"))
+				})
+			})
+
+			Context("when a higher tier allows more updates", func() {
+				BeforeEach(func() {
+					config.ThrottleTiers[1].MaxUpdatesPerHour = 2000
+				})
+
+				It("returns a validation error", func() {
+					err := config.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("ThrottleTiers[1].MaxUpdatesPerHour"))
+				})
+			})
+		})
+
+		Context("when TLS.Mode is set to a known mode", func() {
+			It("does not return a validation error", func() {
+				for _, mode := range []string{"skip", "prefer", "require"} {
+					config.TLS.Mode = mode
+					err := config.Validate()
+					Expect(err).ToNot(HaveOccurred())
+				}
+			})
+		})
+
+		Context("when TLS.Mode is set to an unknown mode", func() {
+			BeforeEach(func() {
+				config.TLS.Mode = "sometimes"
+			})
+
+			It("returns a validation error", func() {
+				err := config.Validate()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("TLS.Mode"))
+			})
+		})
+
+		Context("when TLS.CertFile is specified without TLS.KeyFile", func() {
+			BeforeEach(func() {
+				config.TLS.CertFile = "/path/to/cert.pem"
+			})
+
+			It("returns a validation error", func() {
+				err := config.Validate()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("TLS.CertFile"))
+			})
+		})
+
+	})
+})
+
+var _ = Describe("UsageHistory", func() {
+	Describe("Retention", func() {
+		It("defaults to 30 days", func() {
+			Expect(UsageHistory{}.Retention()).To(Equal(30 * 24 * time.Hour))
+		})
+
+		It("is RetentionInDays when given", func() {
+			Expect(UsageHistory{RetentionInDays: 7}.Retention()).To(Equal(7 * 24 * time.Hour))
+		})
+	})
+})
+
+var _ = Describe("BrokerSchema", func() {
+	Describe("Resolved", func() {
+		It("is the cf-mysql-broker schema for a zero schema", func() {
+			Expect(BrokerSchema{}.IsDefault()).To(BeTrue())
+		})
+
+		It("joins plans on their guid by default", func() {
+			Expect(BrokerSchema{PlansTable: "plans"}.Resolved().PlanKeyColumn).To(Equal("guid"))
+		})
+	})
+})
+
+var _ = Describe("Reclaim", func() {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2016, 5, 4, hour, minute, 0, 0, time.UTC)
+	}
+
+	Describe("InWindow", func() {
+		It("is true between the start and the end of the window", func() {
+			reclaim := Reclaim{WindowStart: "02:00", WindowEnd: "05:00"}
+			Expect(reclaim.InWindow(at(1, 59))).To(BeFalse())
+			Expect(reclaim.InWindow(at(2, 0))).To(BeTrue())
+			Expect(reclaim.InWindow(at(4, 59))).To(BeTrue())
+			Expect(reclaim.InWindow(at(5, 0))).To(BeFalse())
+		})
+
+		It("spans midnight if the end is before the start", func() {
+			reclaim := Reclaim{WindowStart: "22:00", WindowEnd: "04:00"}
+			Expect(reclaim.InWindow(at(23, 0))).To(BeTrue())
+			Expect(reclaim.InWindow(at(3, 0))).To(BeTrue())
+			Expect(reclaim.InWindow(at(12, 0))).To(BeFalse())
+		})
+
+		It("compares in UTC", func() {
+			reclaim := Reclaim{WindowStart: "02:00", WindowEnd: "05:00"}
+			Expect(reclaim.InWindow(at(3, 0).In(time.FixedZone("fake-zone", 8*60*60)))).To(BeTrue())
+		})
+	})
+
+	It("defaults its budgets", func() {
+		reclaim := Reclaim{}
+		Expect(reclaim.MinDataFree()).To(Equal(100.0))
+		Expect(reclaim.MaxTableSize()).To(Equal(1024.0))
+		Expect(reclaim.Budget()).To(Equal(10 * time.Minute))
+		Expect(reclaim.SizeBudget()).To(Equal(4096.0))
+	})
+
+	Describe("WindowStartedAt", func() {
+		It("returns the start of the window on the same day", func() {
+			reclaim := Reclaim{WindowStart: "02:00", WindowEnd: "05:00"}
+			Expect(reclaim.WindowStartedAt(at(3, 30))).To(Equal(at(2, 0)))
+		})
+
+		It("returns the start on the day before for a window spanning midnight", func() {
+			reclaim := Reclaim{WindowStart: "22:00", WindowEnd: "04:00"}
+			Expect(reclaim.WindowStartedAt(at(23, 0))).To(Equal(at(22, 0)))
+			Expect(reclaim.WindowStartedAt(at(3, 0))).To(Equal(at(22, 0).AddDate(0, 0, -1)))
+		})
 	})
 })
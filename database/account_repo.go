@@ -0,0 +1,115 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// Every binding user of an instance is listed, including read-only users and
+// users whose writes have been revoked. The user and host are extracted from
+// the grantee, and the name from the grant, as in violatorsQueryPattern.
+const accountQueryPattern = `
+SELECT dbs.name, dbs.user, dbs.host, instances.guid, instances.plan_guid,
+	MAX(COALESCE(%[7]s, 0)) AS max_user_connections
+FROM   (
+	SELECT DISTINCT %[8]s AS name,
+		SUBSTRING(grantee, 2, CHAR_LENGTH(grantee) - CHAR_LENGTH(SUBSTRING_INDEX(grantee, '@', -1)) - 3) AS user,
+		TRIM(BOTH "'" FROM SUBSTRING_INDEX(grantee, '@', -1)) AS host
+	FROM information_schema.schema_privileges
+	WHERE SUBSTRING(grantee, 2, CHAR_LENGTH(grantee) - CHAR_LENGTH(SUBSTRING_INDEX(grantee, '@', -1)) - 3) NOT IN (%[1]s)
+) AS dbs
+JOIN        %[2]s AS accounts ON dbs.user = %[3]s AND dbs.host = %[4]s
+JOIN        %[5]s AS instances ON dbs.name = %[6]s
+GROUP  BY   dbs.name, dbs.user, dbs.host, instances.guid, instances.plan_guid
+`
+
+// Account is a binding user of an instance, together with the instance it
+// belongs to and its current connection limit.
+type Account struct {
+	Database           Database
+	InstanceGUID       string
+	PlanGUID           string
+	MaxUserConnections int
+}
+
+type AccountRepo interface {
+	All() ([]Account, error)
+}
+
+type accountRepo struct {
+	query         string
+	ignoredUsers  []string
+	server        Server
+	measurementDB *sql.DB
+	actionDB      *sql.DB
+	logger        lager.Logger
+}
+
+func NewAccountRepo(broker Broker, ignoredUsers []string, server Server, measurementDB, actionDB *sql.DB, logger lager.Logger) AccountRepo {
+	ignoredUsersPlaceholders := strings.Join(strings.Split(strings.Repeat("?", len(ignoredUsers)), ""), ",")
+	query := fmt.Sprintf(
+		accountQueryPattern,
+		ignoredUsersPlaceholders,
+		server.accountsTable(),
+		server.collateBinary("accounts.User"),
+		server.collateBinary("accounts.Host"),
+		broker.instancesTable("guid", "db_name", "plan_guid"),
+		server.collate("instances.db_name"),
+		server.accountAttributeValue("max_user_connections"),
+		unescapeSchema("table_schema"),
+	)
+
+	return &accountRepo{
+		query:         query,
+		ignoredUsers:  ignoredUsers,
+		server:        server,
+		measurementDB: measurementDB,
+		actionDB:      actionDB,
+		logger:        logger,
+	}
+}
+
+func (r accountRepo) All() ([]Account, error) {
+	r.logger.Debug("Executing 'account'.All")
+
+	accounts := []Account{}
+
+	parametersInterface := make([]interface{}, len(r.ignoredUsers))
+	for i, v := range r.ignoredUsers {
+		parametersInterface[i] = v
+	}
+
+	rows, err := r.measurementDB.Query(r.query, parametersInterface...)
+	if err != nil {
+		return accounts, fmt.Errorf("Error executing 'account'.All: %s", err.Error())
+	}
+
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			dbName, dbUser, dbHost string
+			instanceGUID, planGUID sql.NullString
+			account                Account
+		)
+		err := rows.Scan(&dbName, &dbUser, &dbHost, &instanceGUID, &planGUID, &account.MaxUserConnections)
+		if err != nil {
+			return accounts, fmt.Errorf("Scanning result row of 'account'.All: %s", err.Error())
+		}
+
+		account.Database = New(dbName, dbUser, dbHost, r.server, r.actionDB, r.logger)
+		account.InstanceGUID = instanceGUID.String
+		account.PlanGUID = planGUID.String
+		accounts = append(accounts, account)
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return accounts, fmt.Errorf("Reading result row of 'account'.All: %s", err.Error())
+	}
+
+	return accounts, nil
+}
@@ -0,0 +1,100 @@
+package database_test
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"database/sql"
+
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+)
+
+var _ = Describe("AccountRepo", func() {
+
+	const brokerDBName = "fake_broker_db_name"
+
+	var (
+		logger *lagertest.TestLogger
+		repo   AccountRepo
+		server Server
+		fakeDB *sql.DB
+		mock   sqlmock.Sqlmock
+	)
+
+	BeforeEach(func() {
+		var err error
+		fakeDB, mock, err = sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		logger = lagertest.NewTestLogger("AccountRepo test")
+		server = Server{Flavor: FlavorMySQL, Version: "5.7.40", Major: 5, Minor: 7}
+	})
+
+	JustBeforeEach(func() {
+		repo = NewAccountRepo(Broker{DBName: brokerDBName}, []string{"fake_admin_user"}, server, fakeDB, fakeDB, logger)
+	})
+
+	AfterEach(func() {
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	Describe("All", func() {
+		var accountColumns = []string{"name", "user", "host", "guid", "plan_guid", "max_user_connections"}
+
+		It("returns every binding user with its instance and connection limit", func() {
+			mock.ExpectQuery("JOIN\\s+`fake_broker_db_name`\\.service_instances").
+				WithArgs("fake_admin_user").
+				WillReturnRows(sqlmock.NewRows(accountColumns).
+					AddRow("fake-database-1", "cf_fake-user-1", "%", "fake-instance-guid-1", "fake-plan-guid", 0).
+					AddRow("fake-database-2", "cf_fake-user-2", "%", "fake-instance-guid-2", nil, 10))
+
+			accounts, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(accounts).To(ConsistOf(
+				Account{
+					Database:     New("fake-database-1", "cf_fake-user-1", "%", server, fakeDB, logger),
+					InstanceGUID: "fake-instance-guid-1",
+					PlanGUID:     "fake-plan-guid",
+				},
+				Account{
+					Database:           New("fake-database-2", "cf_fake-user-2", "%", server, fakeDB, logger),
+					InstanceGUID:       "fake-instance-guid-2",
+					MaxUserConnections: 10,
+				},
+			))
+		})
+
+		Context("when the server stores accounts in mysql.global_priv", func() {
+			BeforeEach(func() {
+				server = Server{Flavor: FlavorMariaDB, Version: "10.6.12-MariaDB", Major: 10, Minor: 6}
+			})
+
+			It("reads the connection limit from the JSON privileges", func() {
+				mock.ExpectQuery("JSON_VALUE\\(accounts\\.Priv, '\\$\\.max_user_connections'\\)").
+					WillReturnRows(sqlmock.NewRows(accountColumns))
+
+				_, err := repo.All()
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the db query fails", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery(".*").
+					WillReturnError(errors.New("fake-query-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := repo.All()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-query-error"))
+			})
+		})
+	})
+})
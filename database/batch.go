@@ -0,0 +1,42 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// maxBatchRows bounds the rows written by one statement, so that it stays well
+// under max_allowed_packet however many instances there are.
+const maxBatchRows = 500
+
+// execBatches writes rows with query, whose %s is replaced by the VALUES list
+// of a batch, each row formatted with rowPlaceholders. It returns the rows
+// affected by all batches.
+func execBatches(db *sql.DB, query, rowPlaceholders string, rows [][]interface{}) (int64, error) {
+	var rowsAffected int64
+	for start := 0; start < len(rows); start += maxBatchRows {
+		end := start + maxBatchRows
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		placeholders := make([]string, 0, end-start)
+		args := []interface{}{}
+		for _, row := range rows[start:end] {
+			placeholders = append(placeholders, rowPlaceholders)
+			args = append(args, row...)
+		}
+
+		result, err := db.Exec(fmt.Sprintf(query, strings.Join(placeholders, ", ")), args...)
+		if err != nil {
+			return rowsAffected, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return rowsAffected, fmt.Errorf("Getting rows affected: %s", err.Error())
+		}
+		rowsAffected += affected
+	}
+	return rowsAffected, nil
+}
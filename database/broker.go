@@ -0,0 +1,182 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+)
+
+// Broker is a broker database and the schema of its tables, that of
+// cf-mysql-broker if Schema is zero. Queries refer to the columns of
+// cf-mysql-broker; for another schema, they read from a derived table
+// renaming the columns of the broker.
+type Broker struct {
+	DBName string
+	Schema config.BrokerSchema
+	// Missing holds the optional tables and columns the broker lacks, as
+	// found by a BrokerDetector. Queries do without them.
+	Missing Missing
+}
+
+// Missing are the optional tables and columns of cf-mysql-broker that older
+// broker schemas lack.
+type Missing struct {
+	ReadOnlyUsers bool
+	MaxTables     bool
+	MaxRoutines   bool
+}
+
+// quotedDBName is the broker database, e.g. for tables owned by the enforcer.
+func (b Broker) quotedDBName() string {
+	return quoteIdentifier(b.DBName)
+}
+
+// instancesTable returns a table expression for the instances of the broker,
+// with the named cf-mysql-broker columns. Only the columns a query needs are
+// mapped, and missing object quota columns are NULL, which means no limit.
+func (b Broker) instancesTable(columns ...string) string {
+	schema := b.Schema.Resolved()
+	if schema.IsDefault() && !b.lacksAny(columns) {
+		return b.quotedDBName() + ".service_instances"
+	}
+
+	joinPlans := false
+	selects := make([]string, len(columns))
+	for i, column := range columns {
+		var expression string
+		switch {
+		case b.lacks(column):
+			expression = "NULL"
+		case column == "id":
+			expression = "si." + quoteIdentifier(schema.IDColumn)
+		case column == "guid":
+			expression = "si." + quoteIdentifier(schema.GUIDColumn)
+		case column == "db_name":
+			expression = "si." + quoteIdentifier(schema.DBNameColumn)
+		case column == "plan_guid":
+			expression = "si." + quoteIdentifier(schema.PlanGUIDColumn)
+		case column == "max_storage_mb":
+			expression = b.planColumn(schema.QuotaColumn, &joinPlans)
+			if schema.QuotaUnit == config.QuotaUnitBytes {
+				expression += " / 1024 / 1024"
+			}
+		case column == "max_tables":
+			expression = b.planColumn(schema.MaxTablesColumn, &joinPlans)
+		case column == "max_routines":
+			expression = b.planColumn(schema.MaxRoutinesColumn, &joinPlans)
+		default:
+			panic(fmt.Sprintf("unknown broker column '%s'", column))
+		}
+		selects[i] = fmt.Sprintf("%s AS %s", expression, column)
+	}
+
+	table := fmt.Sprintf(
+		"(SELECT %s FROM %s.%s AS si",
+		strings.Join(selects, ", "), b.quotedDBName(), quoteIdentifier(schema.InstancesTable),
+	)
+	if joinPlans {
+		table += fmt.Sprintf(
+			" LEFT JOIN %s.%s AS plans ON plans.%s = si.%s",
+			b.quotedDBName(), quoteIdentifier(schema.PlansTable),
+			quoteIdentifier(schema.PlanKeyColumn), quoteIdentifier(schema.PlanGUIDColumn),
+		)
+	}
+	return table + ")"
+}
+
+// lacks is true if column is an optional column the broker lacks.
+func (b Broker) lacks(column string) bool {
+	switch column {
+	case "max_tables":
+		return b.Missing.MaxTables
+	case "max_routines":
+		return b.Missing.MaxRoutines
+	}
+	return false
+}
+
+func (b Broker) lacksAny(columns []string) bool {
+	for _, column := range columns {
+		if b.lacks(column) {
+			return true
+		}
+	}
+	return false
+}
+
+// planColumn returns a quota column, read from the plan of an instance if
+// the schema has a plans table.
+func (b Broker) planColumn(column string, joinPlans *bool) string {
+	if b.Schema.PlansTable == "" {
+		return "si." + quoteIdentifier(column)
+	}
+	*joinPlans = true
+	return "plans." + quoteIdentifier(column)
+}
+
+// readOnlyUsersTable returns a table expression for the read-only users of
+// the broker, with the cf-mysql-broker columns id and grantee. It is empty if
+// the broker lacks read-only users, so every binding user counts as a writer.
+func (b Broker) readOnlyUsersTable() string {
+	if b.Missing.ReadOnlyUsers {
+		return "(SELECT NULL AS id, NULL AS grantee FROM DUAL WHERE FALSE)"
+	}
+
+	schema := b.Schema.Resolved()
+	if schema.IsDefault() {
+		return b.quotedDBName() + ".read_only_users"
+	}
+	return fmt.Sprintf(
+		"(SELECT 1 AS id, ro.%s AS grantee FROM %s.%s AS ro)",
+		quoteIdentifier(schema.GranteeColumn), b.quotedDBName(), quoteIdentifier(schema.ReadOnlyUsersTable),
+	)
+}
+
+// tables returns the broker tables the queries read from, leaving out an
+// optional table the broker lacks.
+func (b Broker) tables() []string {
+	schema := b.Schema.Resolved()
+	tables := []string{schema.InstancesTable}
+	if !b.Missing.ReadOnlyUsers {
+		tables = append(tables, schema.ReadOnlyUsersTable)
+	}
+	if schema.PlansTable != "" {
+		tables = append(tables, schema.PlansTable)
+	}
+	return tables
+}
+
+// quotaTable is the table holding the quota columns, the plans table if the
+// schema has one.
+func (b Broker) quotaTable() string {
+	schema := b.Schema.Resolved()
+	if schema.PlansTable != "" {
+		return schema.PlansTable
+	}
+	return schema.InstancesTable
+}
+
+// DisabledFeatures describes the features disabled as the broker lacks their
+// table or column. Object quotas are only described if enabled.
+func (b Broker) DisabledFeatures(objectQuotas bool) []string {
+	schema := b.Schema.Resolved()
+	column := func(table, column string) string {
+		return fmt.Sprintf("%s.%s.%s", b.quotedDBName(), quoteIdentifier(table), quoteIdentifier(column))
+	}
+
+	disabled := []string{}
+	if b.Missing.ReadOnlyUsers {
+		disabled = append(disabled, fmt.Sprintf(
+			"read-only users, as %s is missing: every binding user is treated as a writer",
+			column(schema.ReadOnlyUsersTable, schema.GranteeColumn),
+		))
+	}
+	if objectQuotas && b.Missing.MaxTables {
+		disabled = append(disabled, fmt.Sprintf("table limits, as %s is missing", column(b.quotaTable(), schema.MaxTablesColumn)))
+	}
+	if objectQuotas && b.Missing.MaxRoutines {
+		disabled = append(disabled, fmt.Sprintf("routine limits, as %s is missing", column(b.quotaTable(), schema.MaxRoutinesColumn)))
+	}
+	return disabled
+}
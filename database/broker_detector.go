@@ -0,0 +1,92 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/go-sql-driver/mysql"
+)
+
+const brokerColumnsQueryPattern = `
+SELECT table_name, column_name
+FROM information_schema.columns
+WHERE table_schema = ?
+AND table_name IN (%s)
+`
+
+// Repos report MySQL errors in their own messages, so the code is matched
+// there too.
+var schemaErrorPattern = regexp.MustCompile(`Error (1146|1054): `)
+
+// IsSchemaError reports whether err is MySQL error 1146 (ER_NO_SUCH_TABLE) or
+// 1054 (ER_BAD_FIELD_ERROR), or reports one. Queries built for a broker
+// schema which changed since fail with these.
+func IsSchemaError(err error) bool {
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+		return mysqlErr.Number == 1146 || mysqlErr.Number == 1054
+	}
+	return schemaErrorPattern.MatchString(err.Error())
+}
+
+// BrokerDetector finds the optional tables and columns a broker lacks, such as
+// read_only_users in older cf-mysql-broker schemas, so that the queries do
+// without them instead of failing every cycle.
+type BrokerDetector interface {
+	Detect(broker Broker) (Broker, error)
+}
+
+type brokerDetector struct {
+	db     *sql.DB
+	logger lager.Logger
+}
+
+func NewBrokerDetector(db *sql.DB, logger lager.Logger) BrokerDetector {
+	return &brokerDetector{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Detect returns the broker with Missing set to the optional tables and
+// columns it lacks. Required tables are left to the preflight check.
+func (d brokerDetector) Detect(broker Broker) (Broker, error) {
+	d.logger.Debug("Executing 'broker detector'.Detect")
+
+	schema := broker.Schema.Resolved()
+	tables := []string{schema.ReadOnlyUsersTable, broker.quotaTable()}
+
+	rows, err := d.db.Query(fmt.Sprintf(brokerColumnsQueryPattern, "?,?"), broker.DBName, tables[0], tables[1])
+	if err != nil {
+		return broker, fmt.Errorf("Finding columns of broker tables: %s", err.Error())
+	}
+
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	// Column names are case-insensitive, table names may not be.
+	columns := map[string]bool{}
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return broker, fmt.Errorf("Scanning column of a broker table: %s", err.Error())
+		}
+		columns[table+"."+strings.ToLower(column)] = true
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return broker, fmt.Errorf("Reading columns of broker tables: %s", err.Error())
+	}
+
+	has := func(table, column string) bool {
+		return columns[table+"."+strings.ToLower(column)]
+	}
+	broker.Missing = Missing{
+		ReadOnlyUsers: !has(schema.ReadOnlyUsersTable, schema.GranteeColumn),
+		MaxTables:     !has(broker.quotaTable(), schema.MaxTablesColumn),
+		MaxRoutines:   !has(broker.quotaTable(), schema.MaxRoutinesColumn),
+	}
+	return broker, nil
+}
@@ -0,0 +1,119 @@
+package database_test
+
+import (
+	"database/sql"
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BrokerDetector", func() {
+
+	const brokerDBName = "fake_broker_db_name"
+
+	var (
+		detector BrokerDetector
+		fakeDB   *sql.DB
+		mock     sqlmock.Sqlmock
+		columns  []string
+	)
+
+	BeforeEach(func() {
+		var err error
+		fakeDB, mock, err = sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		columns = []string{"table_name", "column_name"}
+		detector = NewBrokerDetector(fakeDB, lagertest.NewTestLogger("BrokerDetector test"))
+	})
+
+	AfterEach(func() {
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("finds nothing missing in a current cf-mysql-broker schema", func() {
+		mock.ExpectQuery("FROM information_schema.columns").
+			WithArgs(brokerDBName, "read_only_users", "service_instances").
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("read_only_users", "id").
+				AddRow("read_only_users", "grantee").
+				AddRow("service_instances", "db_name").
+				AddRow("service_instances", "MAX_TABLES").
+				AddRow("service_instances", "max_routines"))
+
+		broker, err := detector.Detect(Broker{DBName: brokerDBName})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(broker).To(Equal(Broker{DBName: brokerDBName}))
+		Expect(broker.DisabledFeatures(true)).To(BeEmpty())
+	})
+
+	It("finds the optional tables and columns an older schema lacks", func() {
+		mock.ExpectQuery("FROM information_schema.columns").
+			WithArgs(brokerDBName, "read_only_users", "service_instances").
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("service_instances", "db_name").
+				AddRow("service_instances", "max_storage_mb"))
+
+		broker, err := detector.Detect(Broker{DBName: brokerDBName})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(broker.Missing).To(Equal(Missing{ReadOnlyUsers: true, MaxTables: true, MaxRoutines: true}))
+		Expect(broker.DisabledFeatures(true)).To(Equal([]string{
+			"read-only users, as `fake_broker_db_name`.`read_only_users`.`grantee` is missing: every binding user is treated as a writer",
+			"table limits, as `fake_broker_db_name`.`service_instances`.`max_tables` is missing",
+			"routine limits, as `fake_broker_db_name`.`service_instances`.`max_routines` is missing",
+		}))
+		Expect(broker.DisabledFeatures(false)).To(HaveLen(1))
+	})
+
+	Context("when the broker has another schema", func() {
+		It("looks for the mapped columns, with quota columns in the plans table", func() {
+			mock.ExpectQuery("FROM information_schema.columns").
+				WithArgs(brokerDBName, "readers", "plans").
+				WillReturnRows(sqlmock.NewRows(columns).
+					AddRow("readers", "username").
+					AddRow("plans", "table_limit"))
+
+			broker, err := detector.Detect(Broker{DBName: brokerDBName, Schema: config.BrokerSchema{
+				ReadOnlyUsersTable: "readers",
+				GranteeColumn:      "username",
+				PlansTable:         "plans",
+				MaxTablesColumn:    "table_limit",
+			}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(broker.Missing).To(Equal(Missing{MaxRoutines: true}))
+		})
+	})
+
+	Context("when the db query fails", func() {
+		It("returns an error", func() {
+			mock.ExpectQuery("FROM information_schema.columns").
+				WillReturnError(errors.New("fake-query-error"))
+
+			_, err := detector.Detect(Broker{DBName: brokerDBName})
+			Expect(err).To(MatchError("Finding columns of broker tables: fake-query-error"))
+		})
+	})
+})
+
+var _ = Describe("IsSchemaError", func() {
+	It("is true for a missing table or column", func() {
+		Expect(IsSchemaError(&mysql.MySQLError{Number: 1146, Message: "Table 'broker.read_only_users' doesn't exist"})).To(BeTrue())
+		Expect(IsSchemaError(&mysql.MySQLError{Number: 1054, Message: "Unknown column 'max_tables'"})).To(BeTrue())
+	})
+
+	It("is true for an error reporting one", func() {
+		Expect(IsSchemaError(errors.New("Error executing 'violator'.All: Error 1146: Table 'broker.read_only_users' doesn't exist"))).To(BeTrue())
+	})
+
+	It("is false for other errors", func() {
+		Expect(IsSchemaError(&mysql.MySQLError{Number: 1045, Message: "Access denied"})).To(BeFalse())
+		Expect(IsSchemaError(errors.New("fake-error 11460"))).To(BeFalse())
+	})
+})
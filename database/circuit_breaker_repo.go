@@ -0,0 +1,150 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// The circuit breaker table is owned by the enforcer and lives in the broker
+// database, so a trip survives restarts until an operator acknowledges it.
+// It holds at most one row, with the instances the trip covered as a JSON
+// array, kept once acknowledged until the enforcer admits them.
+const createCircuitBreakerTableQuery = `
+CREATE TABLE IF NOT EXISTS %s.quota_enforcer_circuit_breaker (
+	id           TINYINT       NOT NULL,
+	reason       VARCHAR(1024) NOT NULL,
+	instances    TEXT          NOT NULL,
+	acknowledged BOOL          NOT NULL DEFAULT FALSE,
+	tripped_at   DATETIME      NOT NULL,
+	PRIMARY KEY (id)
+)`
+
+// A trip keeps the reason and instances of the first trip until it is
+// acknowledged, and replaces an acknowledged trip. acknowledged is assigned
+// last, as the assignments are made in order.
+const tripCircuitBreakerQuery = `
+INSERT INTO %s.quota_enforcer_circuit_breaker (id, reason, instances, acknowledged, tripped_at)
+VALUES (1, ?, ?, FALSE, UTC_TIMESTAMP())
+ON DUPLICATE KEY UPDATE
+	reason = IF(acknowledged, VALUES(reason), reason),
+	instances = IF(acknowledged, VALUES(instances), instances),
+	tripped_at = IF(acknowledged, VALUES(tripped_at), tripped_at),
+	acknowledged = FALSE`
+
+const trippedCircuitBreakerQuery = `SELECT reason FROM %s.quota_enforcer_circuit_breaker WHERE id = 1 AND NOT acknowledged`
+
+const acknowledgedCircuitBreakerQuery = `SELECT instances FROM %s.quota_enforcer_circuit_breaker WHERE id = 1 AND acknowledged`
+
+const acknowledgeCircuitBreakerQuery = `UPDATE %s.quota_enforcer_circuit_breaker SET acknowledged = TRUE WHERE id = 1 AND NOT acknowledged`
+
+const resetCircuitBreakerQuery = `DELETE FROM %s.quota_enforcer_circuit_breaker WHERE acknowledged`
+
+type CircuitBreakerRepo interface {
+	Setup() error
+	Tripped() (bool, string, error)
+	Trip(reason string, dbNames []string) error
+	Acknowledge() (bool, error)
+	Acknowledged() ([]string, error)
+	Reset() error
+}
+
+type circuitBreakerRepo struct {
+	brokerDBName string
+	db           *sql.DB
+	logger       lager.Logger
+}
+
+func NewCircuitBreakerRepo(brokerDBName string, db *sql.DB, logger lager.Logger) CircuitBreakerRepo {
+	return &circuitBreakerRepo{
+		brokerDBName: quoteIdentifier(brokerDBName),
+		db:           db,
+		logger:       logger,
+	}
+}
+
+// Setup creates the circuit breaker table if it does not exist yet.
+func (r circuitBreakerRepo) Setup() error {
+	_, err := r.db.Exec(fmt.Sprintf(createCircuitBreakerTableQuery, r.brokerDBName))
+	if err != nil {
+		return fmt.Errorf("Creating circuit breaker table: %s", err.Error())
+	}
+	return nil
+}
+
+// Tripped returns whether the circuit breaker is tripped, and why.
+func (r circuitBreakerRepo) Tripped() (bool, string, error) {
+	r.logger.Debug("Executing 'circuit breaker'.Tripped")
+
+	var reason string
+	err := r.db.QueryRow(fmt.Sprintf(trippedCircuitBreakerQuery, r.brokerDBName)).Scan(&reason)
+	if err == sql.ErrNoRows {
+		return false, "", nil
+	}
+	if err != nil {
+		return false, "", fmt.Errorf("Reading circuit breaker: %s", err.Error())
+	}
+	return true, reason, nil
+}
+
+// Trip records a trip covering the databases about to be restricted.
+func (r circuitBreakerRepo) Trip(reason string, dbNames []string) error {
+	instances, err := json.Marshal(dbNames)
+	if err != nil {
+		return fmt.Errorf("Tripping circuit breaker: %s", err.Error())
+	}
+
+	_, err = r.db.Exec(fmt.Sprintf(tripCircuitBreakerQuery, r.brokerDBName), reason, string(instances))
+	if err != nil {
+		return fmt.Errorf("Tripping circuit breaker: %s", err.Error())
+	}
+	return nil
+}
+
+// Acknowledge acknowledges a trip. It returns whether the circuit breaker was
+// tripped.
+func (r circuitBreakerRepo) Acknowledge() (bool, error) {
+	result, err := r.db.Exec(fmt.Sprintf(acknowledgeCircuitBreakerQuery, r.brokerDBName))
+	if err != nil {
+		return false, fmt.Errorf("Acknowledging circuit breaker: %s", err.Error())
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("Getting rows affected: %s", err.Error())
+	}
+	return rowsAffected > 0, nil
+}
+
+// Acknowledged returns the databases covered by an acknowledged trip, or nil
+// if there is none.
+func (r circuitBreakerRepo) Acknowledged() ([]string, error) {
+	r.logger.Debug("Executing 'circuit breaker'.Acknowledged")
+
+	var instances string
+	err := r.db.QueryRow(fmt.Sprintf(acknowledgedCircuitBreakerQuery, r.brokerDBName)).Scan(&instances)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Reading acknowledged circuit breaker trip: %s", err.Error())
+	}
+
+	dbNames := []string{}
+	err = json.Unmarshal([]byte(instances), &dbNames)
+	if err != nil {
+		return nil, fmt.Errorf("Decoding instances of acknowledged circuit breaker trip: %s", err.Error())
+	}
+	return dbNames, nil
+}
+
+// Reset removes an acknowledged trip, once its databases were admitted.
+func (r circuitBreakerRepo) Reset() error {
+	_, err := r.db.Exec(fmt.Sprintf(resetCircuitBreakerQuery, r.brokerDBName))
+	if err != nil {
+		return fmt.Errorf("Resetting circuit breaker: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package database_test
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"database/sql"
+
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+)
+
+var _ = Describe("CircuitBreakerRepo", func() {
+
+	const brokerDBName = "fake_broker_db_name"
+
+	var (
+		logger *lagertest.TestLogger
+		repo   CircuitBreakerRepo
+		fakeDB *sql.DB
+		mock   sqlmock.Sqlmock
+	)
+
+	BeforeEach(func() {
+		var err error
+		fakeDB, mock, err = sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		logger = lagertest.NewTestLogger("CircuitBreakerRepo test")
+		repo = NewCircuitBreakerRepo(brokerDBName, fakeDB, logger)
+	})
+
+	AfterEach(func() {
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	Describe("Setup", func() {
+		It("creates the circuit breaker table in the broker database", func() {
+			mock.ExpectExec("CREATE TABLE IF NOT EXISTS `fake_broker_db_name`\\.quota_enforcer_circuit_breaker").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+
+			Expect(repo.Setup()).To(Succeed())
+		})
+
+		Context("when creating the table fails", func() {
+			BeforeEach(func() {
+				mock.ExpectExec("CREATE TABLE").
+					WillReturnError(errors.New("fake-create-error"))
+			})
+
+			It("returns an error", func() {
+				Expect(repo.Setup()).To(MatchError("Creating circuit breaker table: fake-create-error"))
+			})
+		})
+	})
+
+	Describe("Tripped", func() {
+		It("returns the reason of a trip not yet acknowledged", func() {
+			mock.ExpectQuery("SELECT reason FROM `fake_broker_db_name`\\.quota_enforcer_circuit_breaker WHERE id = 1 AND NOT acknowledged").
+				WillReturnRows(sqlmock.NewRows([]string{"reason"}).AddRow("fake-reason"))
+
+			tripped, reason, err := repo.Tripped()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tripped).To(BeTrue())
+			Expect(reason).To(Equal("fake-reason"))
+		})
+
+		It("is not tripped without a row", func() {
+			mock.ExpectQuery("SELECT reason").
+				WillReturnRows(sqlmock.NewRows([]string{"reason"}))
+
+			tripped, _, err := repo.Tripped()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tripped).To(BeFalse())
+		})
+
+		Context("when the db query fails", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery("SELECT reason").
+					WillReturnError(errors.New("fake-query-error"))
+			})
+
+			It("returns an error", func() {
+				_, _, err := repo.Tripped()
+				Expect(err).To(MatchError("Reading circuit breaker: fake-query-error"))
+			})
+		})
+	})
+
+	Describe("Trip", func() {
+		It("keeps the reason and instances of the first trip until it is acknowledged", func() {
+			mock.ExpectExec("INSERT INTO `fake_broker_db_name`\\.quota_enforcer_circuit_breaker(.|\\n)*ON DUPLICATE KEY UPDATE(.|\\n)*reason = IF\\(acknowledged, VALUES\\(reason\\), reason\\)(.|\\n)*acknowledged = FALSE").
+				WithArgs("fake-reason", `["fake-db-1","fake-db-2"]`).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			Expect(repo.Trip("fake-reason", []string{"fake-db-1", "fake-db-2"})).To(Succeed())
+		})
+
+		Context("when the db exec fails", func() {
+			BeforeEach(func() {
+				mock.ExpectExec("INSERT").
+					WillReturnError(errors.New("fake-exec-error"))
+			})
+
+			It("returns an error", func() {
+				Expect(repo.Trip("fake-reason", nil)).To(MatchError("Tripping circuit breaker: fake-exec-error"))
+			})
+		})
+	})
+
+	Describe("Acknowledge", func() {
+		It("marks the trip acknowledged", func() {
+			mock.ExpectExec("UPDATE `fake_broker_db_name`\\.quota_enforcer_circuit_breaker SET acknowledged = TRUE WHERE id = 1 AND NOT acknowledged").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			wasTripped, err := repo.Acknowledge()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(wasTripped).To(BeTrue())
+		})
+
+		It("reports when it was not tripped", func() {
+			mock.ExpectExec("UPDATE").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+
+			wasTripped, err := repo.Acknowledge()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(wasTripped).To(BeFalse())
+		})
+
+		Context("when the db exec fails", func() {
+			BeforeEach(func() {
+				mock.ExpectExec("UPDATE").
+					WillReturnError(errors.New("fake-exec-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := repo.Acknowledge()
+				Expect(err).To(MatchError("Acknowledging circuit breaker: fake-exec-error"))
+			})
+		})
+	})
+
+	Describe("Acknowledged", func() {
+		It("returns the instances of the acknowledged trip", func() {
+			mock.ExpectQuery("SELECT instances FROM `fake_broker_db_name`\\.quota_enforcer_circuit_breaker WHERE id = 1 AND acknowledged").
+				WillReturnRows(sqlmock.NewRows([]string{"instances"}).AddRow(`["fake-db-1","fake-db-2"]`))
+
+			dbNames, err := repo.Acknowledged()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dbNames).To(Equal([]string{"fake-db-1", "fake-db-2"}))
+		})
+
+		It("returns nil without an acknowledged trip", func() {
+			mock.ExpectQuery("SELECT instances").
+				WillReturnRows(sqlmock.NewRows([]string{"instances"}))
+
+			dbNames, err := repo.Acknowledged()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dbNames).To(BeNil())
+		})
+
+		Context("when the instances cannot be decoded", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery("SELECT instances").
+					WillReturnRows(sqlmock.NewRows([]string{"instances"}).AddRow("fake-invalid-json"))
+			})
+
+			It("returns an error", func() {
+				_, err := repo.Acknowledged()
+				Expect(err).To(MatchError(ContainSubstring("Decoding instances of acknowledged circuit breaker trip: ")))
+			})
+		})
+
+		Context("when the db query fails", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery("SELECT instances").
+					WillReturnError(errors.New("fake-query-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := repo.Acknowledged()
+				Expect(err).To(MatchError("Reading acknowledged circuit breaker trip: fake-query-error"))
+			})
+		})
+	})
+
+	Describe("Reset", func() {
+		It("deletes the acknowledged trip", func() {
+			mock.ExpectExec("DELETE FROM `fake_broker_db_name`\\.quota_enforcer_circuit_breaker WHERE acknowledged").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			Expect(repo.Reset()).To(Succeed())
+		})
+
+		Context("when the db exec fails", func() {
+			BeforeEach(func() {
+				mock.ExpectExec("DELETE").
+					WillReturnError(errors.New("fake-exec-error"))
+			})
+
+			It("returns an error", func() {
+				Expect(repo.Reset()).To(MatchError("Resetting circuit breaker: fake-exec-error"))
+			})
+		})
+	})
+})
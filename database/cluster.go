@@ -0,0 +1,85 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// Node is a node of a Galera cluster. A connection is only listed in the
+// processlist of the node it was made to.
+type Node struct {
+	Address string
+	DB      *sql.DB
+}
+
+type clusterRepo struct {
+	repo          Repo
+	nodes         []Node
+	onKillFailure func(db Database, err error)
+	logger        lager.Logger
+}
+
+// NewClusterRepo returns repo, except that the databases it finds kill their
+// active connections on every node of the cluster, rather than on the node
+// repo is connected to, e.g. through a proxy. Nodes failing to kill them are
+// reported to onKillFailure rather than failing the restriction, which is
+// already in place by then.
+func NewClusterRepo(repo Repo, nodes []Node, onKillFailure func(db Database, err error), logger lager.Logger) Repo {
+	return &clusterRepo{
+		repo:          repo,
+		nodes:         nodes,
+		onKillFailure: onKillFailure,
+		logger:        logger,
+	}
+}
+
+func (r clusterRepo) All() ([]Database, error) {
+	databases, err := r.repo.All()
+	for i, db := range databases {
+		databases[i] = &clusterDatabase{
+			Database:      db,
+			nodes:         r.nodes,
+			onKillFailure: r.onKillFailure,
+			logger:        r.logger,
+		}
+	}
+	return databases, err
+}
+
+type clusterDatabase struct {
+	Database
+	nodes         []Node
+	onKillFailure func(db Database, err error)
+	logger        lager.Logger
+}
+
+// KillActiveConnections kills the connections on every node, even if some
+// fail. The nodes that failed are logged and reported together to
+// onKillFailure, and never fail the caller.
+func (d clusterDatabase) KillActiveConnections() error {
+	d.logger.Info(fmt.Sprintf("Killing active connections to database '%s' on %d cluster nodes", d.Name(), len(d.nodes)))
+
+	var failures []string
+	for _, node := range d.nodes {
+		err := killActiveConnections(node.DB, d.Name(), d.logger)
+		if err != nil {
+			d.logger.Error(
+				fmt.Sprintf("Failed to kill active connections to database '%s' on node '%s'", d.Name(), node.Address),
+				err,
+				lager.Data{"Node": node.Address},
+			)
+			failures = append(failures, fmt.Sprintf("node '%s': %s", node.Address, err.Error()))
+		}
+	}
+
+	if len(failures) > 0 {
+		d.onKillFailure(d, fmt.Errorf(
+			"Killing active connections to database '%s' failed on %d of %d nodes: %s",
+			d.Name(), len(failures), len(d.nodes), strings.Join(failures, "; "),
+		))
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package database_test
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database/databasefakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+)
+
+var _ = Describe("ClusterRepo", func() {
+	const (
+		processQueryPattern   = `SELECT ID FROM INFORMATION_SCHEMA.PROCESSLIST WHERE DB = \?$`
+		killConnectionPattern = "KILL CONNECTION \\?"
+	)
+
+	var (
+		logger       *lagertest.TestLogger
+		repo         Repo
+		fakeRepo     *databasefakes.FakeRepo
+		fakeDatabase *databasefakes.FakeDatabase
+		nodes        []Node
+		mocks        []sqlmock.Sqlmock
+		killFailures []error
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("ClusterRepo test")
+
+		nodes = nil
+		mocks = nil
+		for _, address := range []string{"fake-node-0:3306", "fake-node-1:3306"} {
+			db, mock, err := sqlmock.New()
+			Expect(err).ToNot(HaveOccurred())
+			nodes = append(nodes, Node{Address: address, DB: db})
+			mocks = append(mocks, mock)
+		}
+
+		fakeDatabase = &databasefakes.FakeDatabase{}
+		fakeDatabase.NameReturns("fake-db-name")
+		fakeRepo = &databasefakes.FakeRepo{}
+		fakeRepo.AllReturns([]Database{fakeDatabase}, nil)
+
+		killFailures = nil
+		onKillFailure := func(db Database, err error) {
+			Expect(db.Name()).To(Equal("fake-db-name"))
+			killFailures = append(killFailures, err)
+		}
+		repo = NewClusterRepo(fakeRepo, nodes, onKillFailure, logger)
+	})
+
+	AfterEach(func() {
+		for _, mock := range mocks {
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		}
+	})
+
+	It("returns the databases of the repo", func() {
+		databases, err := repo.All()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(databases).To(HaveLen(1))
+		Expect(databases[0].Name()).To(Equal("fake-db-name"))
+
+		Expect(databases[0].RevokePrivileges()).To(Succeed())
+		Expect(fakeDatabase.RevokePrivilegesCallCount()).To(Equal(1))
+	})
+
+	Context("when the repo fails", func() {
+		BeforeEach(func() {
+			fakeRepo.AllReturns([]Database{}, errors.New("fake-repo-error"))
+		})
+
+		It("returns the error", func() {
+			_, err := repo.All()
+			Expect(err).To(MatchError("fake-repo-error"))
+		})
+	})
+
+	Describe("KillActiveConnections", func() {
+		var database Database
+
+		BeforeEach(func() {
+			databases, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+			database = databases[0]
+		})
+
+		It("kills the active connections on every node", func() {
+			for i, mock := range mocks {
+				mock.ExpectQuery(processQueryPattern).
+					WithArgs("fake-db-name").
+					WillReturnRows(sqlmock.NewRows([]string{"ID"}).AddRow(10 + i))
+				mock.ExpectExec(killConnectionPattern).
+					WithArgs(10 + i).
+					WillReturnResult(sqlmock.NewResult(-1, 1))
+			}
+
+			Expect(database.KillActiveConnections()).To(Succeed())
+			Expect(fakeDatabase.KillActiveConnectionsCallCount()).To(Equal(0))
+			Expect(killFailures).To(BeEmpty())
+		})
+
+		Context("when a node fails", func() {
+			BeforeEach(func() {
+				mocks[0].ExpectQuery(processQueryPattern).
+					WillReturnError(errors.New("fake-node-error"))
+				mocks[1].ExpectQuery(processQueryPattern).
+					WithArgs("fake-db-name").
+					WillReturnRows(sqlmock.NewRows([]string{"ID"}).AddRow(11))
+				mocks[1].ExpectExec(killConnectionPattern).
+					WithArgs(11).
+					WillReturnResult(sqlmock.NewResult(-1, 1))
+			})
+
+			It("still kills the connections on the other nodes, and reports the failed node without failing", func() {
+				Expect(database.KillActiveConnections()).To(Succeed())
+				Expect(killFailures).To(HaveLen(1))
+				Expect(killFailures[0]).To(MatchError(
+					"Killing active connections to database 'fake-db-name' failed on 1 of 2 nodes: " +
+						"node 'fake-node-0:3306': Getting list of open connections to database 'fake-db-name': fake-node-error"))
+			})
+
+			It("logs the failure per node", func() {
+				database.KillActiveConnections()
+				Expect(logger.LogMessages()).To(ContainElement(ContainSubstring(
+					"Failed to kill active connections to database 'fake-db-name' on node 'fake-node-0:3306'")))
+			})
+		})
+	})
+})
@@ -1,24 +1,203 @@
 package database
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
 )
 
-func NewConnection(username, password, host string, port int, dbName string) (*sql.DB, error) {
+var tlsConfigCount int64
 
-	var userPass string
-	if password != "" {
-		userPass = fmt.Sprintf("%s:%s", username, password)
+func NewConnection(cfg config.Config) (*sql.DB, error) {
+	c, err := newConnector(cfg, mysql.MySQLDriver{})
+	if err != nil {
+		return nil, err
+	}
+
+	db := sql.OpenDB(c)
+	db.SetMaxOpenConns(cfg.MaxOpenConnections)
+	if cfg.MaxIdleConnections > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConnections)
+	}
+	db.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetimeInSeconds) * time.Second)
+
+	return db, nil
+}
+
+// newConnector returns a connector opening connections to the server of cfg
+// with d.
+func newConnector(cfg config.Config, d driver.Driver) (*connector, error) {
+	var address string
+	if cfg.Socket != "" {
+		address = fmt.Sprintf("unix(%s)", cfg.Socket)
 	} else {
-		userPass = username
+		address = fmt.Sprintf("tcp(%s:%d)", cfg.Host, cfg.Port)
+	}
+
+	c := &connector{
+		driver:      d,
+		user:        cfg.User,
+		address:     address,
+		dbName:      cfg.DBName,
+		params:      dsnParams(cfg.DSNParams),
+		credentials: NewCredentialProvider(cfg),
+	}
+
+	switch cfg.TLS.Mode {
+	case "", config.TLSModeSkip:
+	default:
+		tlsConfigName, err := registerTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		c.tlsConfigName = tlsConfigName
+		c.tlsFallback = cfg.TLS.Mode == config.TLSModePrefer
+	}
+	return c, nil
+}
+
+// dsnParams returns the parameters as name=value pairs, sorted by name so the
+// DSN is stable. Values are escaped, as the driver unescapes them, so that
+// e.g. a sql_mode list or a time zone offset is passed through unchanged.
+func dsnParams(params map[string]string) []string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(params))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, url.QueryEscape(params[name])))
+	}
+	return pairs
+}
+
+func formatDSN(userPass, address, dbName string, params []string) string {
+	dsn := fmt.Sprintf("%s@%s/%s", userPass, address, dbName)
+	if len(params) > 0 {
+		dsn += "?" + strings.Join(params, "&")
+	}
+	return dsn
+}
+
+// registerTLSConfig builds a tls.Config from the TLS settings and registers it
+// with the MySQL driver under a unique name, which is returned.
+func registerTLSConfig(cfg config.Config) (string, error) {
+	tlsConfig := &tls.Config{
+		ServerName: cfg.TLS.ServerName,
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = cfg.Host
+	}
+
+	if cfg.TLS.CACertFile != "" {
+		caCert, err := ioutil.ReadFile(cfg.TLS.CACertFile)
+		if err != nil {
+			return "", fmt.Errorf("Reading TLS CA certificate: %s", err.Error())
+		}
+
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(caCert) {
+			return "", fmt.Errorf("Parsing TLS CA certificate '%s': no certificates found", cfg.TLS.CACertFile)
+		}
+		tlsConfig.RootCAs = rootCAs
+	} else if cfg.TLS.Mode == config.TLSModePrefer {
+		// Without a CA there is nothing to verify against; prefer only promises encryption.
+		tlsConfig.InsecureSkipVerify = true
 	}
 
-	return sql.Open("mysql", fmt.Sprintf(
-		"%s@tcp(%s:%d)/%s",
-		userPass,
-		host,
-		port,
-		dbName,
-	))
+	if cfg.TLS.CertFile != "" {
+		clientCert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return "", fmt.Errorf("Loading TLS client certificate: %s", err.Error())
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	name := fmt.Sprintf("quota-enforcer-%d", atomic.AddInt64(&tlsConfigCount, 1))
+	err := mysql.RegisterTLSConfig(name, tlsConfig)
+	if err != nil {
+		return "", fmt.Errorf("Registering TLS config: %s", err.Error())
+	}
+
+	return name, nil
+}
+
+// connector opens connections with the password currently supplied by its
+// credentials. When authentication fails the credentials are refreshed, so a
+// rotated password is picked up without restarting.
+type connector struct {
+	driver        driver.Driver
+	user          string
+	address       string
+	dbName        string
+	params        []string
+	tlsConfigName string
+	tlsFallback   bool
+	credentials   CredentialProvider
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	password, err := c.credentials.Password()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := c.open(password)
+	if !isAccessDenied(err) {
+		return conn, err
+	}
+
+	refreshed, refreshErr := c.credentials.Refresh()
+	if refreshErr != nil || refreshed == password {
+		return conn, err
+	}
+
+	return c.open(refreshed)
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// open connects with TLS if configured. If TLS is only preferred and the
+// server does not support it, it connects without.
+func (c *connector) open(password string) (driver.Conn, error) {
+	if c.tlsConfigName == "" {
+		return c.Driver().Open(c.dsn(password, c.params))
+	}
+
+	conn, err := c.Driver().Open(c.dsn(password, append(c.params, "tls="+c.tlsConfigName)))
+	if err == mysql.ErrNoTLS && c.tlsFallback {
+		return c.Driver().Open(c.dsn(password, c.params))
+	}
+	return conn, err
+}
+
+func (c *connector) dsn(password string, params []string) string {
+	userPass := c.user
+	if password != "" {
+		userPass = fmt.Sprintf("%s:%s", c.user, password)
+	}
+	return formatDSN(userPass, c.address, c.dbName, params)
+}
+
+// isAccessDenied reports whether err is MySQL error 1045 (ER_ACCESS_DENIED_ERROR).
+func isAccessDenied(err error) bool {
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	return ok && mysqlErr.Number == 1045
 }
@@ -0,0 +1,214 @@
+package database_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeDriver records the DSNs it is opened with, and denies access to
+// passwords other than password.
+type fakeDriver struct {
+	password string
+	dsns     []string
+}
+
+func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
+	d.dsns = append(d.dsns, dsn)
+
+	parsed, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Passwd != d.password {
+		return nil, &mysql.MySQLError{Number: 1045, Message: "Access denied"}
+	}
+	return fakeConn{}, nil
+}
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not implemented") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not implemented") }
+
+var _ = Describe("NewConnection", func() {
+
+	var (
+		cfg     config.Config
+		tempDir string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "connection-test")
+		Expect(err).ToNot(HaveOccurred())
+
+		cfg = config.Config{
+			Host:   "fake-host",
+			Port:   3306,
+			User:   "fake-user",
+			DBName: "fake-db-name",
+		}
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(tempDir)
+	})
+
+	It("opens a connection without TLS by default", func() {
+		db, err := NewConnection(cfg)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(db).ToNot(BeNil())
+		db.Close()
+	})
+
+	Context("when a socket and pool settings are configured", func() {
+		BeforeEach(func() {
+			cfg.Host = ""
+			cfg.Port = 0
+			cfg.Socket = "/var/run/mysqld/mysqld.sock"
+			cfg.DSNParams = map[string]string{"timeout": "5s"}
+			cfg.MaxOpenConnections = 3
+		})
+
+		It("applies the pool settings", func() {
+			db, err := NewConnection(cfg)
+			Expect(err).ToNot(HaveOccurred())
+			defer db.Close()
+
+			Expect(db.Stats().MaxOpenConnections).To(Equal(3))
+		})
+	})
+
+	Context("when DSNParams hold characters special to a DSN", func() {
+		BeforeEach(func() {
+			cfg.DSNParams = map[string]string{
+				"sql_mode":  "'STRICT_TRANS_TABLES,NO_ZERO_DATE'",
+				"time_zone": "'+02:00'",
+				"fake_var":  "a&b=c%d",
+			}
+		})
+
+		It("passes the values to the driver unchanged", func() {
+			fake := &fakeDriver{}
+			connector, err := NewConnector(cfg, fake)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = connector.Connect(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fake.dsns).To(HaveLen(1))
+			parsed, err := mysql.ParseDSN(fake.dsns[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parsed.Params).To(Equal(cfg.DSNParams))
+		})
+	})
+
+	Context("when access is denied", func() {
+		var (
+			fake         *fakeDriver
+			passwordFile string
+		)
+
+		BeforeEach(func() {
+			passwordFile = filepath.Join(tempDir, "password")
+			err := ioutil.WriteFile(passwordFile, []byte("fake-old-password\n"), 0600)
+			Expect(err).ToNot(HaveOccurred())
+
+			cfg.PasswordFile = passwordFile
+			fake = &fakeDriver{password: "fake-new-password"}
+		})
+
+		It("connects with the refreshed password once it is rotated", func() {
+			connector, err := NewConnector(cfg, fake)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = connector.Connect(context.Background())
+			Expect(err).To(MatchError(ContainSubstring("Access denied")))
+
+			err = ioutil.WriteFile(passwordFile, []byte("fake-new-password\n"), 0600)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = connector.Connect(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fake.dsns).To(HaveLen(3))
+			Expect(fake.dsns[1]).To(ContainSubstring(":fake-old-password@"))
+			Expect(fake.dsns[2]).To(ContainSubstring(":fake-new-password@"))
+		})
+
+		Context("when the password has not changed", func() {
+			It("does not retry", func() {
+				fake.password = "fake-other-password"
+				connector, err := NewConnector(cfg, fake)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = connector.Connect(context.Background())
+				Expect(err).To(MatchError(ContainSubstring("Access denied")))
+				Expect(fake.dsns).To(HaveLen(1))
+			})
+		})
+	})
+
+	Context("when TLS is required", func() {
+		BeforeEach(func() {
+			cfg.TLS.Mode = config.TLSModeRequire
+		})
+
+		It("opens a connection using the system CAs", func() {
+			db, err := NewConnection(cfg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(db).ToNot(BeNil())
+			db.Close()
+		})
+
+		Context("when the CA certificate file does not exist", func() {
+			BeforeEach(func() {
+				cfg.TLS.CACertFile = filepath.Join(tempDir, "missing-ca.pem")
+			})
+
+			It("returns an error", func() {
+				_, err := NewConnection(cfg)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Reading TLS CA certificate"))
+			})
+		})
+
+		Context("when the CA certificate file contains no certificates", func() {
+			BeforeEach(func() {
+				cfg.TLS.CACertFile = filepath.Join(tempDir, "ca.pem")
+				err := ioutil.WriteFile(cfg.TLS.CACertFile, []byte("not a certificate"), 0600)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns an error", func() {
+				_, err := NewConnection(cfg)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(cfg.TLS.CACertFile))
+			})
+		})
+
+		Context("when the client certificate cannot be loaded", func() {
+			BeforeEach(func() {
+				cfg.TLS.CertFile = filepath.Join(tempDir, "missing-cert.pem")
+				cfg.TLS.KeyFile = filepath.Join(tempDir, "missing-key.pem")
+			})
+
+			It("returns an error", func() {
+				_, err := NewConnection(cfg)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Loading TLS client certificate"))
+			})
+		})
+	})
+})
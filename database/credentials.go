@@ -0,0 +1,97 @@
+package database
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+)
+
+// CredentialProvider supplies the password used to connect to MySQL.
+type CredentialProvider interface {
+	// Password returns the current password.
+	Password() (string, error)
+	// Refresh re-reads the password from its source and returns it.
+	Refresh() (string, error)
+}
+
+// NewCredentialProvider returns a provider for the password source configured
+// in cfg: PasswordFile, PasswordEnv or, if neither is set, Password.
+func NewCredentialProvider(cfg config.Config) CredentialProvider {
+	switch {
+	case cfg.PasswordFile != "":
+		return &fileCredentials{path: cfg.PasswordFile}
+	case cfg.PasswordEnv != "":
+		return envCredentials{name: cfg.PasswordEnv}
+	default:
+		return staticCredentials{password: cfg.Password}
+	}
+}
+
+type staticCredentials struct {
+	password string
+}
+
+func (c staticCredentials) Password() (string, error) {
+	return c.password, nil
+}
+
+func (c staticCredentials) Refresh() (string, error) {
+	return c.password, nil
+}
+
+type envCredentials struct {
+	name string
+}
+
+func (c envCredentials) Password() (string, error) {
+	password, ok := os.LookupEnv(c.name)
+	if !ok {
+		return "", fmt.Errorf("Reading password: environment variable '%s' is not set", c.name)
+	}
+	return password, nil
+}
+
+func (c envCredentials) Refresh() (string, error) {
+	return c.Password()
+}
+
+// fileCredentials reads the password from a file once and keeps it until
+// Refresh is called. Trailing newlines in the file are ignored.
+type fileCredentials struct {
+	path     string
+	mutex    sync.Mutex
+	password string
+	loaded   bool
+}
+
+func (c *fileCredentials) Password() (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.loaded {
+		return c.password, nil
+	}
+	return c.read()
+}
+
+func (c *fileCredentials) Refresh() (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.read()
+}
+
+func (c *fileCredentials) read() (string, error) {
+	contents, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		return "", fmt.Errorf("Reading password file '%s': %s", c.path, err.Error())
+	}
+
+	c.password = strings.TrimRight(string(contents), "\r\n")
+	c.loaded = true
+	return c.password, nil
+}
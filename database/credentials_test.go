@@ -0,0 +1,130 @@
+package database_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CredentialProvider", func() {
+
+	var (
+		cfg         config.Config
+		credentials CredentialProvider
+	)
+
+	JustBeforeEach(func() {
+		credentials = NewCredentialProvider(cfg)
+	})
+
+	Context("when Password is configured", func() {
+		BeforeEach(func() {
+			cfg = config.Config{Password: "fake-password"}
+		})
+
+		It("returns the configured password", func() {
+			password, err := credentials.Password()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(password).To(Equal("fake-password"))
+
+			password, err = credentials.Refresh()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(password).To(Equal("fake-password"))
+		})
+	})
+
+	Context("when PasswordEnv is configured", func() {
+		const envName = "QUOTA_ENFORCER_TEST_PASSWORD"
+
+		BeforeEach(func() {
+			cfg = config.Config{PasswordEnv: envName}
+		})
+
+		AfterEach(func() {
+			os.Unsetenv(envName)
+		})
+
+		It("returns the value of the environment variable", func() {
+			os.Setenv(envName, "fake-env-password")
+
+			password, err := credentials.Password()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(password).To(Equal("fake-env-password"))
+		})
+
+		Context("when the environment variable is not set", func() {
+			It("returns an error", func() {
+				_, err := credentials.Password()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(envName))
+			})
+		})
+	})
+
+	Context("when PasswordFile is configured", func() {
+		var (
+			tempDir      string
+			passwordFile string
+		)
+
+		BeforeEach(func() {
+			var err error
+			tempDir, err = ioutil.TempDir("", "credentials-test")
+			Expect(err).ToNot(HaveOccurred())
+
+			passwordFile = filepath.Join(tempDir, "password")
+			err = ioutil.WriteFile(passwordFile, []byte("fake-file-password\n"), 0600)
+			Expect(err).ToNot(HaveOccurred())
+
+			cfg = config.Config{PasswordFile: passwordFile}
+		})
+
+		AfterEach(func() {
+			_ = os.RemoveAll(tempDir)
+		})
+
+		It("returns the contents of the file without the trailing newline", func() {
+			password, err := credentials.Password()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(password).To(Equal("fake-file-password"))
+		})
+
+		It("keeps the password until it is refreshed", func() {
+			_, err := credentials.Password()
+			Expect(err).ToNot(HaveOccurred())
+
+			err = ioutil.WriteFile(passwordFile, []byte("fake-rotated-password"), 0600)
+			Expect(err).ToNot(HaveOccurred())
+
+			password, err := credentials.Password()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(password).To(Equal("fake-file-password"))
+
+			password, err = credentials.Refresh()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(password).To(Equal("fake-rotated-password"))
+
+			password, err = credentials.Password()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(password).To(Equal("fake-rotated-password"))
+		})
+
+		Context("when the file does not exist", func() {
+			BeforeEach(func() {
+				cfg.PasswordFile = filepath.Join(tempDir, "missing")
+			})
+
+			It("returns an error", func() {
+				_, err := credentials.Password()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Reading password file"))
+			})
+		})
+	})
+})
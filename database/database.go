@@ -2,35 +2,87 @@ package database
 
 import (
 	"fmt"
+	"strings"
 
 	"database/sql"
 
 	"code.cloudfoundry.org/lager"
 )
 
-// Sprintf thinks the % sign is a formatting directive, so we escape it with a second %.
-const revokeQuery = `REVOKE INSERT, UPDATE, CREATE ON %s.* FROM '%s'@'%%'`
+// The database name is quoted with quoteIdentifier and the account formatted by Server.account.
+const revokeQuery = `REVOKE INSERT, UPDATE, CREATE ON %s.* FROM %s`
 
-const grantQuery = `GRANT INSERT, UPDATE, CREATE ON %s.* TO '%s'@'%%'`
+const grantQuery = `GRANT INSERT, UPDATE, CREATE ON %s.* TO %s`
+
+const grantWritesQuery = `GRANT INSERT, UPDATE ON %s.* TO %s`
+
+const revokeCreateQuery = `REVOKE CREATE ON %s.* FROM %s`
+
+const grantCreateQuery = `GRANT CREATE ON %s.* TO %s`
+
+// The role and the user are formatted by Server.account.
+const revokeRoleQuery = `REVOKE %s FROM %s`
+
+const grantRoleQuery = `GRANT %s TO %s`
+
+const defaultRolesQuery = `SELECT DEFAULT_ROLE_USER, DEFAULT_ROLE_HOST FROM mysql.default_roles WHERE USER = ? AND HOST = ?`
+
+const setDefaultRolesQuery = `SET DEFAULT ROLE %s TO %s`
+
+const alterUserQuery = `ALTER USER %s %s`
 
 type Database interface {
 	Name() string
+	User() string
+	Host() string
+	Role() Role
 	GrantPrivileges() error
 	RevokePrivileges() error
+	GrantCreatePrivilege() error
+	RevokeCreatePrivilege() error
+	LockAccount() error
+	UnlockAccount() error
+	SetMaxUserConnections(maxUserConnections int) error
+	RestoreMaxUserConnections() error
+	SetMaxUpdatesPerHour(maxUpdatesPerHour int) error
 	KillActiveConnections() error
 }
 
+// Role is a role through which a user holds its write privileges on a
+// database. The zero Role means the privileges are granted to the user itself.
+type Role struct {
+	User string
+	Host string
+}
+
 type database struct {
-	name   string
+	name string
+	// schema is the database name as written in the grants, which may escape
+	// wildcards, e.g. cf\_db for cf_db.
+	schema string
 	user   string
-	db     *sql.DB
-	logger lager.Logger
+	host   string
+	role   Role
+	// defaultRole is whether role was a default role of the user when it was
+	// revoked, as recorded along the restriction.
+	defaultRole bool
+	server      Server
+	// maxUserConnections is the connection limit recorded along a restriction.
+	maxUserConnections int
+	// createRestricted is whether CREATE was revoked from the user for
+	// exceeding the object quota, as recorded along that restriction.
+	createRestricted bool
+	db               *sql.DB
+	logger           lager.Logger
 }
 
-func New(name, user string, db *sql.DB, logger lager.Logger) Database {
+func New(name, user, host string, server Server, db *sql.DB, logger lager.Logger) Database {
 	return &database{
 		name:   name,
+		schema: name,
 		user:   user,
+		host:   host,
+		server: server,
 		db:     db,
 		logger: logger,
 	}
@@ -40,9 +92,27 @@ func (d database) Name() string {
 	return d.name
 }
 
+func (d database) User() string {
+	return d.user
+}
+
+func (d database) Host() string {
+	return d.host
+}
+
+func (d database) Role() Role {
+	return d.role
+}
+
+// RevokePrivileges revokes the write privileges of the user, or the role it
+// holds them through, so that other users holding the role keep them.
 func (d database) RevokePrivileges() error {
+	if d.role != (Role{}) {
+		return d.changeRole(revokeRoleQuery, "revoke role")
+	}
+
 	d.logger.Info(fmt.Sprintf("Revoking privileges to db '%s', user '%s'", d.name, d.user))
-	result, err := d.db.Exec(fmt.Sprintf(revokeQuery, d.name, d.user))
+	result, err := d.db.Exec(fmt.Sprintf(revokeQuery, quoteIdentifier(d.schema), d.server.account(d.user, d.host)))
 	if err != nil {
 		return fmt.Errorf("Updating db '%s', user '%s' to revoke privileges: %s", d.name, d.user, err.Error())
 	}
@@ -54,17 +124,28 @@ func (d database) RevokePrivileges() error {
 
 	d.logger.Info(fmt.Sprintf("Updating db '%s', user '%s' to revoke privileges: Rows affected: %d", d.name, d.user, rowsAffected))
 
-	_, err = d.db.Exec("FLUSH PRIVILEGES")
-	if err != nil {
-		return fmt.Errorf("Flushing privileges: %s", err.Error())
-	}
-
-	return nil
+	return d.flushPrivileges()
 }
 
+// GrantPrivileges grants the write privileges of the user, or the role it held
+// them through along with its default role. CREATE is left out while it is
+// restricted for the object quota, which grants it once that is met again.
 func (d database) GrantPrivileges() error {
+	if d.role != (Role{}) {
+		err := d.changeRole(grantRoleQuery, "grant role")
+		if err != nil || !d.defaultRole || !d.server.restoresDefaultRoles() {
+			return err
+		}
+		return d.restoreDefaultRole()
+	}
+
+	query := grantQuery
+	if d.createRestricted {
+		query = grantWritesQuery
+	}
+
 	d.logger.Info(fmt.Sprintf("Granting privileges to db '%s', user '%s'", d.name, d.user))
-	result, err := d.db.Exec(fmt.Sprintf(grantQuery, d.name, d.user))
+	result, err := d.db.Exec(fmt.Sprintf(query, quoteIdentifier(d.schema), d.server.account(d.user, d.host)))
 	if err != nil {
 		return fmt.Errorf("Updating db '%s', user '%s' to grant privileges: %s", d.name, d.user, err.Error())
 	}
@@ -76,7 +157,123 @@ func (d database) GrantPrivileges() error {
 
 	d.logger.Info(fmt.Sprintf("Updating db '%s', user '%s' to grant privileges: Rows affected: %d", d.name, d.user, rowsAffected))
 
-	_, err = d.db.Exec("FLUSH PRIVILEGES")
+	return d.flushPrivileges()
+}
+
+// RevokeCreatePrivilege prevents the user from creating tables, leaving its
+// other privileges untouched.
+func (d database) RevokeCreatePrivilege() error {
+	return d.changePrivileges(revokeCreateQuery, "revoke create privilege")
+}
+
+func (d database) GrantCreatePrivilege() error {
+	return d.changePrivileges(grantCreateQuery, "grant create privilege")
+}
+
+func (d database) changePrivileges(query, action string) error {
+	d.logger.Info(fmt.Sprintf("Updating db '%s', user '%s' to %s", d.name, d.user, action))
+	_, err := d.db.Exec(fmt.Sprintf(query, quoteIdentifier(d.schema), d.server.account(d.user, d.host)))
+	if err != nil {
+		return fmt.Errorf("Updating db '%s', user '%s' to %s: %s", d.name, d.user, action, err.Error())
+	}
+
+	return d.flushPrivileges()
+}
+
+func (d database) changeRole(query, action string) error {
+	d.logger.Info(fmt.Sprintf("Updating db '%s', user '%s' to %s '%s'", d.name, d.user, action, d.role.User))
+	_, err := d.db.Exec(fmt.Sprintf(query, d.server.account(d.role.User, d.role.Host), d.server.account(d.user, d.host)))
+	if err != nil {
+		return fmt.Errorf("Updating db '%s', user '%s' to %s '%s': %s", d.name, d.user, action, d.role.User, err.Error())
+	}
+
+	return d.flushPrivileges()
+}
+
+// restoreDefaultRole adds the role back to the default roles of the user,
+// which SET DEFAULT ROLE replaces as a whole.
+func (d database) restoreDefaultRole() error {
+	rows, err := d.db.Query(defaultRolesQuery, d.user, d.host)
+	if err != nil {
+		return fmt.Errorf("Reading default roles of user '%s': %s", d.user, err.Error())
+	}
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role Role
+		if err := rows.Scan(&role.User, &role.Host); err != nil {
+			//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+			return fmt.Errorf("Scanning default roles of user '%s': %s", d.user, err.Error())
+		}
+		if role == d.role {
+			return nil
+		}
+		roles = append(roles, d.server.account(role.User, role.Host))
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("Reading default roles of user '%s': %s", d.user, err.Error())
+	}
+
+	roles = append(roles, d.server.account(d.role.User, d.role.Host))
+	d.logger.Info(fmt.Sprintf("Updating db '%s', user '%s' to restore default role '%s'", d.name, d.user, d.role.User))
+	_, err = d.db.Exec(fmt.Sprintf(setDefaultRolesQuery, strings.Join(roles, ", "), d.server.account(d.user, d.host)))
+	if err != nil {
+		return fmt.Errorf("Updating db '%s', user '%s' to restore default role '%s': %s", d.name, d.user, d.role.User, err.Error())
+	}
+	return nil
+}
+
+func (d database) LockAccount() error {
+	return d.alterUser("ACCOUNT LOCK", "lock account")
+}
+
+func (d database) UnlockAccount() error {
+	return d.alterUser("ACCOUNT UNLOCK", "unlock account")
+}
+
+// SetMaxUserConnections limits the number of simultaneous connections of the
+// user. Zero removes the limit.
+func (d database) SetMaxUserConnections(maxUserConnections int) error {
+	return d.alterUser(
+		fmt.Sprintf("WITH MAX_USER_CONNECTIONS %d", maxUserConnections),
+		fmt.Sprintf("set max user connections to %d", maxUserConnections),
+	)
+}
+
+// RestoreMaxUserConnections sets the connection limit the user had before it
+// was restricted. Without a recorded limit, it removes the limit.
+func (d database) RestoreMaxUserConnections() error {
+	return d.SetMaxUserConnections(d.maxUserConnections)
+}
+
+// SetMaxUpdatesPerHour limits the number of statements modifying data that the
+// user may execute per hour. Zero removes the limit.
+func (d database) SetMaxUpdatesPerHour(maxUpdatesPerHour int) error {
+	return d.alterUser(
+		fmt.Sprintf("WITH MAX_UPDATES_PER_HOUR %d", maxUpdatesPerHour),
+		fmt.Sprintf("set max updates per hour to %d", maxUpdatesPerHour),
+	)
+}
+
+func (d database) alterUser(clause, action string) error {
+	d.logger.Info(fmt.Sprintf("Updating db '%s', user '%s' to %s", d.name, d.user, action))
+	_, err := d.db.Exec(fmt.Sprintf(alterUserQuery, d.server.account(d.user, d.host), clause))
+	if err != nil {
+		return fmt.Errorf("Updating db '%s', user '%s' to %s: %s", d.name, d.user, action, err.Error())
+	}
+
+	return nil
+}
+
+func (d database) flushPrivileges() error {
+	if !d.server.requiresFlushPrivileges() {
+		return nil
+	}
+
+	_, err := d.db.Exec("FLUSH PRIVILEGES")
 	if err != nil {
 		return fmt.Errorf("Flushing privileges: %s", err.Error())
 	}
@@ -88,10 +285,15 @@ func (d database) GrantPrivileges() error {
 // New connections will get the new privileges.
 func (d database) KillActiveConnections() error {
 	d.logger.Info(fmt.Sprintf("Killing active connections to database '%s'", d.name))
+	return killActiveConnections(d.db, d.name, d.logger)
+}
 
-	rows, err := d.db.Query("SELECT ID FROM INFORMATION_SCHEMA.PROCESSLIST WHERE DB = ?", d.name)
+// killActiveConnections kills the connections to a database on the node db is
+// connected to.
+func killActiveConnections(db *sql.DB, dbName string, logger lager.Logger) error {
+	rows, err := db.Query("SELECT ID FROM INFORMATION_SCHEMA.PROCESSLIST WHERE DB = ?", dbName)
 	if err != nil {
-		return fmt.Errorf("Getting list of open connections to database '%s': %s", d.name, err.Error())
+		return fmt.Errorf("Getting list of open connections to database '%s': %s", dbName, err.Error())
 	}
 	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
 	defer rows.Close()
@@ -99,18 +301,18 @@ func (d database) KillActiveConnections() error {
 		var connectionID int64
 		if err := rows.Scan(&connectionID); err != nil {
 			//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
-			return fmt.Errorf("Scanning open connections to database '%s': %s", d.name, err.Error())
+			return fmt.Errorf("Scanning open connections to database '%s': %s", dbName, err.Error())
 		}
 
-		d.logger.Debug(fmt.Sprintf("Killing active connection %d to database '%s'", connectionID, d.name))
-		_, err := d.db.Exec("KILL CONNECTION ?", connectionID)
+		logger.Debug(fmt.Sprintf("Killing active connection %d to database '%s'", connectionID, dbName))
+		_, err := db.Exec("KILL CONNECTION ?", connectionID)
 		if err != nil {
-			d.logger.Error(fmt.Sprintf("Failed to kill active connection %d to database '%s'", connectionID, d.name), err)
+			logger.Error(fmt.Sprintf("Failed to kill active connection %d to database '%s'", connectionID, dbName), err)
 		}
 	}
 	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
 	if err := rows.Err(); err != nil {
-		return fmt.Errorf("Reading open connections to database '%s': %s", d.name, err.Error())
+		return fmt.Errorf("Reading open connections to database '%s': %s", dbName, err.Error())
 	}
 
 	return nil
@@ -7,6 +7,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	"errors"
+	"regexp"
 
 	"database/sql"
 
@@ -35,7 +36,7 @@ var _ = Describe("Database", func() {
 		Expect(err).ToNot(HaveOccurred())
 
 		logger = lagertest.NewTestLogger("Database test")
-		database = New(dbName, dbUser, fakeDB, logger)
+		database = New(dbName, dbUser, "%", Server{Flavor: FlavorMySQL, Major: 5, Minor: 7}, fakeDB, logger)
 	})
 
 	AfterEach(func() {
@@ -44,7 +45,7 @@ var _ = Describe("Database", func() {
 
 	Describe("RevokePrivileges", func() {
 		var (
-			revokePrivilegesPattern = `REVOKE INSERT, UPDATE, CREATE ON fake-db-name.\* FROM 'fake-db-user'@'\%'`
+			revokePrivilegesPattern = "REVOKE INSERT, UPDATE, CREATE ON `fake-db-name`.\\* FROM 'fake-db-user'@'%'"
 		)
 
 		It("makes a sql query to revoke privileges on a database and then flushes privileges", func() {
@@ -59,6 +60,56 @@ var _ = Describe("Database", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		Context("when the database name and user contain special characters", func() {
+			BeforeEach(func() {
+				database = New("fake`db.näme", `fake'db\user`, "%", Server{Flavor: FlavorMySQL, Major: 5, Minor: 7}, fakeDB, logger)
+			})
+
+			It("quotes the database name and user", func() {
+				mock.ExpectExec(regexp.QuoteMeta("REVOKE INSERT, UPDATE, CREATE ON `fake``db.näme`.* FROM 'fake''db\\\\user'@'%'")).
+					WillReturnResult(sqlmock.NewResult(-1, 1))
+
+				mock.ExpectExec(flushPrivilegesPattern).
+					WithArgs().
+					WillReturnResult(sqlmock.NewResult(-1, 1))
+
+				err := database.RevokePrivileges()
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the server is MySQL 8.0", func() {
+			BeforeEach(func() {
+				database = New(dbName, dbUser, "%", Server{Flavor: FlavorMySQL, Major: 8, Minor: 0}, fakeDB, logger)
+			})
+
+			It("does not flush privileges", func() {
+				mock.ExpectExec(revokePrivilegesPattern).
+					WillReturnResult(sqlmock.NewResult(-1, 1))
+
+				err := database.RevokePrivileges()
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the grantee is a MariaDB role", func() {
+			BeforeEach(func() {
+				database = New(dbName, "fake-role", "", Server{Flavor: FlavorMariaDB, Major: 10, Minor: 6}, fakeDB, logger)
+			})
+
+			It("revokes privileges from the role without a host", func() {
+				mock.ExpectExec("REVOKE INSERT, UPDATE, CREATE ON `fake-db-name`.\\* FROM 'fake-role'$").
+					WillReturnResult(sqlmock.NewResult(-1, 1))
+
+				mock.ExpectExec(flushPrivilegesPattern).
+					WithArgs().
+					WillReturnResult(sqlmock.NewResult(-1, 1))
+
+				err := database.RevokePrivileges()
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
 		Context("when the query fails", func() {
 			BeforeEach(func() {
 				mock.ExpectExec(revokePrivilegesPattern).
@@ -110,7 +161,7 @@ var _ = Describe("Database", func() {
 
 	Describe("GrantPrivileges", func() {
 		var (
-			grantPrivilegesPattern = `GRANT INSERT, UPDATE, CREATE ON fake-db-name.\* TO 'fake-db-user'@'\%'`
+			grantPrivilegesPattern = "GRANT INSERT, UPDATE, CREATE ON `fake-db-name`.\\* TO 'fake-db-user'@'%'"
 		)
 
 		It("grants privileges to the database", func() {
@@ -124,6 +175,24 @@ var _ = Describe("Database", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		Context("when the database name and user contain special characters", func() {
+			BeforeEach(func() {
+				database = New("fake`db.näme", `fake'db\user`, "%", Server{Flavor: FlavorMySQL, Major: 5, Minor: 7}, fakeDB, logger)
+			})
+
+			It("quotes the database name and user", func() {
+				mock.ExpectExec(regexp.QuoteMeta("GRANT INSERT, UPDATE, CREATE ON `fake``db.näme`.* TO 'fake''db\\\\user'@'%'")).
+					WillReturnResult(sqlmock.NewResult(-1, 1))
+
+				mock.ExpectExec(flushPrivilegesPattern).
+					WithArgs().
+					WillReturnResult(sqlmock.NewResult(-1, 1))
+
+				err := database.GrantPrivileges()
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
 		Context("when the query fails", func() {
 			BeforeEach(func() {
 				mock.ExpectExec(grantPrivilegesPattern).
@@ -174,6 +243,111 @@ var _ = Describe("Database", func() {
 
 	})
 
+	Describe("RevokeCreatePrivilege", func() {
+		It("revokes only the create privilege and then flushes privileges", func() {
+			mock.ExpectExec("REVOKE CREATE ON `fake-db-name`.\\* FROM 'fake-db-user'@'%'").
+				WillReturnResult(sqlmock.NewResult(-1, 1))
+
+			mock.ExpectExec(flushPrivilegesPattern).
+				WithArgs().
+				WillReturnResult(sqlmock.NewResult(-1, 1))
+
+			err := database.RevokeCreatePrivilege()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		Context("when the query fails", func() {
+			BeforeEach(func() {
+				mock.ExpectExec("REVOKE").
+					WillReturnError(errors.New("fake-query-error"))
+			})
+
+			It("returns an error", func() {
+				err := database.RevokeCreatePrivilege()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-query-error"))
+			})
+		})
+	})
+
+	Describe("GrantCreatePrivilege", func() {
+		It("grants only the create privilege and then flushes privileges", func() {
+			mock.ExpectExec("GRANT CREATE ON `fake-db-name`.\\* TO 'fake-db-user'@'%'").
+				WillReturnResult(sqlmock.NewResult(-1, 1))
+
+			mock.ExpectExec(flushPrivilegesPattern).
+				WithArgs().
+				WillReturnResult(sqlmock.NewResult(-1, 1))
+
+			err := database.GrantCreatePrivilege()
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Describe("LockAccount", func() {
+		It("locks the account", func() {
+			mock.ExpectExec("ALTER USER 'fake-db-user'@'%' ACCOUNT LOCK").
+				WillReturnResult(sqlmock.NewResult(-1, 0))
+
+			err := database.LockAccount()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		Context("when the query fails", func() {
+			BeforeEach(func() {
+				mock.ExpectExec("ALTER USER").
+					WillReturnError(errors.New("fake-query-error"))
+			})
+
+			It("returns an error", func() {
+				err := database.LockAccount()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-query-error"))
+				Expect(err.Error()).To(ContainSubstring(dbUser))
+			})
+		})
+	})
+
+	Describe("UnlockAccount", func() {
+		It("unlocks the account", func() {
+			mock.ExpectExec("ALTER USER 'fake-db-user'@'%' ACCOUNT UNLOCK").
+				WillReturnResult(sqlmock.NewResult(-1, 0))
+
+			err := database.UnlockAccount()
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Describe("SetMaxUserConnections", func() {
+		It("sets the connection limit of the account", func() {
+			mock.ExpectExec("ALTER USER 'fake-db-user'@'%' WITH MAX_USER_CONNECTIONS 1").
+				WillReturnResult(sqlmock.NewResult(-1, 0))
+
+			err := database.SetMaxUserConnections(1)
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Describe("RestoreMaxUserConnections", func() {
+		It("removes the connection limit when none was recorded", func() {
+			mock.ExpectExec("ALTER USER 'fake-db-user'@'%' WITH MAX_USER_CONNECTIONS 0").
+				WillReturnResult(sqlmock.NewResult(-1, 0))
+
+			err := database.RestoreMaxUserConnections()
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Describe("SetMaxUpdatesPerHour", func() {
+		It("sets the update limit of the account", func() {
+			mock.ExpectExec("ALTER USER 'fake-db-user'@'%' WITH MAX_UPDATES_PER_HOUR 1000").
+				WillReturnResult(sqlmock.NewResult(-1, 0))
+
+			err := database.SetMaxUpdatesPerHour(1000)
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
 	Describe("KillActiveConnections", func() {
 		var (
 			processListColumns    = []string{"ID"}
@@ -0,0 +1,68 @@
+// This file was generated by counterfeiter
+package databasefakes
+
+import (
+	"sync"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+)
+
+type FakeAccountRepo struct {
+	AllStub        func() ([]database.Account, error)
+	allMutex       sync.RWMutex
+	allArgsForCall []struct{}
+	allReturns     struct {
+		result1 []database.Account
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeAccountRepo) All() ([]database.Account, error) {
+	fake.allMutex.Lock()
+	fake.allArgsForCall = append(fake.allArgsForCall, struct{}{})
+	fake.recordInvocation("All", []interface{}{})
+	fake.allMutex.Unlock()
+	if fake.AllStub != nil {
+		return fake.AllStub()
+	} else {
+		return fake.allReturns.result1, fake.allReturns.result2
+	}
+}
+
+func (fake *FakeAccountRepo) AllCallCount() int {
+	fake.allMutex.RLock()
+	defer fake.allMutex.RUnlock()
+	return len(fake.allArgsForCall)
+}
+
+func (fake *FakeAccountRepo) AllReturns(result1 []database.Account, result2 error) {
+	fake.AllStub = nil
+	fake.allReturns = struct {
+		result1 []database.Account
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAccountRepo) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.allMutex.RLock()
+	defer fake.allMutex.RUnlock()
+	return fake.invocations
+}
+
+func (fake *FakeAccountRepo) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ database.AccountRepo = new(FakeAccountRepo)
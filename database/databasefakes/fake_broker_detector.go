@@ -0,0 +1,78 @@
+// This file was generated by counterfeiter
+package databasefakes
+
+import (
+	"sync"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+)
+
+type FakeBrokerDetector struct {
+	DetectStub        func(database.Broker) (database.Broker, error)
+	detectMutex       sync.RWMutex
+	detectArgsForCall []struct {
+		arg1 database.Broker
+	}
+	detectReturns struct {
+		result1 database.Broker
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeBrokerDetector) Detect(arg1 database.Broker) (database.Broker, error) {
+	fake.detectMutex.Lock()
+	fake.detectArgsForCall = append(fake.detectArgsForCall, struct {
+		arg1 database.Broker
+	}{arg1})
+	fake.recordInvocation("Detect", []interface{}{arg1})
+	fake.detectMutex.Unlock()
+	if fake.DetectStub != nil {
+		return fake.DetectStub(arg1)
+	} else {
+		return fake.detectReturns.result1, fake.detectReturns.result2
+	}
+}
+
+func (fake *FakeBrokerDetector) DetectCallCount() int {
+	fake.detectMutex.RLock()
+	defer fake.detectMutex.RUnlock()
+	return len(fake.detectArgsForCall)
+}
+
+func (fake *FakeBrokerDetector) DetectArgsForCall(i int) database.Broker {
+	fake.detectMutex.RLock()
+	defer fake.detectMutex.RUnlock()
+	return fake.detectArgsForCall[i].arg1
+}
+
+func (fake *FakeBrokerDetector) DetectReturns(result1 database.Broker, result2 error) {
+	fake.DetectStub = nil
+	fake.detectReturns = struct {
+		result1 database.Broker
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBrokerDetector) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.detectMutex.RLock()
+	defer fake.detectMutex.RUnlock()
+	return fake.invocations
+}
+
+func (fake *FakeBrokerDetector) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ database.BrokerDetector = new(FakeBrokerDetector)
@@ -0,0 +1,256 @@
+// This file was generated by counterfeiter
+package databasefakes
+
+import (
+	"sync"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+)
+
+type FakeCircuitBreakerRepo struct {
+	SetupStub        func() error
+	setupMutex       sync.RWMutex
+	setupArgsForCall []struct{}
+	setupReturns     struct {
+		result1 error
+	}
+	TrippedStub        func() (bool, string, error)
+	trippedMutex       sync.RWMutex
+	trippedArgsForCall []struct{}
+	trippedReturns     struct {
+		result1 bool
+		result2 string
+		result3 error
+	}
+	TripStub        func(string, []string) error
+	tripMutex       sync.RWMutex
+	tripArgsForCall []struct {
+		arg1 string
+		arg2 []string
+	}
+	tripReturns struct {
+		result1 error
+	}
+	AcknowledgeStub        func() (bool, error)
+	acknowledgeMutex       sync.RWMutex
+	acknowledgeArgsForCall []struct{}
+	acknowledgeReturns     struct {
+		result1 bool
+		result2 error
+	}
+	AcknowledgedStub        func() ([]string, error)
+	acknowledgedMutex       sync.RWMutex
+	acknowledgedArgsForCall []struct{}
+	acknowledgedReturns     struct {
+		result1 []string
+		result2 error
+	}
+	ResetStub        func() error
+	resetMutex       sync.RWMutex
+	resetArgsForCall []struct{}
+	resetReturns     struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeCircuitBreakerRepo) Setup() error {
+	fake.setupMutex.Lock()
+	fake.setupArgsForCall = append(fake.setupArgsForCall, struct{}{})
+	fake.recordInvocation("Setup", []interface{}{})
+	fake.setupMutex.Unlock()
+	if fake.SetupStub != nil {
+		return fake.SetupStub()
+	} else {
+		return fake.setupReturns.result1
+	}
+}
+
+func (fake *FakeCircuitBreakerRepo) SetupCallCount() int {
+	fake.setupMutex.RLock()
+	defer fake.setupMutex.RUnlock()
+	return len(fake.setupArgsForCall)
+}
+
+func (fake *FakeCircuitBreakerRepo) SetupReturns(result1 error) {
+	fake.SetupStub = nil
+	fake.setupReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeCircuitBreakerRepo) Tripped() (bool, string, error) {
+	fake.trippedMutex.Lock()
+	fake.trippedArgsForCall = append(fake.trippedArgsForCall, struct{}{})
+	fake.recordInvocation("Tripped", []interface{}{})
+	fake.trippedMutex.Unlock()
+	if fake.TrippedStub != nil {
+		return fake.TrippedStub()
+	} else {
+		return fake.trippedReturns.result1, fake.trippedReturns.result2, fake.trippedReturns.result3
+	}
+}
+
+func (fake *FakeCircuitBreakerRepo) TrippedCallCount() int {
+	fake.trippedMutex.RLock()
+	defer fake.trippedMutex.RUnlock()
+	return len(fake.trippedArgsForCall)
+}
+
+func (fake *FakeCircuitBreakerRepo) TrippedReturns(result1 bool, result2 string, result3 error) {
+	fake.TrippedStub = nil
+	fake.trippedReturns = struct {
+		result1 bool
+		result2 string
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeCircuitBreakerRepo) Trip(arg1 string, arg2 []string) error {
+	var arg2Copy []string
+	if arg2 != nil {
+		arg2Copy = make([]string, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.tripMutex.Lock()
+	fake.tripArgsForCall = append(fake.tripArgsForCall, struct {
+		arg1 string
+		arg2 []string
+	}{arg1, arg2Copy})
+	fake.recordInvocation("Trip", []interface{}{arg1, arg2Copy})
+	fake.tripMutex.Unlock()
+	if fake.TripStub != nil {
+		return fake.TripStub(arg1, arg2)
+	} else {
+		return fake.tripReturns.result1
+	}
+}
+
+func (fake *FakeCircuitBreakerRepo) TripCallCount() int {
+	fake.tripMutex.RLock()
+	defer fake.tripMutex.RUnlock()
+	return len(fake.tripArgsForCall)
+}
+
+func (fake *FakeCircuitBreakerRepo) TripArgsForCall(i int) (string, []string) {
+	fake.tripMutex.RLock()
+	defer fake.tripMutex.RUnlock()
+	return fake.tripArgsForCall[i].arg1, fake.tripArgsForCall[i].arg2
+}
+
+func (fake *FakeCircuitBreakerRepo) TripReturns(result1 error) {
+	fake.TripStub = nil
+	fake.tripReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeCircuitBreakerRepo) Acknowledge() (bool, error) {
+	fake.acknowledgeMutex.Lock()
+	fake.acknowledgeArgsForCall = append(fake.acknowledgeArgsForCall, struct{}{})
+	fake.recordInvocation("Acknowledge", []interface{}{})
+	fake.acknowledgeMutex.Unlock()
+	if fake.AcknowledgeStub != nil {
+		return fake.AcknowledgeStub()
+	} else {
+		return fake.acknowledgeReturns.result1, fake.acknowledgeReturns.result2
+	}
+}
+
+func (fake *FakeCircuitBreakerRepo) AcknowledgeCallCount() int {
+	fake.acknowledgeMutex.RLock()
+	defer fake.acknowledgeMutex.RUnlock()
+	return len(fake.acknowledgeArgsForCall)
+}
+
+func (fake *FakeCircuitBreakerRepo) AcknowledgeReturns(result1 bool, result2 error) {
+	fake.AcknowledgeStub = nil
+	fake.acknowledgeReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCircuitBreakerRepo) Acknowledged() ([]string, error) {
+	fake.acknowledgedMutex.Lock()
+	fake.acknowledgedArgsForCall = append(fake.acknowledgedArgsForCall, struct{}{})
+	fake.recordInvocation("Acknowledged", []interface{}{})
+	fake.acknowledgedMutex.Unlock()
+	if fake.AcknowledgedStub != nil {
+		return fake.AcknowledgedStub()
+	} else {
+		return fake.acknowledgedReturns.result1, fake.acknowledgedReturns.result2
+	}
+}
+
+func (fake *FakeCircuitBreakerRepo) AcknowledgedCallCount() int {
+	fake.acknowledgedMutex.RLock()
+	defer fake.acknowledgedMutex.RUnlock()
+	return len(fake.acknowledgedArgsForCall)
+}
+
+func (fake *FakeCircuitBreakerRepo) AcknowledgedReturns(result1 []string, result2 error) {
+	fake.AcknowledgedStub = nil
+	fake.acknowledgedReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCircuitBreakerRepo) Reset() error {
+	fake.resetMutex.Lock()
+	fake.resetArgsForCall = append(fake.resetArgsForCall, struct{}{})
+	fake.recordInvocation("Reset", []interface{}{})
+	fake.resetMutex.Unlock()
+	if fake.ResetStub != nil {
+		return fake.ResetStub()
+	} else {
+		return fake.resetReturns.result1
+	}
+}
+
+func (fake *FakeCircuitBreakerRepo) ResetCallCount() int {
+	fake.resetMutex.RLock()
+	defer fake.resetMutex.RUnlock()
+	return len(fake.resetArgsForCall)
+}
+
+func (fake *FakeCircuitBreakerRepo) ResetReturns(result1 error) {
+	fake.ResetStub = nil
+	fake.resetReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeCircuitBreakerRepo) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.setupMutex.RLock()
+	defer fake.setupMutex.RUnlock()
+	fake.trippedMutex.RLock()
+	defer fake.trippedMutex.RUnlock()
+	fake.tripMutex.RLock()
+	defer fake.tripMutex.RUnlock()
+	fake.acknowledgeMutex.RLock()
+	defer fake.acknowledgeMutex.RUnlock()
+	fake.acknowledgedMutex.RLock()
+	defer fake.acknowledgedMutex.RUnlock()
+	fake.resetMutex.RLock()
+	defer fake.resetMutex.RUnlock()
+	return fake.invocations
+}
+
+func (fake *FakeCircuitBreakerRepo) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ database.CircuitBreakerRepo = new(FakeCircuitBreakerRepo)
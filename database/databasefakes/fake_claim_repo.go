@@ -0,0 +1,68 @@
+// This file was generated by counterfeiter
+package databasefakes
+
+import (
+	"sync"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+)
+
+type FakeClaimRepo struct {
+	DuplicatesStub        func() (map[string][]string, error)
+	duplicatesMutex       sync.RWMutex
+	duplicatesArgsForCall []struct{}
+	duplicatesReturns     struct {
+		result1 map[string][]string
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeClaimRepo) Duplicates() (map[string][]string, error) {
+	fake.duplicatesMutex.Lock()
+	fake.duplicatesArgsForCall = append(fake.duplicatesArgsForCall, struct{}{})
+	fake.recordInvocation("Duplicates", []interface{}{})
+	fake.duplicatesMutex.Unlock()
+	if fake.DuplicatesStub != nil {
+		return fake.DuplicatesStub()
+	} else {
+		return fake.duplicatesReturns.result1, fake.duplicatesReturns.result2
+	}
+}
+
+func (fake *FakeClaimRepo) DuplicatesCallCount() int {
+	fake.duplicatesMutex.RLock()
+	defer fake.duplicatesMutex.RUnlock()
+	return len(fake.duplicatesArgsForCall)
+}
+
+func (fake *FakeClaimRepo) DuplicatesReturns(result1 map[string][]string, result2 error) {
+	fake.DuplicatesStub = nil
+	fake.duplicatesReturns = struct {
+		result1 map[string][]string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClaimRepo) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.duplicatesMutex.RLock()
+	defer fake.duplicatesMutex.RUnlock()
+	return fake.invocations
+}
+
+func (fake *FakeClaimRepo) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ database.ClaimRepo = new(FakeClaimRepo)
@@ -14,6 +14,24 @@ type FakeDatabase struct {
 	nameReturns     struct {
 		result1 string
 	}
+	UserStub        func() string
+	userMutex       sync.RWMutex
+	userArgsForCall []struct{}
+	userReturns     struct {
+		result1 string
+	}
+	HostStub        func() string
+	hostMutex       sync.RWMutex
+	hostArgsForCall []struct{}
+	hostReturns     struct {
+		result1 string
+	}
+	RoleStub        func() database.Role
+	roleMutex       sync.RWMutex
+	roleArgsForCall []struct{}
+	roleReturns     struct {
+		result1 database.Role
+	}
 	GrantPrivilegesStub        func() error
 	grantPrivilegesMutex       sync.RWMutex
 	grantPrivilegesArgsForCall []struct{}
@@ -26,6 +44,52 @@ type FakeDatabase struct {
 	revokePrivilegesReturns     struct {
 		result1 error
 	}
+	GrantCreatePrivilegeStub        func() error
+	grantCreatePrivilegeMutex       sync.RWMutex
+	grantCreatePrivilegeArgsForCall []struct{}
+	grantCreatePrivilegeReturns     struct {
+		result1 error
+	}
+	RevokeCreatePrivilegeStub        func() error
+	revokeCreatePrivilegeMutex       sync.RWMutex
+	revokeCreatePrivilegeArgsForCall []struct{}
+	revokeCreatePrivilegeReturns     struct {
+		result1 error
+	}
+	LockAccountStub        func() error
+	lockAccountMutex       sync.RWMutex
+	lockAccountArgsForCall []struct{}
+	lockAccountReturns     struct {
+		result1 error
+	}
+	UnlockAccountStub        func() error
+	unlockAccountMutex       sync.RWMutex
+	unlockAccountArgsForCall []struct{}
+	unlockAccountReturns     struct {
+		result1 error
+	}
+	SetMaxUserConnectionsStub        func(int) error
+	setMaxUserConnectionsMutex       sync.RWMutex
+	setMaxUserConnectionsArgsForCall []struct {
+		arg1 int
+	}
+	setMaxUserConnectionsReturns struct {
+		result1 error
+	}
+	RestoreMaxUserConnectionsStub        func() error
+	restoreMaxUserConnectionsMutex       sync.RWMutex
+	restoreMaxUserConnectionsArgsForCall []struct{}
+	restoreMaxUserConnectionsReturns     struct {
+		result1 error
+	}
+	SetMaxUpdatesPerHourStub        func(int) error
+	setMaxUpdatesPerHourMutex       sync.RWMutex
+	setMaxUpdatesPerHourArgsForCall []struct {
+		arg1 int
+	}
+	setMaxUpdatesPerHourReturns struct {
+		result1 error
+	}
 	KillActiveConnectionsStub        func() error
 	killActiveConnectionsMutex       sync.RWMutex
 	killActiveConnectionsArgsForCall []struct{}
@@ -61,6 +125,81 @@ func (fake *FakeDatabase) NameReturns(result1 string) {
 	}{result1}
 }
 
+func (fake *FakeDatabase) User() string {
+	fake.userMutex.Lock()
+	fake.userArgsForCall = append(fake.userArgsForCall, struct{}{})
+	fake.recordInvocation("User", []interface{}{})
+	fake.userMutex.Unlock()
+	if fake.UserStub != nil {
+		return fake.UserStub()
+	} else {
+		return fake.userReturns.result1
+	}
+}
+
+func (fake *FakeDatabase) UserCallCount() int {
+	fake.userMutex.RLock()
+	defer fake.userMutex.RUnlock()
+	return len(fake.userArgsForCall)
+}
+
+func (fake *FakeDatabase) UserReturns(result1 string) {
+	fake.UserStub = nil
+	fake.userReturns = struct {
+		result1 string
+	}{result1}
+}
+
+func (fake *FakeDatabase) Host() string {
+	fake.hostMutex.Lock()
+	fake.hostArgsForCall = append(fake.hostArgsForCall, struct{}{})
+	fake.recordInvocation("Host", []interface{}{})
+	fake.hostMutex.Unlock()
+	if fake.HostStub != nil {
+		return fake.HostStub()
+	} else {
+		return fake.hostReturns.result1
+	}
+}
+
+func (fake *FakeDatabase) HostCallCount() int {
+	fake.hostMutex.RLock()
+	defer fake.hostMutex.RUnlock()
+	return len(fake.hostArgsForCall)
+}
+
+func (fake *FakeDatabase) HostReturns(result1 string) {
+	fake.HostStub = nil
+	fake.hostReturns = struct {
+		result1 string
+	}{result1}
+}
+
+func (fake *FakeDatabase) Role() database.Role {
+	fake.roleMutex.Lock()
+	fake.roleArgsForCall = append(fake.roleArgsForCall, struct{}{})
+	fake.recordInvocation("Role", []interface{}{})
+	fake.roleMutex.Unlock()
+	if fake.RoleStub != nil {
+		return fake.RoleStub()
+	} else {
+		return fake.roleReturns.result1
+	}
+}
+
+func (fake *FakeDatabase) RoleCallCount() int {
+	fake.roleMutex.RLock()
+	defer fake.roleMutex.RUnlock()
+	return len(fake.roleArgsForCall)
+}
+
+func (fake *FakeDatabase) RoleReturns(result1 database.Role) {
+	fake.RoleStub = nil
+	fake.roleReturns = struct {
+		result1 database.Role
+	}{result1}
+}
+
 func (fake *FakeDatabase) GrantPrivileges() error {
 	fake.grantPrivilegesMutex.Lock()
 	fake.grantPrivilegesArgsForCall = append(fake.grantPrivilegesArgsForCall, struct{}{})
@@ -111,6 +250,197 @@ func (fake *FakeDatabase) RevokePrivilegesReturns(result1 error) {
 	}{result1}
 }
 
+func (fake *FakeDatabase) GrantCreatePrivilege() error {
+	fake.grantCreatePrivilegeMutex.Lock()
+	fake.grantCreatePrivilegeArgsForCall = append(fake.grantCreatePrivilegeArgsForCall, struct{}{})
+	fake.recordInvocation("GrantCreatePrivilege", []interface{}{})
+	fake.grantCreatePrivilegeMutex.Unlock()
+	if fake.GrantCreatePrivilegeStub != nil {
+		return fake.GrantCreatePrivilegeStub()
+	} else {
+		return fake.grantCreatePrivilegeReturns.result1
+	}
+}
+
+func (fake *FakeDatabase) GrantCreatePrivilegeCallCount() int {
+	fake.grantCreatePrivilegeMutex.RLock()
+	defer fake.grantCreatePrivilegeMutex.RUnlock()
+	return len(fake.grantCreatePrivilegeArgsForCall)
+}
+
+func (fake *FakeDatabase) GrantCreatePrivilegeReturns(result1 error) {
+	fake.GrantCreatePrivilegeStub = nil
+	fake.grantCreatePrivilegeReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeDatabase) RevokeCreatePrivilege() error {
+	fake.revokeCreatePrivilegeMutex.Lock()
+	fake.revokeCreatePrivilegeArgsForCall = append(fake.revokeCreatePrivilegeArgsForCall, struct{}{})
+	fake.recordInvocation("RevokeCreatePrivilege", []interface{}{})
+	fake.revokeCreatePrivilegeMutex.Unlock()
+	if fake.RevokeCreatePrivilegeStub != nil {
+		return fake.RevokeCreatePrivilegeStub()
+	} else {
+		return fake.revokeCreatePrivilegeReturns.result1
+	}
+}
+
+func (fake *FakeDatabase) RevokeCreatePrivilegeCallCount() int {
+	fake.revokeCreatePrivilegeMutex.RLock()
+	defer fake.revokeCreatePrivilegeMutex.RUnlock()
+	return len(fake.revokeCreatePrivilegeArgsForCall)
+}
+
+func (fake *FakeDatabase) RevokeCreatePrivilegeReturns(result1 error) {
+	fake.RevokeCreatePrivilegeStub = nil
+	fake.revokeCreatePrivilegeReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeDatabase) LockAccount() error {
+	fake.lockAccountMutex.Lock()
+	fake.lockAccountArgsForCall = append(fake.lockAccountArgsForCall, struct{}{})
+	fake.recordInvocation("LockAccount", []interface{}{})
+	fake.lockAccountMutex.Unlock()
+	if fake.LockAccountStub != nil {
+		return fake.LockAccountStub()
+	} else {
+		return fake.lockAccountReturns.result1
+	}
+}
+
+func (fake *FakeDatabase) LockAccountCallCount() int {
+	fake.lockAccountMutex.RLock()
+	defer fake.lockAccountMutex.RUnlock()
+	return len(fake.lockAccountArgsForCall)
+}
+
+func (fake *FakeDatabase) LockAccountReturns(result1 error) {
+	fake.LockAccountStub = nil
+	fake.lockAccountReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeDatabase) UnlockAccount() error {
+	fake.unlockAccountMutex.Lock()
+	fake.unlockAccountArgsForCall = append(fake.unlockAccountArgsForCall, struct{}{})
+	fake.recordInvocation("UnlockAccount", []interface{}{})
+	fake.unlockAccountMutex.Unlock()
+	if fake.UnlockAccountStub != nil {
+		return fake.UnlockAccountStub()
+	} else {
+		return fake.unlockAccountReturns.result1
+	}
+}
+
+func (fake *FakeDatabase) UnlockAccountCallCount() int {
+	fake.unlockAccountMutex.RLock()
+	defer fake.unlockAccountMutex.RUnlock()
+	return len(fake.unlockAccountArgsForCall)
+}
+
+func (fake *FakeDatabase) UnlockAccountReturns(result1 error) {
+	fake.UnlockAccountStub = nil
+	fake.unlockAccountReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeDatabase) SetMaxUserConnections(arg1 int) error {
+	fake.setMaxUserConnectionsMutex.Lock()
+	fake.setMaxUserConnectionsArgsForCall = append(fake.setMaxUserConnectionsArgsForCall, struct {
+		arg1 int
+	}{arg1})
+	fake.recordInvocation("SetMaxUserConnections", []interface{}{arg1})
+	fake.setMaxUserConnectionsMutex.Unlock()
+	if fake.SetMaxUserConnectionsStub != nil {
+		return fake.SetMaxUserConnectionsStub(arg1)
+	} else {
+		return fake.setMaxUserConnectionsReturns.result1
+	}
+}
+
+func (fake *FakeDatabase) SetMaxUserConnectionsCallCount() int {
+	fake.setMaxUserConnectionsMutex.RLock()
+	defer fake.setMaxUserConnectionsMutex.RUnlock()
+	return len(fake.setMaxUserConnectionsArgsForCall)
+}
+
+func (fake *FakeDatabase) SetMaxUserConnectionsArgsForCall(i int) int {
+	fake.setMaxUserConnectionsMutex.RLock()
+	defer fake.setMaxUserConnectionsMutex.RUnlock()
+	return fake.setMaxUserConnectionsArgsForCall[i].arg1
+}
+
+func (fake *FakeDatabase) SetMaxUserConnectionsReturns(result1 error) {
+	fake.SetMaxUserConnectionsStub = nil
+	fake.setMaxUserConnectionsReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeDatabase) RestoreMaxUserConnections() error {
+	fake.restoreMaxUserConnectionsMutex.Lock()
+	fake.restoreMaxUserConnectionsArgsForCall = append(fake.restoreMaxUserConnectionsArgsForCall, struct{}{})
+	fake.recordInvocation("RestoreMaxUserConnections", []interface{}{})
+	fake.restoreMaxUserConnectionsMutex.Unlock()
+	if fake.RestoreMaxUserConnectionsStub != nil {
+		return fake.RestoreMaxUserConnectionsStub()
+	} else {
+		return fake.restoreMaxUserConnectionsReturns.result1
+	}
+}
+
+func (fake *FakeDatabase) RestoreMaxUserConnectionsCallCount() int {
+	fake.restoreMaxUserConnectionsMutex.RLock()
+	defer fake.restoreMaxUserConnectionsMutex.RUnlock()
+	return len(fake.restoreMaxUserConnectionsArgsForCall)
+}
+
+func (fake *FakeDatabase) RestoreMaxUserConnectionsReturns(result1 error) {
+	fake.RestoreMaxUserConnectionsStub = nil
+	fake.restoreMaxUserConnectionsReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeDatabase) SetMaxUpdatesPerHour(arg1 int) error {
+	fake.setMaxUpdatesPerHourMutex.Lock()
+	fake.setMaxUpdatesPerHourArgsForCall = append(fake.setMaxUpdatesPerHourArgsForCall, struct {
+		arg1 int
+	}{arg1})
+	fake.recordInvocation("SetMaxUpdatesPerHour", []interface{}{arg1})
+	fake.setMaxUpdatesPerHourMutex.Unlock()
+	if fake.SetMaxUpdatesPerHourStub != nil {
+		return fake.SetMaxUpdatesPerHourStub(arg1)
+	} else {
+		return fake.setMaxUpdatesPerHourReturns.result1
+	}
+}
+
+func (fake *FakeDatabase) SetMaxUpdatesPerHourCallCount() int {
+	fake.setMaxUpdatesPerHourMutex.RLock()
+	defer fake.setMaxUpdatesPerHourMutex.RUnlock()
+	return len(fake.setMaxUpdatesPerHourArgsForCall)
+}
+
+func (fake *FakeDatabase) SetMaxUpdatesPerHourArgsForCall(i int) int {
+	fake.setMaxUpdatesPerHourMutex.RLock()
+	defer fake.setMaxUpdatesPerHourMutex.RUnlock()
+	return fake.setMaxUpdatesPerHourArgsForCall[i].arg1
+}
+
+func (fake *FakeDatabase) SetMaxUpdatesPerHourReturns(result1 error) {
+	fake.SetMaxUpdatesPerHourStub = nil
+	fake.setMaxUpdatesPerHourReturns = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeDatabase) KillActiveConnections() error {
 	fake.killActiveConnectionsMutex.Lock()
 	fake.killActiveConnectionsArgsForCall = append(fake.killActiveConnectionsArgsForCall, struct{}{})
@@ -141,10 +471,30 @@ func (fake *FakeDatabase) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.nameMutex.RLock()
 	defer fake.nameMutex.RUnlock()
+	fake.userMutex.RLock()
+	defer fake.userMutex.RUnlock()
+	fake.hostMutex.RLock()
+	defer fake.hostMutex.RUnlock()
+	fake.roleMutex.RLock()
+	defer fake.roleMutex.RUnlock()
 	fake.grantPrivilegesMutex.RLock()
 	defer fake.grantPrivilegesMutex.RUnlock()
 	fake.revokePrivilegesMutex.RLock()
 	defer fake.revokePrivilegesMutex.RUnlock()
+	fake.grantCreatePrivilegeMutex.RLock()
+	defer fake.grantCreatePrivilegeMutex.RUnlock()
+	fake.revokeCreatePrivilegeMutex.RLock()
+	defer fake.revokeCreatePrivilegeMutex.RUnlock()
+	fake.lockAccountMutex.RLock()
+	defer fake.lockAccountMutex.RUnlock()
+	fake.unlockAccountMutex.RLock()
+	defer fake.unlockAccountMutex.RUnlock()
+	fake.setMaxUserConnectionsMutex.RLock()
+	defer fake.setMaxUserConnectionsMutex.RUnlock()
+	fake.restoreMaxUserConnectionsMutex.RLock()
+	defer fake.restoreMaxUserConnectionsMutex.RUnlock()
+	fake.setMaxUpdatesPerHourMutex.RLock()
+	defer fake.setMaxUpdatesPerHourMutex.RUnlock()
 	fake.killActiveConnectionsMutex.RLock()
 	defer fake.killActiveConnectionsMutex.RUnlock()
 	return fake.invocations
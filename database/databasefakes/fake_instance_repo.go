@@ -0,0 +1,148 @@
+// This file was generated by counterfeiter
+package databasefakes
+
+import (
+	"sync"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+)
+
+type FakeInstanceRepo struct {
+	FindStub        func(string) (database.Instance, error)
+	findMutex       sync.RWMutex
+	findArgsForCall []struct {
+		arg1 string
+	}
+	findReturns struct {
+		result1 database.Instance
+		result2 error
+	}
+	AllStub        func() ([]database.Instance, error)
+	allMutex       sync.RWMutex
+	allArgsForCall []struct{}
+	allReturns     struct {
+		result1 []database.Instance
+		result2 error
+	}
+	CountStub        func() (int, error)
+	countMutex       sync.RWMutex
+	countArgsForCall []struct{}
+	countReturns     struct {
+		result1 int
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeInstanceRepo) Find(arg1 string) (database.Instance, error) {
+	fake.findMutex.Lock()
+	fake.findArgsForCall = append(fake.findArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("Find", []interface{}{arg1})
+	fake.findMutex.Unlock()
+	if fake.FindStub != nil {
+		return fake.FindStub(arg1)
+	} else {
+		return fake.findReturns.result1, fake.findReturns.result2
+	}
+}
+
+func (fake *FakeInstanceRepo) FindCallCount() int {
+	fake.findMutex.RLock()
+	defer fake.findMutex.RUnlock()
+	return len(fake.findArgsForCall)
+}
+
+func (fake *FakeInstanceRepo) FindArgsForCall(i int) string {
+	fake.findMutex.RLock()
+	defer fake.findMutex.RUnlock()
+	return fake.findArgsForCall[i].arg1
+}
+
+func (fake *FakeInstanceRepo) FindReturns(result1 database.Instance, result2 error) {
+	fake.FindStub = nil
+	fake.findReturns = struct {
+		result1 database.Instance
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeInstanceRepo) All() ([]database.Instance, error) {
+	fake.allMutex.Lock()
+	fake.allArgsForCall = append(fake.allArgsForCall, struct{}{})
+	fake.recordInvocation("All", []interface{}{})
+	fake.allMutex.Unlock()
+	if fake.AllStub != nil {
+		return fake.AllStub()
+	} else {
+		return fake.allReturns.result1, fake.allReturns.result2
+	}
+}
+
+func (fake *FakeInstanceRepo) AllCallCount() int {
+	fake.allMutex.RLock()
+	defer fake.allMutex.RUnlock()
+	return len(fake.allArgsForCall)
+}
+
+func (fake *FakeInstanceRepo) AllReturns(result1 []database.Instance, result2 error) {
+	fake.AllStub = nil
+	fake.allReturns = struct {
+		result1 []database.Instance
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeInstanceRepo) Count() (int, error) {
+	fake.countMutex.Lock()
+	fake.countArgsForCall = append(fake.countArgsForCall, struct{}{})
+	fake.recordInvocation("Count", []interface{}{})
+	fake.countMutex.Unlock()
+	if fake.CountStub != nil {
+		return fake.CountStub()
+	} else {
+		return fake.countReturns.result1, fake.countReturns.result2
+	}
+}
+
+func (fake *FakeInstanceRepo) CountCallCount() int {
+	fake.countMutex.RLock()
+	defer fake.countMutex.RUnlock()
+	return len(fake.countArgsForCall)
+}
+
+func (fake *FakeInstanceRepo) CountReturns(result1 int, result2 error) {
+	fake.CountStub = nil
+	fake.countReturns = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeInstanceRepo) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.findMutex.RLock()
+	defer fake.findMutex.RUnlock()
+	fake.allMutex.RLock()
+	defer fake.allMutex.RUnlock()
+	fake.countMutex.RLock()
+	defer fake.countMutex.RUnlock()
+	return fake.invocations
+}
+
+func (fake *FakeInstanceRepo) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ database.InstanceRepo = new(FakeInstanceRepo)
@@ -0,0 +1,298 @@
+// This file was generated by counterfeiter
+package databasefakes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+)
+
+type FakeReclaimRepo struct {
+	SetupStub        func() error
+	setupMutex       sync.RWMutex
+	setupArgsForCall []struct{}
+	setupReturns     struct {
+		result1 error
+	}
+	FragmentedStub        func(string, float64, float64) ([]database.TableSize, error)
+	fragmentedMutex       sync.RWMutex
+	fragmentedArgsForCall []struct {
+		arg1 string
+		arg2 float64
+		arg3 float64
+	}
+	fragmentedReturns struct {
+		result1 []database.TableSize
+		result2 error
+	}
+	RebuiltStub        func(string, time.Time) (map[string]bool, error)
+	rebuiltMutex       sync.RWMutex
+	rebuiltArgsForCall []struct {
+		arg1 string
+		arg2 time.Time
+	}
+	rebuiltReturns struct {
+		result1 map[string]bool
+		result2 error
+	}
+	RebuildStub        func(string, string) error
+	rebuildMutex       sync.RWMutex
+	rebuildArgsForCall []struct {
+		arg1 string
+		arg2 string
+	}
+	rebuildReturns struct {
+		result1 error
+	}
+	MeasureStub        func(string, string) (database.TableSize, error)
+	measureMutex       sync.RWMutex
+	measureArgsForCall []struct {
+		arg1 string
+		arg2 string
+	}
+	measureReturns struct {
+		result1 database.TableSize
+		result2 error
+	}
+	RecordStub        func(database.Reclaim) error
+	recordMutex       sync.RWMutex
+	recordArgsForCall []struct {
+		arg1 database.Reclaim
+	}
+	recordReturns struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeReclaimRepo) Setup() error {
+	fake.setupMutex.Lock()
+	fake.setupArgsForCall = append(fake.setupArgsForCall, struct{}{})
+	fake.recordInvocation("Setup", []interface{}{})
+	fake.setupMutex.Unlock()
+	if fake.SetupStub != nil {
+		return fake.SetupStub()
+	} else {
+		return fake.setupReturns.result1
+	}
+}
+
+func (fake *FakeReclaimRepo) SetupCallCount() int {
+	fake.setupMutex.RLock()
+	defer fake.setupMutex.RUnlock()
+	return len(fake.setupArgsForCall)
+}
+
+func (fake *FakeReclaimRepo) SetupReturns(result1 error) {
+	fake.SetupStub = nil
+	fake.setupReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeReclaimRepo) Fragmented(arg1 string, arg2 float64, arg3 float64) ([]database.TableSize, error) {
+	fake.fragmentedMutex.Lock()
+	fake.fragmentedArgsForCall = append(fake.fragmentedArgsForCall, struct {
+		arg1 string
+		arg2 float64
+		arg3 float64
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("Fragmented", []interface{}{arg1, arg2, arg3})
+	fake.fragmentedMutex.Unlock()
+	if fake.FragmentedStub != nil {
+		return fake.FragmentedStub(arg1, arg2, arg3)
+	} else {
+		return fake.fragmentedReturns.result1, fake.fragmentedReturns.result2
+	}
+}
+
+func (fake *FakeReclaimRepo) FragmentedCallCount() int {
+	fake.fragmentedMutex.RLock()
+	defer fake.fragmentedMutex.RUnlock()
+	return len(fake.fragmentedArgsForCall)
+}
+
+func (fake *FakeReclaimRepo) FragmentedArgsForCall(i int) (string, float64, float64) {
+	fake.fragmentedMutex.RLock()
+	defer fake.fragmentedMutex.RUnlock()
+	return fake.fragmentedArgsForCall[i].arg1, fake.fragmentedArgsForCall[i].arg2, fake.fragmentedArgsForCall[i].arg3
+}
+
+func (fake *FakeReclaimRepo) FragmentedReturns(result1 []database.TableSize, result2 error) {
+	fake.FragmentedStub = nil
+	fake.fragmentedReturns = struct {
+		result1 []database.TableSize
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeReclaimRepo) Rebuilt(arg1 string, arg2 time.Time) (map[string]bool, error) {
+	fake.rebuiltMutex.Lock()
+	fake.rebuiltArgsForCall = append(fake.rebuiltArgsForCall, struct {
+		arg1 string
+		arg2 time.Time
+	}{arg1, arg2})
+	fake.recordInvocation("Rebuilt", []interface{}{arg1, arg2})
+	fake.rebuiltMutex.Unlock()
+	if fake.RebuiltStub != nil {
+		return fake.RebuiltStub(arg1, arg2)
+	} else {
+		return fake.rebuiltReturns.result1, fake.rebuiltReturns.result2
+	}
+}
+
+func (fake *FakeReclaimRepo) RebuiltCallCount() int {
+	fake.rebuiltMutex.RLock()
+	defer fake.rebuiltMutex.RUnlock()
+	return len(fake.rebuiltArgsForCall)
+}
+
+func (fake *FakeReclaimRepo) RebuiltArgsForCall(i int) (string, time.Time) {
+	fake.rebuiltMutex.RLock()
+	defer fake.rebuiltMutex.RUnlock()
+	return fake.rebuiltArgsForCall[i].arg1, fake.rebuiltArgsForCall[i].arg2
+}
+
+func (fake *FakeReclaimRepo) RebuiltReturns(result1 map[string]bool, result2 error) {
+	fake.RebuiltStub = nil
+	fake.rebuiltReturns = struct {
+		result1 map[string]bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeReclaimRepo) Rebuild(arg1 string, arg2 string) error {
+	fake.rebuildMutex.Lock()
+	fake.rebuildArgsForCall = append(fake.rebuildArgsForCall, struct {
+		arg1 string
+		arg2 string
+	}{arg1, arg2})
+	fake.recordInvocation("Rebuild", []interface{}{arg1, arg2})
+	fake.rebuildMutex.Unlock()
+	if fake.RebuildStub != nil {
+		return fake.RebuildStub(arg1, arg2)
+	} else {
+		return fake.rebuildReturns.result1
+	}
+}
+
+func (fake *FakeReclaimRepo) RebuildCallCount() int {
+	fake.rebuildMutex.RLock()
+	defer fake.rebuildMutex.RUnlock()
+	return len(fake.rebuildArgsForCall)
+}
+
+func (fake *FakeReclaimRepo) RebuildArgsForCall(i int) (string, string) {
+	fake.rebuildMutex.RLock()
+	defer fake.rebuildMutex.RUnlock()
+	return fake.rebuildArgsForCall[i].arg1, fake.rebuildArgsForCall[i].arg2
+}
+
+func (fake *FakeReclaimRepo) RebuildReturns(result1 error) {
+	fake.RebuildStub = nil
+	fake.rebuildReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeReclaimRepo) Measure(arg1 string, arg2 string) (database.TableSize, error) {
+	fake.measureMutex.Lock()
+	fake.measureArgsForCall = append(fake.measureArgsForCall, struct {
+		arg1 string
+		arg2 string
+	}{arg1, arg2})
+	fake.recordInvocation("Measure", []interface{}{arg1, arg2})
+	fake.measureMutex.Unlock()
+	if fake.MeasureStub != nil {
+		return fake.MeasureStub(arg1, arg2)
+	} else {
+		return fake.measureReturns.result1, fake.measureReturns.result2
+	}
+}
+
+func (fake *FakeReclaimRepo) MeasureCallCount() int {
+	fake.measureMutex.RLock()
+	defer fake.measureMutex.RUnlock()
+	return len(fake.measureArgsForCall)
+}
+
+func (fake *FakeReclaimRepo) MeasureArgsForCall(i int) (string, string) {
+	fake.measureMutex.RLock()
+	defer fake.measureMutex.RUnlock()
+	return fake.measureArgsForCall[i].arg1, fake.measureArgsForCall[i].arg2
+}
+
+func (fake *FakeReclaimRepo) MeasureReturns(result1 database.TableSize, result2 error) {
+	fake.MeasureStub = nil
+	fake.measureReturns = struct {
+		result1 database.TableSize
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeReclaimRepo) Record(arg1 database.Reclaim) error {
+	fake.recordMutex.Lock()
+	fake.recordArgsForCall = append(fake.recordArgsForCall, struct {
+		arg1 database.Reclaim
+	}{arg1})
+	fake.recordInvocation("Record", []interface{}{arg1})
+	fake.recordMutex.Unlock()
+	if fake.RecordStub != nil {
+		return fake.RecordStub(arg1)
+	} else {
+		return fake.recordReturns.result1
+	}
+}
+
+func (fake *FakeReclaimRepo) RecordCallCount() int {
+	fake.recordMutex.RLock()
+	defer fake.recordMutex.RUnlock()
+	return len(fake.recordArgsForCall)
+}
+
+func (fake *FakeReclaimRepo) RecordArgsForCall(i int) database.Reclaim {
+	fake.recordMutex.RLock()
+	defer fake.recordMutex.RUnlock()
+	return fake.recordArgsForCall[i].arg1
+}
+
+func (fake *FakeReclaimRepo) RecordReturns(result1 error) {
+	fake.RecordStub = nil
+	fake.recordReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeReclaimRepo) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.setupMutex.RLock()
+	defer fake.setupMutex.RUnlock()
+	fake.fragmentedMutex.RLock()
+	defer fake.fragmentedMutex.RUnlock()
+	fake.rebuiltMutex.RLock()
+	defer fake.rebuiltMutex.RUnlock()
+	fake.rebuildMutex.RLock()
+	defer fake.rebuildMutex.RUnlock()
+	fake.measureMutex.RLock()
+	defer fake.measureMutex.RUnlock()
+	fake.recordMutex.RLock()
+	defer fake.recordMutex.RUnlock()
+	return fake.invocations
+}
+
+func (fake *FakeReclaimRepo) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ database.ReclaimRepo = new(FakeReclaimRepo)
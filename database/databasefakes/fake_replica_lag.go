@@ -0,0 +1,69 @@
+// This file was generated by counterfeiter
+package databasefakes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+)
+
+type FakeReplicaLag struct {
+	LagStub        func() (time.Duration, error)
+	lagMutex       sync.RWMutex
+	lagArgsForCall []struct{}
+	lagReturns     struct {
+		result1 time.Duration
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeReplicaLag) Lag() (time.Duration, error) {
+	fake.lagMutex.Lock()
+	fake.lagArgsForCall = append(fake.lagArgsForCall, struct{}{})
+	fake.recordInvocation("Lag", []interface{}{})
+	fake.lagMutex.Unlock()
+	if fake.LagStub != nil {
+		return fake.LagStub()
+	} else {
+		return fake.lagReturns.result1, fake.lagReturns.result2
+	}
+}
+
+func (fake *FakeReplicaLag) LagCallCount() int {
+	fake.lagMutex.RLock()
+	defer fake.lagMutex.RUnlock()
+	return len(fake.lagArgsForCall)
+}
+
+func (fake *FakeReplicaLag) LagReturns(result1 time.Duration, result2 error) {
+	fake.LagStub = nil
+	fake.lagReturns = struct {
+		result1 time.Duration
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeReplicaLag) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.lagMutex.RLock()
+	defer fake.lagMutex.RUnlock()
+	return fake.invocations
+}
+
+func (fake *FakeReplicaLag) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ database.ReplicaLag = new(FakeReplicaLag)
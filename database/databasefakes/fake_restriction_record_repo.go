@@ -0,0 +1,191 @@
+// This file was generated by counterfeiter
+package databasefakes
+
+import (
+	"sync"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+)
+
+type FakeRestrictionRecordRepo struct {
+	SetupStub        func() error
+	setupMutex       sync.RWMutex
+	setupArgsForCall []struct{}
+	setupReturns     struct {
+		result1 error
+	}
+	RecordStub        func(database.Database, string) error
+	recordMutex       sync.RWMutex
+	recordArgsForCall []struct {
+		arg1 database.Database
+		arg2 string
+	}
+	recordReturns struct {
+		result1 error
+	}
+	RemoveStub        func(database.Database, string) error
+	removeMutex       sync.RWMutex
+	removeArgsForCall []struct {
+		arg1 database.Database
+		arg2 string
+	}
+	removeReturns struct {
+		result1 error
+	}
+	RestrictedStub        func() (map[string]bool, error)
+	restrictedMutex       sync.RWMutex
+	restrictedArgsForCall []struct{}
+	restrictedReturns     struct {
+		result1 map[string]bool
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeRestrictionRecordRepo) Setup() error {
+	fake.setupMutex.Lock()
+	fake.setupArgsForCall = append(fake.setupArgsForCall, struct{}{})
+	fake.recordInvocation("Setup", []interface{}{})
+	fake.setupMutex.Unlock()
+	if fake.SetupStub != nil {
+		return fake.SetupStub()
+	} else {
+		return fake.setupReturns.result1
+	}
+}
+
+func (fake *FakeRestrictionRecordRepo) SetupCallCount() int {
+	fake.setupMutex.RLock()
+	defer fake.setupMutex.RUnlock()
+	return len(fake.setupArgsForCall)
+}
+
+func (fake *FakeRestrictionRecordRepo) SetupReturns(result1 error) {
+	fake.SetupStub = nil
+	fake.setupReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRestrictionRecordRepo) Record(arg1 database.Database, arg2 string) error {
+	fake.recordMutex.Lock()
+	fake.recordArgsForCall = append(fake.recordArgsForCall, struct {
+		arg1 database.Database
+		arg2 string
+	}{arg1, arg2})
+	fake.recordInvocation("Record", []interface{}{arg1, arg2})
+	fake.recordMutex.Unlock()
+	if fake.RecordStub != nil {
+		return fake.RecordStub(arg1, arg2)
+	} else {
+		return fake.recordReturns.result1
+	}
+}
+
+func (fake *FakeRestrictionRecordRepo) RecordCallCount() int {
+	fake.recordMutex.RLock()
+	defer fake.recordMutex.RUnlock()
+	return len(fake.recordArgsForCall)
+}
+
+func (fake *FakeRestrictionRecordRepo) RecordArgsForCall(i int) (database.Database, string) {
+	fake.recordMutex.RLock()
+	defer fake.recordMutex.RUnlock()
+	return fake.recordArgsForCall[i].arg1, fake.recordArgsForCall[i].arg2
+}
+
+func (fake *FakeRestrictionRecordRepo) RecordReturns(result1 error) {
+	fake.RecordStub = nil
+	fake.recordReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRestrictionRecordRepo) Remove(arg1 database.Database, arg2 string) error {
+	fake.removeMutex.Lock()
+	fake.removeArgsForCall = append(fake.removeArgsForCall, struct {
+		arg1 database.Database
+		arg2 string
+	}{arg1, arg2})
+	fake.recordInvocation("Remove", []interface{}{arg1, arg2})
+	fake.removeMutex.Unlock()
+	if fake.RemoveStub != nil {
+		return fake.RemoveStub(arg1, arg2)
+	} else {
+		return fake.removeReturns.result1
+	}
+}
+
+func (fake *FakeRestrictionRecordRepo) RemoveCallCount() int {
+	fake.removeMutex.RLock()
+	defer fake.removeMutex.RUnlock()
+	return len(fake.removeArgsForCall)
+}
+
+func (fake *FakeRestrictionRecordRepo) RemoveArgsForCall(i int) (database.Database, string) {
+	fake.removeMutex.RLock()
+	defer fake.removeMutex.RUnlock()
+	return fake.removeArgsForCall[i].arg1, fake.removeArgsForCall[i].arg2
+}
+
+func (fake *FakeRestrictionRecordRepo) RemoveReturns(result1 error) {
+	fake.RemoveStub = nil
+	fake.removeReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRestrictionRecordRepo) Restricted() (map[string]bool, error) {
+	fake.restrictedMutex.Lock()
+	fake.restrictedArgsForCall = append(fake.restrictedArgsForCall, struct{}{})
+	fake.recordInvocation("Restricted", []interface{}{})
+	fake.restrictedMutex.Unlock()
+	if fake.RestrictedStub != nil {
+		return fake.RestrictedStub()
+	} else {
+		return fake.restrictedReturns.result1, fake.restrictedReturns.result2
+	}
+}
+
+func (fake *FakeRestrictionRecordRepo) RestrictedCallCount() int {
+	fake.restrictedMutex.RLock()
+	defer fake.restrictedMutex.RUnlock()
+	return len(fake.restrictedArgsForCall)
+}
+
+func (fake *FakeRestrictionRecordRepo) RestrictedReturns(result1 map[string]bool, result2 error) {
+	fake.RestrictedStub = nil
+	fake.restrictedReturns = struct {
+		result1 map[string]bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRestrictionRecordRepo) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.setupMutex.RLock()
+	defer fake.setupMutex.RUnlock()
+	fake.recordMutex.RLock()
+	defer fake.recordMutex.RUnlock()
+	fake.removeMutex.RLock()
+	defer fake.removeMutex.RUnlock()
+	fake.restrictedMutex.RLock()
+	defer fake.restrictedMutex.RUnlock()
+	return fake.invocations
+}
+
+func (fake *FakeRestrictionRecordRepo) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ database.RestrictionRecordRepo = new(FakeRestrictionRecordRepo)
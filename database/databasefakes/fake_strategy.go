@@ -0,0 +1,152 @@
+// This file was generated by counterfeiter
+package databasefakes
+
+import (
+	"sync"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+)
+
+type FakeStrategy struct {
+	NameStub        func() string
+	nameMutex       sync.RWMutex
+	nameArgsForCall []struct{}
+	nameReturns     struct {
+		result1 string
+	}
+	ApplyStub        func(database.Database) error
+	applyMutex       sync.RWMutex
+	applyArgsForCall []struct {
+		arg1 database.Database
+	}
+	applyReturns struct {
+		result1 error
+	}
+	ReverseStub        func(database.Database) error
+	reverseMutex       sync.RWMutex
+	reverseArgsForCall []struct {
+		arg1 database.Database
+	}
+	reverseReturns struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeStrategy) Name() string {
+	fake.nameMutex.Lock()
+	fake.nameArgsForCall = append(fake.nameArgsForCall, struct{}{})
+	fake.recordInvocation("Name", []interface{}{})
+	fake.nameMutex.Unlock()
+	if fake.NameStub != nil {
+		return fake.NameStub()
+	} else {
+		return fake.nameReturns.result1
+	}
+}
+
+func (fake *FakeStrategy) NameCallCount() int {
+	fake.nameMutex.RLock()
+	defer fake.nameMutex.RUnlock()
+	return len(fake.nameArgsForCall)
+}
+
+func (fake *FakeStrategy) NameReturns(result1 string) {
+	fake.NameStub = nil
+	fake.nameReturns = struct {
+		result1 string
+	}{result1}
+}
+
+func (fake *FakeStrategy) Apply(arg1 database.Database) error {
+	fake.applyMutex.Lock()
+	fake.applyArgsForCall = append(fake.applyArgsForCall, struct {
+		arg1 database.Database
+	}{arg1})
+	fake.recordInvocation("Apply", []interface{}{arg1})
+	fake.applyMutex.Unlock()
+	if fake.ApplyStub != nil {
+		return fake.ApplyStub(arg1)
+	} else {
+		return fake.applyReturns.result1
+	}
+}
+
+func (fake *FakeStrategy) ApplyCallCount() int {
+	fake.applyMutex.RLock()
+	defer fake.applyMutex.RUnlock()
+	return len(fake.applyArgsForCall)
+}
+
+func (fake *FakeStrategy) ApplyArgsForCall(i int) database.Database {
+	fake.applyMutex.RLock()
+	defer fake.applyMutex.RUnlock()
+	return fake.applyArgsForCall[i].arg1
+}
+
+func (fake *FakeStrategy) ApplyReturns(result1 error) {
+	fake.ApplyStub = nil
+	fake.applyReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStrategy) Reverse(arg1 database.Database) error {
+	fake.reverseMutex.Lock()
+	fake.reverseArgsForCall = append(fake.reverseArgsForCall, struct {
+		arg1 database.Database
+	}{arg1})
+	fake.recordInvocation("Reverse", []interface{}{arg1})
+	fake.reverseMutex.Unlock()
+	if fake.ReverseStub != nil {
+		return fake.ReverseStub(arg1)
+	} else {
+		return fake.reverseReturns.result1
+	}
+}
+
+func (fake *FakeStrategy) ReverseCallCount() int {
+	fake.reverseMutex.RLock()
+	defer fake.reverseMutex.RUnlock()
+	return len(fake.reverseArgsForCall)
+}
+
+func (fake *FakeStrategy) ReverseArgsForCall(i int) database.Database {
+	fake.reverseMutex.RLock()
+	defer fake.reverseMutex.RUnlock()
+	return fake.reverseArgsForCall[i].arg1
+}
+
+func (fake *FakeStrategy) ReverseReturns(result1 error) {
+	fake.ReverseStub = nil
+	fake.reverseReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStrategy) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.nameMutex.RLock()
+	defer fake.nameMutex.RUnlock()
+	fake.applyMutex.RLock()
+	defer fake.applyMutex.RUnlock()
+	fake.reverseMutex.RLock()
+	defer fake.reverseMutex.RUnlock()
+	return fake.invocations
+}
+
+func (fake *FakeStrategy) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ database.Strategy = new(FakeStrategy)
@@ -0,0 +1,80 @@
+// This file was generated by counterfeiter
+package databasefakes
+
+import (
+	"sync"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+)
+
+type FakeTableSizeRepo struct {
+	LargestStub        func(string, int) ([]database.TableSize, error)
+	largestMutex       sync.RWMutex
+	largestArgsForCall []struct {
+		arg1 string
+		arg2 int
+	}
+	largestReturns struct {
+		result1 []database.TableSize
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeTableSizeRepo) Largest(arg1 string, arg2 int) ([]database.TableSize, error) {
+	fake.largestMutex.Lock()
+	fake.largestArgsForCall = append(fake.largestArgsForCall, struct {
+		arg1 string
+		arg2 int
+	}{arg1, arg2})
+	fake.recordInvocation("Largest", []interface{}{arg1, arg2})
+	fake.largestMutex.Unlock()
+	if fake.LargestStub != nil {
+		return fake.LargestStub(arg1, arg2)
+	} else {
+		return fake.largestReturns.result1, fake.largestReturns.result2
+	}
+}
+
+func (fake *FakeTableSizeRepo) LargestCallCount() int {
+	fake.largestMutex.RLock()
+	defer fake.largestMutex.RUnlock()
+	return len(fake.largestArgsForCall)
+}
+
+func (fake *FakeTableSizeRepo) LargestArgsForCall(i int) (string, int) {
+	fake.largestMutex.RLock()
+	defer fake.largestMutex.RUnlock()
+	return fake.largestArgsForCall[i].arg1, fake.largestArgsForCall[i].arg2
+}
+
+func (fake *FakeTableSizeRepo) LargestReturns(result1 []database.TableSize, result2 error) {
+	fake.LargestStub = nil
+	fake.largestReturns = struct {
+		result1 []database.TableSize
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeTableSizeRepo) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.largestMutex.RLock()
+	defer fake.largestMutex.RUnlock()
+	return fake.invocations
+}
+
+func (fake *FakeTableSizeRepo) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ database.TableSizeRepo = new(FakeTableSizeRepo)
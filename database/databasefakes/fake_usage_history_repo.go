@@ -0,0 +1,188 @@
+// This file was generated by counterfeiter
+package databasefakes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+)
+
+type FakeUsageHistoryRepo struct {
+	SetupStub        func() error
+	setupMutex       sync.RWMutex
+	setupArgsForCall []struct{}
+	setupReturns     struct {
+		result1 error
+	}
+	RecordStub        func() error
+	recordMutex       sync.RWMutex
+	recordArgsForCall []struct{}
+	recordReturns     struct {
+		result1 error
+	}
+	PruneStub        func(time.Duration) error
+	pruneMutex       sync.RWMutex
+	pruneArgsForCall []struct {
+		arg1 time.Duration
+	}
+	pruneReturns struct {
+		result1 error
+	}
+	GrowthStub        func(time.Duration) ([]database.Growth, error)
+	growthMutex       sync.RWMutex
+	growthArgsForCall []struct {
+		arg1 time.Duration
+	}
+	growthReturns struct {
+		result1 []database.Growth
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeUsageHistoryRepo) Setup() error {
+	fake.setupMutex.Lock()
+	fake.setupArgsForCall = append(fake.setupArgsForCall, struct{}{})
+	fake.recordInvocation("Setup", []interface{}{})
+	fake.setupMutex.Unlock()
+	if fake.SetupStub != nil {
+		return fake.SetupStub()
+	} else {
+		return fake.setupReturns.result1
+	}
+}
+
+func (fake *FakeUsageHistoryRepo) SetupCallCount() int {
+	fake.setupMutex.RLock()
+	defer fake.setupMutex.RUnlock()
+	return len(fake.setupArgsForCall)
+}
+
+func (fake *FakeUsageHistoryRepo) SetupReturns(result1 error) {
+	fake.SetupStub = nil
+	fake.setupReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeUsageHistoryRepo) Record() error {
+	fake.recordMutex.Lock()
+	fake.recordArgsForCall = append(fake.recordArgsForCall, struct{}{})
+	fake.recordInvocation("Record", []interface{}{})
+	fake.recordMutex.Unlock()
+	if fake.RecordStub != nil {
+		return fake.RecordStub()
+	} else {
+		return fake.recordReturns.result1
+	}
+}
+
+func (fake *FakeUsageHistoryRepo) RecordCallCount() int {
+	fake.recordMutex.RLock()
+	defer fake.recordMutex.RUnlock()
+	return len(fake.recordArgsForCall)
+}
+
+func (fake *FakeUsageHistoryRepo) RecordReturns(result1 error) {
+	fake.RecordStub = nil
+	fake.recordReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeUsageHistoryRepo) Prune(arg1 time.Duration) error {
+	fake.pruneMutex.Lock()
+	fake.pruneArgsForCall = append(fake.pruneArgsForCall, struct {
+		arg1 time.Duration
+	}{arg1})
+	fake.recordInvocation("Prune", []interface{}{arg1})
+	fake.pruneMutex.Unlock()
+	if fake.PruneStub != nil {
+		return fake.PruneStub(arg1)
+	} else {
+		return fake.pruneReturns.result1
+	}
+}
+
+func (fake *FakeUsageHistoryRepo) PruneCallCount() int {
+	fake.pruneMutex.RLock()
+	defer fake.pruneMutex.RUnlock()
+	return len(fake.pruneArgsForCall)
+}
+
+func (fake *FakeUsageHistoryRepo) PruneArgsForCall(i int) time.Duration {
+	fake.pruneMutex.RLock()
+	defer fake.pruneMutex.RUnlock()
+	return fake.pruneArgsForCall[i].arg1
+}
+
+func (fake *FakeUsageHistoryRepo) PruneReturns(result1 error) {
+	fake.PruneStub = nil
+	fake.pruneReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeUsageHistoryRepo) Growth(arg1 time.Duration) ([]database.Growth, error) {
+	fake.growthMutex.Lock()
+	fake.growthArgsForCall = append(fake.growthArgsForCall, struct {
+		arg1 time.Duration
+	}{arg1})
+	fake.recordInvocation("Growth", []interface{}{arg1})
+	fake.growthMutex.Unlock()
+	if fake.GrowthStub != nil {
+		return fake.GrowthStub(arg1)
+	} else {
+		return fake.growthReturns.result1, fake.growthReturns.result2
+	}
+}
+
+func (fake *FakeUsageHistoryRepo) GrowthCallCount() int {
+	fake.growthMutex.RLock()
+	defer fake.growthMutex.RUnlock()
+	return len(fake.growthArgsForCall)
+}
+
+func (fake *FakeUsageHistoryRepo) GrowthArgsForCall(i int) time.Duration {
+	fake.growthMutex.RLock()
+	defer fake.growthMutex.RUnlock()
+	return fake.growthArgsForCall[i].arg1
+}
+
+func (fake *FakeUsageHistoryRepo) GrowthReturns(result1 []database.Growth, result2 error) {
+	fake.GrowthStub = nil
+	fake.growthReturns = struct {
+		result1 []database.Growth
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeUsageHistoryRepo) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.setupMutex.RLock()
+	defer fake.setupMutex.RUnlock()
+	fake.recordMutex.RLock()
+	defer fake.recordMutex.RUnlock()
+	fake.pruneMutex.RLock()
+	defer fake.pruneMutex.RUnlock()
+	fake.growthMutex.RLock()
+	defer fake.growthMutex.RUnlock()
+	return fake.invocations
+}
+
+func (fake *FakeUsageHistoryRepo) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ database.UsageHistoryRepo = new(FakeUsageHistoryRepo)
@@ -0,0 +1,373 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// Grantees are found as by the violator query, through roles and partial
+// revokes, one privilege at a time so that each is listed. Ignored users are
+// included, so that support can see them. The flags read the same privileges
+// and records as the reformer and object quota queries.
+const granteesQueryPattern = `
+SELECT dbs.user, dbs.host, dbs.privileges, dbs.read_only, %[5]s AS restricted,
+	EXISTS (
+		SELECT 1 FROM information_schema.schema_privileges
+		WHERE %[6]s = dbs.name AND %[7]s = dbs.user AND %[8]s = dbs.host
+		AND privilege_type IN ('SELECT', 'INSERT', 'UPDATE')
+		GROUP BY table_schema
+		HAVING count(*) != 3
+	) AS reformable,
+	EXISTS (
+		SELECT 1 FROM %[9]s.quota_enforcer_restrictions AS restrictions
+		WHERE %[10]s = dbs.name AND %[11]s = dbs.user AND %[12]s = dbs.host
+		AND restrictions.restriction = ?%[13]s
+	) AS recorded,
+	EXISTS (
+		SELECT 1 FROM (%[14]s
+		) AS creators
+		WHERE creators.name = dbs.name AND creators.user = dbs.user AND creators.host = dbs.host
+	) AS holds_create,
+	EXISTS (
+		SELECT 1 FROM %[9]s.quota_enforcer_restrictions AS restrictions
+		JOIN information_schema.schema_privileges AS grants
+			ON %[15]s = restrictions.db_name
+			AND grants.grantee = CONCAT("'", restrictions.user, "'@'", restrictions.host, "'")
+		WHERE %[10]s = dbs.name AND %[11]s = dbs.user AND %[12]s = dbs.host
+		AND restrictions.restriction = ?
+		AND grants.privilege_type = 'INSERT'
+	) AS create_restricted
+FROM (
+	SELECT privileged.name, privileged.user, privileged.host,
+		GROUP_CONCAT(DISTINCT privileged.privilege_type ORDER BY privileged.privilege_type) AS privileges,
+		MAX(read_only_users.id IS NOT NULL) AS read_only
+	FROM (%[1]s
+	) AS privileged
+	LEFT JOIN %[2]s AS read_only_users
+		ON read_only_users.grantee = %[3]s
+	WHERE privileged.name = ?
+	GROUP BY privileged.name, privileged.user, privileged.host
+) AS dbs
+LEFT JOIN %[4]s AS accounts ON dbs.user = %[16]s AND dbs.host = %[17]s
+ORDER BY dbs.user, dbs.host
+`
+
+const privilegeGranteesPattern = `
+		SELECT '%[1]s' AS privilege_type, privilege_grantees.* FROM (%[2]s
+		) AS privilege_grantees`
+
+// objectQuotaQueryPattern reads objectQuotaExceededPattern for one instance.
+const objectQuotaQueryPattern = `
+SELECT %[3]s AS exceeded
+FROM (SELECT ? AS name) AS dbs
+JOIN %[1]s AS instances ON dbs.name = %[2]s
+`
+
+const tableSizesQuery = `
+SELECT table_name, ROUND(COALESCE(data_length + index_length, 0) / 1024 / 1024, 1) AS size_mb
+FROM information_schema.tables
+WHERE table_schema = ?
+ORDER BY size_mb DESC, table_name
+`
+
+// Decisions the enforcer would make for a grantee of an instance.
+const (
+	DecisionNone     = "none"
+	DecisionIgnored  = "ignored"
+	DecisionRestrict = "restrict"
+	DecisionLift     = "lift"
+)
+
+// The privileges a grantee is listed with.
+var diagnosedPrivileges = []string{"CREATE", "INSERT", "SELECT", "UPDATE"}
+
+// Grantee is a user with privileges on an instance database.
+type Grantee struct {
+	User       string
+	Host       string
+	Privileges []string
+	Ignored    bool
+	ReadOnly   bool
+	Decision   string
+	// ObjectQuotaDecision is about CREATE alone, as decided by the object
+	// quota enforcer, and empty if object quotas are not enforced.
+	ObjectQuotaDecision string
+}
+
+// granteeState is what the grantees query reads for a grantee besides its
+// privileges.
+type granteeState struct {
+	restricted       bool
+	reformable       bool
+	recorded         bool
+	holdsCreate      bool
+	createRestricted bool
+}
+
+// HasWrites is true if the grantee holds a privilege the violator query looks for.
+func (g Grantee) HasWrites() bool {
+	return g.hasAny("INSERT", "UPDATE", "CREATE")
+}
+
+func (g Grantee) hasAny(privileges ...string) bool {
+	for _, privilege := range privileges {
+		if g.has(privilege) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g Grantee) has(privilege string) bool {
+	for _, p := range g.Privileges {
+		if p == privilege {
+			return true
+		}
+	}
+	return false
+}
+
+// Diagnosis explains how the enforcer sees one instance database.
+type Diagnosis struct {
+	Instance            Instance
+	Strategy            string
+	ObjectQuotas        bool
+	ObjectQuotaExceeded bool
+	Grantees            []Grantee
+	Tables              []TableSize
+}
+
+type DiagnosisRepo interface {
+	Diagnose(dbName string) (Diagnosis, error)
+}
+
+type diagnosisRepo struct {
+	instanceRepo     InstanceRepo
+	granteesQuery    string
+	objectQuotaQuery string
+	ignoredUsers     []string
+	strategy         Strategy
+	byPrivileges     bool
+	objectQuotas     bool
+	db               *sql.DB
+	logger           lager.Logger
+}
+
+// NewDiagnosisRepo returns a repo deciding for each grantee as the violator
+// and reformer repos of the strategy would, reading the records kept in
+// recordsDBName, and as the object quota repos would if objectQuotas is set.
+func NewDiagnosisRepo(recordsDBName string, broker Broker, ignoredUsers []string, server Server, strategy Strategy, objectQuotas bool, db *sql.DB, logger lager.Logger) DiagnosisRepo {
+	condition := appliedCondition(strategy, server, recordsDBName)
+
+	restricted := "FALSE"
+	if condition != "" {
+		restricted = fmt.Sprintf("COALESCE(%s, FALSE)", condition)
+	}
+
+	// Only the restrictions of roles are reversed from the records when the
+	// privileges themselves are revoked, as in NewReformerRepo.
+	recordCondition := ""
+	if condition == "" {
+		recordCondition = "\n\t\tAND restrictions.role_user <> ''"
+	}
+
+	sources := make([]string, len(diagnosedPrivileges))
+	for i, privilege := range diagnosedPrivileges {
+		sources[i] = fmt.Sprintf(privilegeGranteesPattern, privilege, granteeSources(server, fmt.Sprintf("'%s'", privilege), true))
+	}
+
+	granteesQuery := fmt.Sprintf(
+		granteesQueryPattern,
+		strings.Join(sources, "\n\t\tUNION ALL"),
+		broker.readOnlyUsersTable(),
+		server.collate(`CONCAT("'", privileged.user, "'@'", privileged.host, "'")`),
+		server.accountsTable(),
+		restricted,
+		unescapeSchema("table_schema"),
+		server.collateBinary("SUBSTRING(grantee, 2, CHAR_LENGTH(grantee) - CHAR_LENGTH(SUBSTRING_INDEX(grantee, '@', -1)) - 3)"),
+		server.collateBinary(`TRIM(BOTH "'" FROM SUBSTRING_INDEX(grantee, '@', -1))`),
+		quoteIdentifier(recordsDBName),
+		server.collate("restrictions.db_name"),
+		server.collateBinary("restrictions.user"),
+		server.collateBinary("restrictions.host"),
+		recordCondition,
+		granteeSources(server, "'CREATE'", false),
+		unescapeSchema("grants.table_schema"),
+		server.collateBinary("accounts.User"),
+		server.collateBinary("accounts.Host"),
+	)
+
+	objectQuotaQuery := fmt.Sprintf(
+		objectQuotaQueryPattern,
+		broker.instancesTable("db_name", "max_tables", "max_routines"),
+		server.collate("instances.db_name"),
+		objectQuotaExceededPattern,
+	)
+
+	return &diagnosisRepo{
+		instanceRepo:     NewInstanceRepo(broker, server, db, logger),
+		granteesQuery:    granteesQuery,
+		objectQuotaQuery: objectQuotaQuery,
+		ignoredUsers:     ignoredUsers,
+		strategy:         strategy,
+		byPrivileges:     condition == "",
+		objectQuotas:     objectQuotas,
+		db:               db,
+		logger:           logger,
+	}
+}
+
+func (r diagnosisRepo) Diagnose(dbName string) (Diagnosis, error) {
+	r.logger.Debug(fmt.Sprintf("Executing 'diagnosis'.Diagnose for db '%s'", dbName))
+
+	diagnosis := Diagnosis{Strategy: r.strategy.Name(), ObjectQuotas: r.objectQuotas}
+
+	instance, err := r.instanceRepo.Find(dbName)
+	if err != nil {
+		return diagnosis, err
+	}
+	diagnosis.Instance = instance
+
+	if r.objectQuotas {
+		err = r.db.QueryRow(r.objectQuotaQuery, dbName).Scan(&diagnosis.ObjectQuotaExceeded)
+		if err != nil {
+			return diagnosis, fmt.Errorf("Reading object quota of db '%s': %s", dbName, err.Error())
+		}
+	}
+
+	diagnosis.Grantees, err = r.grantees(instance, diagnosis.ObjectQuotaExceeded)
+	if err != nil {
+		return diagnosis, err
+	}
+
+	diagnosis.Tables, err = r.tables(dbName)
+	if err != nil {
+		return diagnosis, err
+	}
+
+	return diagnosis, nil
+}
+
+func (r diagnosisRepo) grantees(instance Instance, objectQuotaExceeded bool) ([]Grantee, error) {
+	grantees := []Grantee{}
+
+	rows, err := r.db.Query(r.granteesQuery, r.strategy.Name(), ObjectQuotaRestriction, instance.DBName)
+	if err != nil {
+		return grantees, fmt.Errorf("Finding grantees of db '%s': %s", instance.DBName, err.Error())
+	}
+
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			grantee    Grantee
+			privileges string
+			state      granteeState
+		)
+		err := rows.Scan(&grantee.User, &grantee.Host, &privileges, &grantee.ReadOnly,
+			&state.restricted, &state.reformable, &state.recorded, &state.holdsCreate, &state.createRestricted)
+		if err != nil {
+			return grantees, fmt.Errorf("Scanning grantee of db '%s': %s", instance.DBName, err.Error())
+		}
+
+		grantee.Privileges = strings.Split(privileges, ",")
+		grantee.Ignored = r.isIgnored(grantee.User)
+		grantee.Decision = r.decide(instance, grantee, state)
+		grantee.ObjectQuotaDecision = r.decideObjectQuota(objectQuotaExceeded, grantee, state)
+		grantees = append(grantees, grantee)
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return grantees, fmt.Errorf("Reading grantees of db '%s': %s", instance.DBName, err.Error())
+	}
+
+	return grantees, nil
+}
+
+// decide mirrors violatorsQueryPattern and reformersQueryPattern, or
+// restrictionQueryPattern for strategies with an applied condition, and the
+// recorded reformers.
+func (r diagnosisRepo) decide(instance Instance, grantee Grantee, state granteeState) string {
+	if grantee.Ignored {
+		return DecisionIgnored
+	}
+
+	// Neither applies to an instance with an invalid quota.
+	overQuota := instance.OverQuota()
+	underQuota := instance.QuotaMB > 0 && !overQuota
+
+	if r.byPrivileges {
+		if overQuota && grantee.HasWrites() {
+			return DecisionRestrict
+		}
+		if underQuota && (state.reformable && !grantee.ReadOnly || state.recorded) {
+			return DecisionLift
+		}
+		return DecisionNone
+	}
+
+	if overQuota && grantee.HasWrites() && !state.restricted {
+		return DecisionRestrict
+	}
+	if underQuota && state.recorded {
+		return DecisionLift
+	}
+	return DecisionNone
+}
+
+// decideObjectQuota mirrors objectViolatorsQueryPattern and
+// objectReformersQueryPattern.
+func (r diagnosisRepo) decideObjectQuota(exceeded bool, grantee Grantee, state granteeState) string {
+	if !r.objectQuotas {
+		return ""
+	}
+	if grantee.Ignored {
+		return DecisionIgnored
+	}
+	if exceeded && state.holdsCreate {
+		return DecisionRestrict
+	}
+	if !exceeded && state.createRestricted {
+		return DecisionLift
+	}
+	return DecisionNone
+}
+
+func (r diagnosisRepo) isIgnored(user string) bool {
+	for _, ignored := range r.ignoredUsers {
+		if user == ignored {
+			return true
+		}
+	}
+	return false
+}
+
+func (r diagnosisRepo) tables(dbName string) ([]TableSize, error) {
+	tables := []TableSize{}
+
+	rows, err := r.db.Query(tableSizesQuery, dbName)
+	if err != nil {
+		return tables, fmt.Errorf("Measuring tables of db '%s': %s", dbName, err.Error())
+	}
+
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	for rows.Next() {
+		var table TableSize
+		if err := rows.Scan(&table.Name, &table.SizeMB); err != nil {
+			return tables, fmt.Errorf("Scanning table of db '%s': %s", dbName, err.Error())
+		}
+		tables = append(tables, table)
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return tables, fmt.Errorf("Reading tables of db '%s': %s", dbName, err.Error())
+	}
+
+	return tables, nil
+}
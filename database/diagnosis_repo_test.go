@@ -0,0 +1,306 @@
+package database_test
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"database/sql"
+
+	"errors"
+	"regexp"
+
+	"code.cloudfoundry.org/lager/lagertest"
+)
+
+var _ = Describe("DiagnosisRepo", func() {
+
+	const brokerDBName = "fake_broker_db_name"
+
+	var (
+		logger          *lagertest.TestLogger
+		server          Server
+		strategyName    string
+		objectQuotas    bool
+		repo            DiagnosisRepo
+		fakeDB          *sql.DB
+		mock            sqlmock.Sqlmock
+		instanceColumns = []string{"guid", "max_storage_mb", "used_mb"}
+		granteeColumns  = []string{"user", "host", "privileges", "read_only", "restricted", "reformable", "recorded", "holds_create", "create_restricted"}
+		tableColumns    = []string{"table_name", "size_mb"}
+	)
+
+	BeforeEach(func() {
+		var err error
+		fakeDB, mock, err = sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		logger = lagertest.NewTestLogger("DiagnosisRepo test")
+		server = Server{Flavor: FlavorMySQL, Version: "5.7.40", Major: 5, Minor: 7}
+		strategyName = "revoke-writes"
+		objectQuotas = false
+	})
+
+	JustBeforeEach(func() {
+		strategy, err := NewStrategy(strategyName, server, logger)
+		Expect(err).ToNot(HaveOccurred())
+		repo = NewDiagnosisRepo("fake_records_db_name", Broker{DBName: brokerDBName}, []string{"fake_admin_user"}, server, strategy, objectQuotas, fakeDB, logger)
+	})
+
+	AfterEach(func() {
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	expectInstance := func(quotaMB, usedMB float64) {
+		mock.ExpectQuery("FROM\\s+`fake_broker_db_name`\\.service_instances").
+			WithArgs("fake-db-name", "fake-db-name").
+			WillReturnRows(sqlmock.NewRows(instanceColumns).
+				AddRow("fake-instance-guid", quotaMB, usedMB))
+	}
+
+	expectTables := func() {
+		mock.ExpectQuery("FROM information_schema.tables").
+			WithArgs("fake-db-name").
+			WillReturnRows(sqlmock.NewRows(tableColumns).
+				AddRow("big_table", 9.5).
+				AddRow("small_table", 0.5))
+	}
+
+	Context("when the instance is over its quota", func() {
+		It("restricts grantees with write privileges", func() {
+			expectInstance(10, 12.5)
+			mock.ExpectQuery("SELECT 'CREATE' AS privilege_type(.|\\n)*UNION ALL(.|\\n)*SELECT 'UPDATE' AS privilege_type(.|\\n)*LEFT JOIN `fake_broker_db_name`\\.read_only_users(.|\\n)*WHERE privileged\\.name = \\?").
+				WithArgs("revoke-writes", ObjectQuotaRestriction, "fake-db-name").
+				WillReturnRows(sqlmock.NewRows(granteeColumns).
+					AddRow("cf_writer", "%", "CREATE,INSERT,SELECT,UPDATE", false, false, false, false, true, false).
+					AddRow("cf_restricted", "%", "SELECT", false, false, true, false, false, false).
+					AddRow("fake_admin_user", "localhost", "CREATE,INSERT,SELECT,UPDATE", false, false, false, false, true, false))
+			expectTables()
+
+			diagnosis, err := repo.Diagnose("fake-db-name")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(diagnosis).To(Equal(Diagnosis{
+				Instance: Instance{GUID: "fake-instance-guid", DBName: "fake-db-name", UsedMB: 12.5, QuotaMB: 10},
+				Strategy: "revoke-writes",
+				Grantees: []Grantee{
+					{User: "cf_writer", Host: "%", Privileges: []string{"CREATE", "INSERT", "SELECT", "UPDATE"}, Decision: DecisionRestrict},
+					{User: "cf_restricted", Host: "%", Privileges: []string{"SELECT"}, Decision: DecisionNone},
+					{User: "fake_admin_user", Host: "localhost", Privileges: []string{"CREATE", "INSERT", "SELECT", "UPDATE"}, Ignored: true, Decision: DecisionIgnored},
+				},
+				Tables: []TableSize{
+					{Name: "big_table", SizeMB: 9.5},
+					{Name: "small_table", SizeMB: 0.5},
+				},
+			}))
+		})
+	})
+
+	Context("when the instance is under its quota", func() {
+		It("lifts the restriction of grantees which are not read-only", func() {
+			expectInstance(10, 5)
+			mock.ExpectQuery(regexp.QuoteMeta("AND privilege_type IN ('SELECT', 'INSERT', 'UPDATE') GROUP BY table_schema HAVING count(*) != 3 ) AS reformable")).
+				WithArgs("revoke-writes", ObjectQuotaRestriction, "fake-db-name").
+				WillReturnRows(sqlmock.NewRows(granteeColumns).
+					AddRow("cf_restricted", "%", "SELECT", false, false, true, false, false, false).
+					AddRow("cf_read_only", "%", "SELECT", true, false, true, false, false, false).
+					AddRow("cf_writer", "%", "INSERT,SELECT,UPDATE", false, false, false, false, false, false))
+			expectTables()
+
+			diagnosis, err := repo.Diagnose("fake-db-name")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(diagnosis.Grantees).To(Equal([]Grantee{
+				{User: "cf_restricted", Host: "%", Privileges: []string{"SELECT"}, Decision: DecisionLift},
+				{User: "cf_read_only", Host: "%", Privileges: []string{"SELECT"}, ReadOnly: true, Decision: DecisionNone},
+				{User: "cf_writer", Host: "%", Privileges: []string{"INSERT", "SELECT", "UPDATE"}, Decision: DecisionNone},
+			}))
+		})
+
+		It("lifts the recorded restrictions of role members", func() {
+			expectInstance(10, 5)
+			mock.ExpectQuery(regexp.QuoteMeta("AND restrictions.restriction = ? AND restrictions.role_user <> '' ) AS recorded")).
+				WithArgs("revoke-writes", ObjectQuotaRestriction, "fake-db-name").
+				WillReturnRows(sqlmock.NewRows(granteeColumns).
+					AddRow("cf_member", "%", "SELECT", false, false, false, true, false, false))
+			expectTables()
+
+			diagnosis, err := repo.Diagnose("fake-db-name")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(diagnosis.Grantees[0].Decision).To(Equal(DecisionLift))
+		})
+	})
+
+	Context("when the instance has a zero quota", func() {
+		It("neither restricts nor lifts restrictions", func() {
+			expectInstance(0, 5)
+			mock.ExpectQuery(".*").
+				WithArgs("revoke-writes", ObjectQuotaRestriction, "fake-db-name").
+				WillReturnRows(sqlmock.NewRows(granteeColumns).
+					AddRow("cf_writer", "%", "CREATE,INSERT,SELECT,UPDATE", false, false, false, false, true, false).
+					AddRow("cf_restricted", "%", "SELECT", false, false, true, false, false, false))
+			expectTables()
+
+			diagnosis, err := repo.Diagnose("fake-db-name")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(diagnosis.Grantees[0].Decision).To(Equal(DecisionNone))
+			Expect(diagnosis.Grantees[1].Decision).To(Equal(DecisionNone))
+		})
+	})
+
+	Context("when the strategy is applied to the account", func() {
+		BeforeEach(func() {
+			strategyName = "account-lock"
+		})
+
+		It("decides by whether the account is restricted", func() {
+			expectInstance(10, 12.5)
+			mock.ExpectQuery("COALESCE\\(accounts\\.account_locked = 'Y', FALSE\\) AS restricted(.|\\n)*LEFT JOIN mysql\\.user AS accounts").
+				WithArgs("account-lock", ObjectQuotaRestriction, "fake-db-name").
+				WillReturnRows(sqlmock.NewRows(granteeColumns).
+					AddRow("cf_unlocked", "%", "INSERT,SELECT", false, false, false, false, false, false).
+					AddRow("cf_locked", "%", "INSERT,SELECT", false, true, false, true, false, false))
+			expectTables()
+
+			diagnosis, err := repo.Diagnose("fake-db-name")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(diagnosis.Strategy).To(Equal("account-lock"))
+			Expect(diagnosis.Grantees[0].Decision).To(Equal(DecisionRestrict))
+			Expect(diagnosis.Grantees[1].Decision).To(Equal(DecisionNone))
+		})
+
+		It("lifts the restrictions recorded for the strategy", func() {
+			expectInstance(10, 5)
+			mock.ExpectQuery(".*").
+				WithArgs("account-lock", ObjectQuotaRestriction, "fake-db-name").
+				WillReturnRows(sqlmock.NewRows(granteeColumns).
+					AddRow("cf_recorded", "%", "INSERT,SELECT", false, true, false, true, false, false).
+					AddRow("cf_locked_by_operator", "%", "INSERT,SELECT", false, true, false, false, false, false))
+			expectTables()
+
+			diagnosis, err := repo.Diagnose("fake-db-name")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(diagnosis.Grantees[0].Decision).To(Equal(DecisionLift))
+			Expect(diagnosis.Grantees[1].Decision).To(Equal(DecisionNone))
+		})
+	})
+
+	Context("when the server supports roles and partial revokes", func() {
+		BeforeEach(func() {
+			server = Server{Flavor: FlavorMySQL, Version: "8.0.30", Major: 8, Minor: 0, Patch: 30, PartialRevokes: true}
+		})
+
+		It("finds grantees as the violator query does", func() {
+			expectInstance(10, 12.5)
+			mock.ExpectQuery("JOIN \\(SELECT FROM_USER AS role_user(.|\\n)*FROM mysql.role_edges(.|\\n)*global_accounts.Insert_priv = 'Y'(.|\\n)*global_accounts.Select_priv = 'Y'(.|\\n)*WHERE privileged\\.name = \\?").
+				WithArgs("revoke-writes", ObjectQuotaRestriction, "fake-db-name").
+				WillReturnRows(sqlmock.NewRows(granteeColumns).
+					AddRow("cf_member", "%", "INSERT,SELECT", false, false, false, false, false, false))
+			expectTables()
+
+			diagnosis, err := repo.Diagnose("fake-db-name")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(diagnosis.Grantees[0].Decision).To(Equal(DecisionRestrict))
+		})
+	})
+
+	Context("when object quotas are enforced", func() {
+		BeforeEach(func() {
+			objectQuotas = true
+		})
+
+		expectObjectQuota := func(exceeded bool) {
+			mock.ExpectQuery("SELECT \\((.|\\n)*instances.max_tables(.|\\n)*\\) AS exceeded").
+				WithArgs("fake-db-name").
+				WillReturnRows(sqlmock.NewRows([]string{"exceeded"}).AddRow(exceeded))
+		}
+
+		It("revokes CREATE from grantees holding it when the object quota is exceeded", func() {
+			expectInstance(10, 5)
+			expectObjectQuota(true)
+			mock.ExpectQuery(".*").
+				WithArgs("revoke-writes", ObjectQuotaRestriction, "fake-db-name").
+				WillReturnRows(sqlmock.NewRows(granteeColumns).
+					AddRow("cf_writer", "%", "CREATE,INSERT,SELECT,UPDATE", false, false, false, false, true, false).
+					AddRow("cf_member", "%", "CREATE,INSERT,SELECT,UPDATE", false, false, false, false, false, false).
+					AddRow("fake_admin_user", "localhost", "CREATE,INSERT,SELECT,UPDATE", false, false, false, false, true, false))
+			expectTables()
+
+			diagnosis, err := repo.Diagnose("fake-db-name")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(diagnosis.ObjectQuotas).To(BeTrue())
+			Expect(diagnosis.ObjectQuotaExceeded).To(BeTrue())
+			Expect(diagnosis.Grantees[0].ObjectQuotaDecision).To(Equal(DecisionRestrict))
+			Expect(diagnosis.Grantees[1].ObjectQuotaDecision).To(Equal(DecisionNone))
+			Expect(diagnosis.Grantees[2].ObjectQuotaDecision).To(Equal(DecisionIgnored))
+		})
+
+		It("grants CREATE again to the grantees it was recorded as revoked from", func() {
+			expectInstance(10, 5)
+			expectObjectQuota(false)
+			mock.ExpectQuery(".*").
+				WithArgs("revoke-writes", ObjectQuotaRestriction, "fake-db-name").
+				WillReturnRows(sqlmock.NewRows(granteeColumns).
+					AddRow("cf_recorded", "%", "INSERT,SELECT,UPDATE", false, false, false, false, false, true).
+					AddRow("cf_writer", "%", "CREATE,INSERT,SELECT,UPDATE", false, false, false, false, true, false))
+			expectTables()
+
+			diagnosis, err := repo.Diagnose("fake-db-name")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(diagnosis.Grantees[0].ObjectQuotaDecision).To(Equal(DecisionLift))
+			Expect(diagnosis.Grantees[1].ObjectQuotaDecision).To(Equal(DecisionNone))
+		})
+
+		It("returns an error if the object quota cannot be read", func() {
+			expectInstance(10, 5)
+			mock.ExpectQuery(".*").
+				WillReturnError(errors.New("fake-query-error"))
+
+			_, err := repo.Diagnose("fake-db-name")
+			Expect(err).To(MatchError("Reading object quota of db 'fake-db-name': fake-query-error"))
+		})
+	})
+
+	Context("when there is no such instance", func() {
+		It("returns an InstanceNotFoundError", func() {
+			mock.ExpectQuery(".*").
+				WillReturnRows(sqlmock.NewRows(instanceColumns))
+
+			_, err := repo.Diagnose("fake-db-name")
+			Expect(err).To(Equal(InstanceNotFoundError{DBName: "fake-db-name"}))
+		})
+	})
+
+	Context("when the grantees query fails", func() {
+		It("returns an error", func() {
+			expectInstance(10, 5)
+			mock.ExpectQuery(".*").
+				WillReturnError(errors.New("fake-query-error"))
+
+			_, err := repo.Diagnose("fake-db-name")
+			Expect(err).To(MatchError("Finding grantees of db 'fake-db-name': fake-query-error"))
+		})
+	})
+
+	Context("when the tables query fails", func() {
+		It("returns an error", func() {
+			expectInstance(10, 5)
+			mock.ExpectQuery(".*").
+				WillReturnRows(sqlmock.NewRows(granteeColumns))
+			mock.ExpectQuery(".*").
+				WillReturnError(errors.New("fake-query-error"))
+
+			_, err := repo.Diagnose("fake-db-name")
+			Expect(err).To(MatchError("Measuring tables of db 'fake-db-name': fake-query-error"))
+		})
+	})
+})
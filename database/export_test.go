@@ -0,0 +1,13 @@
+package database
+
+import (
+	"database/sql/driver"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+)
+
+// NewConnector exposes the connector of NewConnection to the tests, opening
+// connections with a fake driver.
+func NewConnector(cfg config.Config, d driver.Driver) (driver.Connector, error) {
+	return newConnector(cfg, d)
+}
@@ -0,0 +1,174 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"code.cloudfoundry.org/lager"
+)
+
+const instanceQueryPattern = `
+SELECT instances.guid, COALESCE(instances.max_storage_mb, 0),
+	(
+		SELECT ROUND(COALESCE(SUM(tables.data_length + tables.index_length), 0) / 1024 / 1024, 1)
+		FROM information_schema.tables AS tables
+		WHERE tables.table_schema = ?
+	) AS used_mb
+FROM   %[1]s AS instances
+WHERE  %[2]s = ?
+LIMIT  1
+`
+
+const allInstancesQueryPattern = `
+SELECT instances.guid, instances.db_name, COALESCE(MAX(instances.max_storage_mb), 0) AS quota_mb,
+	ROUND(SUM(COALESCE(tables.data_length + tables.index_length,0) / 1024 / 1024), 1) AS used_mb
+FROM        %[1]s AS instances
+LEFT JOIN   information_schema.tables AS tables ON tables.table_schema = %[2]s
+GROUP  BY   instances.id, instances.guid, instances.db_name
+ORDER  BY   instances.db_name
+`
+
+const countInstancesQueryPattern = `SELECT COUNT(*) FROM %[1]s AS instances`
+
+// Instance is a service instance of the broker, with the storage used by its database.
+type Instance struct {
+	GUID    string
+	DBName  string
+	UsedMB  float64
+	QuotaMB float64
+}
+
+func (i Instance) PercentOfQuota() float64 {
+	if i.QuotaMB <= 0 {
+		return 0
+	}
+	return i.UsedMB / i.QuotaMB * 100
+}
+
+// OverQuota is true under the same condition as in violatorsQueryPattern. An
+// instance with an invalid quota is never over it.
+func (i Instance) OverQuota() bool {
+	return i.QuotaMB > 0 && i.UsedMB >= i.QuotaMB
+}
+
+// State returns the usage state published for the instance, as in
+// measureUsageQueryPattern. Zero warningPercent disables the warning state.
+func (i Instance) State(warningPercent float64) string {
+	if i.QuotaMB <= 0 {
+		return StateInvalidQuota
+	}
+	if i.OverQuota() {
+		return StateOverQuota
+	}
+	if warningPercent > 0 && i.PercentOfQuota() >= warningPercent {
+		return StateWarning
+	}
+	return StateOK
+}
+
+// InstanceNotFoundError is returned when a database belongs to no instance.
+type InstanceNotFoundError struct {
+	DBName string
+}
+
+func (e InstanceNotFoundError) Error() string {
+	return fmt.Sprintf("Finding instance of db '%s': no such instance", e.DBName)
+}
+
+type InstanceRepo interface {
+	Find(dbName string) (Instance, error)
+	All() ([]Instance, error)
+	Count() (int, error)
+}
+
+type instanceRepo struct {
+	query      string
+	allQuery   string
+	countQuery string
+	db         *sql.DB
+	logger     lager.Logger
+}
+
+func NewInstanceRepo(broker Broker, server Server, db *sql.DB, logger lager.Logger) InstanceRepo {
+	query := fmt.Sprintf(
+		instanceQueryPattern,
+		broker.instancesTable("guid", "db_name", "max_storage_mb"),
+		server.collate("instances.db_name"),
+	)
+
+	allQuery := fmt.Sprintf(
+		allInstancesQueryPattern,
+		broker.instancesTable("id", "guid", "db_name", "max_storage_mb"),
+		server.collate("instances.db_name"),
+	)
+
+	return &instanceRepo{
+		query:      query,
+		allQuery:   allQuery,
+		countQuery: fmt.Sprintf(countInstancesQueryPattern, broker.instancesTable("db_name")),
+		db:         db,
+		logger:     logger,
+	}
+}
+
+func (r instanceRepo) Find(dbName string) (Instance, error) {
+	r.logger.Debug(fmt.Sprintf("Executing 'instance'.Find for db '%s'", dbName))
+
+	instance := Instance{DBName: dbName}
+
+	var guid sql.NullString
+	err := r.db.QueryRow(r.query, dbName, dbName).Scan(&guid, &instance.QuotaMB, &instance.UsedMB)
+	if err == sql.ErrNoRows {
+		return instance, InstanceNotFoundError{DBName: dbName}
+	}
+	if err != nil {
+		return instance, fmt.Errorf("Finding instance of db '%s': %s", dbName, err.Error())
+	}
+
+	instance.GUID = guid.String
+	return instance, nil
+}
+
+func (r instanceRepo) All() ([]Instance, error) {
+	r.logger.Debug("Executing 'instance'.All")
+
+	instances := []Instance{}
+
+	rows, err := r.db.Query(r.allQuery)
+	if err != nil {
+		return instances, fmt.Errorf("Error executing 'instance'.All: %s", err.Error())
+	}
+
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			guid     sql.NullString
+			instance Instance
+		)
+		err := rows.Scan(&guid, &instance.DBName, &instance.QuotaMB, &instance.UsedMB)
+		if err != nil {
+			return instances, fmt.Errorf("Scanning result row of 'instance'.All: %s", err.Error())
+		}
+
+		instance.GUID = guid.String
+		instances = append(instances, instance)
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return instances, fmt.Errorf("Reading result row of 'instance'.All: %s", err.Error())
+	}
+
+	return instances, nil
+}
+
+// Count returns the number of instances, without measuring their usage.
+func (r instanceRepo) Count() (int, error) {
+	var count int
+	err := r.db.QueryRow(r.countQuery).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("Counting instances: %s", err.Error())
+	}
+	return count, nil
+}
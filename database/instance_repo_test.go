@@ -0,0 +1,175 @@
+package database_test
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"database/sql"
+
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+)
+
+var _ = Describe("InstanceRepo", func() {
+
+	const brokerDBName = "fake_broker_db_name"
+
+	var (
+		logger *lagertest.TestLogger
+		repo   InstanceRepo
+		fakeDB *sql.DB
+		mock   sqlmock.Sqlmock
+	)
+
+	BeforeEach(func() {
+		var err error
+		fakeDB, mock, err = sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		logger = lagertest.NewTestLogger("InstanceRepo test")
+		server := Server{Flavor: FlavorMySQL, Version: "5.7.40", Major: 5, Minor: 7}
+		repo = NewInstanceRepo(Broker{DBName: brokerDBName}, server, fakeDB, logger)
+	})
+
+	AfterEach(func() {
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	Describe("Find", func() {
+		var instanceColumns = []string{"guid", "max_storage_mb", "used_mb"}
+
+		It("returns the instance of the database with its usage", func() {
+			mock.ExpectQuery("FROM\\s+`fake_broker_db_name`\\.service_instances").
+				WithArgs("fake-db-name", "fake-db-name").
+				WillReturnRows(sqlmock.NewRows(instanceColumns).
+					AddRow("fake-instance-guid", 10, 12.5))
+
+			instance, err := repo.Find("fake-db-name")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance).To(Equal(Instance{
+				GUID:    "fake-instance-guid",
+				DBName:  "fake-db-name",
+				UsedMB:  12.5,
+				QuotaMB: 10,
+			}))
+		})
+
+		Context("when there is no such instance", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery(".*").
+					WillReturnRows(sqlmock.NewRows(instanceColumns))
+			})
+
+			It("returns an error", func() {
+				_, err := repo.Find("fake-db-name")
+				Expect(err).To(MatchError(ContainSubstring("no such instance")))
+				Expect(err).To(BeAssignableToTypeOf(InstanceNotFoundError{}))
+			})
+		})
+
+		Context("when the db query fails", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery(".*").
+					WillReturnError(errors.New("fake-query-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := repo.Find("fake-db-name")
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-query-error"))
+			})
+		})
+	})
+
+	Describe("Count", func() {
+		It("counts the instances", func() {
+			mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM `fake_broker_db_name`\\.service_instances").
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(42))
+
+			count, err := repo.Count()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(count).To(Equal(42))
+		})
+
+		Context("when the db query fails", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery(".*").
+					WillReturnError(errors.New("fake-query-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := repo.Count()
+				Expect(err).To(MatchError("Counting instances: fake-query-error"))
+			})
+		})
+	})
+
+	Describe("All", func() {
+		var instanceColumns = []string{"guid", "db_name", "max_storage_mb", "used_mb"}
+
+		It("returns every instance with its usage", func() {
+			mock.ExpectQuery("FROM\\s+`fake_broker_db_name`\\.service_instances").
+				WillReturnRows(sqlmock.NewRows(instanceColumns).
+					AddRow("fake-guid-1", "fake-db-1", 10, 12.5).
+					AddRow("fake-guid-2", "fake-db-2", 20, 0))
+
+			instances, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instances).To(Equal([]Instance{
+				{GUID: "fake-guid-1", DBName: "fake-db-1", UsedMB: 12.5, QuotaMB: 10},
+				{GUID: "fake-guid-2", DBName: "fake-db-2", UsedMB: 0, QuotaMB: 20},
+			}))
+		})
+
+		Context("when the db query fails", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery(".*").
+					WillReturnError(errors.New("fake-query-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := repo.All()
+				Expect(err).To(MatchError(ContainSubstring("fake-query-error")))
+			})
+		})
+	})
+})
+
+var _ = Describe("Instance", func() {
+	Describe("OverQuota", func() {
+		It("is true when the usage reaches the quota", func() {
+			Expect(Instance{UsedMB: 10, QuotaMB: 10}.OverQuota()).To(BeTrue())
+			Expect(Instance{UsedMB: 9.9, QuotaMB: 10}.OverQuota()).To(BeFalse())
+		})
+
+		It("is never true without a quota", func() {
+			Expect(Instance{UsedMB: 5, QuotaMB: 0}.OverQuota()).To(BeFalse())
+		})
+	})
+
+	Describe("State", func() {
+		It("is over quota when the usage reaches the quota", func() {
+			Expect(Instance{UsedMB: 10, QuotaMB: 10}.State(80)).To(Equal(StateOverQuota))
+		})
+
+		It("is warning when the usage reaches the warning percent", func() {
+			Expect(Instance{UsedMB: 8, QuotaMB: 10}.State(80)).To(Equal(StateWarning))
+		})
+
+		It("is ok below the warning percent", func() {
+			Expect(Instance{UsedMB: 7, QuotaMB: 10}.State(80)).To(Equal(StateOK))
+		})
+
+		It("is invalid without a quota, whatever the usage", func() {
+			Expect(Instance{UsedMB: 5, QuotaMB: 0}.State(80)).To(Equal(StateInvalidQuota))
+		})
+
+		It("is never warning without a warning percent", func() {
+			Expect(Instance{UsedMB: 9.9, QuotaMB: 10}.State(0)).To(Equal(StateOK))
+		})
+	})
+})
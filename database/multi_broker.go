@@ -0,0 +1,267 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// claimsQueryPattern lists the databases claimed by the instances of one
+// broker. The claims of all brokers are combined with UNION ALL.
+const claimsQueryPattern = `SELECT %[2]s AS db_name, ? AS broker FROM %[1]s AS instances`
+
+// ClaimRepo finds databases claimed by instances of more than one broker,
+// which would be measured against conflicting quotas.
+type ClaimRepo interface {
+	Duplicates() (map[string][]string, error)
+}
+
+type claimRepo struct {
+	query   string
+	brokers []Broker
+	db      *sql.DB
+	logger  lager.Logger
+}
+
+func NewClaimRepo(brokers []Broker, server Server, db *sql.DB, logger lager.Logger) ClaimRepo {
+	queries := make([]string, len(brokers))
+	for i, broker := range brokers {
+		queries[i] = fmt.Sprintf(claimsQueryPattern, broker.instancesTable("db_name"), server.collate("instances.db_name"))
+	}
+
+	return &claimRepo{
+		query:   strings.Join(queries, "\nUNION ALL\n"),
+		brokers: brokers,
+		db:      db,
+		logger:  logger,
+	}
+}
+
+// Duplicates returns the brokers claiming each database claimed by more than
+// one broker.
+func (r claimRepo) Duplicates() (map[string][]string, error) {
+	r.logger.Debug("Executing 'claim'.Duplicates")
+
+	parameters := make([]interface{}, len(r.brokers))
+	for i, broker := range r.brokers {
+		parameters[i] = broker.DBName
+	}
+
+	rows, err := r.db.Query(r.query, parameters...)
+	if err != nil {
+		return nil, fmt.Errorf("Finding databases claimed by several brokers: %s", err.Error())
+	}
+
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	claims := map[string][]string{}
+	for rows.Next() {
+		var dbName, broker string
+		if err := rows.Scan(&dbName, &broker); err != nil {
+			return nil, fmt.Errorf("Scanning claim of a broker: %s", err.Error())
+		}
+		if !contains(claims[dbName], broker) {
+			claims[dbName] = append(claims[dbName], broker)
+		}
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Reading claims of brokers: %s", err.Error())
+	}
+
+	duplicates := map[string][]string{}
+	for dbName, brokers := range claims {
+		if len(brokers) > 1 {
+			sort.Strings(brokers)
+			duplicates[dbName] = brokers
+		}
+	}
+	return duplicates, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+type multiBrokerRepo struct {
+	repos  []Repo
+	claims ClaimRepo
+	logger lager.Logger
+}
+
+// NewMultiBrokerRepo returns the databases found by the repos of several
+// brokers, each measured against the quota of its own broker. Databases
+// claimed by more than one broker are left alone, as their quota is ambiguous.
+func NewMultiBrokerRepo(repos []Repo, claims ClaimRepo, logger lager.Logger) Repo {
+	return &multiBrokerRepo{
+		repos:  repos,
+		claims: claims,
+		logger: logger,
+	}
+}
+
+func (r multiBrokerRepo) All() ([]Database, error) {
+	databases := []Database{}
+
+	duplicates, err := r.claims.Duplicates()
+	if err != nil {
+		return databases, err
+	}
+
+	for _, repo := range r.repos {
+		found, err := repo.All()
+		if err != nil {
+			return databases, err
+		}
+		for _, db := range found {
+			if skipDuplicate(duplicates, db.Name(), r.logger) {
+				continue
+			}
+			databases = append(databases, db)
+		}
+	}
+	return databases, nil
+}
+
+type multiBrokerAccountRepo struct {
+	repos  []AccountRepo
+	claims ClaimRepo
+	logger lager.Logger
+}
+
+// NewMultiBrokerAccountRepo is like NewMultiBrokerRepo, for accounts.
+func NewMultiBrokerAccountRepo(repos []AccountRepo, claims ClaimRepo, logger lager.Logger) AccountRepo {
+	return &multiBrokerAccountRepo{
+		repos:  repos,
+		claims: claims,
+		logger: logger,
+	}
+}
+
+func (r multiBrokerAccountRepo) All() ([]Account, error) {
+	accounts := []Account{}
+
+	duplicates, err := r.claims.Duplicates()
+	if err != nil {
+		return accounts, err
+	}
+
+	for _, repo := range r.repos {
+		found, err := repo.All()
+		if err != nil {
+			return accounts, err
+		}
+		for _, account := range found {
+			if skipDuplicate(duplicates, account.Database.Name(), r.logger) {
+				continue
+			}
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts, nil
+}
+
+type multiBrokerUsageRepo struct {
+	repos  []UsageRepo
+	claims ClaimRepo
+	logger lager.Logger
+}
+
+// NewMultiBrokerUsageRepo is like NewMultiBrokerRepo, for usage.
+func NewMultiBrokerUsageRepo(repos []UsageRepo, claims ClaimRepo, logger lager.Logger) UsageRepo {
+	return &multiBrokerUsageRepo{
+		repos:  repos,
+		claims: claims,
+		logger: logger,
+	}
+}
+
+func (r multiBrokerUsageRepo) All() ([]Usage, error) {
+	usages := []Usage{}
+
+	duplicates, err := r.claims.Duplicates()
+	if err != nil {
+		return usages, err
+	}
+
+	for _, repo := range r.repos {
+		found, err := repo.All()
+		if err != nil {
+			return usages, err
+		}
+		for _, usage := range found {
+			if skipDuplicate(duplicates, usage.Database.Name(), r.logger) {
+				continue
+			}
+			usages = append(usages, usage)
+		}
+	}
+	return usages, nil
+}
+
+func skipDuplicate(duplicates map[string][]string, dbName string, logger lager.Logger) bool {
+	brokers, ok := duplicates[dbName]
+	if ok {
+		logger.Debug(fmt.Sprintf("Skipping db '%s', claimed by brokers %s", dbName, strings.Join(brokers, ", ")))
+	}
+	return ok
+}
+
+type multiBrokerInstanceRepo struct {
+	repos []InstanceRepo
+}
+
+// NewMultiBrokerInstanceRepo returns the instances of several brokers. A
+// database claimed by more than one broker is listed once per broker, and
+// found in the first broker claiming it.
+func NewMultiBrokerInstanceRepo(repos []InstanceRepo) InstanceRepo {
+	return &multiBrokerInstanceRepo{repos: repos}
+}
+
+func (r multiBrokerInstanceRepo) Find(dbName string) (Instance, error) {
+	for _, repo := range r.repos {
+		instance, err := repo.Find(dbName)
+		if _, ok := err.(InstanceNotFoundError); ok {
+			continue
+		}
+		return instance, err
+	}
+	return Instance{DBName: dbName}, InstanceNotFoundError{DBName: dbName}
+}
+
+func (r multiBrokerInstanceRepo) All() ([]Instance, error) {
+	instances := []Instance{}
+	for _, repo := range r.repos {
+		found, err := repo.All()
+		if err != nil {
+			return instances, err
+		}
+		instances = append(instances, found...)
+	}
+
+	sort.SliceStable(instances, func(i, j int) bool {
+		return instances[i].DBName < instances[j].DBName
+	})
+	return instances, nil
+}
+
+func (r multiBrokerInstanceRepo) Count() (int, error) {
+	total := 0
+	for _, repo := range r.repos {
+		count, err := repo.Count()
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
@@ -0,0 +1,229 @@
+package database_test
+
+import (
+	"database/sql"
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database/databasefakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Multiple brokers", func() {
+	var logger *lagertest.TestLogger
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("Multi broker test")
+	})
+
+	fakeDatabase := func(name string) *databasefakes.FakeDatabase {
+		db := &databasefakes.FakeDatabase{}
+		db.NameReturns(name)
+		return db
+	}
+
+	Describe("ClaimRepo", func() {
+		var (
+			repo   ClaimRepo
+			fakeDB *sql.DB
+			mock   sqlmock.Sqlmock
+		)
+
+		BeforeEach(func() {
+			var err error
+			fakeDB, mock, err = sqlmock.New()
+			Expect(err).ToNot(HaveOccurred())
+
+			server := Server{Flavor: FlavorMySQL, Version: "8.0.32", Major: 8, Minor: 0}
+			repo = NewClaimRepo([]Broker{{DBName: "fake_broker_a"}, {DBName: "fake_broker_b"}}, server, fakeDB, logger)
+		})
+
+		AfterEach(func() {
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns the databases claimed by more than one broker", func() {
+			mock.ExpectQuery("FROM `fake_broker_a`\\.service_instances AS instances\\s+UNION ALL\\s+SELECT .* FROM `fake_broker_b`\\.service_instances").
+				WithArgs("fake_broker_a", "fake_broker_b").
+				WillReturnRows(sqlmock.NewRows([]string{"db_name", "broker"}).
+					AddRow("fake-db-shared", "fake_broker_b").
+					AddRow("fake-db-a", "fake_broker_a").
+					AddRow("fake-db-shared", "fake_broker_a").
+					AddRow("fake-db-twice", "fake_broker_b").
+					AddRow("fake-db-twice", "fake_broker_b"))
+
+			duplicates, err := repo.Duplicates()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(duplicates).To(Equal(map[string][]string{
+				"fake-db-shared": {"fake_broker_a", "fake_broker_b"},
+			}))
+		})
+
+		Context("when the db query fails", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery(".*").
+					WillReturnError(errors.New("fake-query-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := repo.Duplicates()
+				Expect(err).To(MatchError("Finding databases claimed by several brokers: fake-query-error"))
+			})
+		})
+	})
+
+	Describe("MultiBrokerRepo", func() {
+		var (
+			repo       Repo
+			fakeRepoA  *databasefakes.FakeRepo
+			fakeRepoB  *databasefakes.FakeRepo
+			fakeClaims *databasefakes.FakeClaimRepo
+		)
+
+		BeforeEach(func() {
+			fakeRepoA = &databasefakes.FakeRepo{}
+			fakeRepoA.AllReturns([]Database{fakeDatabase("fake-db-a"), fakeDatabase("fake-db-shared")}, nil)
+			fakeRepoB = &databasefakes.FakeRepo{}
+			fakeRepoB.AllReturns([]Database{fakeDatabase("fake-db-b"), fakeDatabase("fake-db-shared")}, nil)
+
+			fakeClaims = &databasefakes.FakeClaimRepo{}
+			fakeClaims.DuplicatesReturns(map[string][]string{"fake-db-shared": {"fake_broker_a", "fake_broker_b"}}, nil)
+
+			repo = NewMultiBrokerRepo([]Repo{fakeRepoA, fakeRepoB}, fakeClaims, logger)
+		})
+
+		It("returns the databases of every broker, except those claimed by several", func() {
+			databases, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+
+			var names []string
+			for _, db := range databases {
+				names = append(names, db.Name())
+			}
+			Expect(names).To(Equal([]string{"fake-db-a", "fake-db-b"}))
+		})
+
+		Context("when the repo of a broker fails", func() {
+			BeforeEach(func() {
+				fakeRepoB.AllReturns([]Database{}, errors.New("fake-repo-error"))
+			})
+
+			It("returns the error", func() {
+				_, err := repo.All()
+				Expect(err).To(MatchError("fake-repo-error"))
+			})
+		})
+
+		Context("when finding duplicate claims fails", func() {
+			BeforeEach(func() {
+				fakeClaims.DuplicatesReturns(nil, errors.New("fake-claims-error"))
+			})
+
+			It("returns the error without enforcing anything", func() {
+				_, err := repo.All()
+				Expect(err).To(MatchError("fake-claims-error"))
+				Expect(fakeRepoA.AllCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("MultiBrokerAccountRepo", func() {
+		It("returns the accounts of every broker, except those of databases claimed by several", func() {
+			fakeRepoA := &databasefakes.FakeAccountRepo{}
+			fakeRepoA.AllReturns([]Account{{Database: fakeDatabase("fake-db-a"), InstanceGUID: "fake-guid-a"}}, nil)
+			fakeRepoB := &databasefakes.FakeAccountRepo{}
+			fakeRepoB.AllReturns([]Account{{Database: fakeDatabase("fake-db-shared"), InstanceGUID: "fake-guid-shared"}}, nil)
+			fakeClaims := &databasefakes.FakeClaimRepo{}
+			fakeClaims.DuplicatesReturns(map[string][]string{"fake-db-shared": {"fake_broker_a", "fake_broker_b"}}, nil)
+
+			accounts, err := NewMultiBrokerAccountRepo([]AccountRepo{fakeRepoA, fakeRepoB}, fakeClaims, logger).All()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(accounts).To(HaveLen(1))
+			Expect(accounts[0].InstanceGUID).To(Equal("fake-guid-a"))
+		})
+	})
+
+	Describe("MultiBrokerUsageRepo", func() {
+		It("returns the usage of every broker, each against its own quota", func() {
+			fakeRepoA := &databasefakes.FakeUsageRepo{}
+			fakeRepoA.AllReturns([]Usage{{Database: fakeDatabase("fake-db-a"), UsedMB: 5, QuotaMB: 10}}, nil)
+			fakeRepoB := &databasefakes.FakeUsageRepo{}
+			fakeRepoB.AllReturns([]Usage{{Database: fakeDatabase("fake-db-b"), UsedMB: 5, QuotaMB: 100}}, nil)
+			fakeClaims := &databasefakes.FakeClaimRepo{}
+
+			usages, err := NewMultiBrokerUsageRepo([]UsageRepo{fakeRepoA, fakeRepoB}, fakeClaims, logger).All()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(usages).To(HaveLen(2))
+			Expect(usages[0].QuotaMB).To(Equal(10.0))
+			Expect(usages[1].QuotaMB).To(Equal(100.0))
+		})
+	})
+
+	Describe("MultiBrokerInstanceRepo", func() {
+		var (
+			repo       InstanceRepo
+			fakeRepoA  *databasefakes.FakeInstanceRepo
+			fakeRepoB  *databasefakes.FakeInstanceRepo
+			instanceB1 Instance
+		)
+
+		BeforeEach(func() {
+			instanceB1 = Instance{GUID: "fake-guid-b", DBName: "fake-db-b", QuotaMB: 100}
+
+			fakeRepoA = &databasefakes.FakeInstanceRepo{}
+			fakeRepoA.AllReturns([]Instance{{GUID: "fake-guid-c", DBName: "fake-db-c", QuotaMB: 10}}, nil)
+			fakeRepoA.FindReturns(Instance{DBName: "fake-db-b"}, InstanceNotFoundError{DBName: "fake-db-b"})
+			fakeRepoA.CountReturns(1, nil)
+			fakeRepoB = &databasefakes.FakeInstanceRepo{}
+			fakeRepoB.AllReturns([]Instance{instanceB1}, nil)
+			fakeRepoB.FindReturns(instanceB1, nil)
+			fakeRepoB.CountReturns(2, nil)
+
+			repo = NewMultiBrokerInstanceRepo([]InstanceRepo{fakeRepoA, fakeRepoB})
+		})
+
+		It("lists the instances of every broker by database name", func() {
+			instances, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instances).To(HaveLen(2))
+			Expect(instances[0]).To(Equal(instanceB1))
+			Expect(instances[1].DBName).To(Equal("fake-db-c"))
+		})
+
+		It("finds an instance in the broker claiming it", func() {
+			instance, err := repo.Find("fake-db-b")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance).To(Equal(instanceB1))
+		})
+
+		It("counts the instances of every broker", func() {
+			Expect(repo.Count()).To(Equal(3))
+		})
+
+		Context("when no broker claims a database", func() {
+			BeforeEach(func() {
+				fakeRepoB.FindReturns(Instance{DBName: "fake-db-b"}, InstanceNotFoundError{DBName: "fake-db-b"})
+			})
+
+			It("returns an InstanceNotFoundError", func() {
+				_, err := repo.Find("fake-db-b")
+				Expect(err).To(Equal(InstanceNotFoundError{DBName: "fake-db-b"}))
+			})
+		})
+
+		Context("when a broker fails to find an instance", func() {
+			BeforeEach(func() {
+				fakeRepoA.FindReturns(Instance{}, errors.New("fake-find-error"))
+			})
+
+			It("returns the error", func() {
+				_, err := repo.Find("fake-db-b")
+				Expect(err).To(MatchError("fake-find-error"))
+			})
+		})
+	})
+})
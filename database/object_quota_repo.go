@@ -0,0 +1,81 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// objectQuotaExceededPattern is true once an instance has as many tables
+// (including views) or stored routines as its limit allows. A NULL limit
+// means no limit.
+const objectQuotaExceededPattern = `(
+	(instances.max_tables IS NOT NULL AND instances.max_tables <=
+		(SELECT COUNT(*) FROM information_schema.tables WHERE tables.table_schema = dbs.name))
+	OR (instances.max_routines IS NOT NULL AND instances.max_routines <=
+		(SELECT COUNT(*) FROM information_schema.routines WHERE routines.routine_schema = dbs.name))
+)`
+
+// Object quota violators still hold CREATE. Grantees are found as in
+// violatorsQueryPattern, except that CREATE granted through a role is left
+// alone, as it can only be taken along with the other privileges of the role.
+const objectViolatorsQueryPattern = `
+SELECT dbs.name AS violator_db, dbs.user AS violator_user, dbs.host AS violator_host, dbs.grant_schema
+FROM (%[1]s
+) AS dbs
+JOIN %[2]s AS instances ON dbs.name = %[3]s
+WHERE %[4]s
+`
+
+// Object quota reformers are the recorded object quota restrictions, so CREATE
+// is only granted to grantees it was revoked from by the enforcer. Grantees
+// whose writes were revoked for exceeding the storage quota, and so no longer
+// hold INSERT, are left to the storage reformers.
+const objectReformersQueryPattern = `
+SELECT DISTINCT dbs.name AS reformer_db, dbs.user AS reformer_user, dbs.host AS reformer_host, grants.table_schema AS grant_schema
+FROM (
+	SELECT db_name AS name, user, host
+	FROM %[1]s.quota_enforcer_restrictions
+	WHERE restriction = ?
+) AS dbs
+JOIN %[2]s AS instances ON dbs.name = %[3]s
+JOIN information_schema.schema_privileges AS grants
+	ON %[5]s = dbs.name
+	AND grants.grantee = CONCAT("'", dbs.user, "'@'", dbs.host, "'")
+WHERE NOT %[4]s
+AND grants.privilege_type = 'INSERT'
+`
+
+// NewObjectViolatorRepo finds grantees holding CREATE on an instance that has
+// reached its table or routine limit, read from the max_tables and
+// max_routines columns of the broker's instances.
+func NewObjectViolatorRepo(broker Broker, ignoredUsers []string, server Server, measurementDB, actionDB *sql.DB, logger lager.Logger) Repo {
+	return newObjectQuotaRepo(objectViolatorsQueryPattern, broker, ignoredUsers, server, measurementDB, actionDB, logger, "object quota violator")
+}
+
+// NewObjectReformerRepo finds grantees whose CREATE was revoked, according to
+// the records kept in recordsDBName, on an instance that is back under its
+// table and routine limits.
+func NewObjectReformerRepo(recordsDBName string, broker Broker, server Server, measurementDB, actionDB *sql.DB, logger lager.Logger) Repo {
+	query := fmt.Sprintf(
+		objectReformersQueryPattern,
+		quoteIdentifier(recordsDBName),
+		broker.instancesTable("db_name", "max_tables", "max_routines"),
+		server.collate("instances.db_name"),
+		objectQuotaExceededPattern,
+		unescapeSchema("grants.table_schema"),
+	)
+	return newRepo(query, []string{ObjectQuotaRestriction}, server, measurementDB, actionDB, logger, "object quota reformer")
+}
+
+func newObjectQuotaRepo(pattern string, broker Broker, ignoredUsers []string, server Server, measurementDB, actionDB *sql.DB, logger lager.Logger, logTag string) Repo {
+	query := fmt.Sprintf(
+		pattern,
+		directGrantees(server, "'CREATE'", ignoredUsers),
+		broker.instancesTable("db_name", "max_tables", "max_routines"),
+		server.collate("instances.db_name"),
+		objectQuotaExceededPattern,
+	)
+	return newRepo(query, ignoredUsers, server, measurementDB, actionDB, logger, logTag)
+}
@@ -0,0 +1,116 @@
+package database_test
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"database/sql"
+
+	"errors"
+	"regexp"
+
+	"code.cloudfoundry.org/lager/lagertest"
+)
+
+var _ = Describe("ObjectQuotaRepo", func() {
+
+	const brokerDBName = "fake_broker_db_name"
+
+	var (
+		logger             *lagertest.TestLogger
+		server             Server
+		fakeDB             *sql.DB
+		mock               sqlmock.Sqlmock
+		tableSchemaColumns = []string{"db", "user", "host"}
+	)
+
+	BeforeEach(func() {
+		var err error
+		fakeDB, mock, err = sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		logger = lagertest.NewTestLogger("ObjectQuotaRepo test")
+		server = Server{Flavor: FlavorMySQL, Version: "5.7.40", Major: 5, Minor: 7}
+	})
+
+	AfterEach(func() {
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	Describe("NewObjectViolatorRepo", func() {
+		var repo Repo
+
+		BeforeEach(func() {
+			repo = NewObjectViolatorRepo(Broker{DBName: brokerDBName}, []string{"fake_admin_user"}, server, fakeDB, fakeDB, logger)
+		})
+
+		It("returns grantees holding CREATE on instances at their table or routine limit", func() {
+			mock.ExpectQuery("privilege_type IN \\('CREATE'\\)(.|\\n)*JOIN `fake_broker_db_name`\\.service_instances(.|\\n)*instances\\.max_tables <=(.|\\n)*instances\\.max_routines <=").
+				WithArgs("fake_admin_user").
+				WillReturnRows(sqlmock.NewRows(tableSchemaColumns).
+					AddRow("fake-database-1", "cf_fake-user-1", "%"))
+
+			violators, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(violators).To(ConsistOf(
+				New("fake-database-1", "cf_fake-user-1", "%", server, fakeDB, logger),
+			))
+		})
+
+		Context("when the broker lacks the routine limit column", func() {
+			BeforeEach(func() {
+				broker := Broker{DBName: brokerDBName, Missing: Missing{MaxRoutines: true}}
+				repo = NewObjectViolatorRepo(broker, []string{"fake_admin_user"}, server, fakeDB, fakeDB, logger)
+			})
+
+			It("reads no routine limit, enforcing table limits only", func() {
+				mock.ExpectQuery(regexp.QuoteMeta("JOIN (SELECT si.`db_name` AS db_name, si.`max_tables` AS max_tables, NULL AS max_routines " +
+					"FROM `fake_broker_db_name`.`service_instances` AS si) AS instances")).
+					WithArgs("fake_admin_user").
+					WillReturnRows(sqlmock.NewRows(tableSchemaColumns))
+
+				_, err := repo.All()
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the db query fails", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery(".*").
+					WillReturnError(errors.New("fake-query-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := repo.All()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-query-error"))
+			})
+		})
+	})
+
+	Describe("NewObjectReformerRepo", func() {
+		var repo Repo
+
+		BeforeEach(func() {
+			repo = NewObjectReformerRepo("fake_records_db", Broker{DBName: brokerDBName}, server, fakeDB, fakeDB, logger)
+		})
+
+		It("returns the recorded grantees still able to write on instances under their limits", func() {
+			mock.ExpectQuery("FROM `fake_records_db`.quota_enforcer_restrictions\\s+WHERE restriction = \\?(.|\\n)*WHERE NOT \\((.|\\n)*privilege_type = 'INSERT'").
+				WithArgs("object-quota").
+				WillReturnRows(sqlmock.NewRows(tableSchemaColumns).
+					AddRow("fake-database-1", "cf_fake-user-1", "%"))
+
+			reformers, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(reformers).To(ConsistOf(
+				New("fake-database-1", "cf_fake-user-1", "%", server, fakeDB, logger),
+			))
+		})
+	})
+})
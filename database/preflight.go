@@ -0,0 +1,244 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+)
+
+const globalScope = "*.*"
+
+const grantOption = "GRANT OPTION"
+
+var (
+	grantPattern      = regexp.MustCompile(`(?i)^GRANT\s+(.+?)\s+ON\s+(\S+)\s+TO\s+(.+)$`)
+	columnListPattern = regexp.MustCompile(`\s*\([^)]*\)`)
+)
+
+const brokerTablesQueryPattern = `
+SELECT table_name
+FROM information_schema.tables
+WHERE table_schema = ?
+AND table_name IN (%s)
+`
+
+// Preflight checks that the enforcer account holds the privileges the enforcer
+// needs, so that a missing privilege is found at startup rather than when an
+// instance first exceeds its quota.
+type Preflight interface {
+	Check() ([]string, error)
+}
+
+type preflight struct {
+	brokers    []Broker
+	server     Server
+	alterUsers bool
+	optimize   bool
+	db         *sql.DB
+	logger     lager.Logger
+}
+
+// NewPreflight returns a check of the grants of the current user. alterUsers
+// requires CREATE USER, which ALTER USER needs to change account limits and
+// locks, and optimize requires SELECT, which OPTIMIZE TABLE needs besides INSERT.
+// The tables of each broker are checked as mapped by its schema.
+func NewPreflight(brokers []Broker, server Server, alterUsers, optimize bool, db *sql.DB, logger lager.Logger) Preflight {
+	return &preflight{
+		brokers:    brokers,
+		server:     server,
+		alterUsers: alterUsers,
+		optimize:   optimize,
+		db:         db,
+		logger:     logger,
+	}
+}
+
+// Check returns what the enforcer account is missing, or nothing if it is
+// ready. Privileges granted through roles are not considered.
+func (p preflight) Check() ([]string, error) {
+	p.logger.Debug("Executing 'preflight'.Check")
+
+	grants, err := p.grants()
+	if err != nil {
+		return nil, err
+	}
+
+	missing := []string{}
+
+	// Granting back a privilege requires holding it with GRANT OPTION, on
+	// every instance database.
+	for _, privilege := range []string{"INSERT", "UPDATE", "CREATE", grantOption} {
+		if !grants.has(globalScope, privilege) {
+			missing = append(missing, missingPrivilege(privilege, globalScope))
+		}
+	}
+	if !grants.has(globalScope, "PROCESS") {
+		missing = append(missing, missingPrivilege("PROCESS", globalScope))
+	}
+	if !grants.hasAny(globalScope, "SUPER", "CONNECTION_ADMIN", "CONNECTION ADMIN") {
+		missing = append(missing, missingPrivilege("CONNECTION_ADMIN or SUPER", globalScope))
+	}
+	if p.server.requiresFlushPrivileges() && !grants.has(globalScope, "RELOAD") {
+		missing = append(missing, missingPrivilege("RELOAD", globalScope))
+	}
+	// On MySQL 8, revoking a role from its members and setting their default
+	// roles back also require ROLE_ADMIN and CREATE USER.
+	if p.server.restoresDefaultRoles() && !grants.hasAny(globalScope, "SUPER", "ROLE_ADMIN", "ROLE ADMIN") {
+		missing = append(missing, missingPrivilege("ROLE_ADMIN or SUPER", globalScope))
+	}
+	if (p.alterUsers || p.server.restoresDefaultRoles()) && !grants.has(globalScope, "CREATE USER") {
+		missing = append(missing, missingPrivilege("CREATE USER", globalScope))
+	}
+	if p.optimize && !grants.has(globalScope, "SELECT") {
+		missing = append(missing, missingPrivilege("SELECT", globalScope))
+	}
+
+	// Restrictions are recorded in DBName, the first broker database, along
+	// with the connection limit read from the accounts table. Grantees holding
+	// privileges through roles are found from the role tables.
+	recordsScope := p.brokers[0].quotedDBName() + ".*"
+	if !grants.has(globalScope, "DELETE") && !grants.has(recordsScope, "DELETE") {
+		missing = append(missing, missingPrivilege("DELETE", recordsScope))
+	}
+	for _, table := range p.server.systemTables() {
+		tableScope := quoteIdentifier("mysql") + "." + quoteIdentifier(strings.TrimPrefix(table, "mysql."))
+		if !grants.has(globalScope, "SELECT") && !grants.has(quoteIdentifier("mysql")+".*", "SELECT") && !grants.has(tableScope, "SELECT") {
+			missing = append(missing, missingPrivilege("SELECT", tableScope))
+		}
+	}
+
+	for _, broker := range p.brokers {
+		brokerScope := broker.quotedDBName() + ".*"
+		for _, table := range broker.tables() {
+			tableScope := broker.quotedDBName() + "." + quoteIdentifier(table)
+			if !grants.has(globalScope, "SELECT") && !grants.has(brokerScope, "SELECT") && !grants.has(tableScope, "SELECT") {
+				missing = append(missing, missingPrivilege("SELECT", tableScope))
+			}
+		}
+	}
+
+	for _, broker := range p.brokers {
+		missingTables, err := p.missingBrokerTables(broker)
+		if err != nil {
+			return nil, err
+		}
+		missing = append(missing, missingTables...)
+	}
+
+	return missing, nil
+}
+
+func (p preflight) grants() (grants, error) {
+	rows, err := p.db.Query("SHOW GRANTS FOR CURRENT_USER()")
+	if err != nil {
+		return nil, fmt.Errorf("Showing grants of the enforcer account: %s", err.Error())
+	}
+
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	grants := grants{}
+	for rows.Next() {
+		var grant string
+		if err := rows.Scan(&grant); err != nil {
+			return nil, fmt.Errorf("Scanning grant of the enforcer account: %s", err.Error())
+		}
+		grants.add(grant)
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Reading grants of the enforcer account: %s", err.Error())
+	}
+
+	return grants, nil
+}
+
+func (p preflight) missingBrokerTables(broker Broker) ([]string, error) {
+	tables := broker.tables()
+	parameters := []interface{}{broker.DBName}
+	for _, table := range tables {
+		parameters = append(parameters, table)
+	}
+	placeholders := strings.Join(strings.Split(strings.Repeat("?", len(tables)), ""), ",")
+
+	rows, err := p.db.Query(fmt.Sprintf(brokerTablesQueryPattern, placeholders), parameters...)
+	if err != nil {
+		return nil, fmt.Errorf("Finding broker tables: %s", err.Error())
+	}
+
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	found := map[string]bool{}
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, fmt.Errorf("Scanning broker table: %s", err.Error())
+		}
+		found[table] = true
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Reading broker tables: %s", err.Error())
+	}
+
+	missing := []string{}
+	for _, table := range tables {
+		if !found[table] {
+			missing = append(missing, fmt.Sprintf("missing table: %s.%s", broker.quotedDBName(), quoteIdentifier(table)))
+		}
+	}
+	return missing, nil
+}
+
+func missingPrivilege(privilege, scope string) string {
+	return fmt.Sprintf("missing privilege: %s ON %s", privilege, scope)
+}
+
+// grants holds the privileges of an account by scope, e.g. *.* or `db`.*.
+type grants map[string]map[string]bool
+
+// add parses a row of SHOW GRANTS. Column privileges are skipped, as are
+// grants of roles, which have no ON clause.
+func (g grants) add(grant string) {
+	matches := grantPattern.FindStringSubmatch(grant)
+	if matches == nil {
+		return
+	}
+
+	privileges, scope, to := matches[1], matches[2], matches[3]
+	if g[scope] == nil {
+		g[scope] = map[string]bool{}
+	}
+
+	for _, privilege := range strings.Split(columnListPattern.ReplaceAllString(privileges, "(columns)"), ",") {
+		privilege = strings.ToUpper(strings.TrimSpace(privilege))
+		if privilege == "ALL" {
+			privilege = "ALL PRIVILEGES"
+		}
+		g[scope][privilege] = true
+	}
+
+	if strings.Contains(strings.ToUpper(to), "WITH GRANT OPTION") {
+		g[scope][grantOption] = true
+	}
+}
+
+// has is true if the privilege is held on the scope. ALL PRIVILEGES includes
+// every privilege but GRANT OPTION.
+func (g grants) has(scope, privilege string) bool {
+	privileges := g[scope]
+	return privileges[privilege] || privilege != grantOption && privileges["ALL PRIVILEGES"]
+}
+
+func (g grants) hasAny(scope string, privileges ...string) bool {
+	for _, privilege := range privileges {
+		if g.has(scope, privilege) {
+			return true
+		}
+	}
+	return false
+}
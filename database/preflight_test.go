@@ -0,0 +1,260 @@
+package database_test
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"database/sql"
+
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+)
+
+var _ = Describe("Preflight", func() {
+
+	const brokerDBName = "fake_broker_db_name"
+
+	var (
+		logger       *lagertest.TestLogger
+		server       Server
+		alterUsers   bool
+		optimize     bool
+		fakeDB       *sql.DB
+		mock         sqlmock.Sqlmock
+		grants       []string
+		brokerTables []string
+	)
+
+	BeforeEach(func() {
+		var err error
+		fakeDB, mock, err = sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		logger = lagertest.NewTestLogger("Preflight test")
+		server = Server{Flavor: FlavorMySQL, Version: "8.0.32", Major: 8, Minor: 0}
+		alterUsers = false
+		optimize = false
+		brokerTables = []string{"service_instances", "read_only_users"}
+	})
+
+	AfterEach(func() {
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	check := func() ([]string, error) {
+		grantRows := sqlmock.NewRows([]string{"grants"})
+		for _, grant := range grants {
+			grantRows.AddRow(grant)
+		}
+		mock.ExpectQuery("SHOW GRANTS FOR CURRENT_USER\\(\\)").
+			WillReturnRows(grantRows)
+
+		tableRows := sqlmock.NewRows([]string{"table_name"})
+		for _, table := range brokerTables {
+			tableRows.AddRow(table)
+		}
+		mock.ExpectQuery("FROM information_schema.tables").
+			WithArgs(brokerDBName, "service_instances", "read_only_users").
+			WillReturnRows(tableRows)
+
+		return NewPreflight([]Broker{{DBName: brokerDBName}}, server, alterUsers, optimize, fakeDB, logger).Check()
+	}
+
+	Context("when the account has all privileges with GRANT OPTION", func() {
+		BeforeEach(func() {
+			grants = []string{"GRANT ALL PRIVILEGES ON *.* TO 'quota-enforcer'@'%' WITH GRANT OPTION"}
+			alterUsers = true
+		})
+
+		It("reports nothing missing", func() {
+			missing, err := check()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missing).To(BeEmpty())
+		})
+	})
+
+	Context("when the account has exactly the privileges it needs", func() {
+		BeforeEach(func() {
+			grants = []string{
+				"GRANT INSERT, UPDATE, CREATE, PROCESS, CREATE USER ON *.* TO `quota-enforcer`@`%` WITH GRANT OPTION",
+				"GRANT CONNECTION_ADMIN, ROLE_ADMIN ON *.* TO `quota-enforcer`@`%`",
+				"GRANT SELECT, DELETE ON `fake_broker_db_name`.* TO `quota-enforcer`@`%`",
+				"GRANT SELECT ON `mysql`.`user` TO `quota-enforcer`@`%`",
+				"GRANT SELECT ON `mysql`.`role_edges` TO `quota-enforcer`@`%`",
+				"GRANT SELECT ON `mysql`.`default_roles` TO `quota-enforcer`@`%`",
+			}
+		})
+
+		It("reports nothing missing", func() {
+			missing, err := check()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missing).To(BeEmpty())
+		})
+	})
+
+	Context("when the account lacks privileges", func() {
+		BeforeEach(func() {
+			grants = []string{
+				"GRANT USAGE ON *.* TO `quota-enforcer`@`%`",
+				"GRANT INSERT, UPDATE, CREATE ON *.* TO `quota-enforcer`@`%`",
+				"GRANT SELECT (`guid`, `db_name`) ON `fake_broker_db_name`.`service_instances` TO `quota-enforcer`@`%`",
+				"GRANT SELECT ON `fake_broker_db_name`.`read_only_users` TO `quota-enforcer`@`%`",
+			}
+			alterUsers = true
+		})
+
+		It("lists each missing privilege", func() {
+			missing, err := check()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missing).To(Equal([]string{
+				"missing privilege: GRANT OPTION ON *.*",
+				"missing privilege: PROCESS ON *.*",
+				"missing privilege: CONNECTION_ADMIN or SUPER ON *.*",
+				"missing privilege: ROLE_ADMIN or SUPER ON *.*",
+				"missing privilege: CREATE USER ON *.*",
+				"missing privilege: DELETE ON `fake_broker_db_name`.*",
+				"missing privilege: SELECT ON `mysql`.`user`",
+				"missing privilege: SELECT ON `mysql`.`role_edges`",
+				"missing privilege: SELECT ON `mysql`.`default_roles`",
+				"missing privilege: SELECT ON `fake_broker_db_name`.`service_instances`",
+			}))
+		})
+	})
+
+	Context("when tables are optimized to reclaim space", func() {
+		BeforeEach(func() {
+			grants = []string{
+				"GRANT INSERT, UPDATE, CREATE, PROCESS, CREATE USER ON *.* TO `quota-enforcer`@`%` WITH GRANT OPTION",
+				"GRANT CONNECTION_ADMIN, ROLE_ADMIN ON *.* TO `quota-enforcer`@`%`",
+				"GRANT SELECT, DELETE ON `fake_broker_db_name`.* TO `quota-enforcer`@`%`",
+				"GRANT SELECT ON `mysql`.* TO `quota-enforcer`@`%`",
+			}
+			optimize = true
+		})
+
+		It("requires SELECT on every database", func() {
+			missing, err := check()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missing).To(Equal([]string{"missing privilege: SELECT ON *.*"}))
+		})
+	})
+
+	Context("when the server requires FLUSH PRIVILEGES", func() {
+		BeforeEach(func() {
+			server = Server{Flavor: FlavorMariaDB, Version: "10.6.12-MariaDB", Major: 10, Minor: 6}
+			grants = []string{
+				"GRANT INSERT, UPDATE, CREATE, PROCESS, SUPER ON *.* TO `quota-enforcer`@`%` WITH GRANT OPTION",
+				"GRANT SELECT, DELETE ON `fake_broker_db_name`.* TO `quota-enforcer`@`%`",
+				"GRANT SELECT ON `mysql`.`global_priv` TO `quota-enforcer`@`%`",
+				"GRANT SELECT ON `mysql`.`roles_mapping` TO `quota-enforcer`@`%`",
+			}
+		})
+
+		It("requires RELOAD", func() {
+			missing, err := check()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missing).To(Equal([]string{"missing privilege: RELOAD ON *.*"}))
+		})
+	})
+
+	Context("when broker tables are missing", func() {
+		BeforeEach(func() {
+			grants = []string{"GRANT ALL PRIVILEGES ON *.* TO 'quota-enforcer'@'%' WITH GRANT OPTION"}
+			brokerTables = []string{"service_instances"}
+		})
+
+		It("lists each missing table", func() {
+			missing, err := check()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missing).To(Equal([]string{"missing table: `fake_broker_db_name`.`read_only_users`"}))
+		})
+	})
+
+	Context("when there are several broker databases", func() {
+		It("checks the privileges and tables of each", func() {
+			mock.ExpectQuery("SHOW GRANTS FOR CURRENT_USER\\(\\)").
+				WillReturnRows(sqlmock.NewRows([]string{"grants"}).
+					AddRow("GRANT INSERT, UPDATE, CREATE, PROCESS, CREATE USER ON *.* TO `quota-enforcer`@`%` WITH GRANT OPTION").
+					AddRow("GRANT CONNECTION_ADMIN, ROLE_ADMIN ON *.* TO `quota-enforcer`@`%`").
+					AddRow("GRANT SELECT, DELETE ON `fake_broker_db_name`.* TO `quota-enforcer`@`%`").
+					AddRow("GRANT SELECT ON `mysql`.* TO `quota-enforcer`@`%`"))
+			mock.ExpectQuery("FROM information_schema.tables").
+				WithArgs(brokerDBName, "service_instances", "read_only_users").
+				WillReturnRows(sqlmock.NewRows([]string{"table_name"}).AddRow("service_instances").AddRow("read_only_users"))
+			mock.ExpectQuery("FROM information_schema.tables").
+				WithArgs("fake_other_broker", "service_instances", "read_only_users").
+				WillReturnRows(sqlmock.NewRows([]string{"table_name"}).AddRow("service_instances"))
+
+			missing, err := NewPreflight([]Broker{{DBName: brokerDBName}, {DBName: "fake_other_broker"}}, server, alterUsers, optimize, fakeDB, logger).Check()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missing).To(Equal([]string{
+				"missing privilege: SELECT ON `fake_other_broker`.`service_instances`",
+				"missing privilege: SELECT ON `fake_other_broker`.`read_only_users`",
+				"missing table: `fake_other_broker`.`read_only_users`",
+			}))
+		})
+	})
+
+	Context("when the broker lacks read-only users", func() {
+		It("does not require the read-only users table", func() {
+			mock.ExpectQuery("SHOW GRANTS FOR CURRENT_USER\\(\\)").
+				WillReturnRows(sqlmock.NewRows([]string{"grants"}).
+					AddRow("GRANT ALL PRIVILEGES ON *.* TO `quota-enforcer`@`%` WITH GRANT OPTION"))
+			mock.ExpectQuery("FROM information_schema.tables").
+				WithArgs(brokerDBName, "service_instances").
+				WillReturnRows(sqlmock.NewRows([]string{"table_name"}).AddRow("service_instances"))
+
+			broker := Broker{DBName: brokerDBName, Missing: Missing{ReadOnlyUsers: true}}
+			missing, err := NewPreflight([]Broker{broker}, server, alterUsers, optimize, fakeDB, logger).Check()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missing).To(BeEmpty())
+		})
+	})
+
+	Context("when the broker has another schema", func() {
+		It("checks the tables mapped by the schema", func() {
+			mock.ExpectQuery("SHOW GRANTS FOR CURRENT_USER\\(\\)").
+				WillReturnRows(sqlmock.NewRows([]string{"grants"}).
+					AddRow("GRANT ALL PRIVILEGES ON *.* TO `quota-enforcer`@`%` WITH GRANT OPTION"))
+			mock.ExpectQuery("FROM information_schema.tables").
+				WithArgs(brokerDBName, "instances", "readers", "plans").
+				WillReturnRows(sqlmock.NewRows([]string{"table_name"}).AddRow("instances").AddRow("plans"))
+
+			broker := Broker{DBName: brokerDBName, Schema: config.BrokerSchema{
+				InstancesTable:     "instances",
+				PlansTable:         "plans",
+				ReadOnlyUsersTable: "readers",
+			}}
+			missing, err := NewPreflight([]Broker{broker}, server, alterUsers, optimize, fakeDB, logger).Check()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missing).To(Equal([]string{"missing table: `fake_broker_db_name`.`readers`"}))
+		})
+	})
+
+	Context("when showing grants fails", func() {
+		It("returns an error", func() {
+			mock.ExpectQuery("SHOW GRANTS").
+				WillReturnError(errors.New("fake-query-error"))
+
+			_, err := NewPreflight([]Broker{{DBName: brokerDBName}}, server, alterUsers, optimize, fakeDB, logger).Check()
+			Expect(err).To(MatchError("Showing grants of the enforcer account: fake-query-error"))
+		})
+	})
+
+	Context("when finding broker tables fails", func() {
+		It("returns an error", func() {
+			mock.ExpectQuery("SHOW GRANTS").
+				WillReturnRows(sqlmock.NewRows([]string{"grants"}))
+			mock.ExpectQuery("FROM information_schema.tables").
+				WillReturnError(errors.New("fake-query-error"))
+
+			_, err := NewPreflight([]Broker{{DBName: brokerDBName}}, server, alterUsers, optimize, fakeDB, logger).Check()
+			Expect(err).To(MatchError("Finding broker tables: fake-query-error"))
+		})
+	})
+})
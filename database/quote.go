@@ -0,0 +1,23 @@
+package database
+
+import "strings"
+
+// quoteIdentifier quotes a database, table or column name with backticks so
+// it can be used in SQL regardless of the characters it contains.
+func quoteIdentifier(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+// quoteString quotes a value as a SQL string literal, e.g. a user name in a
+// GRANT statement, where placeholders are not allowed.
+func quoteString(value string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		`'`, `''`,
+		"\x00", `\0`,
+		"\n", `\n`,
+		"\r", `\r`,
+		"\x1a", `\Z`,
+	)
+	return "'" + replacer.Replace(value) + "'"
+}
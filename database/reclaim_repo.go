@@ -0,0 +1,236 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// The reclaims table is owned by the enforcer and lives in the broker
+// database. It holds one row per table rebuilt to reclaim free space.
+const createReclaimsTableQuery = `
+CREATE TABLE IF NOT EXISTS %s.quota_enforcer_reclaims (
+	id                  BIGINT       NOT NULL AUTO_INCREMENT,
+	db_name             VARCHAR(255) NOT NULL,
+	table_name          VARCHAR(255) NOT NULL,
+	started_at          DATETIME     NOT NULL,
+	duration_seconds    DOUBLE       NOT NULL,
+	size_before_mb      DOUBLE       NOT NULL,
+	data_free_before_mb DOUBLE       NOT NULL,
+	size_after_mb       DOUBLE       NULL,
+	data_free_after_mb  DOUBLE       NULL,
+	error               TEXT         NULL,
+	PRIMARY KEY (id),
+	KEY (db_name, started_at)
+)`
+
+const recordReclaimQuery = `
+INSERT INTO %s.quota_enforcer_reclaims
+	(db_name, table_name, started_at, duration_seconds, size_before_mb, data_free_before_mb, size_after_mb, data_free_after_mb, error)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// Failed rebuilds count too, so a table failing to rebuild is not retried all
+// window long.
+const rebuiltTablesQuery = `
+SELECT DISTINCT table_name
+FROM %s.quota_enforcer_reclaims
+WHERE db_name = ? AND started_at >= ?
+`
+
+const tableSizesSubquery = `
+SELECT table_name,
+	ROUND(COALESCE(data_length + index_length, 0) / 1024 / 1024, 1) AS size_mb,
+	ROUND(COALESCE(data_free, 0) / 1024 / 1024, 1) AS data_free_mb
+FROM information_schema.tables
+WHERE table_schema = ? AND table_type = 'BASE TABLE'
+`
+
+const fragmentedTablesQuery = `
+SELECT table_name, size_mb, data_free_mb
+FROM (` + tableSizesSubquery + `) AS sizes
+WHERE data_free_mb >= ? AND size_mb <= ?
+ORDER BY data_free_mb DESC, table_name
+`
+
+const measureTableQuery = `
+SELECT table_name, size_mb, data_free_mb
+FROM (` + tableSizesSubquery + `) AS sizes
+WHERE table_name = ?
+`
+
+// OPTIMIZE TABLE recreates InnoDB tables and analyzes them afterwards, so
+// information_schema.tables reflects the new size right away.
+const optimizeTableQuery = `OPTIMIZE TABLE %s.%s`
+
+// Reclaim is the outcome of rebuilding one table. After is zero if Error is set.
+type Reclaim struct {
+	DBName    string
+	Before    TableSize
+	After     TableSize
+	StartedAt time.Time
+	Duration  time.Duration
+	Error     string
+}
+
+type ReclaimRepo interface {
+	Setup() error
+	Fragmented(dbName string, minDataFreeMB, maxSizeMB float64) ([]TableSize, error)
+	Rebuilt(dbName string, since time.Time) (map[string]bool, error)
+	Rebuild(dbName, tableName string) error
+	Measure(dbName, tableName string) (TableSize, error)
+	Record(reclaim Reclaim) error
+}
+
+type reclaimRepo struct {
+	brokerDBName string
+	db           *sql.DB
+	logger       lager.Logger
+}
+
+func NewReclaimRepo(brokerDBName string, db *sql.DB, logger lager.Logger) ReclaimRepo {
+	return &reclaimRepo{
+		brokerDBName: quoteIdentifier(brokerDBName),
+		db:           db,
+		logger:       logger,
+	}
+}
+
+// Setup creates the reclaims table if it does not exist yet.
+func (r reclaimRepo) Setup() error {
+	_, err := r.db.Exec(fmt.Sprintf(createReclaimsTableQuery, r.brokerDBName))
+	if err != nil {
+		return fmt.Errorf("Creating reclaims table: %s", err.Error())
+	}
+	return nil
+}
+
+// Fragmented returns the tables of an instance database with at least
+// minDataFreeMB free and of at most maxSizeMB, most free space first.
+func (r reclaimRepo) Fragmented(dbName string, minDataFreeMB, maxSizeMB float64) ([]TableSize, error) {
+	r.logger.Debug(fmt.Sprintf("Executing 'reclaim'.Fragmented for db '%s'", dbName))
+
+	tables := []TableSize{}
+
+	rows, err := r.db.Query(fragmentedTablesQuery, dbName, minDataFreeMB, maxSizeMB)
+	if err != nil {
+		return tables, fmt.Errorf("Finding fragmented tables of db '%s': %s", dbName, err.Error())
+	}
+
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	for rows.Next() {
+		var table TableSize
+		if err := rows.Scan(&table.Name, &table.SizeMB, &table.DataFreeMB); err != nil {
+			return tables, fmt.Errorf("Scanning fragmented table of db '%s': %s", dbName, err.Error())
+		}
+		tables = append(tables, table)
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return tables, fmt.Errorf("Reading fragmented tables of db '%s': %s", dbName, err.Error())
+	}
+
+	return tables, nil
+}
+
+// Rebuilt returns the names of the tables of an instance database whose
+// rebuild was started since the given time.
+func (r reclaimRepo) Rebuilt(dbName string, since time.Time) (map[string]bool, error) {
+	r.logger.Debug(fmt.Sprintf("Executing 'reclaim'.Rebuilt for db '%s'", dbName))
+
+	tables := map[string]bool{}
+
+	rows, err := r.db.Query(fmt.Sprintf(rebuiltTablesQuery, r.brokerDBName), dbName, since.UTC())
+	if err != nil {
+		return tables, fmt.Errorf("Finding rebuilt tables of db '%s': %s", dbName, err.Error())
+	}
+
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	for rows.Next() {
+		var tableName string
+		if err := rows.Scan(&tableName); err != nil {
+			return tables, fmt.Errorf("Scanning rebuilt table of db '%s': %s", dbName, err.Error())
+		}
+		tables[tableName] = true
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return tables, fmt.Errorf("Reading rebuilt tables of db '%s': %s", dbName, err.Error())
+	}
+
+	return tables, nil
+}
+
+// Rebuild optimizes a table. OPTIMIZE TABLE reports failures as result rows
+// rather than errors, so the first error row is returned.
+func (r reclaimRepo) Rebuild(dbName, tableName string) error {
+	r.logger.Info(fmt.Sprintf("Optimizing table '%s' of db '%s'", tableName, dbName))
+
+	rows, err := r.db.Query(fmt.Sprintf(optimizeTableQuery, quoteIdentifier(dbName), quoteIdentifier(tableName)))
+	if err != nil {
+		return fmt.Errorf("Optimizing table '%s' of db '%s': %s", tableName, dbName, err.Error())
+	}
+
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	for rows.Next() {
+		var table, op, msgType, msgText string
+		if err := rows.Scan(&table, &op, &msgType, &msgText); err != nil {
+			return fmt.Errorf("Scanning result of optimizing table '%s' of db '%s': %s", tableName, dbName, err.Error())
+		}
+		if msgType == "error" {
+			return fmt.Errorf("Optimizing table '%s' of db '%s': %s", tableName, dbName, msgText)
+		}
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("Reading result of optimizing table '%s' of db '%s': %s", tableName, dbName, err.Error())
+	}
+
+	return nil
+}
+
+// Measure returns the current size of a table.
+func (r reclaimRepo) Measure(dbName, tableName string) (TableSize, error) {
+	var table TableSize
+	err := r.db.QueryRow(measureTableQuery, dbName, tableName).Scan(&table.Name, &table.SizeMB, &table.DataFreeMB)
+	if err != nil {
+		return table, fmt.Errorf("Measuring table '%s' of db '%s': %s", tableName, dbName, err.Error())
+	}
+	return table, nil
+}
+
+// Record stores the outcome of rebuilding a table.
+func (r reclaimRepo) Record(reclaim Reclaim) error {
+	var sizeAfterMB, dataFreeAfterMB sql.NullFloat64
+	var reclaimErr sql.NullString
+	if reclaim.Error != "" {
+		reclaimErr = sql.NullString{String: reclaim.Error, Valid: true}
+	} else {
+		sizeAfterMB = sql.NullFloat64{Float64: reclaim.After.SizeMB, Valid: true}
+		dataFreeAfterMB = sql.NullFloat64{Float64: reclaim.After.DataFreeMB, Valid: true}
+	}
+
+	_, err := r.db.Exec(
+		fmt.Sprintf(recordReclaimQuery, r.brokerDBName),
+		reclaim.DBName,
+		reclaim.Before.Name,
+		reclaim.StartedAt.UTC(),
+		reclaim.Duration.Seconds(),
+		reclaim.Before.SizeMB,
+		reclaim.Before.DataFreeMB,
+		sizeAfterMB,
+		dataFreeAfterMB,
+		reclaimErr,
+	)
+	if err != nil {
+		return fmt.Errorf("Recording reclaim of table '%s' of db '%s': %s", reclaim.Before.Name, reclaim.DBName, err.Error())
+	}
+	return nil
+}
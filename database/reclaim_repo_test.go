@@ -0,0 +1,227 @@
+package database_test
+
+import (
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"database/sql"
+
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+)
+
+var _ = Describe("ReclaimRepo", func() {
+
+	const brokerDBName = "fake_broker_db_name"
+
+	var (
+		logger        *lagertest.TestLogger
+		repo          ReclaimRepo
+		fakeDB        *sql.DB
+		mock          sqlmock.Sqlmock
+		tableColumns  = []string{"table_name", "size_mb", "data_free_mb"}
+		resultColumns = []string{"Table", "Op", "Msg_type", "Msg_text"}
+	)
+
+	BeforeEach(func() {
+		var err error
+		fakeDB, mock, err = sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		logger = lagertest.NewTestLogger("ReclaimRepo test")
+		repo = NewReclaimRepo(brokerDBName, fakeDB, logger)
+	})
+
+	AfterEach(func() {
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	Describe("Setup", func() {
+		It("creates the reclaims table in the broker database", func() {
+			mock.ExpectExec("CREATE TABLE IF NOT EXISTS `fake_broker_db_name`\\.quota_enforcer_reclaims").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+
+			Expect(repo.Setup()).To(Succeed())
+		})
+
+		Context("when creating the table fails", func() {
+			BeforeEach(func() {
+				mock.ExpectExec("CREATE TABLE").
+					WillReturnError(errors.New("fake-create-error"))
+			})
+
+			It("returns an error", func() {
+				Expect(repo.Setup()).To(MatchError("Creating reclaims table: fake-create-error"))
+			})
+		})
+	})
+
+	Describe("Fragmented", func() {
+		It("returns the tables with enough free space within the size limit", func() {
+			mock.ExpectQuery("FROM information_schema.tables(.|\\n)*WHERE data_free_mb >= \\? AND size_mb <= \\?").
+				WithArgs("fake-db-name", 100.0, 1024.0).
+				WillReturnRows(sqlmock.NewRows(tableColumns).
+					AddRow("fragmented_table", 500, 300).
+					AddRow("other_table", 200, 100))
+
+			tables, err := repo.Fragmented("fake-db-name", 100, 1024)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tables).To(Equal([]TableSize{
+				{Name: "fragmented_table", SizeMB: 500, DataFreeMB: 300},
+				{Name: "other_table", SizeMB: 200, DataFreeMB: 100},
+			}))
+		})
+
+		Context("when the db query fails", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery(".*").
+					WillReturnError(errors.New("fake-query-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := repo.Fragmented("fake-db-name", 100, 1024)
+				Expect(err).To(MatchError("Finding fragmented tables of db 'fake-db-name': fake-query-error"))
+			})
+		})
+	})
+
+	Describe("Rebuilt", func() {
+		It("returns the tables rebuilt since the given time", func() {
+			since := time.Date(2016, 5, 4, 2, 0, 0, 0, time.UTC)
+			mock.ExpectQuery("SELECT DISTINCT table_name\\s+FROM `fake_broker_db_name`\\.quota_enforcer_reclaims\\s+WHERE db_name = \\? AND started_at >= \\?").
+				WithArgs("fake-db-name", since).
+				WillReturnRows(sqlmock.NewRows([]string{"table_name"}).
+					AddRow("rebuilt_table").
+					AddRow("failed_table"))
+
+			tables, err := repo.Rebuilt("fake-db-name", since)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tables).To(Equal(map[string]bool{"rebuilt_table": true, "failed_table": true}))
+		})
+
+		Context("when the db query fails", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery("SELECT").
+					WillReturnError(errors.New("fake-query-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := repo.Rebuilt("fake-db-name", time.Now())
+				Expect(err).To(MatchError("Finding rebuilt tables of db 'fake-db-name': fake-query-error"))
+			})
+		})
+	})
+
+	Describe("Rebuild", func() {
+		It("optimizes the table", func() {
+			mock.ExpectQuery("OPTIMIZE TABLE `fake-db-name`\\.`fragmented_table`").
+				WillReturnRows(sqlmock.NewRows(resultColumns).
+					AddRow("fake-db-name.fragmented_table", "optimize", "note", "Table does not support optimize, doing recreate + analyze instead").
+					AddRow("fake-db-name.fragmented_table", "optimize", "status", "OK"))
+
+			Expect(repo.Rebuild("fake-db-name", "fragmented_table")).To(Succeed())
+		})
+
+		Context("when the server reports an error row", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery("OPTIMIZE TABLE").
+					WillReturnRows(sqlmock.NewRows(resultColumns).
+						AddRow("fake-db-name.fragmented_table", "optimize", "error", "fake-optimize-error").
+						AddRow("fake-db-name.fragmented_table", "optimize", "status", "Operation failed"))
+			})
+
+			It("returns an error", func() {
+				Expect(repo.Rebuild("fake-db-name", "fragmented_table")).To(MatchError(
+					"Optimizing table 'fragmented_table' of db 'fake-db-name': fake-optimize-error"))
+			})
+		})
+
+		Context("when the db query fails", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery("OPTIMIZE TABLE").
+					WillReturnError(errors.New("fake-query-error"))
+			})
+
+			It("returns an error", func() {
+				Expect(repo.Rebuild("fake-db-name", "fragmented_table")).To(MatchError(
+					"Optimizing table 'fragmented_table' of db 'fake-db-name': fake-query-error"))
+			})
+		})
+	})
+
+	Describe("Measure", func() {
+		It("returns the size of the table", func() {
+			mock.ExpectQuery("FROM information_schema.tables(.|\\n)*WHERE table_name = \\?").
+				WithArgs("fake-db-name", "fragmented_table").
+				WillReturnRows(sqlmock.NewRows(tableColumns).
+					AddRow("fragmented_table", 210, 4))
+
+			table, err := repo.Measure("fake-db-name", "fragmented_table")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(table).To(Equal(TableSize{Name: "fragmented_table", SizeMB: 210, DataFreeMB: 4}))
+		})
+
+		Context("when the table no longer exists", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery("FROM information_schema.tables").
+					WillReturnRows(sqlmock.NewRows(tableColumns))
+			})
+
+			It("returns an error", func() {
+				_, err := repo.Measure("fake-db-name", "fragmented_table")
+				Expect(err).To(MatchError(ContainSubstring("Measuring table 'fragmented_table' of db 'fake-db-name'")))
+			})
+		})
+	})
+
+	Describe("Record", func() {
+		var reclaim Reclaim
+
+		BeforeEach(func() {
+			reclaim = Reclaim{
+				DBName:    "fake-db-name",
+				Before:    TableSize{Name: "fragmented_table", SizeMB: 500, DataFreeMB: 300},
+				After:     TableSize{Name: "fragmented_table", SizeMB: 210, DataFreeMB: 4},
+				StartedAt: time.Date(2016, 5, 4, 3, 2, 1, 0, time.UTC),
+				Duration:  90 * time.Second,
+			}
+		})
+
+		It("stores the sizes before and after", func() {
+			mock.ExpectExec("INSERT INTO `fake_broker_db_name`\\.quota_enforcer_reclaims").
+				WithArgs("fake-db-name", "fragmented_table", reclaim.StartedAt, 90.0, 500.0, 300.0, 210.0, 4.0, nil).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+
+			Expect(repo.Record(reclaim)).To(Succeed())
+		})
+
+		It("stores the error of a failed rebuild without sizes after", func() {
+			reclaim.After = TableSize{}
+			reclaim.Error = "fake-rebuild-error"
+
+			mock.ExpectExec("INSERT INTO `fake_broker_db_name`\\.quota_enforcer_reclaims").
+				WithArgs("fake-db-name", "fragmented_table", reclaim.StartedAt, 90.0, 500.0, 300.0, nil, nil, "fake-rebuild-error").
+				WillReturnResult(sqlmock.NewResult(1, 1))
+
+			Expect(repo.Record(reclaim)).To(Succeed())
+		})
+
+		Context("when the db exec fails", func() {
+			BeforeEach(func() {
+				mock.ExpectExec("INSERT").
+					WillReturnError(errors.New("fake-exec-error"))
+			})
+
+			It("returns an error", func() {
+				Expect(repo.Record(reclaim)).To(MatchError(
+					"Recording reclaim of table 'fragmented_table' of db 'fake-db-name': fake-exec-error"))
+			})
+		})
+	})
+})
@@ -8,31 +8,69 @@ import (
 	"code.cloudfoundry.org/lager"
 )
 
-// LEFT JOIN is required so that dropping all tables will restore write access
+// LEFT JOIN is required so that dropping all tables will restore write access.
+// The user, host and name are extracted from the grant in the same way as in violatorsQueryPattern.
+// CREATE is left out, as the object quota enforcer may have revoked it alone.
+// A grantee with an object quota restriction recorded is create_restricted, so
+// that CREATE is left for the object quota enforcer to grant.
 const reformersQueryPattern = `
-SELECT reformers.name AS reformer_db, reformers.user AS reformer_user
+SELECT reformers.name AS reformer_db, reformers.user AS reformer_user, reformers.host AS reformer_host, reformers.grant_schema,
+	EXISTS (
+		SELECT 1 FROM %[7]s.quota_enforcer_restrictions AS restrictions
+		WHERE %[8]s = reformers.name AND %[9]s = reformers.user AND %[10]s = reformers.host
+		AND restrictions.restriction = ?
+	) AS create_restricted
 FROM (
-	SELECT violator_dbs.name, violator_dbs.user, tables.data_length, tables.index_length
+	SELECT violator_dbs.name, violator_dbs.grant_schema, violator_dbs.user, violator_dbs.host
 	FROM   (
-		SELECT DISTINCT table_schema as name, replace(substring_index(schema_privileges.grantee, '@', 1), "'", '') AS user
+		SELECT DISTINCT %[6]s as name, table_schema AS grant_schema,
+			SUBSTRING(schema_privileges.grantee, 2, CHAR_LENGTH(schema_privileges.grantee) - CHAR_LENGTH(SUBSTRING_INDEX(schema_privileges.grantee, '@', -1)) - 3) AS user,
+			TRIM(BOTH "'" FROM SUBSTRING_INDEX(schema_privileges.grantee, '@', -1)) AS host
 		FROM information_schema.schema_privileges
-		LEFT JOIN %s.read_only_users
-			ON read_only_users.grantee = schema_privileges.grantee COLLATE utf8_general_ci
-		WHERE privilege_type IN ('SELECT', 'INSERT', 'UPDATE', 'CREATE')
-		  AND replace(substring_index(schema_privileges.grantee, '@', 1), "'", '') NOT IN (%s)
+		LEFT JOIN %[5]s AS read_only_users
+			ON read_only_users.grantee = %[3]s
+		WHERE privilege_type IN ('SELECT', 'INSERT', 'UPDATE')
+		  AND SUBSTRING(schema_privileges.grantee, 2, CHAR_LENGTH(schema_privileges.grantee) - CHAR_LENGTH(SUBSTRING_INDEX(schema_privileges.grantee, '@', -1)) - 3) NOT IN (%[2]s)
 		  AND read_only_users.id IS NULL
 		GROUP BY schema_privileges.grantee, table_schema
-		HAVING count(*) != 4
+		HAVING count(*) != 3
 	) AS violator_dbs
-	JOIN        %s.service_instances AS instances ON violator_dbs.name = instances.db_name COLLATE utf8_general_ci
+	JOIN        %[1]s AS instances ON violator_dbs.name = %[4]s
 	LEFT JOIN   information_schema.tables AS tables ON tables.table_schema = violator_dbs.name
-	GROUP  BY   violator_dbs.user
+	GROUP  BY   violator_dbs.name, violator_dbs.grant_schema, violator_dbs.user, violator_dbs.host
 	HAVING ROUND(SUM(COALESCE(tables.data_length + tables.index_length,0) / 1024 / 1024), 1) < MAX(instances.max_storage_mb)
 ) AS reformers
 `
 
-func NewReformerRepo(brokerDBName string, ignoredUsers []string, db *sql.DB, logger lager.Logger) Repo {
+// NewReformerRepo finds the grantees to reverse strategy on. Revoked writes are
+// found from the privileges themselves, and roles revoked from their members
+// and any other restriction from the records kept in recordsDBName.
+func NewReformerRepo(recordsDBName string, broker Broker, ignoredUsers []string, server Server, strategy Strategy, measurementDB, actionDB *sql.DB, logger lager.Logger) Repo {
+	if _, revokesWrites := strategy.(revokeWritesStrategy); !revokesWrites {
+		return NewRecordedReformerRepo(recordsDBName, broker, strategy.Name(), server, measurementDB, actionDB, logger)
+	}
+
 	ignoredUsersPlaceholders := strings.Join(strings.Split(strings.Repeat("?", len(ignoredUsers)), ""), ",")
-	query := fmt.Sprintf(reformersQueryPattern, brokerDBName, ignoredUsersPlaceholders, brokerDBName)
-	return newRepo(query, ignoredUsers, db, logger, "quota reformer")
+	query := fmt.Sprintf(
+		reformersQueryPattern,
+		broker.instancesTable("db_name", "max_storage_mb"),
+		ignoredUsersPlaceholders,
+		server.collate("schema_privileges.grantee"),
+		server.collate("instances.db_name"),
+		broker.readOnlyUsersTable(),
+		unescapeSchema("table_schema"),
+		quoteIdentifier(recordsDBName),
+		server.collate("restrictions.db_name"),
+		server.collateBinary("restrictions.user"),
+		server.collateBinary("restrictions.host"),
+	)
+	parameters := append([]string{ObjectQuotaRestriction}, ignoredUsers...)
+	reformers := newRepo(query, parameters, server, measurementDB, actionDB, logger, "quota reformer")
+	if !server.supportsRoles() {
+		return reformers
+	}
+	return newUnionRepo(
+		reformers,
+		newRecordedReformerRepo(recordsDBName, broker, strategy.Name(), "restrictions.role_user <> ''", server, measurementDB, actionDB, logger, "quota role reformer"),
+	)
 }
@@ -1,6 +1,7 @@
 package database_test
 
 import (
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
 	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
 
 	. "github.com/onsi/ginkgo"
@@ -9,6 +10,7 @@ import (
 	"database/sql"
 
 	"errors"
+	"regexp"
 
 	"code.cloudfoundry.org/lager/lagertest"
 	"github.com/DATA-DOG/go-sqlmock"
@@ -23,10 +25,12 @@ var _ = Describe("ReformerRepo", func() {
 	)
 
 	var (
-		logger *lagertest.TestLogger
-		repo   Repo
-		fakeDB *sql.DB
-		mock   sqlmock.Sqlmock
+		logger   *lagertest.TestLogger
+		repo     Repo
+		server   Server
+		strategy Strategy
+		fakeDB   *sql.DB
+		mock     sqlmock.Sqlmock
 	)
 
 	BeforeEach(func() {
@@ -35,8 +39,11 @@ var _ = Describe("ReformerRepo", func() {
 		Expect(err).ToNot(HaveOccurred())
 
 		logger = lagertest.NewTestLogger("ReformerRepo test")
+		server = Server{Flavor: FlavorMySQL, Version: "5.7.40", Major: 5, Minor: 7}
+		strategy, err = NewStrategy("", server, logger)
+		Expect(err).ToNot(HaveOccurred())
 		ignoredUsers := []string{adminUser, readOnlyUser}
-		repo = NewReformerRepo(brokerDBName, ignoredUsers, fakeDB, logger)
+		repo = NewReformerRepo(brokerDBName, Broker{DBName: brokerDBName}, ignoredUsers, server, strategy, fakeDB, fakeDB, logger)
 	})
 
 	AfterEach(func() {
@@ -45,7 +52,7 @@ var _ = Describe("ReformerRepo", func() {
 
 	Describe("All", func() {
 		var (
-			tableSchemaColumns = []string{"db", "user"}
+			tableSchemaColumns = []string{"db", "user", "host"}
 			matchAny           = ".*"
 		)
 
@@ -54,18 +61,183 @@ var _ = Describe("ReformerRepo", func() {
 				WithArgs().
 				WillReturnRows(
 					sqlmock.NewRows(tableSchemaColumns).
-						AddRow("fake-database-1", "cf_fake-user-1").
-						AddRow("fake-database-2", "cf_fake-user-2"))
+						AddRow("fake-database-1", "cf_fake-user-1", "%").
+						AddRow("fake-database-2", "cf_fake-user-2", "%"))
 
 			reformers, err := repo.All()
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(reformers).To(ConsistOf(
-				New("fake-database-1", "cf_fake-user-1", fakeDB, logger),
-				New("fake-database-2", "cf_fake-user-2", fakeDB, logger),
+				New("fake-database-1", "cf_fake-user-1", "%", server, fakeDB, logger),
+				New("fake-database-2", "cf_fake-user-2", "%", server, fakeDB, logger),
 			))
 		})
 
+		It("marks grantees with an object quota restriction recorded as create restricted", func() {
+			mock.ExpectQuery(regexp.QuoteMeta("EXISTS ( SELECT 1 FROM `fake_broker_db_name`.quota_enforcer_restrictions AS restrictions " +
+				"WHERE restrictions.db_name COLLATE utf8_general_ci = reformers.name AND restrictions.user COLLATE utf8_bin = reformers.user AND restrictions.host COLLATE utf8_bin = reformers.host " +
+				"AND restrictions.restriction = ? ) AS create_restricted")).
+				WithArgs(ObjectQuotaRestriction, adminUser, readOnlyUser).
+				WillReturnRows(sqlmock.NewRows(tableSchemaColumns))
+
+			_, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		Context("when an instance is under its storage quota but over its object quota", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery(matchAny).
+					WillReturnRows(sqlmock.NewRows([]string{"db", "user", "host", "grant_schema", "create_restricted"}).
+						AddRow("fake-database", "cf_fake-user", "%", "fake-database", 1))
+			})
+
+			It("grants writes back without CREATE, which is left to the object quota enforcer", func() {
+				reformers, err := repo.All()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reformers).To(HaveLen(1))
+
+				mock.ExpectExec(regexp.QuoteMeta("GRANT INSERT, UPDATE ON `fake-database`.* TO 'cf_fake-user'@'%'")).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("FLUSH PRIVILEGES").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				Expect(reformers[0].GrantPrivileges()).To(Succeed())
+			})
+		})
+
+		It("quotes the broker database name", func() {
+			mock.ExpectQuery("JOIN\\s+`fake_broker_db_name`\\.service_instances").
+				WithArgs().
+				WillReturnRows(sqlmock.NewRows(tableSchemaColumns))
+
+			_, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("compares broker columns using the utf8 collation", func() {
+			mock.ExpectQuery("instances.db_name COLLATE utf8_general_ci").
+				WithArgs().
+				WillReturnRows(sqlmock.NewRows(tableSchemaColumns))
+
+			_, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		Context("when the server is MySQL 8.0", func() {
+			BeforeEach(func() {
+				server = Server{Flavor: FlavorMySQL, Version: "8.0.32", Major: 8, Minor: 0}
+				repo = NewReformerRepo(brokerDBName, Broker{DBName: brokerDBName}, []string{adminUser, readOnlyUser}, server, strategy, fakeDB, fakeDB, logger)
+			})
+
+			It("compares broker columns using a utf8mb4 collation", func() {
+				mock.ExpectQuery(`CONVERT\(instances.db_name USING utf8mb4\) COLLATE utf8mb4_general_ci`).
+					WithArgs().
+					WillReturnRows(sqlmock.NewRows(tableSchemaColumns))
+				mock.ExpectQuery("role_user <> ''").
+					WillReturnRows(sqlmock.NewRows(tableSchemaColumns))
+
+				_, err := repo.All()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("grants back the roles recorded as revoked from their members", func() {
+				mock.ExpectQuery("information_schema.schema_privileges").
+					WillReturnRows(sqlmock.NewRows(tableSchemaColumns))
+				mock.ExpectQuery("FROM\\s+`fake_broker_db_name`.quota_enforcer_restrictions AS restrictions(.|\\s)*WHERE\\s+restrictions.restriction = \\?\\s+AND\\s+restrictions.role_user <> ''").
+					WithArgs("revoke-writes").
+					WillReturnRows(sqlmock.NewRows([]string{"db_name", "user", "host", "role_user", "role_host", "default_role", "max_user_connections"}).
+						AddRow("fake-db", "fake-member", "%", "fake-role", "%", 1, 0))
+
+				reformers, err := repo.All()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reformers).To(HaveLen(1))
+				Expect(reformers[0].Role()).To(Equal(Role{User: "fake-role", Host: "%"}))
+
+				mock.ExpectExec("GRANT 'fake-role'@'%' TO 'fake-member'@'%'").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery("SELECT DEFAULT_ROLE_USER, DEFAULT_ROLE_HOST FROM mysql.default_roles").
+					WithArgs("fake-member", "%").
+					WillReturnRows(sqlmock.NewRows([]string{"DEFAULT_ROLE_USER", "DEFAULT_ROLE_HOST"}).
+						AddRow("fake-other-role", "%"))
+				mock.ExpectExec("SET DEFAULT ROLE 'fake-other-role'@'%', 'fake-role'@'%' TO 'fake-member'@'%'").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				Expect(reformers[0].GrantPrivileges()).To(Succeed())
+			})
+		})
+
+		It("revokes on the grant pattern of a database whose name escapes wildcards", func() {
+			mock.ExpectQuery("REPLACE\\(REPLACE\\(table_schema, CONCAT\\(CHAR\\(92\\), CHAR\\(95\\)\\), CHAR\\(95\\)\\)").
+				WillReturnRows(sqlmock.NewRows([]string{"db", "user", "host", "grant_schema"}).
+					AddRow("cf_db", "cf_user", "%", "cf\\_db"))
+
+			reformers, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reformers).To(HaveLen(1))
+			Expect(reformers[0].Name()).To(Equal("cf_db"))
+
+			mock.ExpectExec(regexp.QuoteMeta("GRANT INSERT, UPDATE, CREATE ON `cf\\_db`.* TO 'cf_user'@'%'")).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("FLUSH PRIVILEGES").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			Expect(reformers[0].GrantPrivileges()).To(Succeed())
+		})
+
+		Context("when the broker has another schema", func() {
+			BeforeEach(func() {
+				broker := Broker{DBName: brokerDBName, Schema: config.BrokerSchema{
+					ReadOnlyUsersTable: "readers",
+					GranteeColumn:      "username",
+				}}
+				repo = NewReformerRepo(brokerDBName, broker, []string{adminUser, readOnlyUser}, server, strategy, fakeDB, fakeDB, logger)
+			})
+
+			It("reads the read-only users from the mapped table", func() {
+				mock.ExpectQuery(regexp.QuoteMeta("LEFT JOIN (SELECT 1 AS id, ro.`username` AS grantee FROM `fake_broker_db_name`.`readers` AS ro) AS read_only_users")).
+					WithArgs().
+					WillReturnRows(sqlmock.NewRows(tableSchemaColumns))
+
+				_, err := repo.All()
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the broker lacks read-only users", func() {
+			BeforeEach(func() {
+				broker := Broker{DBName: brokerDBName, Missing: Missing{ReadOnlyUsers: true}}
+				repo = NewReformerRepo(brokerDBName, broker, []string{adminUser, readOnlyUser}, server, strategy, fakeDB, fakeDB, logger)
+			})
+
+			It("joins an empty table of read-only users", func() {
+				mock.ExpectQuery(regexp.QuoteMeta("LEFT JOIN (SELECT NULL AS id, NULL AS grantee FROM DUAL WHERE FALSE) AS read_only_users")).
+					WithArgs().
+					WillReturnRows(sqlmock.NewRows(tableSchemaColumns))
+
+				_, err := repo.All()
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the strategy is account-lock", func() {
+			BeforeEach(func() {
+				var err error
+				strategy, err = NewStrategy("account-lock", server, logger)
+				Expect(err).ToNot(HaveOccurred())
+				repo = NewReformerRepo(brokerDBName, Broker{DBName: brokerDBName}, []string{adminUser, readOnlyUser}, server, strategy, fakeDB, fakeDB, logger)
+			})
+
+			It("finds the grantees it was recorded to be applied to, leaving other locked accounts alone", func() {
+				mock.ExpectQuery("FROM\\s+`fake_broker_db_name`.quota_enforcer_restrictions AS restrictions(.|\\s)*JOIN\\s+mysql.user AS accounts(.|\\s)*WHERE\\s+restrictions.restriction = \\?").
+					WithArgs("account-lock").
+					WillReturnRows(sqlmock.NewRows(append(tableSchemaColumns, "max_user_connections")).
+						AddRow("fake-database-1", "cf_fake-user-1", "%", 0))
+
+				databases, err := repo.All()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(databases).To(ConsistOf(
+					New("fake-database-1", "cf_fake-user-1", "%", server, fakeDB, logger),
+				))
+			})
+		})
+
 		It("passes ignored users as ordered parameters", func() {
 			mock.ExpectQuery("NOT IN \\(\\?,\\?\\)").
 				WithArgs().
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// ReplicaLag measures how far the node usage is measured on lags behind.
+type ReplicaLag interface {
+	Lag() (time.Duration, error)
+}
+
+type replicaLag struct {
+	server Server
+	db     *sql.DB
+	logger lager.Logger
+}
+
+func NewReplicaLag(server Server, db *sql.DB, logger lager.Logger) ReplicaLag {
+	return &replicaLag{
+		server: server,
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Lag returns the highest lag of the replication channels of the node. A node
+// which is no replica, e.g. a Galera node, does not lag. A stopped replica
+// returns an error, as its lag is unknown.
+func (r replicaLag) Lag() (time.Duration, error) {
+	r.logger.Debug("Executing 'replica lag'.Lag")
+
+	statement := r.server.replicaStatusStatement()
+	rows, err := r.db.Query(statement)
+	if err != nil {
+		return 0, fmt.Errorf("Executing '%s': %s", statement, err.Error())
+	}
+
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, fmt.Errorf("Reading columns of '%s': %s", statement, err.Error())
+	}
+
+	lagColumn := -1
+	for i, column := range columns {
+		if column == "Seconds_Behind_Source" || column == "Seconds_Behind_Master" {
+			lagColumn = i
+		}
+	}
+
+	var lag time.Duration
+	for rows.Next() {
+		if lagColumn == -1 {
+			return 0, fmt.Errorf("Reading '%s': no Seconds_Behind_Source or Seconds_Behind_Master column", statement)
+		}
+
+		values := make([]sql.NullString, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return 0, fmt.Errorf("Scanning result row of '%s': %s", statement, err.Error())
+		}
+
+		if !values[lagColumn].Valid {
+			return 0, errors.New("Replication is not running on the measurement node")
+		}
+		seconds, err := strconv.Atoi(values[lagColumn].String)
+		if err != nil {
+			return 0, fmt.Errorf("Parsing %s '%s': %s", columns[lagColumn], values[lagColumn].String, err.Error())
+		}
+		if channelLag := time.Duration(seconds) * time.Second; channelLag > lag {
+			lag = channelLag
+		}
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("Reading result row of '%s': %s", statement, err.Error())
+	}
+
+	return lag, nil
+}
@@ -0,0 +1,127 @@
+package database_test
+
+import (
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"database/sql"
+
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+)
+
+var _ = Describe("ReplicaLag", func() {
+	var (
+		logger        *lagertest.TestLogger
+		server        Server
+		fakeDB        *sql.DB
+		mock          sqlmock.Sqlmock
+		statusColumns = []string{"Channel_Name", "Slave_IO_Running", "Seconds_Behind_Master"}
+	)
+
+	BeforeEach(func() {
+		var err error
+		fakeDB, mock, err = sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		logger = lagertest.NewTestLogger("ReplicaLag test")
+		server = Server{Flavor: FlavorMySQL, Version: "5.7.40", Major: 5, Minor: 7}
+	})
+
+	AfterEach(func() {
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	lag := func() (time.Duration, error) {
+		return NewReplicaLag(server, fakeDB, logger).Lag()
+	}
+
+	It("returns the highest lag of the replication channels", func() {
+		mock.ExpectQuery("SHOW SLAVE STATUS").
+			WillReturnRows(sqlmock.NewRows(statusColumns).
+				AddRow("", "Yes", "3").
+				AddRow("other", "Yes", "12"))
+
+		Expect(lag()).To(Equal(12 * time.Second))
+	})
+
+	It("does not lag if the node is no replica", func() {
+		mock.ExpectQuery("SHOW SLAVE STATUS").
+			WillReturnRows(sqlmock.NewRows(statusColumns))
+
+		Expect(lag()).To(BeZero())
+	})
+
+	Context("when the server is MySQL 8.0.22 or later", func() {
+		BeforeEach(func() {
+			server = Server{Flavor: FlavorMySQL, Version: "8.0.22", Major: 8, Minor: 0, Patch: 22}
+		})
+
+		It("reads the renamed replica status", func() {
+			mock.ExpectQuery("SHOW REPLICA STATUS").
+				WillReturnRows(sqlmock.NewRows([]string{"Channel_Name", "Seconds_Behind_Source"}).
+					AddRow("", "5"))
+
+			Expect(lag()).To(Equal(5 * time.Second))
+		})
+	})
+
+	Context("when the server is MySQL 8.0 before 8.0.22", func() {
+		BeforeEach(func() {
+			server = Server{Flavor: FlavorMySQL, Version: "8.0.21", Major: 8, Minor: 0, Patch: 21}
+		})
+
+		It("reads the replica status", func() {
+			mock.ExpectQuery("SHOW SLAVE STATUS").
+				WillReturnRows(sqlmock.NewRows(statusColumns).
+					AddRow("", "Yes", "5"))
+
+			Expect(lag()).To(Equal(5 * time.Second))
+		})
+	})
+
+	Context("when the server is MySQL 8.1 or later", func() {
+		BeforeEach(func() {
+			server = Server{Flavor: FlavorMySQL, Version: "8.4.0", Major: 8, Minor: 4}
+		})
+
+		It("reads the renamed replica status", func() {
+			mock.ExpectQuery("SHOW REPLICA STATUS").
+				WillReturnRows(sqlmock.NewRows([]string{"Channel_Name", "Seconds_Behind_Source"}).
+					AddRow("", "5"))
+
+			Expect(lag()).To(Equal(5 * time.Second))
+		})
+	})
+
+	Context("when replication is stopped", func() {
+		BeforeEach(func() {
+			mock.ExpectQuery("SHOW SLAVE STATUS").
+				WillReturnRows(sqlmock.NewRows(statusColumns).
+					AddRow("", "No", nil))
+		})
+
+		It("returns an error", func() {
+			_, err := lag()
+			Expect(err).To(MatchError("Replication is not running on the measurement node"))
+		})
+	})
+
+	Context("when the db query fails", func() {
+		BeforeEach(func() {
+			mock.ExpectQuery("SHOW SLAVE STATUS").
+				WillReturnError(errors.New("fake-query-error"))
+		})
+
+		It("returns an error", func() {
+			_, err := lag()
+			Expect(err).To(MatchError("Executing 'SHOW SLAVE STATUS': fake-query-error"))
+		})
+	})
+})
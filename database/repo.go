@@ -2,6 +2,7 @@ package database
 
 import (
 	"fmt"
+	"strconv"
 
 	"database/sql"
 
@@ -13,20 +14,26 @@ type Repo interface {
 }
 
 type repo struct {
-	query      string
-	parameters []string
-	db         *sql.DB
-	logger     lager.Logger
-	logTag     string
+	query         string
+	parameters    []string
+	server        Server
+	measurementDB *sql.DB
+	actionDB      *sql.DB
+	logger        lager.Logger
+	logTag        string
 }
 
-func newRepo(query string, parameters []string, db *sql.DB, logger lager.Logger, logTag string) Repo {
+// newRepo returns a repo running query on measurementDB. The databases it
+// finds are changed through actionDB.
+func newRepo(query string, parameters []string, server Server, measurementDB, actionDB *sql.DB, logger lager.Logger, logTag string) Repo {
 	return &repo{
-		query:      query,
-		parameters: parameters,
-		db:         db,
-		logger:     logger,
-		logTag:     logTag,
+		query:         query,
+		parameters:    parameters,
+		server:        server,
+		measurementDB: measurementDB,
+		actionDB:      actionDB,
+		logger:        logger,
+		logTag:        logTag,
 	}
 }
 
@@ -40,7 +47,7 @@ func (r repo) All() ([]Database, error) {
 		parametersInterface[i] = v
 	}
 
-	rows, err := r.db.Query(r.query, parametersInterface...)
+	rows, err := r.measurementDB.Query(r.query, parametersInterface...)
 	if err != nil {
 		return databases, fmt.Errorf("Error executing '%s'.All: %s", r.logTag, err.Error())
 	}
@@ -50,14 +57,37 @@ func (r repo) All() ([]Database, error) {
 	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
 	defer rows.Close()
 
+	columns, err := rows.Columns()
+	if err != nil {
+		//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+		return databases, fmt.Errorf("Reading result columns of '%s'.All: %s", r.logTag, err.Error())
+	}
+
 	for rows.Next() {
-		var dbName, dbUser string
-		if err := rows.Scan(&dbName, &dbUser); err != nil {
+		var dbName, dbUser, dbHost string
+		extras := make([]sql.NullString, len(columns))
+		dest := []interface{}{&dbName, &dbUser, &dbHost}
+		for i := 3; i < len(columns); i++ {
+			dest = append(dest, &extras[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
 			//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
 			return databases, fmt.Errorf("Scanning result row of '%s'.All: %s", r.logTag, err.Error())
 		}
 
-		databases = append(databases, New(dbName, dbUser, r.db, r.logger))
+		db := &database{
+			name:   dbName,
+			schema: dbName,
+			user:   dbUser,
+			host:   dbHost,
+			server: r.server,
+			db:     r.actionDB,
+			logger: r.logger,
+		}
+		for i := 3; i < len(columns); i++ {
+			db.setColumn(columns[i], extras[i].String)
+		}
+		databases = append(databases, db)
 	}
 	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
 	if err := rows.Err(); err != nil {
@@ -74,3 +104,45 @@ func (r repo) All() ([]Database, error) {
 
 	return databases, nil
 }
+
+// setColumn sets a column a query returns after the name, user and host of the
+// database. Unknown columns are ignored.
+func (d *database) setColumn(column, value string) {
+	switch column {
+	case "grant_schema":
+		if value != "" {
+			d.schema = value
+		}
+	case "role_user":
+		d.role.User = value
+	case "role_host":
+		d.role.Host = value
+	case "default_role":
+		d.defaultRole = value == "1"
+	case "max_user_connections":
+		d.maxUserConnections, _ = strconv.Atoi(value)
+	case "create_restricted":
+		d.createRestricted = value == "1"
+	}
+}
+
+type unionRepo struct {
+	repos []Repo
+}
+
+// newUnionRepo returns the databases found by each of repos in turn.
+func newUnionRepo(repos ...Repo) Repo {
+	return &unionRepo{repos: repos}
+}
+
+func (r unionRepo) All() ([]Database, error) {
+	databases := []Database{}
+	for _, repo := range r.repos {
+		found, err := repo.All()
+		if err != nil {
+			return databases, err
+		}
+		databases = append(databases, found...)
+	}
+	return databases, nil
+}
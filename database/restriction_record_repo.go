@@ -0,0 +1,232 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+)
+
+// The restrictions table is owned by the enforcer and lives in the broker
+// database. It records each restriction applied to a grantee, so that it is
+// reversed with the strategy that applied it even after the configured strategy
+// changed, and so that locks and limits set by operators are never taken for
+// restrictions. The connection limit of the account before the restriction is
+// kept to be restored, and so is the role revoked from it, if any, and whether
+// it was a default role.
+const createRestrictionsTableQuery = `
+CREATE TABLE IF NOT EXISTS %s.quota_enforcer_restrictions (
+	db_name              VARCHAR(64)  NOT NULL,
+	user                 VARCHAR(80)  NOT NULL,
+	host                 VARCHAR(255) NOT NULL,
+	role_user            VARCHAR(80)  NOT NULL DEFAULT '',
+	role_host            VARCHAR(255) NOT NULL DEFAULT '',
+	default_role         BOOL         NOT NULL DEFAULT FALSE,
+	restriction          VARCHAR(32)  NOT NULL,
+	max_user_connections INT UNSIGNED NOT NULL,
+	restricted_at        DATETIME     NOT NULL,
+	PRIMARY KEY (db_name, user, host, role_user, role_host, restriction)
+)`
+
+// A restriction recorded again keeps the connection limit recorded first, as
+// the account may already be restricted by then.
+const recordRestrictionQueryPattern = `
+INSERT INTO %[1]s.quota_enforcer_restrictions (db_name, user, host, role_user, role_host, default_role, restriction, max_user_connections, restricted_at)
+SELECT ?, ?, ?, ?, ?, %[4]s, ?, CAST(COALESCE(MAX(%[3]s), 0) AS UNSIGNED), UTC_TIMESTAMP()
+FROM %[2]s AS accounts
+WHERE accounts.User = ? AND accounts.Host = ?
+ON DUPLICATE KEY UPDATE restriction = restriction`
+
+// MySQL drops a revoked role from the default roles of the account.
+const defaultRoleQuery = `EXISTS (
+	SELECT 1 FROM mysql.default_roles
+	WHERE USER = ? AND HOST = ? AND DEFAULT_ROLE_USER = ? AND DEFAULT_ROLE_HOST = ?
+)`
+
+const removeRestrictionQuery = `
+DELETE FROM %s.quota_enforcer_restrictions
+WHERE db_name = ? AND user = ? AND host = ? AND role_user = ? AND role_host = ? AND restriction = ?`
+
+const restrictedDatabasesQuery = `
+SELECT DISTINCT db_name FROM %s.quota_enforcer_restrictions
+WHERE restriction NOT IN (?, ?)`
+
+// Records of accounts that were dropped, e.g. by unbinding, are left behind.
+const pruneRestrictionsQueryPattern = `
+DELETE restrictions FROM %[1]s.quota_enforcer_restrictions AS restrictions
+LEFT JOIN %[2]s AS accounts ON %[3]s = restrictions.user AND %[4]s = restrictions.host
+WHERE accounts.User IS NULL`
+
+// Grantees of recorded restrictions whose instance is back under its quota.
+// LEFT JOIN is required so that dropping all tables reverses the restriction.
+const recordedReformersQueryPattern = `
+SELECT restrictions.db_name, restrictions.user, restrictions.host,
+	restrictions.role_user, restrictions.role_host,
+	MAX(restrictions.default_role) AS default_role,
+	MAX(restrictions.max_user_connections) AS max_user_connections
+FROM        %[1]s.quota_enforcer_restrictions AS restrictions
+JOIN        %[2]s AS accounts ON %[3]s = restrictions.user AND %[4]s = restrictions.host
+JOIN        %[5]s AS instances ON restrictions.db_name = %[6]s
+LEFT JOIN   information_schema.tables AS tables ON tables.table_schema = restrictions.db_name
+WHERE       restrictions.restriction = ?%[7]s
+GROUP  BY   restrictions.db_name, restrictions.user, restrictions.host, restrictions.role_user, restrictions.role_host
+HAVING ROUND(SUM(COALESCE(tables.data_length + tables.index_length,0) / 1024 / 1024), 1) < MAX(instances.max_storage_mb)
+`
+
+// ObjectQuotaRestriction is recorded for grantees whose CREATE was revoked for
+// exceeding their object quota. notify-only is recorded for the grantees it
+// reported, though nothing was restricted.
+const ObjectQuotaRestriction = "object-quota"
+
+// RestrictionRecordRepo records the restrictions applied by the enforcer. A
+// restriction is the name of the strategy that applied it, or ObjectQuotaRestriction.
+type RestrictionRecordRepo interface {
+	Setup() error
+	Record(db Database, restriction string) error
+	Remove(db Database, restriction string) error
+	Restricted() (map[string]bool, error)
+}
+
+type restrictionRecordRepo struct {
+	brokerDBName string
+	server       Server
+	db           *sql.DB
+	logger       lager.Logger
+}
+
+func NewRestrictionRecordRepo(brokerDBName string, server Server, db *sql.DB, logger lager.Logger) RestrictionRecordRepo {
+	return &restrictionRecordRepo{
+		brokerDBName: quoteIdentifier(brokerDBName),
+		server:       server,
+		db:           db,
+		logger:       logger,
+	}
+}
+
+// Setup creates the restrictions table if it does not exist yet, and prunes
+// the records of dropped accounts.
+func (r restrictionRecordRepo) Setup() error {
+	_, err := r.db.Exec(fmt.Sprintf(createRestrictionsTableQuery, r.brokerDBName))
+	if err != nil {
+		return fmt.Errorf("Creating restrictions table: %s", err.Error())
+	}
+
+	result, err := r.db.Exec(fmt.Sprintf(
+		pruneRestrictionsQueryPattern,
+		r.brokerDBName,
+		r.server.accountsTable(),
+		r.server.collateBinary("accounts.User"),
+		r.server.collateBinary("accounts.Host"),
+	))
+	if err != nil {
+		return fmt.Errorf("Pruning restrictions of dropped accounts: %s", err.Error())
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Pruning restrictions of dropped accounts: Getting rows affected: %s", err.Error())
+	}
+	r.logger.Info(fmt.Sprintf("Pruned %d restrictions of dropped accounts", rowsAffected))
+	return nil
+}
+
+// Record is called before the restriction is applied, so that the connection
+// limit it may change is recorded, and so that a restriction applied in part
+// is still reversed.
+func (r restrictionRecordRepo) Record(db Database, restriction string) error {
+	role := db.Role()
+	defaultRole := "FALSE"
+	args := []interface{}{db.Name(), db.User(), db.Host(), role.User, role.Host}
+	if r.server.restoresDefaultRoles() {
+		defaultRole = defaultRoleQuery
+		args = append(args, db.User(), db.Host(), role.User, role.Host)
+	}
+	args = append(args, restriction, db.User(), db.Host())
+
+	query := fmt.Sprintf(
+		recordRestrictionQueryPattern,
+		r.brokerDBName,
+		r.server.accountsTable(),
+		r.server.accountAttributeValue("max_user_connections"),
+		defaultRole,
+	)
+	_, err := r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("Recording restriction '%s' of db '%s', user '%s': %s", restriction, db.Name(), db.User(), err.Error())
+	}
+	return nil
+}
+
+// Remove is called once the restriction is reversed.
+func (r restrictionRecordRepo) Remove(db Database, restriction string) error {
+	role := db.Role()
+	_, err := r.db.Exec(fmt.Sprintf(removeRestrictionQuery, r.brokerDBName), db.Name(), db.User(), db.Host(), role.User, role.Host, restriction)
+	if err != nil {
+		return fmt.Errorf("Removing restriction '%s' of db '%s', user '%s': %s", restriction, db.Name(), db.User(), err.Error())
+	}
+	return nil
+}
+
+// Restricted returns the databases with a grantee restricted for exceeding
+// its storage quota.
+func (r restrictionRecordRepo) Restricted() (map[string]bool, error) {
+	r.logger.Debug("Executing 'restriction record'.Restricted")
+	return restrictedDatabases(r.db, r.brokerDBName)
+}
+
+// restrictedDatabases returns the databases with a restriction recorded in the
+// quoted recordsDBName, but ObjectQuotaRestriction and notify-only, which
+// restricts nothing.
+func restrictedDatabases(db *sql.DB, recordsDBName string) (map[string]bool, error) {
+	restricted := map[string]bool{}
+
+	rows, err := db.Query(fmt.Sprintf(restrictedDatabasesQuery, recordsDBName), ObjectQuotaRestriction, config.StrategyNotifyOnly)
+	if err != nil {
+		return restricted, fmt.Errorf("Reading restricted databases: %s", err.Error())
+	}
+
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	for rows.Next() {
+		var dbName string
+		if err := rows.Scan(&dbName); err != nil {
+			//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+			return restricted, fmt.Errorf("Scanning restricted database: %s", err.Error())
+		}
+		restricted[dbName] = true
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return restricted, fmt.Errorf("Reading restricted databases: %s", err.Error())
+	}
+
+	return restricted, nil
+}
+
+// NewRecordedReformerRepo finds the grantees of the instances of broker which
+// are back under their quota, and were restricted by restriction according to
+// the records kept in recordsDBName.
+func NewRecordedReformerRepo(recordsDBName string, broker Broker, restriction string, server Server, measurementDB, actionDB *sql.DB, logger lager.Logger) Repo {
+	return newRecordedReformerRepo(recordsDBName, broker, restriction, "", server, measurementDB, actionDB, logger, fmt.Sprintf("recorded %s reformer", restriction))
+}
+
+// newRecordedReformerRepo is like NewRecordedReformerRepo, for the records
+// meeting condition too.
+func newRecordedReformerRepo(recordsDBName string, broker Broker, restriction, condition string, server Server, measurementDB, actionDB *sql.DB, logger lager.Logger, logTag string) Repo {
+	if condition != "" {
+		condition = "\nAND         " + condition
+	}
+	query := fmt.Sprintf(
+		recordedReformersQueryPattern,
+		quoteIdentifier(recordsDBName),
+		server.accountsTable(),
+		server.collateBinary("accounts.User"),
+		server.collateBinary("accounts.Host"),
+		broker.instancesTable("db_name", "max_storage_mb"),
+		server.collate("instances.db_name"),
+		condition,
+	)
+	return newRepo(query, []string{restriction}, server, measurementDB, actionDB, logger, logTag)
+}
@@ -0,0 +1,172 @@
+package database_test
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"database/sql"
+
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+)
+
+var _ = Describe("RestrictionRecordRepo", func() {
+
+	const brokerDBName = "fake_broker_db_name"
+
+	var (
+		logger *lagertest.TestLogger
+		server Server
+		repo   RestrictionRecordRepo
+		db     Database
+		fakeDB *sql.DB
+		mock   sqlmock.Sqlmock
+	)
+
+	BeforeEach(func() {
+		var err error
+		fakeDB, mock, err = sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		logger = lagertest.NewTestLogger("RestrictionRecordRepo test")
+		server = Server{Flavor: FlavorMySQL, Version: "8.0.36", Major: 8, Minor: 0}
+		repo = NewRestrictionRecordRepo(brokerDBName, server, fakeDB, logger)
+		db = New("fake-db", "fake-user", "%", server, fakeDB, logger)
+	})
+
+	AfterEach(func() {
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	Describe("Setup", func() {
+		It("creates the restrictions table in the broker database and prunes dropped accounts", func() {
+			mock.ExpectExec("CREATE TABLE IF NOT EXISTS `fake_broker_db_name`\\.quota_enforcer_restrictions").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("DELETE restrictions FROM `fake_broker_db_name`\\.quota_enforcer_restrictions AS restrictions\\s+LEFT JOIN mysql.user AS accounts(.|\\s)*WHERE accounts.User IS NULL").
+				WillReturnResult(sqlmock.NewResult(0, 2))
+
+			Expect(repo.Setup()).To(Succeed())
+		})
+
+		Context("when creating the table fails", func() {
+			BeforeEach(func() {
+				mock.ExpectExec("CREATE TABLE").
+					WillReturnError(errors.New("fake-create-error"))
+			})
+
+			It("returns an error", func() {
+				Expect(repo.Setup()).To(MatchError("Creating restrictions table: fake-create-error"))
+			})
+		})
+	})
+
+	Describe("Record", func() {
+		It("records the restriction along with the connection limit of the account", func() {
+			mock.ExpectExec("INSERT INTO `fake_broker_db_name`\\.quota_enforcer_restrictions(.|\\s)*COALESCE\\(MAX\\(accounts.max_user_connections\\), 0\\)(.|\\s)*FROM mysql.user AS accounts(.|\\s)*ON DUPLICATE KEY UPDATE").
+				WithArgs("fake-db", "fake-user", "%", "", "", "fake-user", "%", "", "", "max-connections", "fake-user", "%").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			Expect(repo.Record(db, "max-connections")).To(Succeed())
+		})
+
+		It("records whether the role is a default role of the account", func() {
+			mock.ExpectExec("EXISTS \\(\\s+SELECT 1 FROM mysql.default_roles").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			Expect(repo.Record(db, "revoke-writes")).To(Succeed())
+		})
+
+		Context("when the server is MariaDB 10.4 or later", func() {
+			BeforeEach(func() {
+				server = Server{Flavor: FlavorMariaDB, Version: "10.6.12-MariaDB", Major: 10, Minor: 6}
+				repo = NewRestrictionRecordRepo(brokerDBName, server, fakeDB, logger)
+			})
+
+			It("reads the connection limit from mysql.global_priv", func() {
+				mock.ExpectExec("\\?, FALSE, \\?(.|\\s)*JSON_VALUE\\(accounts.Priv, '\\$.max_user_connections'\\)(.|\\s)*FROM mysql.global_priv AS accounts").
+					WithArgs("fake-db", "fake-user", "%", "", "", "max-connections", "fake-user", "%").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+
+				Expect(repo.Record(db, "max-connections")).To(Succeed())
+			})
+		})
+
+		Context("when the insert fails", func() {
+			It("returns an error", func() {
+				mock.ExpectExec("INSERT").
+					WillReturnError(errors.New("fake-insert-error"))
+
+				Expect(repo.Record(db, "account-lock")).To(MatchError("Recording restriction 'account-lock' of db 'fake-db', user 'fake-user': fake-insert-error"))
+			})
+		})
+	})
+
+	Describe("Remove", func() {
+		It("deletes the record of the restriction", func() {
+			mock.ExpectExec("DELETE FROM `fake_broker_db_name`\\.quota_enforcer_restrictions").
+				WithArgs("fake-db", "fake-user", "%", "", "", "account-lock").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			Expect(repo.Remove(db, "account-lock")).To(Succeed())
+		})
+
+		Context("when the delete fails", func() {
+			It("returns an error", func() {
+				mock.ExpectExec("DELETE").
+					WillReturnError(errors.New("fake-delete-error"))
+
+				Expect(repo.Remove(db, "account-lock")).To(MatchError("Removing restriction 'account-lock' of db 'fake-db', user 'fake-user': fake-delete-error"))
+			})
+		})
+	})
+
+	Describe("Restricted", func() {
+		It("returns the databases restricted for exceeding their storage quota", func() {
+			mock.ExpectQuery("SELECT DISTINCT db_name FROM `fake_broker_db_name`\\.quota_enforcer_restrictions\\s+WHERE restriction NOT IN \\(\\?, \\?\\)").
+				WithArgs(ObjectQuotaRestriction, "notify-only").
+				WillReturnRows(sqlmock.NewRows([]string{"db_name"}).
+					AddRow("fake-db-1").
+					AddRow("fake-db-2"))
+
+			Expect(repo.Restricted()).To(Equal(map[string]bool{"fake-db-1": true, "fake-db-2": true}))
+		})
+
+		Context("when the query fails", func() {
+			It("returns an error", func() {
+				mock.ExpectQuery("SELECT").
+					WillReturnError(errors.New("fake-query-error"))
+
+				_, err := repo.Restricted()
+				Expect(err).To(MatchError("Reading restricted databases: fake-query-error"))
+			})
+		})
+	})
+
+	Describe("NewRecordedReformerRepo", func() {
+		var reformerRepo Repo
+
+		BeforeEach(func() {
+			reformerRepo = NewRecordedReformerRepo(brokerDBName, Broker{DBName: "fake_other_broker"}, "max-connections", server, fakeDB, fakeDB, logger)
+		})
+
+		It("finds the recorded grantees of instances under quota, with the recorded connection limit", func() {
+			mock.ExpectQuery("FROM\\s+`fake_broker_db_name`.quota_enforcer_restrictions AS restrictions(.|\\s)*JOIN\\s+`fake_other_broker`.service_instances AS instances").
+				WithArgs("max-connections").
+				WillReturnRows(sqlmock.NewRows([]string{"db_name", "user", "host", "max_user_connections"}).
+					AddRow("fake-db", "fake-user", "%", 10))
+
+			databases, err := reformerRepo.All()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(databases).To(HaveLen(1))
+			Expect(databases[0].Name()).To(Equal("fake-db"))
+
+			mock.ExpectExec("ALTER USER 'fake-user'@'%' WITH MAX_USER_CONNECTIONS 10").
+				WillReturnResult(sqlmock.NewResult(-1, 0))
+			Expect(databases[0].RestoreMaxUserConnections()).To(Succeed())
+		})
+	})
+})
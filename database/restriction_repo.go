@@ -0,0 +1,47 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// Strategies other than revoke-writes leave the privileges of a grantee
+// untouched, so whether they have been applied is read from the grantee's
+// account instead. Grantees are found, and invalid quotas skipped, as in
+// violatorsQueryPattern.
+const restrictionQueryPattern = `
+SELECT restricted.name AS restricted_db, restricted.user AS restricted_user, restricted.host AS restricted_host,
+	restricted.grant_schema, restricted.role_user, restricted.role_host
+FROM (
+	SELECT dbs.name, dbs.grant_schema, dbs.user, dbs.host, dbs.role_user, dbs.role_host
+	FROM   (%[1]s
+	) AS dbs
+	JOIN        %[2]s AS accounts ON dbs.user = %[3]s AND dbs.host = %[4]s
+	JOIN        %[5]s AS instances ON dbs.name = %[6]s
+	LEFT JOIN   information_schema.tables AS tables ON tables.table_schema = dbs.name
+	WHERE       NOT (%[7]s)
+	GROUP  BY   dbs.name, dbs.grant_schema, dbs.user, dbs.host, dbs.role_user, dbs.role_host
+	HAVING MAX(instances.max_storage_mb) > 0
+	   AND ROUND(SUM(COALESCE(tables.data_length + tables.index_length,0) / 1024 / 1024), 1) >= MAX(instances.max_storage_mb)
+) AS restricted
+`
+
+// newRestrictionRepo finds grantees over quota whose account is not yet
+// restricted. Which grantees to reverse the restriction on is left to the
+// records of the restrictions, as the same account state may have been set by
+// an operator.
+func newRestrictionRepo(broker Broker, ignoredUsers []string, server Server, condition string, measurementDB, actionDB *sql.DB, logger lager.Logger) Repo {
+	query := fmt.Sprintf(
+		restrictionQueryPattern,
+		writeGrantees(server, "'INSERT', 'UPDATE', 'CREATE'", ignoredUsers),
+		server.accountsTable(),
+		server.collateBinary("accounts.User"),
+		server.collateBinary("accounts.Host"),
+		broker.instancesTable("db_name", "max_storage_mb"),
+		server.collate("instances.db_name"),
+		condition,
+	)
+	return newRepo(query, ignoredUsers, server, measurementDB, actionDB, logger, "quota violator")
+}
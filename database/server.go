@@ -0,0 +1,225 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	FlavorMySQL   = "mysql"
+	FlavorMariaDB = "mariadb"
+)
+
+var serverVersionPattern = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// Server describes the flavor and version of the server being enforced, so
+// queries and statements can be adapted to it.
+type Server struct {
+	Flavor  string
+	Version string
+	Major   int
+	Minor   int
+	Patch   int
+	// PartialRevokes is true when MySQL 8.0.16 or later runs with
+	// partial_revokes, so a global privilege can be revoked on one database.
+	PartialRevokes bool
+}
+
+// DetectServer asks the server for its version, and MySQL 8 whether partial
+// revokes are enabled. The variable is missing before MySQL 8.0.16.
+func DetectServer(db *sql.DB) (Server, error) {
+	var version string
+	err := db.QueryRow("SELECT VERSION()").Scan(&version)
+	if err != nil {
+		return Server{}, fmt.Errorf("Detecting server version: %s", err.Error())
+	}
+
+	server, err := ParseServerVersion(version)
+	if err != nil || server.Flavor != FlavorMySQL || server.Major < 8 {
+		return server, err
+	}
+
+	var name, value string
+	err = db.QueryRow("SHOW GLOBAL VARIABLES LIKE 'partial_revokes'").Scan(&name, &value)
+	if err != nil && err != sql.ErrNoRows {
+		return server, fmt.Errorf("Detecting partial revokes: %s", err.Error())
+	}
+	server.PartialRevokes = strings.EqualFold(value, "ON")
+
+	return server, nil
+}
+
+// ParseServerVersion parses the result of SELECT VERSION(),
+// e.g. '5.7.40-log', '8.0.32' or '10.6.12-MariaDB-1:10.6.12+maria~ubu2004'.
+func ParseServerVersion(version string) (Server, error) {
+	matches := serverVersionPattern.FindStringSubmatch(version)
+	if matches == nil {
+		return Server{}, fmt.Errorf("Parsing server version '%s': unknown format", version)
+	}
+
+	server := Server{
+		Flavor:  FlavorMySQL,
+		Version: version,
+	}
+	server.Major, _ = strconv.Atoi(matches[1])
+	server.Minor, _ = strconv.Atoi(matches[2])
+	server.Patch, _ = strconv.Atoi(matches[3])
+
+	if strings.Contains(strings.ToLower(version), "mariadb") {
+		server.Flavor = FlavorMariaDB
+	}
+
+	return server, nil
+}
+
+func (s Server) String() string {
+	return fmt.Sprintf("%s %s", s.Flavor, s.Version)
+}
+
+// StatisticsExpiryParam is the session variable after which MySQL 8 refreshes
+// the table sizes cached in information_schema, one day by default.
+const StatisticsExpiryParam = "information_schema_stats_expiry"
+
+// SessionParams returns params with the session variables the enforcer needs
+// on this server added, unless they are set already: on MySQL 8, table sizes
+// are read fresh rather than from a cache up to a day old. It returns false if
+// nothing is added.
+func (s Server) SessionParams(params map[string]string) (map[string]string, bool) {
+	if s.Flavor != FlavorMySQL || s.Major < 8 {
+		return params, false
+	}
+	if _, set := params[StatisticsExpiryParam]; set {
+		return params, false
+	}
+
+	withExpiry := map[string]string{StatisticsExpiryParam: "0"}
+	for name, value := range params {
+		withExpiry[name] = value
+	}
+	return withExpiry, true
+}
+
+// isLegacyMySQL is true for MySQL 5.x and for an undetected server.
+func (s Server) isLegacyMySQL() bool {
+	return s.Flavor != FlavorMariaDB && s.Major < 8
+}
+
+// collate makes a broker column comparable with information_schema columns.
+// MySQL 5.x brokers use utf8; MySQL 8.0 and recent MariaDB default to utf8mb4,
+// for which utf8_general_ci is not a valid collation.
+func (s Server) collate(expression string) string {
+	if s.isLegacyMySQL() {
+		return fmt.Sprintf("%s COLLATE utf8_general_ci", expression)
+	}
+	return fmt.Sprintf("CONVERT(%s USING utf8mb4) COLLATE utf8mb4_general_ci", expression)
+}
+
+// collateBinary is like collate, but compares case sensitively, as is done
+// for user names in the accounts table.
+func (s Server) collateBinary(expression string) string {
+	if s.isLegacyMySQL() {
+		return fmt.Sprintf("%s COLLATE utf8_bin", expression)
+	}
+	return fmt.Sprintf("CONVERT(%s USING utf8mb4) COLLATE utf8mb4_bin", expression)
+}
+
+// usesGlobalPriv is true for MariaDB 10.4 and later, where account attributes
+// are stored as JSON in mysql.global_priv and mysql.user is a view.
+func (s Server) usesGlobalPriv() bool {
+	return s.Flavor == FlavorMariaDB && (s.Major > 10 || s.Major == 10 && s.Minor >= 4)
+}
+
+func (s Server) supportsAccountLock() bool {
+	if s.Flavor == FlavorMariaDB {
+		return s.usesGlobalPriv()
+	}
+	return s.Major > 5 || s.Major == 5 && s.Minor >= 7
+}
+
+// accountsTable is the table holding one row per account, with User and Host columns.
+func (s Server) accountsTable() string {
+	if s.usesGlobalPriv() {
+		return "mysql.global_priv"
+	}
+	return "mysql.user"
+}
+
+// supportsRoles is true for MySQL 8.0 and MariaDB 10.0.5 and later.
+func (s Server) supportsRoles() bool {
+	return !s.isLegacyMySQL()
+}
+
+// roleEdges returns a query of the roles granted to accounts, with the columns
+// role_user, role_host, member_user and member_host. MariaDB roles have an
+// empty host.
+func (s Server) roleEdges() string {
+	switch {
+	case s.Flavor == FlavorMariaDB:
+		return "SELECT Role AS role_user, '' AS role_host, User AS member_user, Host AS member_host FROM mysql.roles_mapping"
+	case s.supportsRoles():
+		return "SELECT FROM_USER AS role_user, FROM_HOST AS role_host, TO_USER AS member_user, TO_HOST AS member_host FROM mysql.role_edges"
+	}
+	return "SELECT NULL AS role_user, NULL AS role_host, NULL AS member_user, NULL AS member_host FROM DUAL WHERE FALSE"
+}
+
+// restoresDefaultRoles is true for MySQL, where revoking a role also removes
+// it from the default roles of the account. MariaDB keeps the default role.
+func (s Server) restoresDefaultRoles() bool {
+	return s.Flavor == FlavorMySQL && s.supportsRoles()
+}
+
+// systemTables are the tables of the mysql schema the enforcer reads.
+func (s Server) systemTables() []string {
+	switch {
+	case s.Flavor == FlavorMariaDB:
+		return []string{s.accountsTable(), "mysql.roles_mapping"}
+	case s.supportsRoles():
+		return []string{s.accountsTable(), "mysql.role_edges", "mysql.default_roles"}
+	}
+	return []string{s.accountsTable()}
+}
+
+// accountAttribute returns a condition comparing an account attribute in
+// accountsTable, aliased as accounts, with a value.
+func (s Server) accountAttribute(attribute, columnValue, jsonValue string) string {
+	if s.usesGlobalPriv() {
+		return fmt.Sprintf("%s = '%s'", s.accountAttributeValue(attribute), jsonValue)
+	}
+	return fmt.Sprintf("%s = %s", s.accountAttributeValue(attribute), columnValue)
+}
+
+// accountAttributeValue returns an expression for an account attribute in
+// accountsTable, aliased as accounts.
+func (s Server) accountAttributeValue(attribute string) string {
+	if s.usesGlobalPriv() {
+		return fmt.Sprintf("JSON_VALUE(accounts.Priv, '$.%s')", attribute)
+	}
+	return fmt.Sprintf("accounts.%s", attribute)
+}
+
+// requiresFlushPrivileges is false for MySQL 8.0 and later, where GRANT and
+// REVOKE take effect immediately and FLUSH PRIVILEGES is deprecated.
+func (s Server) requiresFlushPrivileges() bool {
+	return s.Flavor == FlavorMariaDB || s.Major < 8
+}
+
+// account formats a user and host for GRANT and REVOKE. MariaDB roles have an
+// empty host and are named without one.
+func (s Server) account(user, host string) string {
+	if host == "" && s.Flavor == FlavorMariaDB {
+		return quoteString(user)
+	}
+	return fmt.Sprintf("%s@%s", quoteString(user), quoteString(host))
+}
+
+// replicaStatusStatement shows the replication state of a replica. MySQL 8.0.22
+// renamed SHOW SLAVE STATUS, and later releases removed it.
+func (s Server) replicaStatusStatement() string {
+	if s.Flavor == FlavorMySQL && (s.Major > 8 || s.Major == 8 && (s.Minor > 0 || s.Patch >= 22)) {
+		return "SHOW REPLICA STATUS"
+	}
+	return "SHOW SLAVE STATUS"
+}
@@ -0,0 +1,140 @@
+package database_test
+
+import (
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"errors"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var _ = Describe("Server", func() {
+
+	Describe("ParseServerVersion", func() {
+		It("parses MySQL 5.7 versions", func() {
+			server, err := ParseServerVersion("5.7.40-log")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(server).To(Equal(Server{Flavor: FlavorMySQL, Version: "5.7.40-log", Major: 5, Minor: 7, Patch: 40}))
+		})
+
+		It("parses MySQL 8.0 versions", func() {
+			server, err := ParseServerVersion("8.0.32")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(server).To(Equal(Server{Flavor: FlavorMySQL, Version: "8.0.32", Major: 8, Minor: 0, Patch: 32}))
+		})
+
+		It("parses MariaDB versions", func() {
+			server, err := ParseServerVersion("10.6.12-MariaDB-1:10.6.12+maria~ubu2004")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(server.Flavor).To(Equal(FlavorMariaDB))
+			Expect(server.Major).To(Equal(10))
+			Expect(server.Minor).To(Equal(6))
+			Expect(server.Patch).To(Equal(12))
+		})
+
+		Context("when the version has an unknown format", func() {
+			It("returns an error", func() {
+				_, err := ParseServerVersion("fake-version")
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-version"))
+			})
+		})
+	})
+
+	Describe("DetectServer", func() {
+		It("queries the server version", func() {
+			fakeDB, mock, err := sqlmock.New()
+			Expect(err).ToNot(HaveOccurred())
+
+			mock.ExpectQuery(`SELECT VERSION\(\)`).
+				WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.0.32"))
+
+			mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'partial_revokes'`).
+				WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("partial_revokes", "ON"))
+
+			server, err := DetectServer(fakeDB)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(server.Major).To(Equal(8))
+			Expect(server.PartialRevokes).To(BeTrue())
+
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		Context("when the server is MySQL 8 before partial revokes", func() {
+			It("detects them as disabled", func() {
+				fakeDB, mock, err := sqlmock.New()
+				Expect(err).ToNot(HaveOccurred())
+
+				mock.ExpectQuery(`SELECT VERSION\(\)`).
+					WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.0.12"))
+				mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'partial_revokes'`).
+					WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}))
+
+				server, err := DetectServer(fakeDB)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(server.PartialRevokes).To(BeFalse())
+
+				Expect(mock.ExpectationsWereMet()).To(Succeed())
+			})
+		})
+
+		Context("when the server is MariaDB", func() {
+			It("does not detect partial revokes", func() {
+				fakeDB, mock, err := sqlmock.New()
+				Expect(err).ToNot(HaveOccurred())
+
+				mock.ExpectQuery(`SELECT VERSION\(\)`).
+					WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("10.6.12-MariaDB"))
+
+				server, err := DetectServer(fakeDB)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(server.PartialRevokes).To(BeFalse())
+
+				Expect(mock.ExpectationsWereMet()).To(Succeed())
+			})
+		})
+
+		Context("when the query fails", func() {
+			It("returns an error", func() {
+				fakeDB, mock, err := sqlmock.New()
+				Expect(err).ToNot(HaveOccurred())
+
+				mock.ExpectQuery(`SELECT VERSION\(\)`).
+					WillReturnError(errors.New("fake-query-error"))
+
+				_, err = DetectServer(fakeDB)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-query-error"))
+			})
+		})
+	})
+
+	Describe("SessionParams", func() {
+		It("reads fresh table sizes on MySQL 8", func() {
+			server := Server{Flavor: FlavorMySQL, Major: 8}
+			params := map[string]string{"tls": "true"}
+
+			sessionParams, changed := server.SessionParams(params)
+			Expect(changed).To(BeTrue())
+			Expect(sessionParams).To(Equal(map[string]string{"tls": "true", StatisticsExpiryParam: "0"}))
+			Expect(params).To(Equal(map[string]string{"tls": "true"}))
+		})
+
+		It("keeps an expiry set by the operator", func() {
+			server := Server{Flavor: FlavorMySQL, Major: 8}
+
+			_, changed := server.SessionParams(map[string]string{StatisticsExpiryParam: "60"})
+			Expect(changed).To(BeFalse())
+		})
+
+		It("adds nothing on servers without the statistics cache", func() {
+			for _, server := range []Server{{Flavor: FlavorMySQL, Major: 5, Minor: 7}, {Flavor: FlavorMariaDB, Major: 10, Minor: 6}} {
+				_, changed := server.SessionParams(nil)
+				Expect(changed).To(BeFalse())
+			}
+		})
+	})
+})
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+)
+
+// RestrictedMaxUserConnections is the connection limit applied by the
+// max-connections strategy. It leaves room for the app to connect and delete data.
+const RestrictedMaxUserConnections = 1
+
+// Strategy is applied to the grantees of an instance that exceeds its quota,
+// and reversed once the instance is back under its quota.
+type Strategy interface {
+	Name() string
+	Apply(db Database) error
+	Reverse(db Database) error
+}
+
+// NewStrategy returns the strategy with the given config name, checking that
+// the server supports it. An empty name selects revoke-writes.
+func NewStrategy(name string, server Server, logger lager.Logger) (Strategy, error) {
+	switch name {
+	case "", config.StrategyRevokeWrites:
+		return revokeWritesStrategy{}, nil
+	case config.StrategyAccountLock:
+		if !server.supportsAccountLock() {
+			return nil, fmt.Errorf("Enforcement strategy '%s' is not supported by %s", name, server)
+		}
+		return accountLockStrategy{}, nil
+	case config.StrategyMaxConnections:
+		return maxConnectionsStrategy{}, nil
+	case config.StrategyNotifyOnly:
+		return notifyOnlyStrategy{logger: logger}, nil
+	}
+	return nil, fmt.Errorf("Unknown enforcement strategy '%s'", name)
+}
+
+type revokeWritesStrategy struct{}
+
+func (revokeWritesStrategy) Name() string {
+	return config.StrategyRevokeWrites
+}
+
+func (revokeWritesStrategy) Apply(db Database) error {
+	err := db.RevokePrivileges()
+	if err != nil {
+		return fmt.Errorf("Revoking privileges: %s", err.Error())
+	}
+
+	err = db.KillActiveConnections()
+	if err != nil {
+		return fmt.Errorf("Resetting active privileges: %s", err.Error())
+	}
+	return nil
+}
+
+func (revokeWritesStrategy) Reverse(db Database) error {
+	err := db.GrantPrivileges()
+	if err != nil {
+		return fmt.Errorf("Granting privileges: %s", err.Error())
+	}
+
+	err = db.KillActiveConnections()
+	if err != nil {
+		return fmt.Errorf("Resetting active privileges: %s", err.Error())
+	}
+	return nil
+}
+
+type accountLockStrategy struct{}
+
+func (accountLockStrategy) Name() string {
+	return config.StrategyAccountLock
+}
+
+func (accountLockStrategy) Apply(db Database) error {
+	err := db.LockAccount()
+	if err != nil {
+		return fmt.Errorf("Locking account: %s", err.Error())
+	}
+
+	err = db.KillActiveConnections()
+	if err != nil {
+		return fmt.Errorf("Killing active connections: %s", err.Error())
+	}
+	return nil
+}
+
+func (accountLockStrategy) Reverse(db Database) error {
+	err := db.UnlockAccount()
+	if err != nil {
+		return fmt.Errorf("Unlocking account: %s", err.Error())
+	}
+	return nil
+}
+
+type maxConnectionsStrategy struct{}
+
+func (maxConnectionsStrategy) Name() string {
+	return config.StrategyMaxConnections
+}
+
+func (maxConnectionsStrategy) Apply(db Database) error {
+	err := db.SetMaxUserConnections(RestrictedMaxUserConnections)
+	if err != nil {
+		return fmt.Errorf("Limiting connections: %s", err.Error())
+	}
+
+	err = db.KillActiveConnections()
+	if err != nil {
+		return fmt.Errorf("Killing active connections: %s", err.Error())
+	}
+	return nil
+}
+
+// Reverse restores the connection limit recorded when the strategy was
+// applied, so that a limit set by an operator is kept.
+func (maxConnectionsStrategy) Reverse(db Database) error {
+	err := db.RestoreMaxUserConnections()
+	if err != nil {
+		return fmt.Errorf("Restoring connection limit: %s", err.Error())
+	}
+	return nil
+}
+
+// notifyOnlyStrategy only logs. Its grantees are recorded like any other
+// restriction, so an instance is reported once each time it exceeds its quota.
+type notifyOnlyStrategy struct {
+	logger lager.Logger
+}
+
+func (notifyOnlyStrategy) Name() string {
+	return config.StrategyNotifyOnly
+}
+
+func (s notifyOnlyStrategy) Apply(db Database) error {
+	s.logger.Info(fmt.Sprintf("Database '%s' exceeds its quota, not enforcing", db.Name()))
+	return nil
+}
+
+func (notifyOnlyStrategy) Reverse(db Database) error {
+	return nil
+}
+
+const reportedConditionPattern = `EXISTS (
+		SELECT 1 FROM %[1]s.quota_enforcer_restrictions AS restrictions
+		WHERE %[2]s = dbs.name AND %[3]s = dbs.user AND %[4]s = dbs.host
+		AND restrictions.restriction = '%[5]s'
+	)`
+
+// appliedCondition returns a SQL condition on the grantee's row in the accounts
+// table which is true once the strategy has been applied, e.g. by an operator.
+// Strategies that revoke privileges have no such condition; their state is the
+// privileges themselves. notify-only changes no account, so its condition is
+// the record of the grantee, dbs, kept in recordsDBName.
+func appliedCondition(strategy Strategy, server Server, recordsDBName string) string {
+	switch strategy.(type) {
+	case accountLockStrategy:
+		return server.accountAttribute("account_locked", "'Y'", "true")
+	case maxConnectionsStrategy:
+		value := fmt.Sprintf("%d", RestrictedMaxUserConnections)
+		return server.accountAttribute("max_user_connections", value, value)
+	case notifyOnlyStrategy:
+		return fmt.Sprintf(
+			reportedConditionPattern,
+			quoteIdentifier(recordsDBName),
+			server.collate("restrictions.db_name"),
+			server.collateBinary("restrictions.user"),
+			server.collateBinary("restrictions.host"),
+			config.StrategyNotifyOnly,
+		)
+	}
+	return ""
+}
@@ -0,0 +1,142 @@
+package database_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database/databasefakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Strategy", func() {
+
+	var (
+		logger   *lagertest.TestLogger
+		server   Server
+		fakeDB   *databasefakes.FakeDatabase
+		strategy Strategy
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("Strategy test")
+		server = Server{Flavor: FlavorMySQL, Version: "5.7.40", Major: 5, Minor: 7}
+		fakeDB = &databasefakes.FakeDatabase{}
+		fakeDB.NameReturns("fake-db-name")
+	})
+
+	newStrategy := func(name string) Strategy {
+		s, err := NewStrategy(name, server, logger)
+		Expect(err).ToNot(HaveOccurred())
+		return s
+	}
+
+	Describe("NewStrategy", func() {
+		It("defaults to revoke-writes", func() {
+			Expect(newStrategy("").Name()).To(Equal("revoke-writes"))
+		})
+
+		Context("when the strategy is unknown", func() {
+			It("returns an error", func() {
+				_, err := NewStrategy("fake-strategy", server, logger)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-strategy"))
+			})
+		})
+
+		Context("when the server does not support account locking", func() {
+			BeforeEach(func() {
+				server = Server{Flavor: FlavorMariaDB, Version: "10.1.48-MariaDB", Major: 10, Minor: 1}
+			})
+
+			It("returns an error for account-lock", func() {
+				_, err := NewStrategy("account-lock", server, logger)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("not supported"))
+			})
+		})
+	})
+
+	Describe("revoke-writes", func() {
+		BeforeEach(func() {
+			strategy = newStrategy("revoke-writes")
+		})
+
+		It("revokes privileges and kills active connections", func() {
+			Expect(strategy.Apply(fakeDB)).To(Succeed())
+			Expect(fakeDB.RevokePrivilegesCallCount()).To(Equal(1))
+			Expect(fakeDB.KillActiveConnectionsCallCount()).To(Equal(1))
+		})
+
+		It("grants privileges and kills active connections when reversed", func() {
+			Expect(strategy.Reverse(fakeDB)).To(Succeed())
+			Expect(fakeDB.GrantPrivilegesCallCount()).To(Equal(1))
+			Expect(fakeDB.KillActiveConnectionsCallCount()).To(Equal(1))
+		})
+
+		Context("when revoking privileges fails", func() {
+			BeforeEach(func() {
+				fakeDB.RevokePrivilegesReturns(errors.New("fake-revoke-error"))
+			})
+
+			It("returns an error without killing connections", func() {
+				err := strategy.Apply(fakeDB)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-revoke-error"))
+				Expect(fakeDB.KillActiveConnectionsCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("account-lock", func() {
+		BeforeEach(func() {
+			strategy = newStrategy("account-lock")
+		})
+
+		It("locks the account and kills active connections", func() {
+			Expect(strategy.Apply(fakeDB)).To(Succeed())
+			Expect(fakeDB.LockAccountCallCount()).To(Equal(1))
+			Expect(fakeDB.KillActiveConnectionsCallCount()).To(Equal(1))
+			Expect(fakeDB.RevokePrivilegesCallCount()).To(Equal(0))
+		})
+
+		It("unlocks the account when reversed", func() {
+			Expect(strategy.Reverse(fakeDB)).To(Succeed())
+			Expect(fakeDB.UnlockAccountCallCount()).To(Equal(1))
+		})
+	})
+
+	Describe("max-connections", func() {
+		BeforeEach(func() {
+			strategy = newStrategy("max-connections")
+		})
+
+		It("limits the user to a single connection and kills active connections", func() {
+			Expect(strategy.Apply(fakeDB)).To(Succeed())
+			Expect(fakeDB.SetMaxUserConnectionsCallCount()).To(Equal(1))
+			Expect(fakeDB.SetMaxUserConnectionsArgsForCall(0)).To(Equal(1))
+			Expect(fakeDB.KillActiveConnectionsCallCount()).To(Equal(1))
+		})
+
+		It("restores the recorded limit when reversed", func() {
+			Expect(strategy.Reverse(fakeDB)).To(Succeed())
+			Expect(fakeDB.RestoreMaxUserConnectionsCallCount()).To(Equal(1))
+			Expect(fakeDB.SetMaxUserConnectionsCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("notify-only", func() {
+		BeforeEach(func() {
+			strategy = newStrategy("notify-only")
+		})
+
+		It("logs the violation without changing the database", func() {
+			Expect(strategy.Apply(fakeDB)).To(Succeed())
+			Expect(fakeDB.Invocations()).To(HaveKey("Name"))
+			Expect(fakeDB.Invocations()).To(HaveLen(1))
+			Expect(logger.LogMessages()).To(ContainElement(ContainSubstring("exceeds its quota")))
+		})
+	})
+})
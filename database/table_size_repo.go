@@ -0,0 +1,71 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"code.cloudfoundry.org/lager"
+)
+
+const largestTablesQuery = `
+SELECT table_name,
+	ROUND(COALESCE(data_length + index_length, 0) / 1024 / 1024, 1) AS size_mb,
+	ROUND(COALESCE(data_free, 0) / 1024 / 1024, 1) AS data_free_mb
+FROM information_schema.tables
+WHERE table_schema = ?
+ORDER BY size_mb DESC, table_name
+LIMIT ?
+`
+
+// TableSize is the data and index size of a table. DataFreeMB is allocated
+// but unused, and is not measured for diagnoses.
+type TableSize struct {
+	Name       string  `json:"name"`
+	SizeMB     float64 `json:"size_mb"`
+	DataFreeMB float64 `json:"data_free_mb"`
+}
+
+type TableSizeRepo interface {
+	Largest(dbName string, limit int) ([]TableSize, error)
+}
+
+type tableSizeRepo struct {
+	db     *sql.DB
+	logger lager.Logger
+}
+
+func NewTableSizeRepo(db *sql.DB, logger lager.Logger) TableSizeRepo {
+	return &tableSizeRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Largest returns up to limit tables of an instance database, largest first.
+func (r tableSizeRepo) Largest(dbName string, limit int) ([]TableSize, error) {
+	r.logger.Debug(fmt.Sprintf("Executing 'table size'.Largest for db '%s'", dbName))
+
+	tables := []TableSize{}
+
+	rows, err := r.db.Query(largestTablesQuery, dbName, limit)
+	if err != nil {
+		return tables, fmt.Errorf("Measuring largest tables of db '%s': %s", dbName, err.Error())
+	}
+
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	for rows.Next() {
+		var table TableSize
+		if err := rows.Scan(&table.Name, &table.SizeMB, &table.DataFreeMB); err != nil {
+			return tables, fmt.Errorf("Scanning largest table of db '%s': %s", dbName, err.Error())
+		}
+		tables = append(tables, table)
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return tables, fmt.Errorf("Reading largest tables of db '%s': %s", dbName, err.Error())
+	}
+
+	return tables, nil
+}
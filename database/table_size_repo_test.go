@@ -0,0 +1,77 @@
+package database_test
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"database/sql"
+
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+)
+
+var _ = Describe("TableSizeRepo", func() {
+	var (
+		logger       *lagertest.TestLogger
+		repo         TableSizeRepo
+		fakeDB       *sql.DB
+		mock         sqlmock.Sqlmock
+		tableColumns = []string{"table_name", "size_mb", "data_free_mb"}
+	)
+
+	BeforeEach(func() {
+		var err error
+		fakeDB, mock, err = sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		logger = lagertest.NewTestLogger("TableSizeRepo test")
+		repo = NewTableSizeRepo(fakeDB, logger)
+	})
+
+	AfterEach(func() {
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	Describe("Largest", func() {
+		It("returns the largest tables with their free space", func() {
+			mock.ExpectQuery("FROM information_schema.tables(.|\\n)*LIMIT \\?").
+				WithArgs("fake-db-name", 2).
+				WillReturnRows(sqlmock.NewRows(tableColumns).
+					AddRow("big_table", 9.5, 2.0).
+					AddRow("small_table", 0.5, 0))
+
+			tables, err := repo.Largest("fake-db-name", 2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tables).To(Equal([]TableSize{
+				{Name: "big_table", SizeMB: 9.5, DataFreeMB: 2},
+				{Name: "small_table", SizeMB: 0.5},
+			}))
+		})
+
+		It("returns no tables for an empty database", func() {
+			mock.ExpectQuery("FROM information_schema.tables").
+				WithArgs("fake-db-name", 5).
+				WillReturnRows(sqlmock.NewRows(tableColumns))
+
+			tables, err := repo.Largest("fake-db-name", 5)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tables).To(BeEmpty())
+		})
+
+		Context("when the db query fails", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery(".*").
+					WillReturnError(errors.New("fake-query-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := repo.Largest("fake-db-name", 5)
+				Expect(err).To(MatchError("Measuring largest tables of db 'fake-db-name': fake-query-error"))
+			})
+		})
+	})
+})
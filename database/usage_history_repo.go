@@ -0,0 +1,272 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// The usage history table is owned by the enforcer and lives in the broker
+// database. It holds one usage sample per instance and sampling time.
+const createUsageHistoryTableQuery = `
+CREATE TABLE IF NOT EXISTS %s.quota_enforcer_usage_history (
+	db_name     VARCHAR(255) NOT NULL,
+	measured_at DATETIME     NOT NULL,
+	used_bytes  BIGINT       NOT NULL,
+	quota_bytes BIGINT       NOT NULL,
+	PRIMARY KEY (db_name, measured_at)
+)`
+
+const measureUsageHistoryQueryPattern = `
+SELECT instances.db_name,
+	CAST(SUM(COALESCE(tables.data_length + tables.index_length, 0)) AS SIGNED) AS used_bytes,
+	CAST(COALESCE(MAX(instances.max_storage_mb), 0) * 1024 * 1024 AS SIGNED) AS quota_bytes
+FROM        %[2]s AS instances
+LEFT JOIN   information_schema.tables AS tables ON tables.table_schema = %[1]s
+GROUP  BY   instances.db_name
+`
+
+// The samples measured are written in batches, each row as recordUsageHistoryRow.
+const recordUsageHistoryQueryPattern = `
+INSERT IGNORE INTO %s.quota_enforcer_usage_history (db_name, measured_at, used_bytes, quota_bytes)
+VALUES %%s
+`
+
+const recordUsageHistoryRow = "(?, UTC_TIMESTAMP(), ?, ?)"
+
+const pruneUsageHistoryQuery = `
+DELETE FROM %s.quota_enforcer_usage_history
+WHERE measured_at < UTC_TIMESTAMP() - INTERVAL ? SECOND`
+
+// The samples of each instance within the window are summed up for a least
+// squares fit, with their age in days, negated, as x and the MB used as y, so
+// that only one row per instance is read. Samples of deleted instances are
+// left to expire with the retention.
+const usageFitsQueryPattern = `
+SELECT fits.db_name, fits.n, fits.sum_x, fits.sum_y, fits.sum_xx, fits.sum_xy,
+	latest.used_bytes / 1024 / 1024 AS used_mb,
+	latest.quota_bytes / 1024 / 1024 AS quota_mb
+FROM (
+	SELECT samples.db_name,
+		COUNT(*) AS n,
+		SUM(samples.x) AS sum_x,
+		SUM(samples.y) AS sum_y,
+		SUM(samples.x * samples.x) AS sum_xx,
+		SUM(samples.x * samples.y) AS sum_xy,
+		MAX(samples.measured_at) AS measured_at
+	FROM (
+		SELECT history.db_name, history.measured_at,
+			-TIMESTAMPDIFF(SECOND, history.measured_at, UTC_TIMESTAMP()) / 86400 AS x,
+			history.used_bytes / 1024 / 1024 AS y
+		FROM   %[1]s.quota_enforcer_usage_history AS history
+		JOIN   %[2]s AS instances ON history.db_name = instances.db_name
+		WHERE  history.measured_at >= UTC_TIMESTAMP() - INTERVAL ? SECOND
+	) AS samples
+	GROUP  BY samples.db_name
+	HAVING COUNT(*) >= 2
+) AS fits
+JOIN   %[1]s.quota_enforcer_usage_history AS latest
+	ON latest.db_name = fits.db_name AND latest.measured_at = fits.measured_at
+ORDER  BY fits.db_name
+`
+
+// maxDaysUntilQuota is the longest projection a time.Duration holds.
+const maxDaysUntilQuota = float64(math.MaxInt64) / float64(24*time.Hour)
+
+// Growth is the storage growth of an instance, fitted to its usage history.
+type Growth struct {
+	DBName   string
+	UsedMB   float64
+	QuotaMB  float64
+	MBPerDay float64
+}
+
+// TimeUntilQuota returns when the instance is projected to reach its quota at
+// its current growth, or zero if it already has. It is false if the instance
+// has an invalid quota, or is under it and not growing, which includes growing
+// too slowly to reach it within the roughly 292 years a time.Duration holds.
+func (g Growth) TimeUntilQuota() (time.Duration, bool) {
+	if g.QuotaMB <= 0 {
+		return 0, false
+	}
+	if g.UsedMB >= g.QuotaMB {
+		return 0, true
+	}
+	if g.MBPerDay <= 0 {
+		return 0, false
+	}
+	days := (g.QuotaMB - g.UsedMB) / g.MBPerDay
+	if days >= maxDaysUntilQuota {
+		return 0, false
+	}
+	return time.Duration(days * float64(24*time.Hour)), true
+}
+
+type UsageHistoryRepo interface {
+	Setup() error
+	Record() error
+	Prune(retention time.Duration) error
+	Growth(window time.Duration) ([]Growth, error)
+}
+
+type usageHistoryRepo struct {
+	brokerDBName  string
+	measureQuery  string
+	recordQuery   string
+	fitsQuery     string
+	measurementDB *sql.DB
+	db            *sql.DB
+	logger        lager.Logger
+}
+
+// NewUsageHistoryRepo returns a repo measuring usage on measurementDB, and
+// keeping the history in db.
+func NewUsageHistoryRepo(broker Broker, server Server, measurementDB, db *sql.DB, logger lager.Logger) UsageHistoryRepo {
+	measureQuery := fmt.Sprintf(
+		measureUsageHistoryQueryPattern,
+		server.collate("instances.db_name"),
+		broker.instancesTable("db_name", "max_storage_mb"),
+	)
+
+	fitsQuery := fmt.Sprintf(usageFitsQueryPattern, broker.quotedDBName(), broker.instancesTable("db_name"))
+
+	return &usageHistoryRepo{
+		brokerDBName:  broker.quotedDBName(),
+		measureQuery:  measureQuery,
+		recordQuery:   fmt.Sprintf(recordUsageHistoryQueryPattern, broker.quotedDBName()),
+		fitsQuery:     fitsQuery,
+		measurementDB: measurementDB,
+		db:            db,
+		logger:        logger,
+	}
+}
+
+// Setup creates the usage history table if it does not exist yet.
+func (r usageHistoryRepo) Setup() error {
+	_, err := r.db.Exec(fmt.Sprintf(createUsageHistoryTableQuery, r.brokerDBName))
+	if err != nil {
+		return fmt.Errorf("Creating usage history table: %s", err.Error())
+	}
+	return nil
+}
+
+// Record stores a usage sample of every instance.
+func (r usageHistoryRepo) Record() error {
+	samples, err := r.measure()
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := execBatches(r.db, r.recordQuery, recordUsageHistoryRow, samples)
+	if err != nil {
+		return fmt.Errorf("Recording usage history: %s", err.Error())
+	}
+	r.logger.Debug(fmt.Sprintf("Recording usage history: Rows affected: %d", rowsAffected))
+	return nil
+}
+
+// measure returns the name, used and quota bytes of every instance, as rows to
+// record.
+func (r usageHistoryRepo) measure() ([][]interface{}, error) {
+	samples := [][]interface{}{}
+
+	rows, err := r.measurementDB.Query(r.measureQuery)
+	if err != nil {
+		return samples, fmt.Errorf("Measuring usage history: %s", err.Error())
+	}
+
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			dbName     string
+			usedBytes  int64
+			quotaBytes int64
+		)
+		if err := rows.Scan(&dbName, &usedBytes, &quotaBytes); err != nil {
+			//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+			return samples, fmt.Errorf("Scanning measured usage history: %s", err.Error())
+		}
+		samples = append(samples, []interface{}{dbName, usedBytes, quotaBytes})
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return samples, fmt.Errorf("Reading measured usage history: %s", err.Error())
+	}
+
+	return samples, nil
+}
+
+// Prune deletes samples older than retention.
+func (r usageHistoryRepo) Prune(retention time.Duration) error {
+	_, err := r.db.Exec(fmt.Sprintf(pruneUsageHistoryQuery, r.brokerDBName), int64(retention.Seconds()))
+	if err != nil {
+		return fmt.Errorf("Pruning usage history: %s", err.Error())
+	}
+	return nil
+}
+
+// Growth fits a line to the samples of each instance within window, by least
+// squares. Instances with fewer than two samples are left out.
+func (r usageHistoryRepo) Growth(window time.Duration) ([]Growth, error) {
+	r.logger.Debug("Executing 'usage history'.Growth")
+
+	growths := []Growth{}
+
+	rows, err := r.db.Query(r.fitsQuery, int64(window.Seconds()))
+	if err != nil {
+		return growths, fmt.Errorf("Error executing 'usage history'.Growth: %s", err.Error())
+	}
+
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	for rows.Next() {
+		var fit growthFit
+		err := rows.Scan(&fit.dbName, &fit.n, &fit.sumX, &fit.sumY, &fit.sumXX, &fit.sumXY, &fit.lastUsedMB, &fit.lastQuotaMB)
+		if err != nil {
+			return growths, fmt.Errorf("Scanning result row of 'usage history'.Growth: %s", err.Error())
+		}
+
+		if growth, ok := fit.growth(); ok {
+			growths = append(growths, growth)
+		}
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return growths, fmt.Errorf("Reading result row of 'usage history'.Growth: %s", err.Error())
+	}
+
+	return growths, nil
+}
+
+// growthFit holds the sums of the samples of one instance, as summed up by
+// usageFitsQueryPattern, along with its latest sample.
+type growthFit struct {
+	dbName                   string
+	n                        float64
+	sumX, sumY, sumXX, sumXY float64
+	lastUsedMB, lastQuotaMB  float64
+}
+
+func (f growthFit) growth() (Growth, bool) {
+	if f.n < 2 {
+		return Growth{}, false
+	}
+
+	denominator := f.n*f.sumXX - f.sumX*f.sumX
+	if denominator == 0 {
+		return Growth{}, false
+	}
+
+	return Growth{
+		DBName:   f.dbName,
+		UsedMB:   f.lastUsedMB,
+		QuotaMB:  f.lastQuotaMB,
+		MBPerDay: (f.n*f.sumXY - f.sumX*f.sumY) / denominator,
+	}, true
+}
@@ -0,0 +1,227 @@
+package database_test
+
+import (
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"database/sql"
+
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+)
+
+var _ = Describe("UsageHistoryRepo", func() {
+
+	const brokerDBName = "fake_broker_db_name"
+
+	var (
+		logger     *lagertest.TestLogger
+		repo       UsageHistoryRepo
+		fakeDB     *sql.DB
+		mock       sqlmock.Sqlmock
+		fitColumns = []string{"db_name", "n", "sum_x", "sum_y", "sum_xx", "sum_xy", "used_mb", "quota_mb"}
+	)
+
+	BeforeEach(func() {
+		var err error
+		fakeDB, mock, err = sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		logger = lagertest.NewTestLogger("UsageHistoryRepo test")
+		server := Server{Flavor: FlavorMySQL, Version: "5.7.40", Major: 5, Minor: 7}
+		repo = NewUsageHistoryRepo(Broker{DBName: brokerDBName}, server, fakeDB, fakeDB, logger)
+	})
+
+	AfterEach(func() {
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	Describe("Setup", func() {
+		It("creates the usage history table in the broker database", func() {
+			mock.ExpectExec("CREATE TABLE IF NOT EXISTS `fake_broker_db_name`\\.quota_enforcer_usage_history").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+
+			Expect(repo.Setup()).To(Succeed())
+		})
+
+		Context("when creating the table fails", func() {
+			BeforeEach(func() {
+				mock.ExpectExec("CREATE TABLE").
+					WillReturnError(errors.New("fake-create-error"))
+			})
+
+			It("returns an error", func() {
+				Expect(repo.Setup()).To(MatchError("Creating usage history table: fake-create-error"))
+			})
+		})
+	})
+
+	Describe("Record", func() {
+		var sampleColumns = []string{"db_name", "used_bytes", "quota_bytes"}
+
+		It("stores a sample of every instance", func() {
+			mock.ExpectQuery("FROM\\s+`fake_broker_db_name`\\.service_instances(.|\\n)*information_schema\\.tables").
+				WillReturnRows(sqlmock.NewRows(sampleColumns).
+					AddRow("fake-db-1", 1024, 2048).
+					AddRow("fake-db-2", 0, 0))
+			mock.ExpectExec("INSERT IGNORE INTO `fake_broker_db_name`\\.quota_enforcer_usage_history(.|\\n)*VALUES \\(\\?, UTC_TIMESTAMP\\(\\), \\?, \\?\\), \\(\\?, UTC_TIMESTAMP\\(\\), \\?, \\?\\)").
+				WithArgs("fake-db-1", 1024, 2048, "fake-db-2", 0, 0).
+				WillReturnResult(sqlmock.NewResult(0, 2))
+
+			Expect(repo.Record()).To(Succeed())
+		})
+
+		It("stores nothing without instances", func() {
+			mock.ExpectQuery("SELECT").
+				WillReturnRows(sqlmock.NewRows(sampleColumns))
+
+			Expect(repo.Record()).To(Succeed())
+		})
+
+		Context("when measuring on a separate connection", func() {
+			var (
+				measurementDB   *sql.DB
+				measurementMock sqlmock.Sqlmock
+			)
+
+			BeforeEach(func() {
+				var err error
+				measurementDB, measurementMock, err = sqlmock.New()
+				Expect(err).ToNot(HaveOccurred())
+				server := Server{Flavor: FlavorMySQL, Version: "5.7.40", Major: 5, Minor: 7}
+				repo = NewUsageHistoryRepo(Broker{DBName: brokerDBName}, server, measurementDB, fakeDB, logger)
+			})
+
+			AfterEach(func() {
+				Expect(measurementMock.ExpectationsWereMet()).To(Succeed())
+			})
+
+			It("measures on the measurement connection and writes on the other", func() {
+				measurementMock.ExpectQuery("information_schema\\.tables").
+					WillReturnRows(sqlmock.NewRows(sampleColumns).
+						AddRow("fake-db-1", 1024, 2048))
+				mock.ExpectExec("INSERT IGNORE INTO").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+
+				Expect(repo.Record()).To(Succeed())
+			})
+		})
+
+		Context("when measuring fails", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery("SELECT").
+					WillReturnError(errors.New("fake-query-error"))
+			})
+
+			It("returns an error", func() {
+				Expect(repo.Record()).To(MatchError("Measuring usage history: fake-query-error"))
+			})
+		})
+
+		Context("when the db exec fails", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery("SELECT").
+					WillReturnRows(sqlmock.NewRows(sampleColumns).
+						AddRow("fake-db-1", 1024, 2048))
+				mock.ExpectExec("INSERT").
+					WillReturnError(errors.New("fake-exec-error"))
+			})
+
+			It("returns an error", func() {
+				Expect(repo.Record()).To(MatchError("Recording usage history: fake-exec-error"))
+			})
+		})
+	})
+
+	Describe("Prune", func() {
+		It("deletes samples older than the retention", func() {
+			mock.ExpectExec("DELETE FROM `fake_broker_db_name`\\.quota_enforcer_usage_history(.|\\n)*INTERVAL \\? SECOND").
+				WithArgs(int64(2 * 24 * 60 * 60)).
+				WillReturnResult(sqlmock.NewResult(0, 10))
+
+			Expect(repo.Prune(48 * time.Hour)).To(Succeed())
+		})
+
+		Context("when the db exec fails", func() {
+			BeforeEach(func() {
+				mock.ExpectExec("DELETE").
+					WillReturnError(errors.New("fake-exec-error"))
+			})
+
+			It("returns an error", func() {
+				Expect(repo.Prune(time.Hour)).To(MatchError("Pruning usage history: fake-exec-error"))
+			})
+		})
+	})
+
+	Describe("Growth", func() {
+		It("fits the growth per day of each instance to the sums of its samples", func() {
+			mock.ExpectQuery("FROM\\s+`fake_broker_db_name`\\.quota_enforcer_usage_history(.|\\s)*GROUP\\s+BY samples.db_name\\s+HAVING COUNT\\(\\*\\) >= 2").
+				WithArgs(int64(7 * 24 * 60 * 60)).
+				WillReturnRows(sqlmock.NewRows(fitColumns).
+					AddRow("fake-db-1", 3, -3, 15, 5, -13, 6, 10).
+					AddRow("fake-db-3", 2, -1.0/24, 6, 1.0/576, -0.125, 3, 20))
+
+			growths, err := repo.Growth(7 * 24 * time.Hour)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(growths).To(HaveLen(2))
+			Expect(growths[0].DBName).To(Equal("fake-db-1"))
+			Expect(growths[0].UsedMB).To(Equal(6.0))
+			Expect(growths[0].QuotaMB).To(Equal(10.0))
+			Expect(growths[0].MBPerDay).To(BeNumerically("~", 1, 0.0001))
+
+			Expect(growths[1].DBName).To(Equal("fake-db-3"))
+			Expect(growths[1].MBPerDay).To(BeNumerically("~", 0, 0.0001))
+		})
+
+		Context("when the db query fails", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery(".*").
+					WillReturnError(errors.New("fake-query-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := repo.Growth(time.Hour)
+				Expect(err).To(MatchError(ContainSubstring("fake-query-error")))
+			})
+		})
+	})
+})
+
+var _ = Describe("Growth", func() {
+	Describe("TimeUntilQuota", func() {
+		It("projects when a growing instance reaches its quota", func() {
+			timeUntilQuota, ok := Growth{UsedMB: 6, QuotaMB: 10, MBPerDay: 2}.TimeUntilQuota()
+			Expect(ok).To(BeTrue())
+			Expect(timeUntilQuota).To(Equal(48 * time.Hour))
+		})
+
+		It("is zero for an instance over its quota", func() {
+			timeUntilQuota, ok := Growth{UsedMB: 12, QuotaMB: 10, MBPerDay: 2}.TimeUntilQuota()
+			Expect(ok).To(BeTrue())
+			Expect(timeUntilQuota).To(BeZero())
+		})
+
+		It("is unknown for an instance which is not growing", func() {
+			_, ok := Growth{UsedMB: 6, QuotaMB: 10, MBPerDay: 0}.TimeUntilQuota()
+			Expect(ok).To(BeFalse())
+		})
+
+		It("is unknown for an instance growing too slowly to ever reach its quota", func() {
+			_, ok := Growth{UsedMB: 6, QuotaMB: 1e12, MBPerDay: 1e-6}.TimeUntilQuota()
+			Expect(ok).To(BeFalse())
+		})
+
+		It("is unknown for an instance with an invalid quota", func() {
+			_, ok := Growth{UsedMB: 6, QuotaMB: 0, MBPerDay: 2}.TimeUntilQuota()
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// The usage states of an instance. They are computed from its usage alone, so
+// StateOverQuota does not tell whether the instance is actually restricted,
+// which is published separately.
+const (
+	StateOK        = "ok"
+	StateWarning   = "warning"
+	StateOverQuota = "over-quota"
+
+	// StateInvalidQuota is the state of an instance with a zero or NULL quota,
+	// which is never enforced.
+	StateInvalidQuota = "invalid-quota"
+)
+
+// The usage table is owned by the enforcer and lives in the broker database,
+// so the broker and dashboards can read usage without measuring it themselves.
+// restricted is whether a grantee of the instance is restricted for exceeding
+// the storage quota.
+const createUsageTableQuery = `
+CREATE TABLE IF NOT EXISTS %s.quota_enforcer_usage (
+	service_instance_id INT          NOT NULL,
+	db_name             VARCHAR(255) NOT NULL,
+	used_bytes          BIGINT       NOT NULL,
+	quota_bytes         BIGINT       NOT NULL,
+	state               VARCHAR(16)  NOT NULL,
+	restricted          BOOL         NOT NULL DEFAULT FALSE,
+	last_measured_at    DATETIME     NOT NULL,
+	PRIMARY KEY (service_instance_id)
+)`
+
+// An instance is over quota, or has an invalid quota, under the same conditions
+// as in violatorsQueryPattern.
+// The warning threshold is a percentage of the quota; NULL disables the warning state.
+const measureUsageQueryPattern = `
+SELECT instances.id, instances.db_name,
+	CAST(SUM(COALESCE(tables.data_length + tables.index_length, 0)) AS SIGNED) AS used_bytes,
+	CAST(COALESCE(MAX(instances.max_storage_mb), 0) * 1024 * 1024 AS SIGNED) AS quota_bytes,
+	CASE
+		WHEN COALESCE(MAX(instances.max_storage_mb), 0) <= 0 THEN '%[5]s'
+		WHEN ROUND(SUM(COALESCE(tables.data_length + tables.index_length,0) / 1024 / 1024), 1) >= MAX(instances.max_storage_mb) THEN '%[2]s'
+		WHEN SUM(COALESCE(tables.data_length + tables.index_length,0) / 1024 / 1024) >= MAX(instances.max_storage_mb) * ? / 100 THEN '%[3]s'
+		ELSE '%[4]s'
+	END AS state
+FROM        %[6]s AS instances
+LEFT JOIN   information_schema.tables AS tables ON tables.table_schema = %[1]s
+GROUP  BY   instances.id, instances.db_name
+`
+
+// The usage measured is written in batches, each row as publishUsageRow.
+const publishUsageQueryPattern = `
+INSERT INTO %s.quota_enforcer_usage
+	(service_instance_id, db_name, used_bytes, quota_bytes, state, restricted, last_measured_at)
+VALUES %%s
+ON DUPLICATE KEY UPDATE
+	db_name = VALUES(db_name),
+	used_bytes = VALUES(used_bytes),
+	quota_bytes = VALUES(quota_bytes),
+	state = VALUES(state),
+	restricted = VALUES(restricted),
+	last_measured_at = VALUES(last_measured_at)
+`
+
+const publishUsageRow = "(?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())"
+
+const deleteStaleUsageQuery = `
+DELETE FROM %[1]s.quota_enforcer_usage
+WHERE service_instance_id NOT IN (SELECT instances.id FROM %[2]s AS instances)`
+
+// UsagePublisher writes the usage, state and whether it is restricted of every
+// instance to the usage table.
+type UsagePublisher interface {
+	Setup() error
+	Publish() error
+}
+
+type usagePublisher struct {
+	brokerDBName     string
+	instancesTable   string
+	measureQuery     string
+	publishQuery     string
+	recordsDBName    string
+	warningThreshold sql.NullFloat64
+	measurementDB    *sql.DB
+	db               *sql.DB
+	logger           lager.Logger
+}
+
+// NewUsagePublisher returns a publisher marking instances that use at least
+// warningPercent of their quota as warning. Zero disables the warning state.
+// Usage is measured on measurementDB and written through db, and restrictions
+// are read from the records kept in recordsDBName.
+func NewUsagePublisher(recordsDBName string, broker Broker, warningPercent float64, server Server, measurementDB, db *sql.DB, logger lager.Logger) UsagePublisher {
+	measureQuery := fmt.Sprintf(
+		measureUsageQueryPattern,
+		server.collate("instances.db_name"),
+		StateOverQuota,
+		StateWarning,
+		StateOK,
+		StateInvalidQuota,
+		broker.instancesTable("id", "db_name", "max_storage_mb"),
+	)
+
+	return &usagePublisher{
+		brokerDBName:     broker.quotedDBName(),
+		instancesTable:   broker.instancesTable("id"),
+		measureQuery:     measureQuery,
+		publishQuery:     fmt.Sprintf(publishUsageQueryPattern, broker.quotedDBName()),
+		recordsDBName:    quoteIdentifier(recordsDBName),
+		warningThreshold: sql.NullFloat64{Float64: warningPercent, Valid: warningPercent > 0},
+		measurementDB:    measurementDB,
+		db:               db,
+		logger:           logger,
+	}
+}
+
+// Setup creates the usage table if it does not exist yet.
+func (p usagePublisher) Setup() error {
+	_, err := p.db.Exec(fmt.Sprintf(createUsageTableQuery, p.brokerDBName))
+	if err != nil {
+		return fmt.Errorf("Creating usage table: %s", err.Error())
+	}
+	return nil
+}
+
+func (p usagePublisher) Publish() error {
+	restricted, err := restrictedDatabases(p.db, p.recordsDBName)
+	if err != nil {
+		return err
+	}
+
+	usages, err := p.measure(restricted)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := execBatches(p.db, p.publishQuery, publishUsageRow, usages)
+	if err != nil {
+		return fmt.Errorf("Publishing usage: %s", err.Error())
+	}
+	p.logger.Debug(fmt.Sprintf("Publishing usage: Rows affected: %d", rowsAffected))
+
+	_, err = p.db.Exec(fmt.Sprintf(deleteStaleUsageQuery, p.brokerDBName, p.instancesTable))
+	if err != nil {
+		return fmt.Errorf("Deleting usage of deleted instances: %s", err.Error())
+	}
+
+	return nil
+}
+
+// measure returns the id, name, used and quota bytes, state and whether it is
+// restricted of every instance, as rows to publish.
+func (p usagePublisher) measure(restricted map[string]bool) ([][]interface{}, error) {
+	usages := [][]interface{}{}
+
+	rows, err := p.measurementDB.Query(p.measureQuery, p.warningThreshold)
+	if err != nil {
+		return usages, fmt.Errorf("Measuring usage: %s", err.Error())
+	}
+
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id         int64
+			dbName     string
+			usedBytes  int64
+			quotaBytes int64
+			state      string
+		)
+		if err := rows.Scan(&id, &dbName, &usedBytes, &quotaBytes, &state); err != nil {
+			//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+			return usages, fmt.Errorf("Scanning measured usage: %s", err.Error())
+		}
+		usages = append(usages, []interface{}{id, dbName, usedBytes, quotaBytes, state, restricted[dbName]})
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return usages, fmt.Errorf("Reading measured usage: %s", err.Error())
+	}
+
+	return usages, nil
+}
@@ -0,0 +1,190 @@
+package database_test
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"database/sql"
+
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+)
+
+var _ = Describe("UsagePublisher", func() {
+
+	const (
+		recordsDBName = "fake_records_db_name"
+		brokerDBName  = "fake_broker_db_name"
+	)
+
+	var (
+		logger         *lagertest.TestLogger
+		publisher      UsagePublisher
+		warningPercent float64
+		fakeDB         *sql.DB
+		mock           sqlmock.Sqlmock
+		usageColumns   = []string{"id", "db_name", "used_bytes", "quota_bytes", "state"}
+	)
+
+	expectRestricted := func(dbNames ...string) {
+		rows := sqlmock.NewRows([]string{"db_name"})
+		for _, dbName := range dbNames {
+			rows.AddRow(dbName)
+		}
+		mock.ExpectQuery("SELECT DISTINCT db_name FROM `fake_records_db_name`\\.quota_enforcer_restrictions").
+			WithArgs(ObjectQuotaRestriction, "notify-only").
+			WillReturnRows(rows)
+	}
+
+	BeforeEach(func() {
+		var err error
+		fakeDB, mock, err = sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		logger = lagertest.NewTestLogger("UsagePublisher test")
+		warningPercent = 80
+	})
+
+	JustBeforeEach(func() {
+		server := Server{Flavor: FlavorMySQL, Version: "5.7.40", Major: 5, Minor: 7}
+		publisher = NewUsagePublisher(recordsDBName, Broker{DBName: brokerDBName}, warningPercent, server, fakeDB, fakeDB, logger)
+	})
+
+	AfterEach(func() {
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	Describe("Setup", func() {
+		It("creates the usage table in the broker database, keyed by service instance id", func() {
+			mock.ExpectExec("CREATE TABLE IF NOT EXISTS `fake_broker_db_name`\\.quota_enforcer_usage(.|\\n)*PRIMARY KEY \\(service_instance_id\\)").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+
+			Expect(publisher.Setup()).To(Succeed())
+		})
+	})
+
+	Describe("Publish", func() {
+		It("upserts the usage, state and restriction of every instance and deletes stale rows", func() {
+			expectRestricted("fake-db-2")
+			mock.ExpectQuery("'invalid-quota'(.|\\n)*'over-quota'(.|\\n)*'warning'(.|\\n)*'ok'(.|\\n)*FROM\\s+`fake_broker_db_name`\\.service_instances").
+				WithArgs(80.0).
+				WillReturnRows(sqlmock.NewRows(usageColumns).
+					AddRow(1, "fake-db-1", 1024, 2048, "ok").
+					AddRow(2, "fake-db-2", 4096, 2048, "over-quota"))
+			mock.ExpectExec("INSERT INTO `fake_broker_db_name`\\.quota_enforcer_usage(.|\\n)*VALUES \\(\\?, \\?, \\?, \\?, \\?, \\?, UTC_TIMESTAMP\\(\\)\\), \\(\\?, \\?, \\?, \\?, \\?, \\?, UTC_TIMESTAMP\\(\\)\\)\\s+ON DUPLICATE KEY UPDATE").
+				WithArgs(1, "fake-db-1", 1024, 2048, "ok", false, 2, "fake-db-2", 4096, 2048, "over-quota", true).
+				WillReturnResult(sqlmock.NewResult(0, 2))
+			mock.ExpectExec("DELETE FROM `fake_broker_db_name`\\.quota_enforcer_usage\\s+WHERE service_instance_id NOT IN").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+
+			Expect(publisher.Publish()).To(Succeed())
+		})
+
+		Context("when measuring on a separate connection", func() {
+			var (
+				measurementDB   *sql.DB
+				measurementMock sqlmock.Sqlmock
+			)
+
+			JustBeforeEach(func() {
+				var err error
+				measurementDB, measurementMock, err = sqlmock.New()
+				Expect(err).ToNot(HaveOccurred())
+				server := Server{Flavor: FlavorMySQL, Version: "5.7.40", Major: 5, Minor: 7}
+				publisher = NewUsagePublisher(recordsDBName, Broker{DBName: brokerDBName}, warningPercent, server, measurementDB, fakeDB, logger)
+			})
+
+			AfterEach(func() {
+				Expect(measurementMock.ExpectationsWereMet()).To(Succeed())
+			})
+
+			It("measures on the measurement connection and writes on the other", func() {
+				expectRestricted()
+				measurementMock.ExpectQuery("information_schema.tables").
+					WillReturnRows(sqlmock.NewRows(usageColumns).
+						AddRow(1, "fake-db-1", 1024, 2048, "ok"))
+				mock.ExpectExec("INSERT INTO").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("DELETE FROM").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+
+				Expect(publisher.Publish()).To(Succeed())
+			})
+		})
+
+		Context("when there is no warning threshold", func() {
+			BeforeEach(func() {
+				warningPercent = 0
+			})
+
+			It("never marks instances as warning", func() {
+				expectRestricted()
+				mock.ExpectQuery("SELECT").
+					WithArgs(nil).
+					WillReturnRows(sqlmock.NewRows(usageColumns))
+				mock.ExpectExec("DELETE FROM").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+
+				Expect(publisher.Publish()).To(Succeed())
+			})
+		})
+
+		Context("when reading the restricted databases fails", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery("quota_enforcer_restrictions").
+					WillReturnError(errors.New("fake-restricted-error"))
+			})
+
+			It("returns an error", func() {
+				Expect(publisher.Publish()).To(MatchError("Reading restricted databases: fake-restricted-error"))
+			})
+		})
+
+		Context("when measuring fails", func() {
+			BeforeEach(func() {
+				expectRestricted()
+				mock.ExpectQuery("SELECT").
+					WillReturnError(errors.New("fake-measure-error"))
+			})
+
+			It("returns an error", func() {
+				Expect(publisher.Publish()).To(MatchError("Measuring usage: fake-measure-error"))
+			})
+		})
+
+		Context("when publishing fails", func() {
+			BeforeEach(func() {
+				expectRestricted()
+				mock.ExpectQuery("SELECT").
+					WillReturnRows(sqlmock.NewRows(usageColumns).
+						AddRow(1, "fake-db-1", 1024, 2048, "ok"))
+				mock.ExpectExec("INSERT INTO").
+					WillReturnError(errors.New("fake-publish-error"))
+			})
+
+			It("returns an error", func() {
+				Expect(publisher.Publish()).To(MatchError("Publishing usage: fake-publish-error"))
+			})
+		})
+
+		Context("when deleting stale rows fails", func() {
+			BeforeEach(func() {
+				expectRestricted()
+				mock.ExpectQuery("SELECT").
+					WillReturnRows(sqlmock.NewRows(usageColumns))
+				mock.ExpectExec("DELETE FROM").
+					WillReturnError(errors.New("fake-delete-error"))
+			})
+
+			It("returns an error", func() {
+				err := publisher.Publish()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-delete-error"))
+			})
+		})
+	})
+})
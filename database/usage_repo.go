@@ -0,0 +1,124 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// The user and host are extracted from the grantee, and the name from the
+// grant, as in violatorsQueryPattern.
+const usageQueryPattern = `
+SELECT dbs.name, dbs.user, dbs.host,
+	ROUND(SUM(COALESCE(tables.data_length + tables.index_length,0) / 1024 / 1024), 1) AS used_mb,
+	COALESCE(MAX(instances.max_storage_mb), 0) AS quota_mb,
+	MAX(COALESCE(%[7]s, 0)) AS max_updates
+FROM   (
+	SELECT DISTINCT %[8]s AS name,
+		SUBSTRING(grantee, 2, CHAR_LENGTH(grantee) - CHAR_LENGTH(SUBSTRING_INDEX(grantee, '@', -1)) - 3) AS user,
+		TRIM(BOTH "'" FROM SUBSTRING_INDEX(grantee, '@', -1)) AS host
+	FROM information_schema.schema_privileges
+	WHERE privilege_type IN ('INSERT', 'UPDATE', 'CREATE')
+	AND SUBSTRING(grantee, 2, CHAR_LENGTH(grantee) - CHAR_LENGTH(SUBSTRING_INDEX(grantee, '@', -1)) - 3) NOT IN (%[1]s)
+) AS dbs
+JOIN        %[2]s AS accounts ON dbs.user = %[3]s AND dbs.host = %[4]s
+JOIN        %[5]s AS instances ON dbs.name = %[6]s
+LEFT JOIN   information_schema.tables AS tables ON tables.table_schema = dbs.name
+GROUP  BY   dbs.name, dbs.user, dbs.host
+`
+
+// Usage is the storage used by an instance database, together with the
+// current account limits of one of its writers.
+type Usage struct {
+	Database          Database
+	UsedMB            float64
+	QuotaMB           float64
+	MaxUpdatesPerHour int
+}
+
+// PercentOfQuota returns the used storage as a percentage of the quota, or
+// zero if the instance has no quota.
+func (u Usage) PercentOfQuota() float64 {
+	if u.QuotaMB <= 0 {
+		return 0
+	}
+	return u.UsedMB / u.QuotaMB * 100
+}
+
+type UsageRepo interface {
+	All() ([]Usage, error)
+}
+
+type usageRepo struct {
+	query         string
+	ignoredUsers  []string
+	server        Server
+	measurementDB *sql.DB
+	actionDB      *sql.DB
+	logger        lager.Logger
+}
+
+func NewUsageRepo(broker Broker, ignoredUsers []string, server Server, measurementDB, actionDB *sql.DB, logger lager.Logger) UsageRepo {
+	ignoredUsersPlaceholders := strings.Join(strings.Split(strings.Repeat("?", len(ignoredUsers)), ""), ",")
+	query := fmt.Sprintf(
+		usageQueryPattern,
+		ignoredUsersPlaceholders,
+		server.accountsTable(),
+		server.collateBinary("accounts.User"),
+		server.collateBinary("accounts.Host"),
+		broker.instancesTable("db_name", "max_storage_mb"),
+		server.collate("instances.db_name"),
+		server.accountAttributeValue("max_updates"),
+		unescapeSchema("table_schema"),
+	)
+
+	return &usageRepo{
+		query:         query,
+		ignoredUsers:  ignoredUsers,
+		server:        server,
+		measurementDB: measurementDB,
+		actionDB:      actionDB,
+		logger:        logger,
+	}
+}
+
+func (r usageRepo) All() ([]Usage, error) {
+	r.logger.Debug("Executing 'usage'.All")
+
+	usages := []Usage{}
+
+	parametersInterface := make([]interface{}, len(r.ignoredUsers))
+	for i, v := range r.ignoredUsers {
+		parametersInterface[i] = v
+	}
+
+	rows, err := r.measurementDB.Query(r.query, parametersInterface...)
+	if err != nil {
+		return usages, fmt.Errorf("Error executing 'usage'.All: %s", err.Error())
+	}
+
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			dbName, dbUser, dbHost string
+			usage                  Usage
+		)
+		err := rows.Scan(&dbName, &dbUser, &dbHost, &usage.UsedMB, &usage.QuotaMB, &usage.MaxUpdatesPerHour)
+		if err != nil {
+			return usages, fmt.Errorf("Scanning result row of 'usage'.All: %s", err.Error())
+		}
+
+		usage.Database = New(dbName, dbUser, dbHost, r.server, r.actionDB, r.logger)
+		usages = append(usages, usage)
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return usages, fmt.Errorf("Reading result row of 'usage'.All: %s", err.Error())
+	}
+
+	return usages, nil
+}
@@ -0,0 +1,85 @@
+package database_test
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"database/sql"
+
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+)
+
+var _ = Describe("UsageRepo", func() {
+
+	const brokerDBName = "fake_broker_db_name"
+
+	var (
+		logger *lagertest.TestLogger
+		repo   UsageRepo
+		server Server
+		fakeDB *sql.DB
+		mock   sqlmock.Sqlmock
+	)
+
+	BeforeEach(func() {
+		var err error
+		fakeDB, mock, err = sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		logger = lagertest.NewTestLogger("UsageRepo test")
+		server = Server{Flavor: FlavorMySQL, Version: "5.7.40", Major: 5, Minor: 7}
+		repo = NewUsageRepo(Broker{DBName: brokerDBName}, []string{"fake_admin_user"}, server, fakeDB, fakeDB, logger)
+	})
+
+	AfterEach(func() {
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	Describe("All", func() {
+		var usageColumns = []string{"name", "user", "host", "used_mb", "quota_mb", "max_updates"}
+
+		It("returns the usage and limits of every writer", func() {
+			mock.ExpectQuery("accounts.max_updates").
+				WithArgs("fake_admin_user").
+				WillReturnRows(sqlmock.NewRows(usageColumns).
+					AddRow("fake-database-1", "cf_fake-user-1", "%", 9.5, 10, 0).
+					AddRow("fake-database-2", "cf_fake-user-2", "%", 1.2, 100, 1000))
+
+			usages, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(usages).To(ConsistOf(
+				Usage{
+					Database: New("fake-database-1", "cf_fake-user-1", "%", server, fakeDB, logger),
+					UsedMB:   9.5,
+					QuotaMB:  10,
+				},
+				Usage{
+					Database:          New("fake-database-2", "cf_fake-user-2", "%", server, fakeDB, logger),
+					UsedMB:            1.2,
+					QuotaMB:           100,
+					MaxUpdatesPerHour: 1000,
+				},
+			))
+			Expect(usages[0].PercentOfQuota()).To(BeNumerically("~", 95, 0.001))
+		})
+
+		Context("when the db query fails", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery(".*").
+					WillReturnError(errors.New("fake-query-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := repo.All()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-query-error"))
+			})
+		})
+	})
+})
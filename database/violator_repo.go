@@ -8,25 +8,144 @@ import (
 	"code.cloudfoundry.org/lager"
 )
 
+// Grantees have the form 'user'@'host'; the user is everything between the
+// leading quote and the quote before the last '@', so it may contain quotes and '@'.
+// A database granted as a pattern has its wildcards escaped, e.g. cf\_db; the
+// name is unescaped, and the pattern kept as grant_schema to revoke on.
+const granteesPattern = `
+		SELECT DISTINCT %[1]s AS name, table_schema AS grant_schema,
+			%[2]s AS user,
+			%[3]s AS host
+		FROM information_schema.schema_privileges
+		WHERE %[4]s`
+
+// Write privileges granted to a role are taken from its members by revoking the
+// role from them, so that a role shared with other accounts is left unchanged.
+// The role is returned as role_user and role_host, which are empty for the
+// grantees that are not roles.
+const directGranteesPattern = `
+	SELECT privileges.name, privileges.grant_schema, privileges.user, privileges.host, '' AS role_user, '' AS role_host
+	FROM (%[1]s) AS privileges`
+
+const nonRoleGranteesPattern = directGranteesPattern + `
+	LEFT JOIN (%[2]s) AS roles ON %[3]s = privileges.user AND %[4]s = privileges.host
+	WHERE roles.role_user IS NULL`
+
+const roleMembersPattern = `
+	SELECT privileges.name, privileges.grant_schema, %[5]s AS user, %[6]s AS host, privileges.user AS role_user, privileges.host AS role_host
+	FROM (%[1]s) AS privileges
+	JOIN (%[2]s) AS roles ON %[3]s = privileges.user AND %[4]s = privileges.host`
+
+// With partial_revokes, the global write privileges of an account bound to a
+// database are revoked on it alone. An account already restricted on the
+// database has it listed in its Restrictions attribute.
+const partialRevokesPattern = `
+	SELECT bindings.name, bindings.grant_schema, bindings.user, bindings.host, '' AS role_user, '' AS role_host
+	FROM (%[1]s) AS bindings
+	JOIN mysql.user AS global_accounts ON %[2]s = bindings.user AND %[3]s = bindings.host
+	WHERE (%[4]s)
+	AND NOT JSON_CONTAINS(COALESCE(JSON_EXTRACT(global_accounts.User_attributes, '$.Restrictions[*].Database'), JSON_ARRAY()), JSON_QUOTE(bindings.grant_schema))`
+
+const writeGranteesPattern = `
+		SELECT grantees.* FROM (%s
+		) AS grantees
+		WHERE grantees.user NOT IN (%s)`
+
+// writeGrantees returns a query of the accounts holding any of the write
+// privileges, a quoted list, on a database, except the ignored users, with
+// the columns name, grant_schema, user, host, role_user and role_host.
+func writeGrantees(server Server, privileges string, ignoredUsers []string) string {
+	return grantees(server, privileges, ignoredUsers, true)
+}
+
+// directGrantees is like writeGrantees, for the privileges granted to users
+// themselves, leaving out roles and their members.
+func directGrantees(server Server, privileges string, ignoredUsers []string) string {
+	return grantees(server, privileges, ignoredUsers, false)
+}
+
+func grantees(server Server, privileges string, ignoredUsers []string, throughRoles bool) string {
+	ignoredUsersPlaceholders := strings.Join(strings.Split(strings.Repeat("?", len(ignoredUsers)), ""), ",")
+	return fmt.Sprintf(writeGranteesPattern, granteeSources(server, privileges, throughRoles), ignoredUsersPlaceholders)
+}
+
+// granteeSources returns the union of the grantees holding any of the
+// privileges, a quoted list, with the columns of writeGrantees and no user
+// left out.
+func granteeSources(server Server, privileges string, throughRoles bool) string {
+	user := server.collateBinary("SUBSTRING(grantee, 2, CHAR_LENGTH(grantee) - CHAR_LENGTH(SUBSTRING_INDEX(grantee, '@', -1)) - 3)")
+	host := server.collateBinary(`TRIM(BOTH "'" FROM SUBSTRING_INDEX(grantee, '@', -1))`)
+	name := unescapeSchema("table_schema")
+	privileged := fmt.Sprintf(granteesPattern, name, user, host, fmt.Sprintf("privilege_type IN (%s)", privileges))
+
+	branches := []string{fmt.Sprintf(directGranteesPattern, privileged)}
+	if server.supportsRoles() {
+		roleArgs := []interface{}{
+			privileged,
+			server.roleEdges(),
+			server.collateBinary("roles.role_user"),
+			server.collateBinary("roles.role_host"),
+			server.collateBinary("roles.member_user"),
+			server.collateBinary("roles.member_host"),
+		}
+		branches = []string{fmt.Sprintf(nonRoleGranteesPattern, roleArgs[:4]...)}
+		if throughRoles {
+			branches = append(branches, fmt.Sprintf(roleMembersPattern, roleArgs...))
+		}
+	}
+	if throughRoles && server.PartialRevokes {
+		bindings := fmt.Sprintf(granteesPattern, name, user, host, "TRUE")
+		branches = append(branches, fmt.Sprintf(partialRevokesPattern,
+			bindings, server.collateBinary("global_accounts.User"), server.collateBinary("global_accounts.Host"), globalPrivileges(privileges)))
+	}
+
+	return strings.Join(branches, "\n\tUNION")
+}
+
+// globalPrivileges returns a condition on global_accounts holding any of the
+// privileges, a quoted list, e.g. global_accounts.Insert_priv = 'Y'.
+func globalPrivileges(privileges string) string {
+	conditions := []string{}
+	for _, privilege := range strings.Split(privileges, ",") {
+		privilege = strings.ToLower(strings.Trim(strings.TrimSpace(privilege), "'"))
+		conditions = append(conditions, fmt.Sprintf("global_accounts.%s_priv = 'Y'", strings.ToUpper(privilege[:1])+privilege[1:]))
+	}
+	return strings.Join(conditions, " OR ")
+}
+
+// unescapeSchema returns the database name a schema pattern of a grant
+// stands for, with its escaped wildcards, \_ and \%, unescaped.
+func unescapeSchema(column string) string {
+	return fmt.Sprintf("REPLACE(REPLACE(%s, CONCAT(CHAR(92), CHAR(95)), CHAR(95)), CONCAT(CHAR(92), CHAR(37)), CHAR(37))", column)
+}
+
+// A zero or NULL quota is invalid, e.g. after a bad broker migration, and never
+// makes an instance a violator.
 const violatorsQueryPattern = `
-SELECT violators.name AS violator_db, violators.user AS violator_user
+SELECT violators.name AS violator_db, violators.user AS violator_user, violators.host AS violator_host,
+	violators.grant_schema, violators.role_user, violators.role_host
 FROM (
-	SELECT dbs.name, dbs.user, tables.data_length, tables.index_length
-	FROM   (
-		SELECT DISTINCT table_schema AS name, replace(substring_index(grantee, '@', 1), "'", '') AS user
-		FROM information_schema.schema_privileges
-		WHERE privilege_type IN ('INSERT', 'UPDATE', 'CREATE')
-		AND replace(substring_index(grantee, '@', 1), "'", '') NOT IN (%s)
+	SELECT dbs.name, dbs.grant_schema, dbs.user, dbs.host, dbs.role_user, dbs.role_host
+	FROM   (%[1]s
 	) AS dbs
-	JOIN %s.service_instances AS instances ON dbs.name = instances.db_name COLLATE utf8_general_ci
+	JOIN %[2]s AS instances ON dbs.name = %[3]s
 	JOIN information_schema.tables AS tables ON tables.table_schema = dbs.name
-	GROUP BY dbs.user
-	HAVING ROUND(SUM(COALESCE(tables.data_length + tables.index_length,0) / 1024 / 1024), 1) >= MAX(instances.max_storage_mb)
+	GROUP BY dbs.name, dbs.grant_schema, dbs.user, dbs.host, dbs.role_user, dbs.role_host
+	HAVING MAX(instances.max_storage_mb) > 0
+	   AND ROUND(SUM(COALESCE(tables.data_length + tables.index_length,0) / 1024 / 1024), 1) >= MAX(instances.max_storage_mb)
 ) AS violators
 `
 
-func NewViolatorRepo(brokerDBName string, ignoredUsers []string, db *sql.DB, logger lager.Logger) Repo {
-	ignoredUsersPlaceholders := strings.Join(strings.Split(strings.Repeat("?", len(ignoredUsers)), ""), ",")
-	query := fmt.Sprintf(violatorsQueryPattern, ignoredUsersPlaceholders, brokerDBName)
-	return newRepo(query, ignoredUsers, db, logger, "quota violator")
+func NewViolatorRepo(recordsDBName string, broker Broker, ignoredUsers []string, server Server, strategy Strategy, measurementDB, actionDB *sql.DB, logger lager.Logger) Repo {
+	if condition := appliedCondition(strategy, server, recordsDBName); condition != "" {
+		return newRestrictionRepo(broker, ignoredUsers, server, condition, measurementDB, actionDB, logger)
+	}
+
+	query := fmt.Sprintf(
+		violatorsQueryPattern,
+		writeGrantees(server, "'INSERT', 'UPDATE', 'CREATE'", ignoredUsers),
+		broker.instancesTable("db_name", "max_storage_mb"),
+		server.collate("instances.db_name"),
+	)
+	return newRepo(query, ignoredUsers, server, measurementDB, actionDB, logger, "quota violator")
 }
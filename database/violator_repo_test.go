@@ -2,6 +2,7 @@ package database_test
 
 import (
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
 	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
 
 	. "github.com/onsi/ginkgo"
@@ -10,6 +11,7 @@ import (
 	"database/sql"
 
 	"errors"
+	"regexp"
 
 	"code.cloudfoundry.org/lager/lagertest"
 )
@@ -19,10 +21,12 @@ var _ = Describe("ViolatorRepo", func() {
 	const brokerDBName = "fake_broker_db_name"
 
 	var (
-		logger *lagertest.TestLogger
-		repo   Repo
-		fakeDB *sql.DB
-		mock   sqlmock.Sqlmock
+		logger   *lagertest.TestLogger
+		repo     Repo
+		server   Server
+		strategy Strategy
+		fakeDB   *sql.DB
+		mock     sqlmock.Sqlmock
 	)
 
 	BeforeEach(func() {
@@ -31,8 +35,11 @@ var _ = Describe("ViolatorRepo", func() {
 		Expect(err).ToNot(HaveOccurred())
 
 		logger = lagertest.NewTestLogger("ViolatorRepo test")
+		server = Server{Flavor: FlavorMySQL, Version: "5.7.40", Major: 5, Minor: 7}
+		strategy, err = NewStrategy("", server, logger)
+		Expect(err).ToNot(HaveOccurred())
 		ignoredUsers := []string{"fake_admin_user"}
-		repo = NewViolatorRepo(brokerDBName, ignoredUsers, fakeDB, logger)
+		repo = NewViolatorRepo("fake_records_db_name", Broker{DBName: brokerDBName}, ignoredUsers, server, strategy, fakeDB, fakeDB, logger)
 	})
 
 	AfterEach(func() {
@@ -41,7 +48,7 @@ var _ = Describe("ViolatorRepo", func() {
 
 	Describe("All", func() {
 		var (
-			tableSchemaColumns = []string{"db", "user"}
+			tableSchemaColumns = []string{"db", "user", "host"}
 			matchAny           = ".*"
 		)
 
@@ -49,25 +56,231 @@ var _ = Describe("ViolatorRepo", func() {
 			mock.ExpectQuery(matchAny).
 				WithArgs().
 				WillReturnRows(sqlmock.NewRows(tableSchemaColumns).
-					AddRow("fake-database-1", "cf_fake-user-1").
-					AddRow("fake-database-2", "cf_fake-user-2"))
+					AddRow("fake-database-1", "cf_fake-user-1", "%").
+					AddRow("fake-database-2", "cf_fake-user-2", "%"))
 
 			violators, err := repo.All()
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(violators).To(ConsistOf(
-				New("fake-database-1", "cf_fake-user-1", fakeDB, logger),
-				New("fake-database-2", "cf_fake-user-2", fakeDB, logger),
+				New("fake-database-1", "cf_fake-user-1", "%", server, fakeDB, logger),
+				New("fake-database-2", "cf_fake-user-2", "%", server, fakeDB, logger),
 			))
 		})
 
+		Context("when measuring on a separate connection", func() {
+			var (
+				actionDB   *sql.DB
+				actionMock sqlmock.Sqlmock
+			)
+
+			BeforeEach(func() {
+				var err error
+				actionDB, actionMock, err = sqlmock.New()
+				Expect(err).ToNot(HaveOccurred())
+				repo = NewViolatorRepo("fake_records_db_name", Broker{DBName: brokerDBName}, []string{"fake_admin_user"}, server, strategy, fakeDB, actionDB, logger)
+			})
+
+			AfterEach(func() {
+				Expect(actionMock.ExpectationsWereMet()).To(Succeed())
+			})
+
+			It("finds violators on the measurement connection and restricts them on the action connection", func() {
+				mock.ExpectQuery(matchAny).
+					WithArgs("fake_admin_user").
+					WillReturnRows(sqlmock.NewRows(tableSchemaColumns).
+						AddRow("fake-database-1", "cf_fake-user-1", "%"))
+				actionMock.ExpectExec("REVOKE INSERT, UPDATE, CREATE ON `fake-database-1`\\.\\* FROM 'cf_fake-user-1'@'%'").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				actionMock.ExpectExec("FLUSH PRIVILEGES").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+
+				violators, err := repo.All()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(violators).To(HaveLen(1))
+				Expect(violators[0].RevokePrivileges()).To(Succeed())
+			})
+		})
+
+		It("quotes the broker database name", func() {
+			mock.ExpectQuery("JOIN\\s+`fake_broker_db_name`\\.service_instances").
+				WithArgs().
+				WillReturnRows(sqlmock.NewRows(tableSchemaColumns))
+
+			_, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("never treats a zero or NULL quota as exceeded", func() {
+			mock.ExpectQuery("HAVING MAX\\(instances.max_storage_mb\\) > 0").
+				WithArgs().
+				WillReturnRows(sqlmock.NewRows(tableSchemaColumns))
+
+			_, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("compares broker columns using the utf8 collation", func() {
+			mock.ExpectQuery("instances.db_name COLLATE utf8_general_ci").
+				WithArgs().
+				WillReturnRows(sqlmock.NewRows(tableSchemaColumns))
+
+			_, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		Context("when the server is MySQL 8.0", func() {
+			BeforeEach(func() {
+				server = Server{Flavor: FlavorMySQL, Version: "8.0.32", Major: 8, Minor: 0}
+				repo = NewViolatorRepo("fake_records_db_name", Broker{DBName: brokerDBName}, []string{"fake_admin_user"}, server, strategy, fakeDB, fakeDB, logger)
+			})
+
+			It("compares broker columns using a utf8mb4 collation", func() {
+				mock.ExpectQuery(`CONVERT\(instances.db_name USING utf8mb4\) COLLATE utf8mb4_general_ci`).
+					WithArgs().
+					WillReturnRows(sqlmock.NewRows(tableSchemaColumns))
+
+				_, err := repo.All()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("finds the members of roles holding write privileges, with their role", func() {
+				mock.ExpectQuery("LEFT JOIN \\(SELECT FROM_USER AS role_user(.|\\s)*WHERE roles.role_user IS NULL(.|\\s)*UNION(.|\\s)*JOIN \\(SELECT FROM_USER AS role_user(.|\\s)*FROM mysql.role_edges").
+					WithArgs("fake_admin_user").
+					WillReturnRows(sqlmock.NewRows([]string{"db", "user", "host", "grant_schema", "role_user", "role_host"}).
+						AddRow("fake-database-1", "cf_fake-member", "%", "fake-database-1", "fake-role", "%"))
+
+				violators, err := repo.All()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(violators).To(HaveLen(1))
+				Expect(violators[0].User()).To(Equal("cf_fake-member"))
+
+				mock.ExpectExec("REVOKE 'fake-role'@'%' FROM 'cf_fake-member'@'%'").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				Expect(violators[0].RevokePrivileges()).To(Succeed())
+			})
+
+			Context("when partial revokes are enabled", func() {
+				BeforeEach(func() {
+					server.PartialRevokes = true
+					repo = NewViolatorRepo("fake_records_db_name", Broker{DBName: brokerDBName}, []string{"fake_admin_user"}, server, strategy, fakeDB, fakeDB, logger)
+				})
+
+				It("finds the bound accounts holding global write privileges not yet revoked on the database", func() {
+					mock.ExpectQuery("UNION(.|\\s)*JOIN mysql.user AS global_accounts(.|\\s)*global_accounts.Insert_priv = 'Y'(.|\\s)*NOT JSON_CONTAINS\\(COALESCE\\(JSON_EXTRACT\\(global_accounts.User_attributes, '\\$.Restrictions\\[\\*\\].Database'\\)").
+						WithArgs("fake_admin_user").
+						WillReturnRows(sqlmock.NewRows(tableSchemaColumns))
+
+					_, err := repo.All()
+					Expect(err).ToNot(HaveOccurred())
+				})
+			})
+		})
+
+		It("finds grants of database names escaping wildcards", func() {
+			mock.ExpectQuery("REPLACE\\(REPLACE\\(table_schema, CONCAT\\(CHAR\\(92\\), CHAR\\(95\\)\\), CHAR\\(95\\)\\), CONCAT\\(CHAR\\(92\\), CHAR\\(37\\)\\), CHAR\\(37\\)\\) AS name, table_schema AS grant_schema").
+				WillReturnRows(sqlmock.NewRows([]string{"db", "user", "host", "grant_schema", "role_user", "role_host"}).
+					AddRow("cf_db", "cf_user", "%", "cf\\_db", "", ""))
+
+			violators, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(violators).To(HaveLen(1))
+
+			mock.ExpectExec(regexp.QuoteMeta("REVOKE INSERT, UPDATE, CREATE ON `cf\\_db`.* FROM 'cf_user'@'%'")).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("FLUSH PRIVILEGES").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			Expect(violators[0].RevokePrivileges()).To(Succeed())
+		})
+
+		Context("when the broker has another schema", func() {
+			BeforeEach(func() {
+				broker := Broker{DBName: brokerDBName, Schema: config.BrokerSchema{
+					InstancesTable: "instances",
+					DBNameColumn:   "database_name",
+					PlansTable:     "plans",
+					PlanKeyColumn:  "id",
+					QuotaColumn:    "storage_bytes",
+					QuotaUnit:      config.QuotaUnitBytes,
+				}}
+				repo = NewViolatorRepo("fake_records_db_name", broker, []string{"fake_admin_user"}, server, strategy, fakeDB, fakeDB, logger)
+			})
+
+			It("reads the quota of the plan of each instance, converted to MB", func() {
+				mock.ExpectQuery(regexp.QuoteMeta("(SELECT si.`database_name` AS db_name, plans.`storage_bytes` / 1024 / 1024 AS max_storage_mb " +
+					"FROM `fake_broker_db_name`.`instances` AS si " +
+					"LEFT JOIN `fake_broker_db_name`.`plans` AS plans ON plans.`id` = si.`plan_guid`) AS instances")).
+					WithArgs().
+					WillReturnRows(sqlmock.NewRows(tableSchemaColumns))
+
+				_, err := repo.All()
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the strategy is account-lock", func() {
+			BeforeEach(func() {
+				var err error
+				strategy, err = NewStrategy("account-lock", server, logger)
+				Expect(err).ToNot(HaveOccurred())
+				repo = NewViolatorRepo("fake_records_db_name", Broker{DBName: brokerDBName}, []string{"fake_admin_user"}, server, strategy, fakeDB, fakeDB, logger)
+			})
+
+			It("checks whether the account is locked", func() {
+				mock.ExpectQuery("JOIN\\s+mysql.user AS accounts(.|\\s)*NOT \\(accounts.account_locked = 'Y'\\)").
+					WithArgs().
+					WillReturnRows(sqlmock.NewRows(tableSchemaColumns).
+						AddRow("fake-database-1", "cf_fake-user-1", "%"))
+
+				databases, err := repo.All()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(databases).To(ConsistOf(
+					New("fake-database-1", "cf_fake-user-1", "%", server, fakeDB, logger),
+				))
+			})
+
+			Context("when the server is MariaDB 10.4 or later", func() {
+				BeforeEach(func() {
+					server = Server{Flavor: FlavorMariaDB, Version: "10.6.12-MariaDB", Major: 10, Minor: 6}
+					repo = NewViolatorRepo("fake_records_db_name", Broker{DBName: brokerDBName}, []string{"fake_admin_user"}, server, strategy, fakeDB, fakeDB, logger)
+				})
+
+				It("reads the account attributes from mysql.global_priv", func() {
+					mock.ExpectQuery("JOIN\\s+mysql.global_priv AS accounts(.|\\s)*NOT \\(JSON_VALUE\\(accounts.Priv, '\\$.account_locked'\\) = 'true'\\)").
+						WithArgs().
+						WillReturnRows(sqlmock.NewRows(tableSchemaColumns))
+
+					_, err := repo.All()
+					Expect(err).ToNot(HaveOccurred())
+				})
+			})
+		})
+
+		Context("when the strategy is notify-only", func() {
+			BeforeEach(func() {
+				var err error
+				strategy, err = NewStrategy("notify-only", server, logger)
+				Expect(err).ToNot(HaveOccurred())
+				repo = NewViolatorRepo("fake_records_db_name", Broker{DBName: brokerDBName}, []string{"fake_admin_user"}, server, strategy, fakeDB, fakeDB, logger)
+			})
+
+			It("leaves out the grantees already reported", func() {
+				mock.ExpectQuery("NOT \\(EXISTS \\(\\s*SELECT 1 FROM `fake_records_db_name`.quota_enforcer_restrictions AS restrictions(.|\\s)*AND restrictions.restriction = 'notify-only'").
+					WithArgs().
+					WillReturnRows(sqlmock.NewRows(tableSchemaColumns))
+
+				_, err := repo.All()
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
 		It("passes ignored users as ordered parameters", func() {
 			mock.ExpectQuery("NOT IN \\(\\?\\)").
 				WithArgs().
 				WillReturnRows(
 					sqlmock.NewRows(tableSchemaColumns).
-						AddRow("fake-database-1", "cf_fake-user-1").
-						AddRow("fake-database-2", "cf_fake-user-2"))
+						AddRow("fake-database-1", "cf_fake-user-1", "%").
+						AddRow("fake-database-2", "cf_fake-user-2", "%"))
 
 			_, err := repo.All()
 			Expect(err).ToNot(HaveOccurred())
@@ -0,0 +1,211 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// The warnings table is owned by the enforcer and lives in the broker
+// database. It holds the highest warning threshold reported per instance.
+const createWarningsTableQuery = `
+CREATE TABLE IF NOT EXISTS %s.quota_enforcer_warnings (
+	db_name           VARCHAR(255) NOT NULL,
+	threshold_percent DOUBLE       NOT NULL,
+	reported_at       DATETIME     NOT NULL,
+	PRIMARY KEY (db_name)
+)`
+
+const recordWarningQuery = `
+INSERT INTO %s.quota_enforcer_warnings (db_name, threshold_percent, reported_at)
+VALUES (?, ?, UTC_TIMESTAMP())
+ON DUPLICATE KEY UPDATE threshold_percent = VALUES(threshold_percent), reported_at = VALUES(reported_at)`
+
+const clearWarningQuery = `DELETE FROM %s.quota_enforcer_warnings WHERE db_name = ?`
+
+// Usage is measured where tables are, the thresholds reported are read from
+// the warnings table, which the enforcer writes on the primary.
+const warningUsageQueryPattern = `
+SELECT instances.db_name,
+	ROUND(SUM(COALESCE(tables.data_length + tables.index_length,0) / 1024 / 1024), 1) AS used_mb,
+	MAX(instances.max_storage_mb) AS quota_mb
+FROM        %[2]s AS instances
+LEFT JOIN   information_schema.tables AS tables ON tables.table_schema = %[1]s
+WHERE       instances.max_storage_mb > 0
+GROUP  BY   instances.db_name
+`
+
+const reportedWarningsQuery = `SELECT db_name, threshold_percent FROM %s.quota_enforcer_warnings`
+
+// Thresholds of instances no longer measured, e.g. deleted ones, are left
+// behind, and would be inherited by an instance created with the same name.
+const pruneWarningsQueryPattern = `
+DELETE warnings FROM %[1]s.quota_enforcer_warnings AS warnings
+LEFT JOIN %[2]s AS instances ON warnings.db_name = %[3]s AND instances.max_storage_mb > 0
+WHERE instances.db_name IS NULL`
+
+// WarningState is the usage of an instance and the highest warning threshold
+// reported for it so far, or zero if none was.
+type WarningState struct {
+	DBName          string
+	UsedMB          float64
+	QuotaMB         float64
+	ReportedPercent float64
+}
+
+func (w WarningState) PercentOfQuota() float64 {
+	if w.QuotaMB <= 0 {
+		return 0
+	}
+	return w.UsedMB / w.QuotaMB * 100
+}
+
+type WarningRepo interface {
+	Setup() error
+	All() ([]WarningState, error)
+	Record(dbName string, thresholdPercent float64) error
+	Prune() error
+}
+
+type warningRepo struct {
+	brokerDBName  string
+	query         string
+	pruneQuery    string
+	measurementDB *sql.DB
+	db            *sql.DB
+	logger        lager.Logger
+}
+
+// NewWarningRepo returns a repo measuring usage on measurementDB, and keeping
+// the thresholds reported in db.
+func NewWarningRepo(broker Broker, server Server, measurementDB, db *sql.DB, logger lager.Logger) WarningRepo {
+	query := fmt.Sprintf(
+		warningUsageQueryPattern,
+		server.collate("instances.db_name"),
+		broker.instancesTable("db_name", "max_storage_mb"),
+	)
+
+	pruneQuery := fmt.Sprintf(
+		pruneWarningsQueryPattern,
+		broker.quotedDBName(),
+		broker.instancesTable("db_name", "max_storage_mb"),
+		server.collate("instances.db_name"),
+	)
+
+	return &warningRepo{
+		brokerDBName:  broker.quotedDBName(),
+		query:         query,
+		pruneQuery:    pruneQuery,
+		measurementDB: measurementDB,
+		db:            db,
+		logger:        logger,
+	}
+}
+
+// Setup creates the warnings table if it does not exist yet.
+func (r warningRepo) Setup() error {
+	_, err := r.db.Exec(fmt.Sprintf(createWarningsTableQuery, r.brokerDBName))
+	if err != nil {
+		return fmt.Errorf("Creating warnings table: %s", err.Error())
+	}
+	return nil
+}
+
+func (r warningRepo) All() ([]WarningState, error) {
+	r.logger.Debug("Executing 'warning'.All")
+
+	states := []WarningState{}
+
+	reported, err := r.reported()
+	if err != nil {
+		return states, err
+	}
+
+	rows, err := r.measurementDB.Query(r.query)
+	if err != nil {
+		return states, fmt.Errorf("Error executing 'warning'.All: %s", err.Error())
+	}
+
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	for rows.Next() {
+		var state WarningState
+		err := rows.Scan(&state.DBName, &state.UsedMB, &state.QuotaMB)
+		if err != nil {
+			return states, fmt.Errorf("Scanning result row of 'warning'.All: %s", err.Error())
+		}
+		state.ReportedPercent = reported[state.DBName]
+		states = append(states, state)
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return states, fmt.Errorf("Reading result row of 'warning'.All: %s", err.Error())
+	}
+
+	return states, nil
+}
+
+// reported returns the highest threshold reported per instance.
+func (r warningRepo) reported() (map[string]float64, error) {
+	reported := map[string]float64{}
+
+	rows, err := r.db.Query(fmt.Sprintf(reportedWarningsQuery, r.brokerDBName))
+	if err != nil {
+		return reported, fmt.Errorf("Reading reported warning thresholds: %s", err.Error())
+	}
+
+	//TODO: untested Close, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/15
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			dbName  string
+			percent float64
+		)
+		if err := rows.Scan(&dbName, &percent); err != nil {
+			return reported, fmt.Errorf("Scanning reported warning threshold: %s", err.Error())
+		}
+		reported[dbName] = percent
+	}
+	//TODO: untested error case, due to limitation of sqlmock: https://github.com/DATA-DOG/go-sqlmock/issues/13
+	if err := rows.Err(); err != nil {
+		return reported, fmt.Errorf("Reading reported warning thresholds: %s", err.Error())
+	}
+
+	return reported, nil
+}
+
+// Record stores the highest threshold reported for an instance. Zero clears
+// it, so the next crossing is reported again.
+func (r warningRepo) Record(dbName string, thresholdPercent float64) error {
+	var err error
+	if thresholdPercent == 0 {
+		_, err = r.db.Exec(fmt.Sprintf(clearWarningQuery, r.brokerDBName), dbName)
+	} else {
+		_, err = r.db.Exec(fmt.Sprintf(recordWarningQuery, r.brokerDBName), dbName, thresholdPercent)
+	}
+	if err != nil {
+		return fmt.Errorf("Recording warning threshold of db '%s': %s", dbName, err.Error())
+	}
+	return nil
+}
+
+// Prune deletes the thresholds reported for instances which are no longer
+// measured, as they are deleted or have no quota.
+func (r warningRepo) Prune() error {
+	result, err := r.db.Exec(r.pruneQuery)
+	if err != nil {
+		return fmt.Errorf("Pruning warning thresholds of instances no longer measured: %s", err.Error())
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Pruning warning thresholds of instances no longer measured: Getting rows affected: %s", err.Error())
+	}
+	if rowsAffected > 0 {
+		r.logger.Info(fmt.Sprintf("Pruned %d warning thresholds of instances no longer measured", rowsAffected))
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package database_test
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"database/sql"
+
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+)
+
+var _ = Describe("WarningRepo", func() {
+
+	const brokerDBName = "fake_broker_db_name"
+
+	var (
+		logger *lagertest.TestLogger
+		repo   WarningRepo
+		fakeDB *sql.DB
+		mock   sqlmock.Sqlmock
+	)
+
+	BeforeEach(func() {
+		var err error
+		fakeDB, mock, err = sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		logger = lagertest.NewTestLogger("WarningRepo test")
+		server := Server{Flavor: FlavorMySQL, Version: "5.7.40", Major: 5, Minor: 7}
+		repo = NewWarningRepo(Broker{DBName: brokerDBName}, server, fakeDB, fakeDB, logger)
+	})
+
+	AfterEach(func() {
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	Describe("Setup", func() {
+		It("creates the warnings table in the broker database", func() {
+			mock.ExpectExec("CREATE TABLE IF NOT EXISTS `fake_broker_db_name`\\.quota_enforcer_warnings").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+
+			Expect(repo.Setup()).To(Succeed())
+		})
+
+		Context("when creating the table fails", func() {
+			BeforeEach(func() {
+				mock.ExpectExec("CREATE TABLE").
+					WillReturnError(errors.New("fake-create-error"))
+			})
+
+			It("returns an error", func() {
+				err := repo.Setup()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-create-error"))
+			})
+		})
+	})
+
+	Describe("All", func() {
+		It("returns the usage and reported threshold of every instance with a quota", func() {
+			mock.ExpectQuery("SELECT db_name, threshold_percent FROM `fake_broker_db_name`\\.quota_enforcer_warnings").
+				WillReturnRows(sqlmock.NewRows([]string{"db_name", "threshold_percent"}).
+					AddRow("fake-db-1", 80).
+					AddRow("fake-db-deleted", 90))
+			mock.ExpectQuery("information_schema\\.tables(.|\\n)*instances\\.max_storage_mb > 0").
+				WillReturnRows(sqlmock.NewRows([]string{"db_name", "used_mb", "quota_mb"}).
+					AddRow("fake-db-1", 85, 100).
+					AddRow("fake-db-2", 1, 10))
+
+			states, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(states).To(Equal([]WarningState{
+				{DBName: "fake-db-1", UsedMB: 85, QuotaMB: 100, ReportedPercent: 80},
+				{DBName: "fake-db-2", UsedMB: 1, QuotaMB: 10},
+			}))
+			Expect(states[0].PercentOfQuota()).To(BeNumerically("~", 85, 0.001))
+		})
+
+		Context("when measuring on a separate connection", func() {
+			var (
+				measurementDB   *sql.DB
+				measurementMock sqlmock.Sqlmock
+			)
+
+			BeforeEach(func() {
+				var err error
+				measurementDB, measurementMock, err = sqlmock.New()
+				Expect(err).ToNot(HaveOccurred())
+				server := Server{Flavor: FlavorMySQL, Version: "5.7.40", Major: 5, Minor: 7}
+				repo = NewWarningRepo(Broker{DBName: brokerDBName}, server, measurementDB, fakeDB, logger)
+			})
+
+			AfterEach(func() {
+				Expect(measurementMock.ExpectationsWereMet()).To(Succeed())
+			})
+
+			It("measures on the measurement connection and reads the reported thresholds from the other", func() {
+				mock.ExpectQuery("quota_enforcer_warnings").
+					WillReturnRows(sqlmock.NewRows([]string{"db_name", "threshold_percent"}))
+				measurementMock.ExpectQuery("information_schema\\.tables").
+					WillReturnRows(sqlmock.NewRows([]string{"db_name", "used_mb", "quota_mb"}).
+						AddRow("fake-db-1", 85, 100))
+
+				states, err := repo.All()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(states).To(HaveLen(1))
+			})
+		})
+
+		Context("when reading the reported thresholds fails", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery("quota_enforcer_warnings").
+					WillReturnError(errors.New("fake-reported-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := repo.All()
+				Expect(err).To(MatchError("Reading reported warning thresholds: fake-reported-error"))
+			})
+		})
+
+		Context("when the db query fails", func() {
+			BeforeEach(func() {
+				mock.ExpectQuery("quota_enforcer_warnings").
+					WillReturnRows(sqlmock.NewRows([]string{"db_name", "threshold_percent"}))
+				mock.ExpectQuery(".*").
+					WillReturnError(errors.New("fake-query-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := repo.All()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-query-error"))
+			})
+		})
+	})
+
+	Describe("Record", func() {
+		It("stores the reported threshold", func() {
+			mock.ExpectExec("INSERT INTO `fake_broker_db_name`\\.quota_enforcer_warnings(.|\\n)*ON DUPLICATE KEY UPDATE").
+				WithArgs("fake-db-name", 80.0).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			Expect(repo.Record("fake-db-name", 80)).To(Succeed())
+		})
+
+		It("clears the record for a zero threshold", func() {
+			mock.ExpectExec("DELETE FROM `fake_broker_db_name`\\.quota_enforcer_warnings WHERE db_name = \\?").
+				WithArgs("fake-db-name").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			Expect(repo.Record("fake-db-name", 0)).To(Succeed())
+		})
+
+		Context("when the statement fails", func() {
+			BeforeEach(func() {
+				mock.ExpectExec(".*").
+					WillReturnError(errors.New("fake-exec-error"))
+			})
+
+			It("returns an error", func() {
+				err := repo.Record("fake-db-name", 90)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-exec-error"))
+			})
+		})
+	})
+
+	Describe("Prune", func() {
+		It("deletes the thresholds of instances no longer measured", func() {
+			mock.ExpectExec("DELETE warnings FROM `fake_broker_db_name`\\.quota_enforcer_warnings AS warnings\\s+" +
+				"LEFT JOIN `fake_broker_db_name`\\.service_instances AS instances ON warnings\\.db_name = instances\\.db_name COLLATE utf8_general_ci AND instances\\.max_storage_mb > 0\\s+" +
+				"WHERE instances\\.db_name IS NULL").
+				WillReturnResult(sqlmock.NewResult(0, 2))
+
+			Expect(repo.Prune()).To(Succeed())
+			Expect(logger.LogMessages()).To(ContainElement(ContainSubstring("Pruned 2 warning thresholds of instances no longer measured")))
+		})
+
+		Context("when the statement fails", func() {
+			BeforeEach(func() {
+				mock.ExpectExec("DELETE warnings").
+					WillReturnError(errors.New("fake-exec-error"))
+			})
+
+			It("returns an error", func() {
+				err := repo.Prune()
+				Expect(err).To(MatchError("Pruning warning thresholds of instances no longer measured: fake-exec-error"))
+			})
+		})
+	})
+})
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/grouper"
+	"github.com/tedsuo/ifrit/http_server"
+
+	"code.cloudfoundry.org/cflager"
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/clock"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/enforcer"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/metrics"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/notifier"
+	"github.com/pivotal-cf-experimental/service-config"
+)
+
+const targetUpMetric = "quota_enforcer_target_up"
+
+// target is an enforcer started for one of the targets of the config, along
+// with the function closing it. A target which failed to start has no
+// enforcer. Either is started again by start.
+type target struct {
+	name          string
+	enforcer      enforcer.Enforcer
+	closeEnforcer func()
+	start         func() (enforcer.Enforcer, func(), error)
+	pause         time.Duration
+	logger        lager.Logger
+}
+
+func enforceCommand(args []string) int {
+	serviceConfig := service_config.New()
+
+	flags := flag.NewFlagSet("enforce", flag.ContinueOnError)
+	runOnce := flags.Bool("runOnce", false, "Run only once instead of continuously")
+	pidFile := flags.String("pidFile", "", "Location of pid file")
+	if code := parseFlags(flags, serviceConfig, args); code != exitOK {
+		return code
+	}
+	logger, _ := cflager.New("Quota Enforcer")
+
+	config, code := readConfig(serviceConfig, logger)
+	if code != exitOK {
+		return code
+	}
+
+	// With several targets, each logs in its own session and labels its
+	// metrics with its name. A target which fails to start does not stop
+	// enforcement of the others, and is retried in the background.
+	multiple := len(config.Targets) > 0
+	m := metrics.New()
+
+	var targets []target
+	startCode := exitOK
+	for i, targetConfig := range config.TargetConfigs() {
+		t := target{
+			name:   "enforcer",
+			pause:  time.Duration(targetConfig.PauseInSeconds) * time.Second,
+			logger: logger,
+		}
+		targetName := ""
+		targetMetrics := m
+		if multiple {
+			targetName = config.Targets[i].Name
+			t.name = "enforcer-" + targetName
+			t.logger = logger.Session(targetName)
+			targetMetrics = metrics.WithLabels(m, metrics.Labels{"target": targetName})
+		}
+
+		e, closeTarget, code := newTargetEnforcer(targetName, targetConfig, targetMetrics, t.logger)
+		if multiple {
+			up := 0.0
+			if code == exitOK {
+				up = 1
+			}
+			targetMetrics.SetGauge(targetUpMetric, nil, up)
+		}
+		if code != exitOK {
+			closeTarget()
+			if !multiple {
+				return code
+			}
+			startCode = code
+		} else {
+			t.enforcer = e
+			t.closeEnforcer = closeTarget
+		}
+		t.start = startTarget(targetName, targetConfig, targetMetrics, t.logger)
+		targets = append(targets, t)
+	}
+
+	if *runOnce {
+		logger.Info("Running once")
+
+		code := startCode
+		for _, t := range targets {
+			if t.enforcer == nil {
+				t.logger.Info("Skipping target which failed to start")
+				continue
+			}
+			defer t.closeEnforcer()
+
+			err := t.enforcer.EnforceOnce()
+			if err != nil {
+				code = fail(t.logger, "Quota Enforcing Failed", err, exitFailed)
+			}
+		}
+		return code
+	}
+
+	var members grouper.Members
+	for _, t := range targets {
+		members = append(members, grouper.Member{
+			Name:   t.name,
+			Runner: enforcer.NewRestartingRunner(t.enforcer, t.closeEnforcer, t.start, clock.DefaultClock(), t.pause, t.logger),
+		})
+	}
+	if config.MetricsPort != 0 {
+		metricsAddress := fmt.Sprintf(":%d", config.MetricsPort)
+		members = append(members, grouper.Member{Name: "metrics", Runner: http_server.New(metricsAddress, m)})
+		logger.Info("Serving metrics", lager.Data{"Address": metricsAddress})
+	}
+
+	process := ifrit.Invoke(grouper.NewParallel(os.Interrupt, members))
+	logger.Info("Running continuously")
+
+	// Write pid file once we are running continuously
+	if *pidFile != "" {
+		pid := os.Getpid()
+		err := writePidFile(pid, *pidFile)
+		if err != nil {
+			logger.Error("Cannot write pid to file", err, lager.Data{"pidFile": *pidFile, "pid": pid})
+			fmt.Fprintf(os.Stderr, "Cannot write pid to file '%s': %s\n", *pidFile, err.Error())
+			return exitFailed
+		}
+		logger.Info("Wrote pid to file", lager.Data{"pidFile": *pidFile, "pid": pid})
+	}
+
+	err := <-process.Wait()
+	if err != nil {
+		return fail(logger, "Quota Enforcing Failed", err, exitFailed)
+	}
+	return exitOK
+}
+
+// startTarget returns a function starting the enforcer of a target again, for
+// NewRestartingRunner.
+func startTarget(name string, cfg config.Config, m metrics.Metrics, logger lager.Logger) func() (enforcer.Enforcer, func(), error) {
+	return func() (enforcer.Enforcer, func(), error) {
+		e, closeTarget, code := newTargetEnforcer(name, cfg, m, logger)
+		if name != "" {
+			up := 0.0
+			if code == exitOK {
+				up = 1
+			}
+			m.SetGauge(targetUpMetric, nil, up)
+		}
+		if code != exitOK {
+			return nil, closeTarget, fmt.Errorf("Starting the target failed with exit code %d", code)
+		}
+		return e, closeTarget, nil
+	}
+}
+
+// newTargetEnforcer connects to a target and builds its enforcer. The name of
+// the target is empty if the config lists no Targets. The returned function
+// closes the connections of the target and must always be called.
+func newTargetEnforcer(name string, config config.Config, m metrics.Metrics, logger lager.Logger) (enforcer.Enforcer, func(), int) {
+	var closers []io.Closer
+	closeTarget := func() {
+		for _, closer := range closers {
+			closer.Close()
+		}
+	}
+
+	brokerDBName := config.DBName
+
+	db, server, code := connect(config, logger)
+	if db != nil {
+		closers = append(closers, db)
+	}
+	if code != exitOK {
+		return nil, closeTarget, code
+	}
+
+	measurementDB, measurementServer, code := connectMeasurement(config, db, server, logger)
+	if measurementDB != nil && measurementDB != db {
+		closers = append(closers, measurementDB)
+	}
+	if code != exitOK {
+		return nil, closeTarget, code
+	}
+
+	ignoredUsers := ignoredUsers(config)
+
+	strategy, err := database.NewStrategy(config.EnforcementStrategy, server, logger)
+	if err != nil {
+		return nil, closeTarget, fail(logger, "Invalid enforcement strategy", err, exitInvalidConfig)
+	}
+	logger.Info("Using enforcement strategy", lager.Data{"Strategy": strategy.Name()})
+
+	// Detected each time the target starts. A broker migrated to another
+	// schema since has the schema guard start the target again.
+	brokers, _, code := detectBrokers(config, db, logger)
+	if code != exitOK {
+		return nil, closeTarget, code
+	}
+
+	if _, code := preflight(config, brokers, server, db, logger); code != exitOK {
+		return nil, closeTarget, code
+	}
+
+	nodes, code := connectClusterNodes(config, logger)
+	for _, node := range nodes {
+		closers = append(closers, node.DB)
+	}
+	if code != exitOK {
+		return nil, closeTarget, code
+	}
+
+	var instanceRepos []database.InstanceRepo
+	for _, broker := range brokers {
+		instanceRepos = append(instanceRepos, database.NewInstanceRepo(broker, server, measurementDB, logger))
+	}
+	instanceRepo := instanceRepos[0]
+	if len(instanceRepos) > 1 {
+		instanceRepo = database.NewMultiBrokerInstanceRepo(instanceRepos)
+	}
+	n := notifier.New(config.Webhooks, name, instanceRepo, clock.DefaultClock(), logger)
+	closers = append(closers, n)
+
+	// Connections a cluster node failed to kill stay open until the client
+	// reconnects, which operators are told about.
+	onKillFailure := func(db database.Database, err error) {
+		n.Notify(notifier.Event{Type: notifier.EventError, DBName: db.Name(), Error: err.Error()})
+	}
+
+	// The repos of several brokers are combined, leaving out databases
+	// claimed by more than one broker.
+	claimRepo := database.NewClaimRepo(brokers, server, measurementDB, logger)
+	combineRepos := func(newRepo func(broker database.Broker) database.Repo) database.Repo {
+		var repos []database.Repo
+		for _, broker := range brokers {
+			repos = append(repos, newRepo(broker))
+		}
+
+		repo := repos[0]
+		if len(repos) > 1 {
+			repo = database.NewMultiBrokerRepo(repos, claimRepo, logger)
+		}
+		if len(nodes) > 0 {
+			repo = database.NewClusterRepo(repo, nodes, onKillFailure, logger)
+		}
+		return repo
+	}
+
+	violatorRepo := combineRepos(func(broker database.Broker) database.Repo {
+		return database.NewViolatorRepo(brokerDBName, broker, ignoredUsers, server, strategy, measurementDB, db, logger)
+	})
+	var reversals []enforcer.Reversal
+	for _, reversalStrategy := range reversalStrategies(strategy, server, logger) {
+		reformerRepo := combineRepos(func(broker database.Broker) database.Repo {
+			return database.NewReformerRepo(brokerDBName, broker, ignoredUsers, server, reversalStrategy, measurementDB, db, logger)
+		})
+		reversals = append(reversals, enforcer.Reversal{Strategy: reversalStrategy, Reformers: reformerRepo})
+	}
+
+	restrictionRecordRepo := database.NewRestrictionRecordRepo(brokerDBName, server, db, logger)
+	err = restrictionRecordRepo.Setup()
+	if err != nil {
+		return nil, closeTarget, fail(logger, "Failed to set up restrictions table", err, exitFailed)
+	}
+
+	var reconcilers []enforcer.Reconciler
+	if len(brokers) > 1 {
+		reconcilers = append(reconcilers, enforcer.NewClaimChecker(claimRepo, m, logger))
+	}
+	if config.ObjectQuotas {
+		objectViolatorRepo := combineRepos(func(broker database.Broker) database.Repo {
+			return database.NewObjectViolatorRepo(broker, ignoredUsers, server, measurementDB, db, logger)
+		})
+		objectReformerRepo := combineRepos(func(broker database.Broker) database.Repo {
+			return database.NewObjectReformerRepo(brokerDBName, broker, server, measurementDB, db, logger)
+		})
+		reconcilers = append(reconcilers, enforcer.NewObjectQuotaEnforcer(objectViolatorRepo, objectReformerRepo, restrictionRecordRepo, logger))
+	}
+	if !config.ConnectionLimits.IsEmpty() {
+		var accountRepos []database.AccountRepo
+		for _, broker := range brokers {
+			accountRepos = append(accountRepos, database.NewAccountRepo(broker, ignoredUsers, server, measurementDB, db, logger))
+		}
+		accountRepo := accountRepos[0]
+		if len(accountRepos) > 1 {
+			accountRepo = database.NewMultiBrokerAccountRepo(accountRepos, claimRepo, logger)
+		}
+		reconcilers = append(reconcilers, enforcer.NewConnectionLimiter(accountRepo, config.ConnectionLimits, strategy, logger))
+	}
+	if len(config.ThrottleTiers) > 0 {
+		var usageRepos []database.UsageRepo
+		for _, broker := range brokers {
+			usageRepos = append(usageRepos, database.NewUsageRepo(broker, ignoredUsers, server, measurementDB, db, logger))
+		}
+		usageRepo := usageRepos[0]
+		if len(usageRepos) > 1 {
+			usageRepo = database.NewMultiBrokerUsageRepo(usageRepos, claimRepo, logger)
+		}
+		reconcilers = append(reconcilers, enforcer.NewThrottler(usageRepo, config.ThrottleTiers, logger))
+	}
+
+	// Warnings, published usage and usage history are kept in the broker
+	// database of each instance, where its broker can read them.
+	for _, broker := range brokers {
+		if len(config.WarningThresholds) > 0 {
+			warningRepo := database.NewWarningRepo(broker, server, measurementDB, db, logger)
+			err = warningRepo.Setup()
+			if err != nil {
+				return nil, closeTarget, fail(logger, "Failed to set up warnings table", err, exitFailed)
+			}
+			reconcilers = append(reconcilers, enforcer.NewWarner(warningRepo, config.WarningThresholds, n, m, logger))
+		}
+		if config.PublishUsage {
+			usagePublisher := database.NewUsagePublisher(brokerDBName, broker, config.LowestWarningThreshold(), server, measurementDB, db, logger)
+			err = usagePublisher.Setup()
+			if err != nil {
+				return nil, closeTarget, fail(logger, "Failed to set up usage table", err, exitFailed)
+			}
+			reconcilers = append(reconcilers, enforcer.NewPublisher(usagePublisher, logger))
+		}
+
+		if config.UsageHistory.Enabled {
+			usageHistoryRepo := database.NewUsageHistoryRepo(broker, server, measurementDB, db, logger)
+			err = usageHistoryRepo.Setup()
+			if err != nil {
+				return nil, closeTarget, fail(logger, "Failed to set up usage history table", err, exitFailed)
+			}
+			reconcilers = append(reconcilers, enforcer.NewHistoryRecorder(
+				usageHistoryRepo,
+				time.Duration(config.UsageHistory.SampleIntervalInSeconds)*time.Second,
+				config.UsageHistory.Retention(),
+				clock.DefaultClock(),
+				m,
+				logger,
+			))
+		}
+	}
+
+	if config.Reclaim.Enabled {
+		reclaimRepo := database.NewReclaimRepo(brokerDBName, db, logger)
+		err = reclaimRepo.Setup()
+		if err != nil {
+			return nil, closeTarget, fail(logger, "Failed to set up reclaims table", err, exitFailed)
+		}
+		reconcilers = append(reconcilers, enforcer.NewReclaimer(config.Reclaim, instanceRepo, restrictionRecordRepo, reclaimRepo, clock.DefaultClock(), m, logger))
+	}
+
+	var circuitBreakerRepo database.CircuitBreakerRepo
+	if !config.CircuitBreaker.IsEmpty() {
+		circuitBreakerRepo = database.NewCircuitBreakerRepo(brokerDBName, db, logger)
+		err = circuitBreakerRepo.Setup()
+		if err != nil {
+			return nil, closeTarget, fail(logger, "Failed to set up circuit breaker table", err, exitFailed)
+		}
+	}
+	circuitBreaker := enforcer.NewCircuitBreaker(config.CircuitBreaker, circuitBreakerRepo, instanceRepo, n, m, logger)
+
+	tableSizeRepo := database.NewTableSizeRepo(measurementDB, logger)
+
+	e := enforcer.NewEnforcer(violatorRepo, strategy, reversals, restrictionRecordRepo, circuitBreaker, tableSizeRepo, config.LargestTableCount(), reconcilers, n, logger)
+	if !config.Measurement.IsEmpty() {
+		replicaLag := database.NewReplicaLag(measurementServer, measurementDB, logger)
+		e = enforcer.NewLagGuard(e, replicaLag, config.MaxLag(), m, logger)
+	}
+	e = enforcer.NewSchemaGuard(e, database.NewBrokerDetector(db, logger), brokers, clock.DefaultClock(), logger)
+	return e, closeTarget, exitOK
+}
+
+// reversalStrategies returns the strategy along with every other strategy the
+// server supports, as restrictions are reversed with the strategy that applied
+// them even after the configured strategy changed. notify-only restricts nothing,
+// but its records are cleared the same way.
+func reversalStrategies(strategy database.Strategy, server database.Server, logger lager.Logger) []database.Strategy {
+	strategies := []database.Strategy{strategy}
+	for _, name := range []string{config.StrategyRevokeWrites, config.StrategyAccountLock, config.StrategyMaxConnections, config.StrategyNotifyOnly} {
+		if name == strategy.Name() {
+			continue
+		}
+		// A strategy the server does not support was never applied.
+		if former, err := database.NewStrategy(name, server, logger); err == nil {
+			strategies = append(strategies, former)
+		}
+	}
+	return strategies
+}
+
+func writePidFile(pid int, pidFile string) error {
+	return ioutil.WriteFile(pidFile, []byte(strconv.Itoa(pid)), 0644)
+}
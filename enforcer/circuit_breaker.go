@@ -0,0 +1,168 @@
+package enforcer
+
+import (
+	"errors"
+	"fmt"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/metrics"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/notifier"
+)
+
+const circuitBreakerTrippedMetric = "quota_enforcer_circuit_breaker_tripped"
+
+// CircuitBreaker guards against restricting many instances at once, e.g.
+// after a bad broker migration changed their quotas.
+type CircuitBreaker interface {
+	// Allow returns whether the violators may be restricted in this cycle.
+	Allow(violators []database.Database) (bool, error)
+}
+
+type circuitBreaker struct {
+	limits       config.CircuitBreaker
+	breakerRepo  database.CircuitBreakerRepo
+	instanceRepo database.InstanceRepo
+	notifier     notifier.Notifier
+	metrics      metrics.Metrics
+	logger       lager.Logger
+}
+
+// NewCircuitBreaker returns a circuit breaker which trips when more instances
+// would be newly restricted than the limits allow. Once tripped, it allows no
+// restrictions until the trip is acknowledged. The instances the acknowledged
+// trip covered are then admitted once, and only further instances count
+// towards the limits. Without limits, it allows all.
+func NewCircuitBreaker(limits config.CircuitBreaker, breakerRepo database.CircuitBreakerRepo, instanceRepo database.InstanceRepo, notifier notifier.Notifier, metrics metrics.Metrics, logger lager.Logger) CircuitBreaker {
+	if limits.IsEmpty() {
+		return noCircuitBreaker{}
+	}
+	return &circuitBreaker{
+		limits:       limits,
+		breakerRepo:  breakerRepo,
+		instanceRepo: instanceRepo,
+		notifier:     notifier,
+		metrics:      metrics,
+		logger:       logger,
+	}
+}
+
+func (b circuitBreaker) Allow(violators []database.Database) (bool, error) {
+	tripped, reason, err := b.breakerRepo.Tripped()
+	if err != nil {
+		return false, err
+	}
+	if tripped {
+		b.metrics.SetGauge(circuitBreakerTrippedMetric, nil, 1)
+		if len(violators) > 0 {
+			b.logger.Error(
+				"Circuit breaker is tripped, not restricting violators until it is acknowledged",
+				errors.New(reason),
+				lager.Data{"Violators": len(violators)},
+			)
+		}
+		return false, nil
+	}
+
+	acknowledged, err := b.breakerRepo.Acknowledged()
+	if err != nil {
+		return false, err
+	}
+
+	dbNames := instanceNames(violators)
+	reason, err = b.exceededLimit(len(unacknowledged(dbNames, acknowledged)))
+	if err != nil {
+		return false, err
+	}
+	if reason == "" {
+		if acknowledged != nil {
+			err = b.breakerRepo.Reset()
+			if err != nil {
+				return false, err
+			}
+			b.logger.Info("Admitting the instances of the acknowledged circuit breaker trip", lager.Data{"Instances": len(acknowledged)})
+		}
+		b.metrics.SetGauge(circuitBreakerTrippedMetric, nil, 0)
+		return true, nil
+	}
+
+	err = b.breakerRepo.Trip(reason, dbNames)
+	if err != nil {
+		return false, err
+	}
+	b.metrics.SetGauge(circuitBreakerTrippedMetric, nil, 1)
+	b.logger.Error("Circuit breaker tripped, not restricting violators until it is acknowledged", errors.New(reason))
+	b.notifier.Notify(notifier.Event{Type: notifier.EventCircuitBreakerTripped, Error: reason})
+
+	return false, nil
+}
+
+// exceededLimit returns why restricting count instances exceeds a limit, or
+// nothing if it does not.
+func (b circuitBreaker) exceededLimit(count int) (string, error) {
+	if count == 0 {
+		return "", nil
+	}
+
+	if b.limits.MaxRestrictions > 0 && count > b.limits.MaxRestrictions {
+		return fmt.Sprintf(
+			"%d instances would be newly restricted, more than the limit of %d",
+			count, b.limits.MaxRestrictions,
+		), nil
+	}
+
+	if b.limits.MaxRestrictionsPercent > 0 {
+		total, err := b.instanceRepo.Count()
+		if err != nil {
+			return "", err
+		}
+		if total == 0 {
+			return "", nil
+		}
+		percent := float64(count) / float64(total) * 100
+		if percent > b.limits.MaxRestrictionsPercent {
+			return fmt.Sprintf(
+				"%d of %d instances (%.1f%%) would be newly restricted, more than the limit of %g%%",
+				count, total, percent, b.limits.MaxRestrictionsPercent,
+			), nil
+		}
+	}
+
+	return "", nil
+}
+
+// instanceNames returns the distinct databases of the violators, which has
+// one entry per grantee, in order.
+func instanceNames(violators []database.Database) []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, db := range violators {
+		if !seen[db.Name()] {
+			seen[db.Name()] = true
+			names = append(names, db.Name())
+		}
+	}
+	return names
+}
+
+// unacknowledged returns the databases not covered by an acknowledged trip.
+func unacknowledged(dbNames, acknowledged []string) []string {
+	covered := map[string]bool{}
+	for _, name := range acknowledged {
+		covered[name] = true
+	}
+	names := []string{}
+	for _, name := range dbNames {
+		if !covered[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+type noCircuitBreaker struct{}
+
+func (noCircuitBreaker) Allow(violators []database.Database) (bool, error) {
+	return true, nil
+}
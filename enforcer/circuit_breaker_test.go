@@ -0,0 +1,233 @@
+package enforcer_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database/databasefakes"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/enforcer"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/metrics/metricsfakes"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/notifier"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/notifier/notifierfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CircuitBreaker", func() {
+	var (
+		breaker          CircuitBreaker
+		limits           config.CircuitBreaker
+		fakeBreakerRepo  *databasefakes.FakeCircuitBreakerRepo
+		fakeInstanceRepo *databasefakes.FakeInstanceRepo
+		fakeNotifier     *notifierfakes.FakeNotifier
+		fakeMetrics      *metricsfakes.FakeMetrics
+		logger           *lagertest.TestLogger
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("CircuitBreaker test")
+		limits = config.CircuitBreaker{MaxRestrictions: 2}
+		fakeBreakerRepo = &databasefakes.FakeCircuitBreakerRepo{}
+		fakeInstanceRepo = &databasefakes.FakeInstanceRepo{}
+		fakeInstanceRepo.CountReturns(100, nil)
+		fakeNotifier = &notifierfakes.FakeNotifier{}
+		fakeMetrics = &metricsfakes.FakeMetrics{}
+	})
+
+	JustBeforeEach(func() {
+		breaker = NewCircuitBreaker(limits, fakeBreakerRepo, fakeInstanceRepo, fakeNotifier, fakeMetrics, logger)
+	})
+
+	violators := func(names ...string) []database.Database {
+		var dbs []database.Database
+		for _, name := range names {
+			name := name
+			dbs = append(dbs, &databasefakes.FakeDatabase{NameStub: func() string { return name }})
+		}
+		return dbs
+	}
+
+	Context("when no limit is configured", func() {
+		BeforeEach(func() {
+			limits = config.CircuitBreaker{}
+		})
+
+		It("allows any number of restrictions without reading the table", func() {
+			allowed, err := breaker.Allow(violators("db-1", "db-2", "db-3"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(allowed).To(BeTrue())
+			Expect(fakeBreakerRepo.TrippedCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the violators are within the limit", func() {
+		It("allows restricting them", func() {
+			allowed, err := breaker.Allow(violators("db-1", "db-2"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(allowed).To(BeTrue())
+			Expect(fakeBreakerRepo.TripCallCount()).To(Equal(0))
+
+			name, _, value := fakeMetrics.SetGaugeArgsForCall(0)
+			Expect(name).To(Equal("quota_enforcer_circuit_breaker_tripped"))
+			Expect(value).To(BeZero())
+		})
+
+		It("counts instances rather than grantees", func() {
+			allowed, err := breaker.Allow(violators("db-1", "db-1", "db-2", "db-2"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(allowed).To(BeTrue())
+		})
+	})
+
+	Context("when more instances would be restricted than allowed", func() {
+		It("trips, alerts and disallows restricting them", func() {
+			allowed, err := breaker.Allow(violators("db-1", "db-2", "db-3"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(allowed).To(BeFalse())
+
+			reason := "3 instances would be newly restricted, more than the limit of 2"
+			Expect(fakeBreakerRepo.TripCallCount()).To(Equal(1))
+			tripReason, dbNames := fakeBreakerRepo.TripArgsForCall(0)
+			Expect(tripReason).To(Equal(reason))
+			Expect(dbNames).To(Equal([]string{"db-1", "db-2", "db-3"}))
+
+			Expect(fakeNotifier.NotifyCallCount()).To(Equal(1))
+			Expect(fakeNotifier.NotifyArgsForCall(0)).To(Equal(notifier.Event{
+				Type:  notifier.EventCircuitBreakerTripped,
+				Error: reason,
+			}))
+
+			_, _, value := fakeMetrics.SetGaugeArgsForCall(0)
+			Expect(value).To(Equal(1.0))
+			Expect(logger.LogMessages()).To(ContainElement(ContainSubstring("Circuit breaker tripped")))
+		})
+
+		Context("when tripping fails", func() {
+			BeforeEach(func() {
+				fakeBreakerRepo.TripReturns(errors.New("fake-trip-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := breaker.Allow(violators("db-1", "db-2", "db-3"))
+				Expect(err).To(MatchError("fake-trip-error"))
+			})
+		})
+	})
+
+	Context("when a percentage of instances is configured", func() {
+		BeforeEach(func() {
+			limits = config.CircuitBreaker{MaxRestrictionsPercent: 2.5}
+			fakeInstanceRepo.CountReturns(80, nil)
+		})
+
+		It("allows restricting up to that percentage of all instances", func() {
+			allowed, err := breaker.Allow(violators("db-1", "db-2"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(allowed).To(BeTrue())
+		})
+
+		It("trips beyond that percentage", func() {
+			allowed, err := breaker.Allow(violators("db-1", "db-2", "db-3"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(allowed).To(BeFalse())
+			reason, _ := fakeBreakerRepo.TripArgsForCall(0)
+			Expect(reason).To(Equal("3 of 80 instances (3.8%) would be newly restricted, more than the limit of 2.5%"))
+		})
+
+		Context("when counting instances fails", func() {
+			BeforeEach(func() {
+				fakeInstanceRepo.CountReturns(0, errors.New("fake-count-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := breaker.Allow(violators("db-1"))
+				Expect(err).To(MatchError("fake-count-error"))
+			})
+		})
+	})
+
+	Context("when the circuit breaker is tripped", func() {
+		BeforeEach(func() {
+			fakeBreakerRepo.TrippedReturns(true, "fake-reason", nil)
+		})
+
+		It("disallows restrictions until it is acknowledged, without alerting again", func() {
+			allowed, err := breaker.Allow(violators("db-1"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(allowed).To(BeFalse())
+
+			Expect(fakeBreakerRepo.TripCallCount()).To(Equal(0))
+			Expect(fakeNotifier.NotifyCallCount()).To(Equal(0))
+			Expect(logger.LogMessages()).To(ContainElement(ContainSubstring("Circuit breaker is tripped")))
+		})
+	})
+
+	Context("when a trip was acknowledged", func() {
+		BeforeEach(func() {
+			fakeBreakerRepo.AcknowledgedReturns([]string{"db-1", "db-2", "db-3"}, nil)
+		})
+
+		It("admits the instances it covered once", func() {
+			allowed, err := breaker.Allow(violators("db-1", "db-2", "db-3"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(allowed).To(BeTrue())
+
+			Expect(fakeBreakerRepo.TripCallCount()).To(Equal(0))
+			Expect(fakeBreakerRepo.ResetCallCount()).To(Equal(1))
+			Expect(logger.LogMessages()).To(ContainElement(ContainSubstring("Admitting the instances of the acknowledged circuit breaker trip")))
+		})
+
+		It("counts only the instances beyond it", func() {
+			allowed, err := breaker.Allow(violators("db-1", "db-2", "db-3", "db-4", "db-5"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(allowed).To(BeTrue())
+		})
+
+		It("trips again when more new instances would be restricted than allowed", func() {
+			allowed, err := breaker.Allow(violators("db-1", "db-4", "db-5", "db-6"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(allowed).To(BeFalse())
+
+			Expect(fakeBreakerRepo.ResetCallCount()).To(Equal(0))
+			reason, dbNames := fakeBreakerRepo.TripArgsForCall(0)
+			Expect(reason).To(Equal("3 instances would be newly restricted, more than the limit of 2"))
+			Expect(dbNames).To(Equal([]string{"db-1", "db-4", "db-5", "db-6"}))
+		})
+
+		Context("when resetting fails", func() {
+			BeforeEach(func() {
+				fakeBreakerRepo.ResetReturns(errors.New("fake-reset-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := breaker.Allow(violators("db-1"))
+				Expect(err).To(MatchError("fake-reset-error"))
+			})
+		})
+	})
+
+	Context("when reading the acknowledged trip fails", func() {
+		BeforeEach(func() {
+			fakeBreakerRepo.AcknowledgedReturns(nil, errors.New("fake-read-error"))
+		})
+
+		It("returns an error", func() {
+			_, err := breaker.Allow(violators("db-1"))
+			Expect(err).To(MatchError("fake-read-error"))
+		})
+	})
+
+	Context("when reading the circuit breaker fails", func() {
+		BeforeEach(func() {
+			fakeBreakerRepo.TrippedReturns(false, "", errors.New("fake-read-error"))
+		})
+
+		It("returns an error", func() {
+			_, err := breaker.Allow(violators("db-1"))
+			Expect(err).To(MatchError("fake-read-error"))
+		})
+	})
+})
@@ -0,0 +1,54 @@
+package enforcer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/metrics"
+)
+
+const duplicateClaimsMetric = "quota_enforcer_duplicate_claims"
+
+// claimChecker reports databases claimed by instances of more than one
+// broker. The multi-broker repos leave them alone, so an operator has to
+// decide which broker owns them.
+type claimChecker struct {
+	claims  database.ClaimRepo
+	metrics metrics.Metrics
+	logger  lager.Logger
+}
+
+func NewClaimChecker(claims database.ClaimRepo, metrics metrics.Metrics, logger lager.Logger) Reconciler {
+	return &claimChecker{
+		claims:  claims,
+		metrics: metrics,
+		logger:  logger,
+	}
+}
+
+func (c claimChecker) ReconcileOnce() error {
+	duplicates, err := c.claims.Duplicates()
+	if err != nil {
+		return err
+	}
+	c.metrics.SetGauge(duplicateClaimsMetric, nil, float64(len(duplicates)))
+
+	dbNames := make([]string, 0, len(duplicates))
+	for dbName := range duplicates {
+		dbNames = append(dbNames, dbName)
+	}
+	sort.Strings(dbNames)
+
+	for _, dbName := range dbNames {
+		brokers := duplicates[dbName]
+		c.logger.Error(
+			fmt.Sprintf("Database '%s' is claimed by brokers %s, not enforcing it", dbName, strings.Join(brokers, ", ")),
+			fmt.Errorf("claimed by %d brokers", len(brokers)),
+			lager.Data{"Brokers": brokers},
+		)
+	}
+	return nil
+}
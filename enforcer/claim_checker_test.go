@@ -0,0 +1,69 @@
+package enforcer_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database/databasefakes"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/enforcer"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/metrics/metricsfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ClaimChecker", func() {
+	var (
+		checker     Reconciler
+		fakeClaims  *databasefakes.FakeClaimRepo
+		fakeMetrics *metricsfakes.FakeMetrics
+		logger      *lagertest.TestLogger
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("ClaimChecker test")
+		fakeClaims = &databasefakes.FakeClaimRepo{}
+		fakeMetrics = &metricsfakes.FakeMetrics{}
+
+		checker = NewClaimChecker(fakeClaims, fakeMetrics, logger)
+	})
+
+	Context("when databases are claimed by several brokers", func() {
+		BeforeEach(func() {
+			fakeClaims.DuplicatesReturns(map[string][]string{
+				"fake-db-shared": {"fake_broker_a", "fake_broker_b"},
+			}, nil)
+		})
+
+		It("logs an error for each and exposes their number", func() {
+			Expect(checker.ReconcileOnce()).To(Succeed())
+
+			Expect(logger.LogMessages()).To(ContainElement(ContainSubstring(
+				"Database 'fake-db-shared' is claimed by brokers fake_broker_a, fake_broker_b, not enforcing it")))
+
+			name, _, value := fakeMetrics.SetGaugeArgsForCall(0)
+			Expect(name).To(Equal("quota_enforcer_duplicate_claims"))
+			Expect(value).To(Equal(1.0))
+		})
+	})
+
+	Context("when no database is claimed twice", func() {
+		It("logs nothing", func() {
+			Expect(checker.ReconcileOnce()).To(Succeed())
+			Expect(logger.LogMessages()).To(BeEmpty())
+
+			_, _, value := fakeMetrics.SetGaugeArgsForCall(0)
+			Expect(value).To(Equal(0.0))
+		})
+	})
+
+	Context("when finding duplicate claims fails", func() {
+		BeforeEach(func() {
+			fakeClaims.DuplicatesReturns(nil, errors.New("fake-claims-error"))
+		})
+
+		It("returns an error", func() {
+			Expect(checker.ReconcileOnce()).To(MatchError("fake-claims-error"))
+		})
+	})
+})
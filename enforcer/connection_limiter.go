@@ -0,0 +1,74 @@
+package enforcer
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+)
+
+// connectionLimiter sets MAX_USER_CONNECTIONS of every binding user to the
+// limit configured for its instance.
+type connectionLimiter struct {
+	accountRepo database.AccountRepo
+	limits      config.ConnectionLimits
+	strategy    database.Strategy
+	logger      lager.Logger
+}
+
+// NewConnectionLimiter returns a reconciler applying limits. Users restricted
+// by the max-connections strategy are left to the strategy.
+func NewConnectionLimiter(accountRepo database.AccountRepo, limits config.ConnectionLimits, strategy database.Strategy, logger lager.Logger) Reconciler {
+	return &connectionLimiter{
+		accountRepo: accountRepo,
+		limits:      limits,
+		strategy:    strategy,
+		logger:      logger,
+	}
+}
+
+func (l connectionLimiter) ReconcileOnce() error {
+	l.logger.Info("Reconciling connection limits")
+
+	accounts, err := l.accountRepo.All()
+	if err != nil {
+		return fmt.Errorf("Finding accounts: %s", err.Error())
+	}
+
+	for _, account := range accounts {
+		limit := l.limitFor(account)
+		if limit == 0 || account.MaxUserConnections == limit || l.isRestricted(account) {
+			continue
+		}
+
+		l.logger.Info(fmt.Sprintf(
+			"Limiting connections of db '%s' from %d to %d",
+			account.Database.Name(),
+			account.MaxUserConnections,
+			limit,
+		))
+
+		err = account.Database.SetMaxUserConnections(limit)
+		if err != nil {
+			return fmt.Errorf("Limiting connections of '%s': %s", account.Database.Name(), err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (l connectionLimiter) limitFor(account database.Account) int {
+	if limit, ok := l.limits.Instances[account.InstanceGUID]; ok {
+		return limit
+	}
+	if limit, ok := l.limits.Plans[account.PlanGUID]; ok {
+		return limit
+	}
+	return l.limits.Default
+}
+
+func (l connectionLimiter) isRestricted(account database.Account) bool {
+	return l.strategy.Name() == config.StrategyMaxConnections &&
+		account.MaxUserConnections == database.RestrictedMaxUserConnections
+}
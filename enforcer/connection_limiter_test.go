@@ -0,0 +1,141 @@
+package enforcer_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database/databasefakes"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/enforcer"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ConnectionLimiter", func() {
+	var (
+		limiter         Reconciler
+		fakeAccountRepo *databasefakes.FakeAccountRepo
+		fakeStrategy    *databasefakes.FakeStrategy
+		fakeDB          *databasefakes.FakeDatabase
+		limits          config.ConnectionLimits
+		logger          *lagertest.TestLogger
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("ConnectionLimiter test")
+		fakeAccountRepo = &databasefakes.FakeAccountRepo{}
+		fakeStrategy = &databasefakes.FakeStrategy{}
+		fakeStrategy.NameReturns(config.StrategyRevokeWrites)
+		fakeDB = &databasefakes.FakeDatabase{}
+		fakeDB.NameReturns("fake-db-name")
+
+		limits = config.ConnectionLimits{
+			Default:   10,
+			Plans:     map[string]int{"fake-plan-guid": 20},
+			Instances: map[string]int{"fake-instance-guid": 30},
+		}
+	})
+
+	JustBeforeEach(func() {
+		limiter = NewConnectionLimiter(fakeAccountRepo, limits, fakeStrategy, logger)
+	})
+
+	account := func(instanceGUID, planGUID string, maxUserConnections int) []database.Account {
+		return []database.Account{{
+			Database:           fakeDB,
+			InstanceGUID:       instanceGUID,
+			PlanGUID:           planGUID,
+			MaxUserConnections: maxUserConnections,
+		}}
+	}
+
+	It("applies the limit of the instance over that of its plan", func() {
+		fakeAccountRepo.AllReturns(account("fake-instance-guid", "fake-plan-guid", 0), nil)
+
+		Expect(limiter.ReconcileOnce()).To(Succeed())
+		Expect(fakeDB.SetMaxUserConnectionsCallCount()).To(Equal(1))
+		Expect(fakeDB.SetMaxUserConnectionsArgsForCall(0)).To(Equal(30))
+	})
+
+	It("applies the limit of the plan over the default", func() {
+		fakeAccountRepo.AllReturns(account("other-instance-guid", "fake-plan-guid", 5), nil)
+
+		Expect(limiter.ReconcileOnce()).To(Succeed())
+		Expect(fakeDB.SetMaxUserConnectionsArgsForCall(0)).To(Equal(20))
+	})
+
+	It("applies the default otherwise", func() {
+		fakeAccountRepo.AllReturns(account("other-instance-guid", "other-plan-guid", 0), nil)
+
+		Expect(limiter.ReconcileOnce()).To(Succeed())
+		Expect(fakeDB.SetMaxUserConnectionsArgsForCall(0)).To(Equal(10))
+	})
+
+	It("does not change a limit that is already applied", func() {
+		fakeAccountRepo.AllReturns(account("other-instance-guid", "other-plan-guid", 10), nil)
+
+		Expect(limiter.ReconcileOnce()).To(Succeed())
+		Expect(fakeDB.SetMaxUserConnectionsCallCount()).To(Equal(0))
+	})
+
+	Context("when no limit is configured for the instance", func() {
+		BeforeEach(func() {
+			limits.Default = 0
+		})
+
+		It("leaves its connection limit alone", func() {
+			fakeAccountRepo.AllReturns(account("other-instance-guid", "other-plan-guid", 5), nil)
+
+			Expect(limiter.ReconcileOnce()).To(Succeed())
+			Expect(fakeDB.SetMaxUserConnectionsCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the strategy is max-connections", func() {
+		BeforeEach(func() {
+			fakeStrategy.NameReturns(config.StrategyMaxConnections)
+		})
+
+		It("leaves restricted users to the strategy", func() {
+			fakeAccountRepo.AllReturns(account("fake-instance-guid", "fake-plan-guid", database.RestrictedMaxUserConnections), nil)
+
+			Expect(limiter.ReconcileOnce()).To(Succeed())
+			Expect(fakeDB.SetMaxUserConnectionsCallCount()).To(Equal(0))
+		})
+
+		It("restores the limit once the strategy is reversed", func() {
+			fakeAccountRepo.AllReturns(account("fake-instance-guid", "fake-plan-guid", 0), nil)
+
+			Expect(limiter.ReconcileOnce()).To(Succeed())
+			Expect(fakeDB.SetMaxUserConnectionsArgsForCall(0)).To(Equal(30))
+		})
+	})
+
+	Context("when finding accounts fails", func() {
+		BeforeEach(func() {
+			fakeAccountRepo.AllReturns(nil, errors.New("fake-account-error"))
+		})
+
+		It("returns an error", func() {
+			err := limiter.ReconcileOnce()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-account-error"))
+		})
+	})
+
+	Context("when setting the limit fails", func() {
+		BeforeEach(func() {
+			fakeAccountRepo.AllReturns(account("fake-instance-guid", "fake-plan-guid", 0), nil)
+			fakeDB.SetMaxUserConnectionsReturns(errors.New("fake-alter-error"))
+		})
+
+		It("returns an error", func() {
+			err := limiter.ReconcileOnce()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-alter-error"))
+			Expect(err.Error()).To(ContainSubstring("fake-db-name"))
+		})
+	})
+})
@@ -0,0 +1,41 @@
+package enforcer
+
+import "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/metrics"
+
+// dbGauges sets gauges labelled with the database they describe, and deletes
+// those of databases no longer measured, e.g. deleted instances, which would
+// otherwise be served with their last value forever.
+type dbGauges struct {
+	metrics  metrics.Metrics
+	names    []string
+	previous map[string]bool
+	current  map[string]bool
+}
+
+func newDBGauges(m metrics.Metrics, names ...string) *dbGauges {
+	return &dbGauges{
+		metrics:  m,
+		names:    names,
+		previous: map[string]bool{},
+		current:  map[string]bool{},
+	}
+}
+
+func (g *dbGauges) set(name, dbName string, value float64) {
+	g.metrics.SetGauge(name, metrics.Labels{"db_name": dbName}, value)
+	g.current[dbName] = true
+}
+
+// sweep deletes the gauges of the databases set before the previous sweep but
+// not since.
+func (g *dbGauges) sweep() {
+	for dbName := range g.previous {
+		if g.current[dbName] {
+			continue
+		}
+		for _, name := range g.names {
+			g.metrics.DeleteGauge(name, metrics.Labels{"db_name": dbName})
+		}
+	}
+	g.previous, g.current = g.current, map[string]bool{}
+}
@@ -2,29 +2,66 @@ package enforcer
 
 import (
 	"fmt"
+	"strings"
 
 	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
 	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/notifier"
 )
 
 type Enforcer interface {
 	EnforceOnce() error
 }
 
+// Reversal is a strategy along with the repo of the grantees to reverse it on.
+type Reversal struct {
+	Strategy  database.Strategy
+	Reformers database.Repo
+}
+
 type enforcer struct {
-	violatorRepo, reformerRepo database.Repo
-	logger                     lager.Logger
+	violatorRepo          database.Repo
+	strategy              database.Strategy
+	reversals             []Reversal
+	restrictionRecordRepo database.RestrictionRecordRepo
+	circuitBreaker        CircuitBreaker
+	tableSizeRepo         database.TableSizeRepo
+	largestTables         int
+	reconcilers           []Reconciler
+	notifier              notifier.Notifier
+	logger                lager.Logger
 }
 
-func NewEnforcer(violatorRepo, reformerRepo database.Repo, logger lager.Logger) Enforcer {
+// NewEnforcer returns an enforcer applying strategy to violators, unless the
+// circuit breaker disallows it. Each restriction is recorded before it is
+// applied, notify-only included, so that an instance is reported once each time
+// it exceeds its quota. The reversals cover the strategy and any strategy configured before,
+// so that a restriction is reversed with the strategy that applied it. The
+// reconcilers run in order before violators are looked for. Every transition is sent to the notifier. The
+// largestTables largest tables of a violator are logged and sent along when it
+// is restricted.
+func NewEnforcer(violatorRepo database.Repo, strategy database.Strategy, reversals []Reversal, restrictionRecordRepo database.RestrictionRecordRepo, circuitBreaker CircuitBreaker, tableSizeRepo database.TableSizeRepo, largestTables int, reconcilers []Reconciler, notifier notifier.Notifier, logger lager.Logger) Enforcer {
 	return &enforcer{
-		violatorRepo: violatorRepo,
-		reformerRepo: reformerRepo,
-		logger:       logger,
+		violatorRepo:          violatorRepo,
+		strategy:              strategy,
+		reversals:             reversals,
+		restrictionRecordRepo: restrictionRecordRepo,
+		circuitBreaker:        circuitBreaker,
+		tableSizeRepo:         tableSizeRepo,
+		largestTables:         largestTables,
+		reconcilers:           reconcilers,
+		notifier:              notifier,
+		logger:                logger,
 	}
 }
 
+// EnforceOnce runs every reconciler even if one fails, so that one failing
+// reconciler holds back neither the others nor the storage quota. Their
+// failures are returned together once the storage quota is enforced.
 func (e enforcer) EnforceOnce() error {
+	reconcileErr := e.reconcile()
+
 	err := e.revokePrivilegesFromViolators()
 	if err != nil {
 		return err
@@ -35,7 +72,31 @@ func (e enforcer) EnforceOnce() error {
 		return err
 	}
 
-	return nil
+	return reconcileErr
+}
+
+func (e enforcer) reconcile() error {
+	var errs []error
+	for i, reconciler := range e.reconcilers {
+		err := reconciler.ReconcileOnce()
+		if err != nil {
+			e.logger.Error("Failed to reconcile", err, lager.Data{"reconciler": i})
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) <= 1 {
+		if len(errs) == 1 {
+			return errs[0]
+		}
+		return nil
+	}
+
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return fmt.Errorf("%d reconcilers failed: %s", len(errs), strings.Join(messages, "; "))
 }
 
 func (e enforcer) revokePrivilegesFromViolators() error {
@@ -46,39 +107,95 @@ func (e enforcer) revokePrivilegesFromViolators() error {
 		return fmt.Errorf("Finding violators: %s", err.Error())
 	}
 
+	allowed, err := e.circuitBreaker.Allow(violators)
+	if err != nil {
+		return fmt.Errorf("Checking circuit breaker: %s", err.Error())
+	}
+	if !allowed {
+		return nil
+	}
+
+	// A violator has one entry per user, so tables are measured once per database.
+	largestTables := map[string][]database.TableSize{}
 	for _, db := range violators {
-		err = db.RevokePrivileges()
+		tables, measured := largestTables[db.Name()]
+		if !measured {
+			tables = e.measureLargestTables(db.Name())
+			largestTables[db.Name()] = tables
+		}
+
+		err = e.restrictionRecordRepo.Record(db, e.strategy.Name())
 		if err != nil {
-			return fmt.Errorf("Revoking privileges: %s", err.Error())
+			e.notifier.Notify(notifier.Event{Type: notifier.EventError, DBName: db.Name(), Error: err.Error()})
+			return err
 		}
 
-		err = db.KillActiveConnections()
+		err = e.strategy.Apply(db)
 		if err != nil {
-			return fmt.Errorf("Resetting active privileges: %s", err.Error())
+			err = fmt.Errorf("Applying '%s' to '%s': %s", e.strategy.Name(), db.Name(), err.Error())
+			e.notifier.Notify(notifier.Event{Type: notifier.EventError, DBName: db.Name(), Error: err.Error()})
+			return err
 		}
+		restricted, _ := eventTypes(e.strategy)
+		e.notifier.Notify(notifier.Event{Type: restricted, DBName: db.Name(), LargestTables: tables})
 	}
 	return nil
 }
 
-func (e enforcer) grantPrivilegesToReformed() error {
-	e.logger.Info("Looking for reformers")
-
-	reformers, err := e.reformerRepo.All()
+// measureLargestTables logs the largest tables of a violator. Failing to
+// measure them is logged, but never stops enforcement.
+func (e enforcer) measureLargestTables(dbName string) []database.TableSize {
+	tables, err := e.tableSizeRepo.Largest(dbName, e.largestTables)
 	if err != nil {
-		return fmt.Errorf("Finding reformers: %s", err.Error())
+		e.logger.Error(fmt.Sprintf("Failed to measure largest tables of db '%s'", dbName), err)
+		return nil
+	}
+
+	descriptions := make([]string, len(tables))
+	for i, table := range tables {
+		descriptions[i] = fmt.Sprintf("%s %.1f MB (%.1f MB free)", table.Name, table.SizeMB, table.DataFreeMB)
 	}
+	e.logger.Info(fmt.Sprintf("Largest tables of db '%s': %s", dbName, strings.Join(descriptions, ", ")))
 
-	for _, db := range reformers {
-		err = db.GrantPrivileges()
+	return tables
+}
+
+func (e enforcer) grantPrivilegesToReformed() error {
+	e.logger.Info("Looking for reformers")
+
+	for _, reversal := range e.reversals {
+		strategy := reversal.Strategy
+		reformers, err := reversal.Reformers.All()
 		if err != nil {
-			return fmt.Errorf("Granting privileges: %s", err.Error())
+			return fmt.Errorf("Finding reformers of '%s': %s", strategy.Name(), err.Error())
 		}
 
-		err = db.KillActiveConnections()
-		if err != nil {
-			return fmt.Errorf("Resetting active privileges: %s", err.Error())
+		for _, db := range reformers {
+			err = strategy.Reverse(db)
+			if err != nil {
+				err = fmt.Errorf("Reversing '%s' on '%s': %s", strategy.Name(), db.Name(), err.Error())
+				e.notifier.Notify(notifier.Event{Type: notifier.EventError, DBName: db.Name(), Error: err.Error()})
+				return err
+			}
+
+			err = e.restrictionRecordRepo.Remove(db, strategy.Name())
+			if err != nil {
+				e.notifier.Notify(notifier.Event{Type: notifier.EventError, DBName: db.Name(), Error: err.Error()})
+				return err
+			}
+			_, reversed := eventTypes(strategy)
+			e.notifier.Notify(notifier.Event{Type: reversed, DBName: db.Name()})
 		}
 	}
 
 	return nil
 }
+
+// eventTypes returns the types of the events sent when strategy is applied and
+// reversed. notify-only restricts nothing, so it reports the quota instead.
+func eventTypes(strategy database.Strategy) (string, string) {
+	if strategy.Name() == config.StrategyNotifyOnly {
+		return notifier.EventOverQuota, notifier.EventUnderQuota
+	}
+	return notifier.EventRevoked, notifier.EventRestored
+}
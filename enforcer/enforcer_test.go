@@ -1,10 +1,15 @@
 package enforcer_test
 
 import (
+	"errors"
+
 	"code.cloudfoundry.org/lager/lagertest"
 	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
 	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database/databasefakes"
 	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/enforcer"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/enforcer/enforcerfakes"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/notifier"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/notifier/notifierfakes"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -15,6 +20,11 @@ var _ = Describe("Enforcer", func() {
 		enforcer         Enforcer
 		fakeViolatorRepo *databasefakes.FakeRepo
 		fakeReformerRepo *databasefakes.FakeRepo
+		fakeStrategy     *databasefakes.FakeStrategy
+		fakeRecords      *databasefakes.FakeRestrictionRecordRepo
+		fakeBreaker      *enforcerfakes.FakeCircuitBreaker
+		fakeTableSizes   *databasefakes.FakeTableSizeRepo
+		fakeNotifier     *notifierfakes.FakeNotifier
 		logger           *lagertest.TestLogger
 	)
 
@@ -22,7 +32,66 @@ var _ = Describe("Enforcer", func() {
 		logger = lagertest.NewTestLogger("Enforcer test")
 		fakeViolatorRepo = &databasefakes.FakeRepo{}
 		fakeReformerRepo = &databasefakes.FakeRepo{}
-		enforcer = NewEnforcer(fakeViolatorRepo, fakeReformerRepo, logger)
+		fakeStrategy = &databasefakes.FakeStrategy{}
+		fakeStrategy.NameReturns("fake-strategy")
+		fakeRecords = &databasefakes.FakeRestrictionRecordRepo{}
+		fakeBreaker = &enforcerfakes.FakeCircuitBreaker{}
+		fakeBreaker.AllowReturns(true, nil)
+		fakeTableSizes = &databasefakes.FakeTableSizeRepo{}
+		fakeNotifier = &notifierfakes.FakeNotifier{}
+		enforcer = NewEnforcer(fakeViolatorRepo, fakeStrategy, []Reversal{{Strategy: fakeStrategy, Reformers: fakeReformerRepo}}, fakeRecords, fakeBreaker, fakeTableSizes, 5, nil, fakeNotifier, logger)
+	})
+
+	Context("when reconcilers are given", func() {
+		var fakeReconcilers []*enforcerfakes.FakeReconciler
+
+		BeforeEach(func() {
+			fakeReconcilers = []*enforcerfakes.FakeReconciler{{}, {}}
+			enforcer = NewEnforcer(fakeViolatorRepo, fakeStrategy, []Reversal{{Strategy: fakeStrategy, Reformers: fakeReformerRepo}}, fakeRecords, fakeBreaker, fakeTableSizes, 5, []Reconciler{fakeReconcilers[0], fakeReconcilers[1]}, fakeNotifier, logger)
+		})
+
+		It("runs them in order before looking for violators", func() {
+			fakeReconcilers[0].ReconcileOnceStub = func() error {
+				Expect(fakeReconcilers[1].ReconcileOnceCallCount()).To(Equal(0))
+				return nil
+			}
+			fakeReconcilers[1].ReconcileOnceStub = func() error {
+				Expect(fakeViolatorRepo.AllCallCount()).To(Equal(0))
+				return nil
+			}
+
+			err := enforcer.EnforceOnce()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeReconcilers[0].ReconcileOnceCallCount()).To(Equal(1))
+			Expect(fakeReconcilers[1].ReconcileOnceCallCount()).To(Equal(1))
+			Expect(fakeViolatorRepo.AllCallCount()).To(Equal(1))
+		})
+
+		Context("when a reconciler fails", func() {
+			BeforeEach(func() {
+				fakeReconcilers[0].ReconcileOnceReturns(errors.New("fake-reconcile-error"))
+			})
+
+			It("runs the others and enforces the storage quota before returning the error", func() {
+				err := enforcer.EnforceOnce()
+				Expect(err).To(MatchError("fake-reconcile-error"))
+				Expect(fakeReconcilers[1].ReconcileOnceCallCount()).To(Equal(1))
+				Expect(fakeViolatorRepo.AllCallCount()).To(Equal(1))
+				Expect(logger.LogMessages()).To(ContainElement(ContainSubstring("Failed to reconcile")))
+			})
+
+			Context("when several reconcilers fail", func() {
+				BeforeEach(func() {
+					fakeReconcilers[1].ReconcileOnceReturns(errors.New("fake-other-reconcile-error"))
+				})
+
+				It("returns their errors combined", func() {
+					err := enforcer.EnforceOnce()
+					Expect(err).To(MatchError("2 reconcilers failed: fake-reconcile-error; fake-other-reconcile-error"))
+				})
+			})
+		})
 	})
 
 	Context("when there are no violators", func() {
@@ -39,21 +108,207 @@ var _ = Describe("Enforcer", func() {
 
 		BeforeEach(func() {
 			fakeViolators = []database.Database{
-				&databasefakes.FakeDatabase{},
-				&databasefakes.FakeDatabase{},
+				&databasefakes.FakeDatabase{NameStub: func() string { return "fake-violator-0" }},
+				&databasefakes.FakeDatabase{NameStub: func() string { return "fake-violator-1" }},
 			}
 			fakeViolatorRepo.AllReturns(fakeViolators, nil)
 		})
 
-		It("revokes privileges on the violators", func() {
+		It("applies the strategy to the violators", func() {
 			err := enforcer.EnforceOnce()
 			Expect(err).NotTo(HaveOccurred())
 
-			for _, db := range fakeViolators {
-				fakeDB := db.(*databasefakes.FakeDatabase)
-				Expect(fakeDB.RevokePrivilegesCallCount()).To(Equal(1))
-				Expect(fakeDB.KillActiveConnectionsCallCount()).To(Equal(1))
+			Expect(fakeStrategy.ApplyCallCount()).To(Equal(2))
+			Expect(fakeStrategy.ApplyArgsForCall(0)).To(BeIdenticalTo(fakeViolators[0]))
+			Expect(fakeStrategy.ApplyArgsForCall(1)).To(BeIdenticalTo(fakeViolators[1]))
+		})
+
+		It("records each restriction before applying it", func() {
+			fakeRecords.RecordStub = func(database.Database, string) error {
+				Expect(fakeStrategy.ApplyCallCount()).To(Equal(fakeRecords.RecordCallCount() - 1))
+				return nil
 			}
+
+			err := enforcer.EnforceOnce()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeRecords.RecordCallCount()).To(Equal(2))
+			db, restriction := fakeRecords.RecordArgsForCall(1)
+			Expect(db).To(BeIdenticalTo(fakeViolators[1]))
+			Expect(restriction).To(Equal("fake-strategy"))
+		})
+
+		Context("when the strategy is notify-only", func() {
+			BeforeEach(func() {
+				fakeStrategy.NameReturns("notify-only")
+			})
+
+			It("records the violators, so that each is reported once", func() {
+				err := enforcer.EnforceOnce()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeStrategy.ApplyCallCount()).To(Equal(2))
+				Expect(fakeRecords.RecordCallCount()).To(Equal(2))
+				_, restriction := fakeRecords.RecordArgsForCall(0)
+				Expect(restriction).To(Equal("notify-only"))
+			})
+
+			It("notifies that the violators are over quota", func() {
+				err := enforcer.EnforceOnce()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeNotifier.NotifyArgsForCall(0)).To(Equal(notifier.Event{
+					Type:   notifier.EventOverQuota,
+					DBName: "fake-violator-0",
+				}))
+			})
+		})
+
+		Context("when recording a restriction fails", func() {
+			BeforeEach(func() {
+				fakeRecords.RecordReturns(errors.New("fake-record-error"))
+			})
+
+			It("returns an error without applying the strategy", func() {
+				err := enforcer.EnforceOnce()
+				Expect(err).To(MatchError("fake-record-error"))
+				Expect(fakeStrategy.ApplyCallCount()).To(Equal(0))
+			})
+		})
+
+		It("asks the circuit breaker before applying the strategy", func() {
+			fakeBreaker.AllowStub = func(violators []database.Database) (bool, error) {
+				Expect(fakeStrategy.ApplyCallCount()).To(Equal(0))
+				return true, nil
+			}
+
+			err := enforcer.EnforceOnce()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeBreaker.AllowCallCount()).To(Equal(1))
+			Expect(fakeBreaker.AllowArgsForCall(0)).To(Equal(fakeViolators))
+		})
+
+		Context("when the circuit breaker disallows restrictions", func() {
+			BeforeEach(func() {
+				fakeBreaker.AllowReturns(false, nil)
+			})
+
+			It("does not apply the strategy, but still reverses it for reformers", func() {
+				err := enforcer.EnforceOnce()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeStrategy.ApplyCallCount()).To(Equal(0))
+				Expect(fakeReformerRepo.AllCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the circuit breaker fails", func() {
+			BeforeEach(func() {
+				fakeBreaker.AllowReturns(false, errors.New("fake-breaker-error"))
+			})
+
+			It("returns an error without applying the strategy", func() {
+				err := enforcer.EnforceOnce()
+				Expect(err).To(MatchError("Checking circuit breaker: fake-breaker-error"))
+				Expect(fakeStrategy.ApplyCallCount()).To(Equal(0))
+			})
+		})
+
+		It("notifies that the violators were revoked", func() {
+			err := enforcer.EnforceOnce()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeNotifier.NotifyCallCount()).To(Equal(2))
+			Expect(fakeNotifier.NotifyArgsForCall(0)).To(Equal(notifier.Event{
+				Type:   notifier.EventRevoked,
+				DBName: "fake-violator-0",
+			}))
+		})
+
+		Context("when the violators have tables", func() {
+			var tables []database.TableSize
+
+			BeforeEach(func() {
+				tables = []database.TableSize{
+					{Name: "fake-big-table", SizeMB: 9.5, DataFreeMB: 2},
+					{Name: "fake-small-table", SizeMB: 0.5},
+				}
+				fakeTableSizes.LargestReturns(tables, nil)
+			})
+
+			It("captures the largest tables of each violator before applying the strategy", func() {
+				fakeStrategy.ApplyStub = func(database.Database) error {
+					Expect(fakeTableSizes.LargestCallCount()).To(Equal(fakeStrategy.ApplyCallCount()))
+					return nil
+				}
+
+				err := enforcer.EnforceOnce()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeTableSizes.LargestCallCount()).To(Equal(2))
+				dbName, limit := fakeTableSizes.LargestArgsForCall(0)
+				Expect(dbName).To(Equal("fake-violator-0"))
+				Expect(limit).To(Equal(5))
+			})
+
+			It("logs them and sends them along with the revoked event", func() {
+				err := enforcer.EnforceOnce()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(logger.LogMessages()).To(ContainElement(ContainSubstring(
+					"Largest tables of db 'fake-violator-0': fake-big-table 9.5 MB (2.0 MB free), fake-small-table 0.5 MB (0.0 MB free)")))
+				Expect(fakeNotifier.NotifyArgsForCall(1).LargestTables).To(Equal(tables))
+			})
+
+			It("measures a violator with several users once", func() {
+				fakeViolatorRepo.AllReturns([]database.Database{fakeViolators[0], fakeViolators[0]}, nil)
+
+				err := enforcer.EnforceOnce()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeTableSizes.LargestCallCount()).To(Equal(1))
+				Expect(fakeNotifier.NotifyArgsForCall(1).LargestTables).To(Equal(tables))
+			})
+		})
+
+		Context("when measuring the largest tables fails", func() {
+			BeforeEach(func() {
+				fakeTableSizes.LargestReturns(nil, errors.New("fake-measure-error"))
+			})
+
+			It("logs the error and still applies the strategy", func() {
+				err := enforcer.EnforceOnce()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeStrategy.ApplyCallCount()).To(Equal(2))
+				Expect(logger.LogMessages()).To(ContainElement(ContainSubstring("Failed to measure largest tables of db 'fake-violator-0'")))
+				Expect(fakeNotifier.NotifyArgsForCall(0).LargestTables).To(BeNil())
+			})
+		})
+
+		Context("when applying the strategy fails", func() {
+			BeforeEach(func() {
+				fakeStrategy.ApplyReturns(errors.New("fake-apply-error"))
+			})
+
+			It("returns an error", func() {
+				err := enforcer.EnforceOnce()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-apply-error"))
+				Expect(err.Error()).To(ContainSubstring("fake-strategy"))
+			})
+
+			It("notifies about the error", func() {
+				err := enforcer.EnforceOnce()
+
+				Expect(fakeNotifier.NotifyCallCount()).To(Equal(1))
+				Expect(fakeNotifier.NotifyArgsForCall(0)).To(Equal(notifier.Event{
+					Type:   notifier.EventError,
+					DBName: "fake-violator-0",
+					Error:  err.Error(),
+				}))
+			})
 		})
 	})
 
@@ -71,21 +326,104 @@ var _ = Describe("Enforcer", func() {
 
 		BeforeEach(func() {
 			fakeReformers = []database.Database{
-				&databasefakes.FakeDatabase{},
-				&databasefakes.FakeDatabase{},
+				&databasefakes.FakeDatabase{NameStub: func() string { return "fake-reformer-0" }},
+				&databasefakes.FakeDatabase{NameStub: func() string { return "fake-reformer-1" }},
 			}
 			fakeReformerRepo.AllReturns(fakeReformers, nil)
 		})
 
-		It("grants privileges on the reformers", func() {
+		It("reverses the strategy on the reformers", func() {
 			err := enforcer.EnforceOnce()
 			Expect(err).NotTo(HaveOccurred())
 
-			for _, db := range fakeReformers {
-				fakeDB := db.(*databasefakes.FakeDatabase)
-				Expect(fakeDB.GrantPrivilegesCallCount()).To(Equal(1))
-				Expect(fakeDB.KillActiveConnectionsCallCount()).To(Equal(1))
-			}
+			Expect(fakeStrategy.ReverseCallCount()).To(Equal(2))
+			Expect(fakeStrategy.ReverseArgsForCall(0)).To(BeIdenticalTo(fakeReformers[0]))
+			Expect(fakeStrategy.ReverseArgsForCall(1)).To(BeIdenticalTo(fakeReformers[1]))
+		})
+
+		It("removes the record of each reversed restriction", func() {
+			err := enforcer.EnforceOnce()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeRecords.RemoveCallCount()).To(Equal(2))
+			db, restriction := fakeRecords.RemoveArgsForCall(0)
+			Expect(db).To(BeIdenticalTo(fakeReformers[0]))
+			Expect(restriction).To(Equal("fake-strategy"))
+		})
+
+		Context("when a strategy configured before has reformers", func() {
+			var (
+				fakeFormerStrategy     *databasefakes.FakeStrategy
+				fakeFormerReformerRepo *databasefakes.FakeRepo
+			)
+
+			BeforeEach(func() {
+				fakeFormerStrategy = &databasefakes.FakeStrategy{}
+				fakeFormerStrategy.NameReturns("fake-former-strategy")
+				fakeFormerReformerRepo = &databasefakes.FakeRepo{}
+				fakeFormerReformerRepo.AllReturns(fakeReformers[:1], nil)
+				fakeReformerRepo.AllReturns(nil, nil)
+				enforcer = NewEnforcer(fakeViolatorRepo, fakeStrategy, []Reversal{
+					{Strategy: fakeStrategy, Reformers: fakeReformerRepo},
+					{Strategy: fakeFormerStrategy, Reformers: fakeFormerReformerRepo},
+				}, fakeRecords, fakeBreaker, fakeTableSizes, 5, nil, fakeNotifier, logger)
+			})
+
+			It("reverses their restrictions with that strategy", func() {
+				err := enforcer.EnforceOnce()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeStrategy.ReverseCallCount()).To(Equal(0))
+				Expect(fakeFormerStrategy.ReverseCallCount()).To(Equal(1))
+				Expect(fakeFormerStrategy.ReverseArgsForCall(0)).To(BeIdenticalTo(fakeReformers[0]))
+				_, restriction := fakeRecords.RemoveArgsForCall(0)
+				Expect(restriction).To(Equal("fake-former-strategy"))
+			})
+		})
+
+		It("notifies that the reformers were restored", func() {
+			err := enforcer.EnforceOnce()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeNotifier.NotifyCallCount()).To(Equal(2))
+			Expect(fakeNotifier.NotifyArgsForCall(1)).To(Equal(notifier.Event{
+				Type:   notifier.EventRestored,
+				DBName: "fake-reformer-1",
+			}))
+		})
+
+		Context("when the strategy is notify-only", func() {
+			BeforeEach(func() {
+				fakeStrategy.NameReturns("notify-only")
+			})
+
+			It("removes the records and notifies that the reformers are under quota", func() {
+				err := enforcer.EnforceOnce()
+				Expect(err).NotTo(HaveOccurred())
+
+				_, restriction := fakeRecords.RemoveArgsForCall(0)
+				Expect(restriction).To(Equal("notify-only"))
+				Expect(fakeNotifier.NotifyArgsForCall(1)).To(Equal(notifier.Event{
+					Type:   notifier.EventUnderQuota,
+					DBName: "fake-reformer-1",
+				}))
+			})
+		})
+
+		Context("when reversing the strategy fails", func() {
+			BeforeEach(func() {
+				fakeStrategy.ReverseReturns(errors.New("fake-reverse-error"))
+			})
+
+			It("returns an error and notifies about it", func() {
+				err := enforcer.EnforceOnce()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-reverse-error"))
+
+				event := fakeNotifier.NotifyArgsForCall(0)
+				Expect(event.Type).To(Equal(notifier.EventError))
+				Expect(event.Error).To(Equal(err.Error()))
+			})
 		})
 	})
 })
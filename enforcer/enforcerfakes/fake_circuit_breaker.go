@@ -0,0 +1,84 @@
+// This file was generated by counterfeiter
+package enforcerfakes
+
+import (
+	"sync"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/enforcer"
+)
+
+type FakeCircuitBreaker struct {
+	AllowStub        func([]database.Database) (bool, error)
+	allowMutex       sync.RWMutex
+	allowArgsForCall []struct {
+		arg1 []database.Database
+	}
+	allowReturns struct {
+		result1 bool
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeCircuitBreaker) Allow(arg1 []database.Database) (bool, error) {
+	var arg1Copy []database.Database
+	if arg1 != nil {
+		arg1Copy = make([]database.Database, len(arg1))
+		copy(arg1Copy, arg1)
+	}
+	fake.allowMutex.Lock()
+	fake.allowArgsForCall = append(fake.allowArgsForCall, struct {
+		arg1 []database.Database
+	}{arg1Copy})
+	fake.recordInvocation("Allow", []interface{}{arg1Copy})
+	fake.allowMutex.Unlock()
+	if fake.AllowStub != nil {
+		return fake.AllowStub(arg1)
+	} else {
+		return fake.allowReturns.result1, fake.allowReturns.result2
+	}
+}
+
+func (fake *FakeCircuitBreaker) AllowCallCount() int {
+	fake.allowMutex.RLock()
+	defer fake.allowMutex.RUnlock()
+	return len(fake.allowArgsForCall)
+}
+
+func (fake *FakeCircuitBreaker) AllowArgsForCall(i int) []database.Database {
+	fake.allowMutex.RLock()
+	defer fake.allowMutex.RUnlock()
+	return fake.allowArgsForCall[i].arg1
+}
+
+func (fake *FakeCircuitBreaker) AllowReturns(result1 bool, result2 error) {
+	fake.AllowStub = nil
+	fake.allowReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCircuitBreaker) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.allowMutex.RLock()
+	defer fake.allowMutex.RUnlock()
+	return fake.invocations
+}
+
+func (fake *FakeCircuitBreaker) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ enforcer.CircuitBreaker = new(FakeCircuitBreaker)
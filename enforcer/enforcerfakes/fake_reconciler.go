@@ -0,0 +1,66 @@
+// This file was generated by counterfeiter
+package enforcerfakes
+
+import (
+	"sync"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/enforcer"
+)
+
+type FakeReconciler struct {
+	ReconcileOnceStub        func() error
+	reconcileOnceMutex       sync.RWMutex
+	reconcileOnceArgsForCall []struct{}
+	reconcileOnceReturns     struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeReconciler) ReconcileOnce() error {
+	fake.reconcileOnceMutex.Lock()
+	fake.reconcileOnceArgsForCall = append(fake.reconcileOnceArgsForCall, struct{}{})
+	fake.recordInvocation("ReconcileOnce", []interface{}{})
+	fake.reconcileOnceMutex.Unlock()
+	if fake.ReconcileOnceStub != nil {
+		return fake.ReconcileOnceStub()
+	} else {
+		return fake.reconcileOnceReturns.result1
+	}
+}
+
+func (fake *FakeReconciler) ReconcileOnceCallCount() int {
+	fake.reconcileOnceMutex.RLock()
+	defer fake.reconcileOnceMutex.RUnlock()
+	return len(fake.reconcileOnceArgsForCall)
+}
+
+func (fake *FakeReconciler) ReconcileOnceReturns(result1 error) {
+	fake.ReconcileOnceStub = nil
+	fake.reconcileOnceReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeReconciler) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.reconcileOnceMutex.RLock()
+	defer fake.reconcileOnceMutex.RUnlock()
+	return fake.invocations
+}
+
+func (fake *FakeReconciler) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ enforcer.Reconciler = new(FakeReconciler)
@@ -0,0 +1,101 @@
+package enforcer
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/clock"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/metrics"
+)
+
+const (
+	growthMetric         = "quota_enforcer_growth_mb_per_day"
+	timeUntilQuotaMetric = "quota_enforcer_seconds_until_quota"
+)
+
+// ForecastWindow is how far back usage samples are used to fit growth.
+const ForecastWindow = 7 * 24 * time.Hour
+
+// forecastHorizon is how soon an instance must be projected to reach its
+// quota to be logged.
+const forecastHorizon = 7 * 24 * time.Hour
+
+// historyRecorder samples the usage of every instance into the usage history
+// and forecasts when instances will reach their quota.
+type historyRecorder struct {
+	usageHistoryRepo database.UsageHistoryRepo
+	interval         time.Duration
+	retention        time.Duration
+	clock            clock.Clock
+	metrics          metrics.Metrics
+	gauges           *dbGauges
+	logger           lager.Logger
+
+	lastSample time.Time
+}
+
+// NewHistoryRecorder returns a recorder sampling at most every interval, or
+// every cycle if interval is zero, and keeping samples for retention.
+func NewHistoryRecorder(usageHistoryRepo database.UsageHistoryRepo, interval, retention time.Duration, clock clock.Clock, metrics metrics.Metrics, logger lager.Logger) Reconciler {
+	return &historyRecorder{
+		usageHistoryRepo: usageHistoryRepo,
+		interval:         interval,
+		retention:        retention,
+		clock:            clock,
+		metrics:          metrics,
+		gauges:           newDBGauges(metrics, growthMetric, timeUntilQuotaMetric),
+		logger:           logger,
+	}
+}
+
+func (r *historyRecorder) ReconcileOnce() error {
+	now := r.clock.Now()
+	if !r.lastSample.IsZero() && now.Sub(r.lastSample) < r.interval {
+		return nil
+	}
+
+	r.logger.Info("Recording usage history")
+
+	err := r.usageHistoryRepo.Record()
+	if err != nil {
+		return err
+	}
+
+	err = r.usageHistoryRepo.Prune(r.retention)
+	if err != nil {
+		return err
+	}
+	r.lastSample = now
+
+	growths, err := r.usageHistoryRepo.Growth(ForecastWindow)
+	if err != nil {
+		return err
+	}
+
+	// Instances deleted, or left without recent samples, are no longer
+	// forecast.
+	defer r.gauges.sweep()
+
+	for _, growth := range growths {
+		r.gauges.set(growthMetric, growth.DBName, growth.MBPerDay)
+
+		timeUntilQuota, ok := growth.TimeUntilQuota()
+		if !ok {
+			r.gauges.set(timeUntilQuotaMetric, growth.DBName, math.Inf(1))
+			continue
+		}
+		r.gauges.set(timeUntilQuotaMetric, growth.DBName, timeUntilQuota.Seconds())
+
+		if timeUntilQuota > 0 && timeUntilQuota <= forecastHorizon {
+			r.logger.Info(fmt.Sprintf(
+				"Database '%s' is projected to exceed its quota in %.1f days, growing %.1f MB per day",
+				growth.DBName, timeUntilQuota.Hours()/24, growth.MBPerDay,
+			))
+		}
+	}
+
+	return nil
+}
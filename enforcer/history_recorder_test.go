@@ -0,0 +1,152 @@
+package enforcer_test
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/clock/clockfakes"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database/databasefakes"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/enforcer"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/metrics"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/metrics/metricsfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HistoryRecorder", func() {
+	var (
+		recorder             Reconciler
+		fakeUsageHistoryRepo *databasefakes.FakeUsageHistoryRepo
+		fakeClock            *clockfakes.FakeClock
+		fakeMetrics          *metricsfakes.FakeMetrics
+		logger               *lagertest.TestLogger
+		now                  time.Time
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("HistoryRecorder test")
+		fakeUsageHistoryRepo = &databasefakes.FakeUsageHistoryRepo{}
+		fakeMetrics = &metricsfakes.FakeMetrics{}
+
+		now = time.Date(2016, 5, 4, 3, 2, 1, 0, time.UTC)
+		fakeClock = &clockfakes.FakeClock{}
+		fakeClock.NowStub = func() time.Time { return now }
+
+		recorder = NewHistoryRecorder(fakeUsageHistoryRepo, time.Hour, 48*time.Hour, fakeClock, fakeMetrics, logger)
+	})
+
+	It("records a sample and prunes samples older than the retention", func() {
+		Expect(recorder.ReconcileOnce()).To(Succeed())
+
+		Expect(fakeUsageHistoryRepo.RecordCallCount()).To(Equal(1))
+		Expect(fakeUsageHistoryRepo.PruneCallCount()).To(Equal(1))
+		Expect(fakeUsageHistoryRepo.PruneArgsForCall(0)).To(Equal(48 * time.Hour))
+		Expect(fakeUsageHistoryRepo.GrowthArgsForCall(0)).To(Equal(ForecastWindow))
+	})
+
+	It("samples at most once per interval", func() {
+		Expect(recorder.ReconcileOnce()).To(Succeed())
+
+		now = now.Add(59 * time.Minute)
+		Expect(recorder.ReconcileOnce()).To(Succeed())
+		Expect(fakeUsageHistoryRepo.RecordCallCount()).To(Equal(1))
+
+		now = now.Add(time.Minute)
+		Expect(recorder.ReconcileOnce()).To(Succeed())
+		Expect(fakeUsageHistoryRepo.RecordCallCount()).To(Equal(2))
+	})
+
+	Context("when instances are growing", func() {
+		BeforeEach(func() {
+			fakeUsageHistoryRepo.GrowthReturns([]database.Growth{
+				{DBName: "fake-db-soon", UsedMB: 8, QuotaMB: 10, MBPerDay: 1},
+				{DBName: "fake-db-later", UsedMB: 1, QuotaMB: 100, MBPerDay: 1},
+				{DBName: "fake-db-shrinking", UsedMB: 8, QuotaMB: 10, MBPerDay: -1},
+			}, nil)
+		})
+
+		It("exposes the growth and time until quota of each instance", func() {
+			Expect(recorder.ReconcileOnce()).To(Succeed())
+
+			Expect(fakeMetrics.SetGaugeCallCount()).To(Equal(6))
+
+			name, labels, value := fakeMetrics.SetGaugeArgsForCall(0)
+			Expect(name).To(Equal("quota_enforcer_growth_mb_per_day"))
+			Expect(labels).To(Equal(metrics.Labels{"db_name": "fake-db-soon"}))
+			Expect(value).To(Equal(1.0))
+
+			name, _, value = fakeMetrics.SetGaugeArgsForCall(1)
+			Expect(name).To(Equal("quota_enforcer_seconds_until_quota"))
+			Expect(value).To(Equal((48 * time.Hour).Seconds()))
+
+			_, labels, value = fakeMetrics.SetGaugeArgsForCall(5)
+			Expect(labels).To(Equal(metrics.Labels{"db_name": "fake-db-shrinking"}))
+			Expect(value).To(Equal(math.Inf(1)))
+		})
+
+		It("deletes the metrics of instances no longer forecast", func() {
+			Expect(recorder.ReconcileOnce()).To(Succeed())
+
+			fakeUsageHistoryRepo.GrowthReturns([]database.Growth{
+				{DBName: "fake-db-soon", UsedMB: 8, QuotaMB: 10, MBPerDay: 1},
+				{DBName: "fake-db-later", UsedMB: 1, QuotaMB: 100, MBPerDay: 1},
+			}, nil)
+			now = now.Add(time.Hour)
+			Expect(recorder.ReconcileOnce()).To(Succeed())
+
+			Expect(fakeMetrics.DeleteGaugeCallCount()).To(Equal(2))
+			name, labels := fakeMetrics.DeleteGaugeArgsForCall(0)
+			Expect(name).To(Equal("quota_enforcer_growth_mb_per_day"))
+			Expect(labels).To(Equal(metrics.Labels{"db_name": "fake-db-shrinking"}))
+			name, labels = fakeMetrics.DeleteGaugeArgsForCall(1)
+			Expect(name).To(Equal("quota_enforcer_seconds_until_quota"))
+			Expect(labels).To(Equal(metrics.Labels{"db_name": "fake-db-shrinking"}))
+		})
+
+		It("logs instances projected to reach their quota within a week", func() {
+			Expect(recorder.ReconcileOnce()).To(Succeed())
+
+			Expect(logger.LogMessages()).To(ContainElement(ContainSubstring(
+				"Database 'fake-db-soon' is projected to exceed its quota in 2.0 days, growing 1.0 MB per day")))
+			Expect(logger.LogMessages()).NotTo(ContainElement(ContainSubstring("fake-db-later")))
+		})
+	})
+
+	Context("when recording fails", func() {
+		BeforeEach(func() {
+			fakeUsageHistoryRepo.RecordReturns(errors.New("fake-record-error"))
+		})
+
+		It("returns an error and samples again next cycle", func() {
+			Expect(recorder.ReconcileOnce()).To(MatchError("fake-record-error"))
+
+			fakeUsageHistoryRepo.RecordReturns(nil)
+			Expect(recorder.ReconcileOnce()).To(Succeed())
+			Expect(fakeUsageHistoryRepo.RecordCallCount()).To(Equal(2))
+		})
+	})
+
+	Context("when pruning fails", func() {
+		BeforeEach(func() {
+			fakeUsageHistoryRepo.PruneReturns(errors.New("fake-prune-error"))
+		})
+
+		It("returns an error", func() {
+			Expect(recorder.ReconcileOnce()).To(MatchError("fake-prune-error"))
+		})
+	})
+
+	Context("when forecasting fails", func() {
+		BeforeEach(func() {
+			fakeUsageHistoryRepo.GrowthReturns(nil, errors.New("fake-growth-error"))
+		})
+
+		It("returns an error", func() {
+			Expect(recorder.ReconcileOnce()).To(MatchError("fake-growth-error"))
+		})
+	})
+})
@@ -0,0 +1,46 @@
+package enforcer
+
+import (
+	"fmt"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/metrics"
+)
+
+const replicaLagMetric = "quota_enforcer_replica_lag_seconds"
+
+// lagGuard skips enforcement while the node usage and privileges are measured
+// on lags too far behind. Stale grants would have the enforcer restrict users
+// again which it just restricted, or miss that usage fell.
+type lagGuard struct {
+	enforcer   Enforcer
+	replicaLag database.ReplicaLag
+	maxLag     time.Duration
+	metrics    metrics.Metrics
+	logger     lager.Logger
+}
+
+func NewLagGuard(enforcer Enforcer, replicaLag database.ReplicaLag, maxLag time.Duration, metrics metrics.Metrics, logger lager.Logger) Enforcer {
+	return &lagGuard{
+		enforcer:   enforcer,
+		replicaLag: replicaLag,
+		maxLag:     maxLag,
+		metrics:    metrics,
+		logger:     logger,
+	}
+}
+
+func (g lagGuard) EnforceOnce() error {
+	lag, err := g.replicaLag.Lag()
+	if err != nil {
+		return fmt.Errorf("Checking replica lag: %s", err.Error())
+	}
+	g.metrics.SetGauge(replicaLagMetric, nil, lag.Seconds())
+
+	if lag > g.maxLag {
+		return fmt.Errorf("Measurement node lags %s behind, more than %s, skipping enforcement", lag, g.maxLag)
+	}
+	return g.enforcer.EnforceOnce()
+}
@@ -0,0 +1,79 @@
+package enforcer_test
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database/databasefakes"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/enforcer"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/enforcer/enforcerfakes"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/metrics/metricsfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LagGuard", func() {
+	var (
+		guard          Enforcer
+		fakeEnforcer   *enforcerfakes.FakeEnforcer
+		fakeReplicaLag *databasefakes.FakeReplicaLag
+		fakeMetrics    *metricsfakes.FakeMetrics
+		logger         *lagertest.TestLogger
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("LagGuard test")
+		fakeEnforcer = &enforcerfakes.FakeEnforcer{}
+		fakeReplicaLag = &databasefakes.FakeReplicaLag{}
+		fakeMetrics = &metricsfakes.FakeMetrics{}
+		guard = NewLagGuard(fakeEnforcer, fakeReplicaLag, 30*time.Second, fakeMetrics, logger)
+	})
+
+	Context("when the measurement node is current enough", func() {
+		BeforeEach(func() {
+			fakeReplicaLag.LagReturns(30*time.Second, nil)
+		})
+
+		It("enforces", func() {
+			Expect(guard.EnforceOnce()).To(Succeed())
+			Expect(fakeEnforcer.EnforceOnceCallCount()).To(Equal(1))
+		})
+
+		It("exposes the lag", func() {
+			Expect(guard.EnforceOnce()).To(Succeed())
+
+			name, _, value := fakeMetrics.SetGaugeArgsForCall(0)
+			Expect(name).To(Equal("quota_enforcer_replica_lag_seconds"))
+			Expect(value).To(Equal(30.0))
+		})
+
+		It("returns the error of enforcing", func() {
+			fakeEnforcer.EnforceOnceReturns(errors.New("fake-enforce-error"))
+			Expect(guard.EnforceOnce()).To(MatchError("fake-enforce-error"))
+		})
+	})
+
+	Context("when the measurement node lags too far behind", func() {
+		BeforeEach(func() {
+			fakeReplicaLag.LagReturns(31*time.Second, nil)
+		})
+
+		It("skips enforcement with an error", func() {
+			Expect(guard.EnforceOnce()).To(MatchError("Measurement node lags 31s behind, more than 30s, skipping enforcement"))
+			Expect(fakeEnforcer.EnforceOnceCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the lag cannot be measured", func() {
+		BeforeEach(func() {
+			fakeReplicaLag.LagReturns(0, errors.New("fake-lag-error"))
+		})
+
+		It("skips enforcement with an error", func() {
+			Expect(guard.EnforceOnce()).To(MatchError("Checking replica lag: fake-lag-error"))
+			Expect(fakeEnforcer.EnforceOnceCallCount()).To(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,80 @@
+package enforcer
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+)
+
+// objectQuotaEnforcer revokes CREATE from instances that reached their table
+// or routine limit, and grants it again once they are back under it. Each
+// revoke is recorded, so that CREATE is only granted where it was revoked.
+type objectQuotaEnforcer struct {
+	violatorRepo, reformerRepo database.Repo
+	restrictionRecordRepo      database.RestrictionRecordRepo
+	logger                     lager.Logger
+}
+
+func NewObjectQuotaEnforcer(violatorRepo, reformerRepo database.Repo, restrictionRecordRepo database.RestrictionRecordRepo, logger lager.Logger) Reconciler {
+	return &objectQuotaEnforcer{
+		violatorRepo:          violatorRepo,
+		reformerRepo:          reformerRepo,
+		restrictionRecordRepo: restrictionRecordRepo,
+		logger:                logger,
+	}
+}
+
+func (e objectQuotaEnforcer) ReconcileOnce() error {
+	e.logger.Info("Looking for object quota violators")
+
+	violators, err := e.violatorRepo.All()
+	if err != nil {
+		return fmt.Errorf("Finding object quota violators: %s", err.Error())
+	}
+
+	for _, db := range violators {
+		e.logger.Info(fmt.Sprintf("Database '%s' exceeds its object quota", db.Name()))
+
+		err = e.restrictionRecordRepo.Record(db, database.ObjectQuotaRestriction)
+		if err != nil {
+			return err
+		}
+
+		err = db.RevokeCreatePrivilege()
+		if err != nil {
+			return fmt.Errorf("Revoking create privilege on '%s': %s", db.Name(), err.Error())
+		}
+
+		err = db.KillActiveConnections()
+		if err != nil {
+			return fmt.Errorf("Resetting active privileges on '%s': %s", db.Name(), err.Error())
+		}
+	}
+
+	e.logger.Info("Looking for object quota reformers")
+
+	reformers, err := e.reformerRepo.All()
+	if err != nil {
+		return fmt.Errorf("Finding object quota reformers: %s", err.Error())
+	}
+
+	for _, db := range reformers {
+		err = db.GrantCreatePrivilege()
+		if err != nil {
+			return fmt.Errorf("Granting create privilege on '%s': %s", db.Name(), err.Error())
+		}
+
+		err = db.KillActiveConnections()
+		if err != nil {
+			return fmt.Errorf("Resetting active privileges on '%s': %s", db.Name(), err.Error())
+		}
+
+		err = e.restrictionRecordRepo.Remove(db, database.ObjectQuotaRestriction)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
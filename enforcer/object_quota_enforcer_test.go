@@ -0,0 +1,126 @@
+package enforcer_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database/databasefakes"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/enforcer"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ObjectQuotaEnforcer", func() {
+	var (
+		objectQuotaEnforcer Reconciler
+		fakeViolatorRepo    *databasefakes.FakeRepo
+		fakeReformerRepo    *databasefakes.FakeRepo
+		fakeRecords         *databasefakes.FakeRestrictionRecordRepo
+		fakeViolator        *databasefakes.FakeDatabase
+		fakeReformer        *databasefakes.FakeDatabase
+		logger              *lagertest.TestLogger
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("ObjectQuotaEnforcer test")
+		fakeViolatorRepo = &databasefakes.FakeRepo{}
+		fakeReformerRepo = &databasefakes.FakeRepo{}
+		fakeRecords = &databasefakes.FakeRestrictionRecordRepo{}
+
+		fakeViolator = &databasefakes.FakeDatabase{}
+		fakeViolator.NameReturns("fake-violator")
+		fakeReformer = &databasefakes.FakeDatabase{}
+		fakeReformer.NameReturns("fake-reformer")
+
+		fakeViolatorRepo.AllReturns([]database.Database{fakeViolator}, nil)
+		fakeReformerRepo.AllReturns([]database.Database{fakeReformer}, nil)
+
+		objectQuotaEnforcer = NewObjectQuotaEnforcer(fakeViolatorRepo, fakeReformerRepo, fakeRecords, logger)
+	})
+
+	It("revokes only CREATE from violators and grants it to reformers", func() {
+		Expect(objectQuotaEnforcer.ReconcileOnce()).To(Succeed())
+
+		Expect(fakeViolator.RevokeCreatePrivilegeCallCount()).To(Equal(1))
+		Expect(fakeViolator.KillActiveConnectionsCallCount()).To(Equal(1))
+		Expect(fakeViolator.RevokePrivilegesCallCount()).To(Equal(0))
+
+		Expect(fakeReformer.GrantCreatePrivilegeCallCount()).To(Equal(1))
+		Expect(fakeReformer.KillActiveConnectionsCallCount()).To(Equal(1))
+		Expect(fakeReformer.GrantPrivilegesCallCount()).To(Equal(0))
+	})
+
+	It("records each revoke before it, and removes the record once CREATE is granted again", func() {
+		fakeViolator.RevokeCreatePrivilegeStub = func() error {
+			Expect(fakeRecords.RecordCallCount()).To(Equal(1))
+			return nil
+		}
+
+		Expect(objectQuotaEnforcer.ReconcileOnce()).To(Succeed())
+
+		db, restriction := fakeRecords.RecordArgsForCall(0)
+		Expect(db).To(BeIdenticalTo(fakeViolator))
+		Expect(restriction).To(Equal(database.ObjectQuotaRestriction))
+
+		Expect(fakeRecords.RemoveCallCount()).To(Equal(1))
+		db, restriction = fakeRecords.RemoveArgsForCall(0)
+		Expect(db).To(BeIdenticalTo(fakeReformer))
+		Expect(restriction).To(Equal(database.ObjectQuotaRestriction))
+	})
+
+	Context("when recording a revoke fails", func() {
+		BeforeEach(func() {
+			fakeRecords.RecordReturns(errors.New("fake-record-error"))
+		})
+
+		It("returns an error without revoking CREATE", func() {
+			Expect(objectQuotaEnforcer.ReconcileOnce()).To(MatchError("fake-record-error"))
+			Expect(fakeViolator.RevokeCreatePrivilegeCallCount()).To(Equal(0))
+		})
+	})
+
+	It("reports object quota violations separately", func() {
+		Expect(objectQuotaEnforcer.ReconcileOnce()).To(Succeed())
+		Expect(logger.LogMessages()).To(ContainElement(ContainSubstring("exceeds its object quota")))
+	})
+
+	Context("when finding violators fails", func() {
+		BeforeEach(func() {
+			fakeViolatorRepo.AllReturns(nil, errors.New("fake-violator-error"))
+		})
+
+		It("returns an error", func() {
+			err := objectQuotaEnforcer.ReconcileOnce()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-violator-error"))
+			Expect(fakeReformerRepo.AllCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when revoking CREATE fails", func() {
+		BeforeEach(func() {
+			fakeViolator.RevokeCreatePrivilegeReturns(errors.New("fake-revoke-error"))
+		})
+
+		It("returns an error", func() {
+			err := objectQuotaEnforcer.ReconcileOnce()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-revoke-error"))
+			Expect(err.Error()).To(ContainSubstring("fake-violator"))
+		})
+	})
+
+	Context("when granting CREATE fails", func() {
+		BeforeEach(func() {
+			fakeReformer.GrantCreatePrivilegeReturns(errors.New("fake-grant-error"))
+		})
+
+		It("returns an error", func() {
+			err := objectQuotaEnforcer.ReconcileOnce()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-grant-error"))
+		})
+	})
+})
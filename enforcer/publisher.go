@@ -0,0 +1,27 @@
+package enforcer
+
+import (
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+)
+
+// publisher writes the usage and state of every instance back to the broker
+// database, and whether it is restricted. As a reconciler, it runs before
+// violators and reformers are looked for, so a restriction applied or lifted
+// in a cycle is published in the next one.
+type publisher struct {
+	usagePublisher database.UsagePublisher
+	logger         lager.Logger
+}
+
+func NewPublisher(usagePublisher database.UsagePublisher, logger lager.Logger) Reconciler {
+	return &publisher{
+		usagePublisher: usagePublisher,
+		logger:         logger,
+	}
+}
+
+func (p publisher) ReconcileOnce() error {
+	p.logger.Info("Publishing usage")
+	return p.usagePublisher.Publish()
+}
@@ -0,0 +1,144 @@
+package enforcer
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/clock"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/metrics"
+)
+
+const reclaimsTotalMetric = "quota_enforcer_reclaims_total"
+
+// reclaimer rebuilds fragmented tables of restricted instances, so that rows
+// deleted to get back under quota free their space. The next cycle measures
+// the smaller tables and lifts the restriction.
+type reclaimer struct {
+	reclaim               config.Reclaim
+	instanceRepo          database.InstanceRepo
+	restrictionRecordRepo database.RestrictionRecordRepo
+	reclaimRepo           database.ReclaimRepo
+	clock                 clock.Clock
+	metrics               metrics.Metrics
+	logger                lager.Logger
+}
+
+// NewReclaimer returns a reclaimer which only rebuilds tables within the
+// reclaim window, each at most once per window, and starts no rebuild once
+// the time budget of a cycle is spent or that would exceed its size budget.
+// Only instances still over quota with a restriction recorded are reclaimed,
+// which leaves out those the circuit breaker held back.
+func NewReclaimer(reclaim config.Reclaim, instanceRepo database.InstanceRepo, restrictionRecordRepo database.RestrictionRecordRepo, reclaimRepo database.ReclaimRepo, clock clock.Clock, metrics metrics.Metrics, logger lager.Logger) Reconciler {
+	return &reclaimer{
+		reclaim:               reclaim,
+		instanceRepo:          instanceRepo,
+		restrictionRecordRepo: restrictionRecordRepo,
+		reclaimRepo:           reclaimRepo,
+		clock:                 clock,
+		metrics:               metrics,
+		logger:                logger,
+	}
+}
+
+func (r reclaimer) ReconcileOnce() error {
+	started := r.clock.Now()
+	if !r.reclaim.InWindow(started) {
+		return nil
+	}
+
+	r.logger.Info("Looking for fragmented tables of restricted instances")
+
+	restricted, err := r.restrictionRecordRepo.Restricted()
+	if err != nil {
+		return err
+	}
+	if len(restricted) == 0 {
+		return nil
+	}
+
+	instances, err := r.instanceRepo.All()
+	if err != nil {
+		return fmt.Errorf("Finding instances: %s", err.Error())
+	}
+
+	deadline := started.Add(r.reclaim.Budget())
+	windowStartedAt := r.reclaim.WindowStartedAt(started)
+	sizeBudget := r.reclaim.SizeBudget()
+	var rebuiltMB float64
+	for _, instance := range instances {
+		if !restricted[instance.DBName] || !instance.OverQuota() {
+			continue
+		}
+
+		tables, err := r.reclaimRepo.Fragmented(instance.DBName, r.reclaim.MinDataFree(), r.reclaim.MaxTableSize())
+		if err != nil {
+			return err
+		}
+
+		// Tables in the shared tablespace all report its free space, and
+		// would otherwise be rebuilt every cycle.
+		rebuilt, err := r.reclaimRepo.Rebuilt(instance.DBName, windowStartedAt)
+		if err != nil {
+			return err
+		}
+
+		for _, table := range tables {
+			if rebuilt[table.Name] {
+				r.logger.Debug(fmt.Sprintf("Table '%s' of db '%s' was already rebuilt in this window", table.Name, instance.DBName))
+				continue
+			}
+			if rebuiltMB+table.SizeMB > sizeBudget {
+				r.logger.Info(fmt.Sprintf(
+					"Rebuilding table '%s' of db '%s' would exceed the reclaim size budget of %.1f MB, continuing next cycle",
+					table.Name, instance.DBName, sizeBudget,
+				))
+				continue
+			}
+			if !r.clock.Now().Before(deadline) {
+				r.logger.Info(fmt.Sprintf("Reclaim budget of %s spent, continuing next cycle", r.reclaim.Budget()))
+				return nil
+			}
+
+			err = r.rebuild(instance.DBName, table)
+			if err != nil {
+				return err
+			}
+			rebuiltMB += table.SizeMB
+		}
+	}
+
+	return nil
+}
+
+// rebuild rebuilds and re-measures a table, and records the outcome. A failed
+// rebuild is recorded and logged, but does not stop reclaiming other tables.
+func (r reclaimer) rebuild(dbName string, table database.TableSize) error {
+	reclaim := database.Reclaim{
+		DBName:    dbName,
+		Before:    table,
+		StartedAt: r.clock.Now(),
+	}
+
+	err := r.reclaimRepo.Rebuild(dbName, table.Name)
+	if err == nil {
+		reclaim.After, err = r.reclaimRepo.Measure(dbName, table.Name)
+	}
+	reclaim.Duration = r.clock.Now().Sub(reclaim.StartedAt)
+
+	result := "ok"
+	if err != nil {
+		reclaim.Error = err.Error()
+		result = "error"
+		r.logger.Error(fmt.Sprintf("Failed to reclaim free space of table '%s' of db '%s'", table.Name, dbName), err)
+	} else {
+		r.logger.Info(fmt.Sprintf(
+			"Reclaimed table '%s' of db '%s' in %s: %.1f MB (%.1f MB free) before, %.1f MB (%.1f MB free) after",
+			table.Name, dbName, reclaim.Duration, table.SizeMB, table.DataFreeMB, reclaim.After.SizeMB, reclaim.After.DataFreeMB,
+		))
+	}
+	r.metrics.IncrementCounter(reclaimsTotalMetric, metrics.Labels{"result": result})
+
+	return r.reclaimRepo.Record(reclaim)
+}
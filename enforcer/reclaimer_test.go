@@ -0,0 +1,253 @@
+package enforcer_test
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/clock/clockfakes"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database/databasefakes"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/enforcer"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/metrics"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/metrics/metricsfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reclaimer", func() {
+	var (
+		reclaimer                 Reconciler
+		reclaim                   config.Reclaim
+		fakeInstanceRepo          *databasefakes.FakeInstanceRepo
+		fakeRestrictionRecordRepo *databasefakes.FakeRestrictionRecordRepo
+		fakeReclaimRepo           *databasefakes.FakeReclaimRepo
+		fakeClock                 *clockfakes.FakeClock
+		fakeMetrics               *metricsfakes.FakeMetrics
+		logger                    *lagertest.TestLogger
+		now                       time.Time
+		fragmented                database.TableSize
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("Reclaimer test")
+		fakeInstanceRepo = &databasefakes.FakeInstanceRepo{}
+		fakeInstanceRepo.AllReturns([]database.Instance{
+			{DBName: "fake-db-over", UsedMB: 12, QuotaMB: 10},
+			{DBName: "fake-db-under", UsedMB: 5, QuotaMB: 10},
+			{DBName: "fake-db-unrestricted", UsedMB: 12, QuotaMB: 10},
+		}, nil)
+
+		fakeRestrictionRecordRepo = &databasefakes.FakeRestrictionRecordRepo{}
+		fakeRestrictionRecordRepo.RestrictedReturns(map[string]bool{"fake-db-over": true, "fake-db-under": true}, nil)
+
+		fragmented = database.TableSize{Name: "fake-table", SizeMB: 500, DataFreeMB: 300}
+		fakeReclaimRepo = &databasefakes.FakeReclaimRepo{}
+		fakeReclaimRepo.FragmentedReturns([]database.TableSize{fragmented}, nil)
+		fakeReclaimRepo.MeasureReturns(database.TableSize{Name: "fake-table", SizeMB: 210, DataFreeMB: 4}, nil)
+
+		fakeMetrics = &metricsfakes.FakeMetrics{}
+
+		now = time.Date(2016, 5, 4, 3, 0, 0, 0, time.UTC)
+		fakeClock = &clockfakes.FakeClock{}
+		fakeClock.NowStub = func() time.Time { return now }
+
+		reclaim = config.Reclaim{Enabled: true, WindowStart: "02:00", WindowEnd: "05:00"}
+	})
+
+	JustBeforeEach(func() {
+		reclaimer = NewReclaimer(reclaim, fakeInstanceRepo, fakeRestrictionRecordRepo, fakeReclaimRepo, fakeClock, fakeMetrics, logger)
+	})
+
+	It("rebuilds the fragmented tables of restricted instances still over quota", func() {
+		Expect(reclaimer.ReconcileOnce()).To(Succeed())
+
+		Expect(fakeReclaimRepo.FragmentedCallCount()).To(Equal(1))
+		dbName, minDataFreeMB, maxSizeMB := fakeReclaimRepo.FragmentedArgsForCall(0)
+		Expect(dbName).To(Equal("fake-db-over"))
+		Expect(minDataFreeMB).To(Equal(100.0))
+		Expect(maxSizeMB).To(Equal(1024.0))
+
+		Expect(fakeReclaimRepo.RebuildCallCount()).To(Equal(1))
+		dbName, tableName := fakeReclaimRepo.RebuildArgsForCall(0)
+		Expect(dbName).To(Equal("fake-db-over"))
+		Expect(tableName).To(Equal("fake-table"))
+	})
+
+	It("leaves out instances over quota without a restriction recorded", func() {
+		Expect(reclaimer.ReconcileOnce()).To(Succeed())
+
+		for i := 0; i < fakeReclaimRepo.FragmentedCallCount(); i++ {
+			dbName, _, _ := fakeReclaimRepo.FragmentedArgsForCall(i)
+			Expect(dbName).NotTo(Equal("fake-db-unrestricted"))
+		}
+	})
+
+	Context("when no instance is restricted", func() {
+		BeforeEach(func() {
+			fakeRestrictionRecordRepo.RestrictedReturns(map[string]bool{}, nil)
+		})
+
+		It("does not look for instances", func() {
+			Expect(reclaimer.ReconcileOnce()).To(Succeed())
+			Expect(fakeInstanceRepo.AllCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when finding restricted instances fails", func() {
+		BeforeEach(func() {
+			fakeRestrictionRecordRepo.RestrictedReturns(nil, errors.New("fake-restricted-error"))
+		})
+
+		It("returns an error", func() {
+			Expect(reclaimer.ReconcileOnce()).To(MatchError("fake-restricted-error"))
+			Expect(fakeReclaimRepo.RebuildCallCount()).To(Equal(0))
+		})
+	})
+
+	It("records the sizes before and after", func() {
+		fakeReclaimRepo.RebuildStub = func(string, string) error {
+			now = now.Add(90 * time.Second)
+			return nil
+		}
+
+		Expect(reclaimer.ReconcileOnce()).To(Succeed())
+
+		Expect(fakeReclaimRepo.RecordCallCount()).To(Equal(1))
+		Expect(fakeReclaimRepo.RecordArgsForCall(0)).To(Equal(database.Reclaim{
+			DBName:    "fake-db-over",
+			Before:    fragmented,
+			After:     database.TableSize{Name: "fake-table", SizeMB: 210, DataFreeMB: 4},
+			StartedAt: time.Date(2016, 5, 4, 3, 0, 0, 0, time.UTC),
+			Duration:  90 * time.Second,
+		}))
+		Expect(logger.LogMessages()).To(ContainElement(ContainSubstring(
+			"Reclaimed table 'fake-table' of db 'fake-db-over' in 1m30s: 500.0 MB (300.0 MB free) before, 210.0 MB (4.0 MB free) after")))
+
+		name, labels := fakeMetrics.IncrementCounterArgsForCall(0)
+		Expect(name).To(Equal("quota_enforcer_reclaims_total"))
+		Expect(labels).To(Equal(metrics.Labels{"result": "ok"}))
+	})
+
+	Context("outside the window", func() {
+		BeforeEach(func() {
+			now = time.Date(2016, 5, 4, 5, 0, 0, 0, time.UTC)
+		})
+
+		It("does nothing", func() {
+			Expect(reclaimer.ReconcileOnce()).To(Succeed())
+			Expect(fakeInstanceRepo.AllCallCount()).To(Equal(0))
+			Expect(fakeReclaimRepo.RebuildCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the budget of the cycle is spent", func() {
+		BeforeEach(func() {
+			reclaim.MaxSecondsPerCycle = 60
+			fakeReclaimRepo.FragmentedReturns([]database.TableSize{fragmented, fragmented, fragmented}, nil)
+			fakeReclaimRepo.RebuildStub = func(string, string) error {
+				now = now.Add(45 * time.Second)
+				return nil
+			}
+		})
+
+		It("starts no further rebuilds", func() {
+			Expect(reclaimer.ReconcileOnce()).To(Succeed())
+			Expect(fakeReclaimRepo.RebuildCallCount()).To(Equal(2))
+		})
+	})
+
+	It("looks up the tables rebuilt since the window started", func() {
+		Expect(reclaimer.ReconcileOnce()).To(Succeed())
+
+		Expect(fakeReclaimRepo.RebuiltCallCount()).To(Equal(1))
+		dbName, since := fakeReclaimRepo.RebuiltArgsForCall(0)
+		Expect(dbName).To(Equal("fake-db-over"))
+		Expect(since).To(Equal(time.Date(2016, 5, 4, 2, 0, 0, 0, time.UTC)))
+	})
+
+	Context("when a table was already rebuilt in the window", func() {
+		BeforeEach(func() {
+			other := database.TableSize{Name: "fake-other-table", SizeMB: 100, DataFreeMB: 200}
+			fakeReclaimRepo.FragmentedReturns([]database.TableSize{fragmented, other}, nil)
+			fakeReclaimRepo.RebuiltReturns(map[string]bool{"fake-table": true}, nil)
+		})
+
+		It("skips it", func() {
+			Expect(reclaimer.ReconcileOnce()).To(Succeed())
+
+			Expect(fakeReclaimRepo.RebuildCallCount()).To(Equal(1))
+			_, tableName := fakeReclaimRepo.RebuildArgsForCall(0)
+			Expect(tableName).To(Equal("fake-other-table"))
+		})
+	})
+
+	Context("when rebuilding a table would exceed the size budget of the cycle", func() {
+		BeforeEach(func() {
+			reclaim.MaxMBPerCycle = 800
+			small := database.TableSize{Name: "fake-small-table", SizeMB: 200, DataFreeMB: 150}
+			fakeReclaimRepo.FragmentedReturns([]database.TableSize{fragmented, fragmented, small}, nil)
+		})
+
+		It("skips it and rebuilds the tables still fitting", func() {
+			Expect(reclaimer.ReconcileOnce()).To(Succeed())
+
+			Expect(fakeReclaimRepo.RebuildCallCount()).To(Equal(2))
+			_, tableName := fakeReclaimRepo.RebuildArgsForCall(1)
+			Expect(tableName).To(Equal("fake-small-table"))
+			Expect(logger.LogMessages()).To(ContainElement(ContainSubstring(
+				"Rebuilding table 'fake-table' of db 'fake-db-over' would exceed the reclaim size budget of 800.0 MB")))
+		})
+	})
+
+	Context("when finding rebuilt tables fails", func() {
+		BeforeEach(func() {
+			fakeReclaimRepo.RebuiltReturns(nil, errors.New("fake-rebuilt-error"))
+		})
+
+		It("returns an error", func() {
+			Expect(reclaimer.ReconcileOnce()).To(MatchError("fake-rebuilt-error"))
+			Expect(fakeReclaimRepo.RebuildCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when a rebuild fails", func() {
+		BeforeEach(func() {
+			fakeReclaimRepo.FragmentedReturns([]database.TableSize{fragmented, fragmented}, nil)
+			fakeReclaimRepo.RebuildReturns(errors.New("fake-rebuild-error"))
+		})
+
+		It("records the error and continues with other tables", func() {
+			Expect(reclaimer.ReconcileOnce()).To(Succeed())
+
+			Expect(fakeReclaimRepo.RebuildCallCount()).To(Equal(2))
+			Expect(fakeReclaimRepo.MeasureCallCount()).To(Equal(0))
+			Expect(fakeReclaimRepo.RecordArgsForCall(0).Error).To(Equal("fake-rebuild-error"))
+
+			_, labels := fakeMetrics.IncrementCounterArgsForCall(0)
+			Expect(labels).To(Equal(metrics.Labels{"result": "error"}))
+		})
+	})
+
+	Context("when finding instances fails", func() {
+		BeforeEach(func() {
+			fakeInstanceRepo.AllReturns(nil, errors.New("fake-instances-error"))
+		})
+
+		It("returns an error", func() {
+			Expect(reclaimer.ReconcileOnce()).To(MatchError("Finding instances: fake-instances-error"))
+		})
+	})
+
+	Context("when recording fails", func() {
+		BeforeEach(func() {
+			fakeReclaimRepo.RecordReturns(errors.New("fake-record-error"))
+		})
+
+		It("returns an error", func() {
+			Expect(reclaimer.ReconcileOnce()).To(MatchError("fake-record-error"))
+		})
+	})
+})
@@ -0,0 +1,7 @@
+package enforcer
+
+// Reconciler brings account limits in line with the config and the usage of
+// each instance. Reconcilers run on every cycle, before violators are looked for.
+type Reconciler interface {
+	ReconcileOnce() error
+}
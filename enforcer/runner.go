@@ -1,6 +1,7 @@
 package enforcer
 
 import (
+	"fmt"
 	"os"
 	"time"
 
@@ -40,3 +41,87 @@ func (r runner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 		}
 	}
 }
+
+// maxStartBackoff bounds the time between attempts to start an enforcer.
+const maxStartBackoff = 10 * time.Minute
+
+type restartingRunner struct {
+	enforcer      Enforcer
+	closeEnforcer func()
+	start         func() (Enforcer, func(), error)
+	clock         clock.Clock
+	pause         time.Duration
+	logger        lager.Logger
+}
+
+// NewRestartingRunner returns a runner like NewRunner, which starts the
+// enforcer again with start once it returns ErrStale. The enforcer is nil if
+// it failed to start. Starting is retried after pause, doubling the wait after
+// each failure up to maxStartBackoff. The function returned along with an
+// enforcer closes it, and is called once the enforcer failed to start, is
+// started again, or the runner exits.
+func NewRestartingRunner(enforcer Enforcer, closeEnforcer func(), start func() (Enforcer, func(), error), clock clock.Clock, pause time.Duration,
+	logger lager.Logger) ifrit.Runner {
+	return &restartingRunner{
+		enforcer:      enforcer,
+		closeEnforcer: closeEnforcer,
+		start:         start,
+		clock:         clock,
+		pause:         pause,
+		logger:        logger,
+	}
+}
+
+func (r restartingRunner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	close(ready)
+
+	enforcer, closeEnforcer := r.enforcer, r.closeEnforcer
+	defer func() {
+		if enforcer != nil {
+			closeEnforcer()
+		}
+	}()
+
+	backoff := r.pause
+	for {
+		if enforcer == nil {
+			r.logger.Info(fmt.Sprintf("Starting in %s", backoff))
+			select {
+			case <-signals:
+				return nil
+			case <-r.clock.After(backoff):
+			}
+
+			started, closeStarted, err := r.start()
+			if err != nil {
+				closeStarted()
+				r.logger.Error("Failed to start", err)
+
+				backoff *= 2
+				if backoff > maxStartBackoff {
+					backoff = maxStartBackoff
+				}
+				continue
+			}
+			enforcer, closeEnforcer = started, closeStarted
+			backoff = r.pause
+			r.logger.Info("Started")
+		}
+
+		err := enforcer.EnforceOnce()
+		if err == ErrStale {
+			r.logger.Info("Restarting, as the broker schema changed")
+			closeEnforcer()
+			enforcer = nil
+			continue
+		}
+		if err != nil {
+			r.logger.Error("Enforcing Failed", err)
+		}
+		select {
+		case <-signals:
+			return nil
+		case <-r.clock.After(r.pause):
+		}
+	}
+}
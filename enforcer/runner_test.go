@@ -80,3 +80,128 @@ var _ = Describe("Runner", func() {
 	})
 
 })
+
+var _ = Describe("RestartingRunner", func() {
+
+	var (
+		enforcer      *enforcerfakes.FakeEnforcer
+		started       *enforcerfakes.FakeEnforcer
+		clock         *clockfakes.FakeClock
+		logger        *lagertest.TestLogger
+		pause         time.Duration
+		failures      int
+		starts        int
+		closes        int
+		runner        ifrit.Runner
+		signals       chan os.Signal
+		ready         chan struct{}
+		errStartFails = errors.New("fake-start-error")
+	)
+
+	BeforeEach(func() {
+		enforcer = &enforcerfakes.FakeEnforcer{}
+		started = &enforcerfakes.FakeEnforcer{}
+		clock = &clockfakes.FakeClock{}
+		pause = 4 * time.Minute
+		logger = lagertest.NewTestLogger("RestartingRunner test")
+		failures = 0
+		starts = 0
+		closes = 0
+
+		signals = make(chan os.Signal, 1)
+		ready = make(chan struct{})
+
+		clock.AfterStub = func(d time.Duration) <-chan time.Time {
+			return time.After(1 * time.Millisecond)
+		}
+		started.EnforceOnceStub = func() error {
+			signals <- os.Interrupt
+			return nil
+		}
+	})
+
+	start := func() (enforcerPkg.Enforcer, func(), error) {
+		starts++
+		closeEnforcer := func() { closes++ }
+		if starts <= failures {
+			return nil, closeEnforcer, errStartFails
+		}
+		return started, closeEnforcer, nil
+	}
+
+	Context("when the enforcer started", func() {
+		JustBeforeEach(func() {
+			runner = enforcerPkg.NewRestartingRunner(enforcer, func() { closes++ }, start, clock, pause, logger)
+		})
+
+		It("runs it and closes it once it exits", func() {
+			enforcer.EnforceOnceStub = func() error {
+				signals <- os.Interrupt
+				return nil
+			}
+
+			Expect(runner.Run(signals, ready)).To(Succeed())
+
+			Expect(enforcer.EnforceOnceCallCount()).To(Equal(1))
+			Expect(starts).To(Equal(0))
+			Expect(closes).To(Equal(1))
+		})
+
+		Context("when the enforcer is stale", func() {
+			BeforeEach(func() {
+				enforcer.EnforceOnceReturns(enforcerPkg.ErrStale)
+			})
+
+			It("closes it and starts it again", func() {
+				Expect(runner.Run(signals, ready)).To(Succeed())
+
+				Expect(enforcer.EnforceOnceCallCount()).To(Equal(1))
+				Expect(starts).To(Equal(1))
+				Expect(started.EnforceOnceCallCount()).To(Equal(1))
+				Expect(closes).To(Equal(2))
+				Expect(logger.LogMessages()).To(ContainElement(ContainSubstring("Restarting, as the broker schema changed")))
+			})
+		})
+	})
+
+	Context("when the enforcer failed to start", func() {
+		BeforeEach(func() {
+			failures = 3
+		})
+
+		JustBeforeEach(func() {
+			runner = enforcerPkg.NewRestartingRunner(nil, nil, start, clock, pause, logger)
+		})
+
+		It("retries to start with exponential backoff and then runs the enforcer", func() {
+			Expect(runner.Run(signals, ready)).To(Succeed())
+
+			Expect(starts).To(Equal(4))
+			Expect(started.EnforceOnceCallCount()).To(Equal(1))
+			Expect(clock.AfterArgsForCall(0)).To(Equal(4 * time.Minute))
+			Expect(clock.AfterArgsForCall(1)).To(Equal(8 * time.Minute))
+			Expect(clock.AfterArgsForCall(2)).To(Equal(10 * time.Minute))
+			Expect(clock.AfterArgsForCall(3)).To(Equal(10 * time.Minute))
+			Expect(logger.LogMessages()).To(ContainElement(ContainSubstring("Failed to start")))
+		})
+
+		It("closes each attempt which failed, and the enforcer started once it exits", func() {
+			Expect(runner.Run(signals, ready)).To(Succeed())
+			Expect(closes).To(Equal(4))
+		})
+
+		Context("when interrupted before starting", func() {
+			BeforeEach(func() {
+				signals <- os.Interrupt
+				clock.AfterStub = func(d time.Duration) <-chan time.Time {
+					return make(chan time.Time)
+				}
+			})
+
+			It("exits without starting", func() {
+				Expect(runner.Run(signals, ready)).To(Succeed())
+				Expect(starts).To(Equal(0))
+			})
+		})
+	})
+})
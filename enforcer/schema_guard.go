@@ -0,0 +1,90 @@
+package enforcer
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/clock"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+)
+
+// ErrStale is returned by an enforcer built for a broker schema which changed
+// since. It must be built again, as NewRestartingRunner does.
+var ErrStale = errors.New("The broker schema changed since the enforcer started")
+
+// redetectInterval is how often the brokers are detected again while
+// enforcement succeeds.
+const redetectInterval = 10 * time.Minute
+
+// schemaGuard detects the brokers again, as the queries of an enforcer are
+// built for the optional tables and columns detected when it started. A broker
+// migrated to a newer schema, or back, would have them miss a table or column
+// they could use, or fail every cycle.
+type schemaGuard struct {
+	enforcer   Enforcer
+	detector   database.BrokerDetector
+	brokers    []database.Broker
+	detectedAt time.Time
+	clock      clock.Clock
+	logger     lager.Logger
+}
+
+// NewSchemaGuard returns an enforcer returning ErrStale once the brokers, as
+// detected when it started, changed. They are detected again every
+// redetectInterval, and right after a cycle failed on a missing table or
+// column.
+func NewSchemaGuard(enforcer Enforcer, detector database.BrokerDetector, brokers []database.Broker, clock clock.Clock, logger lager.Logger) Enforcer {
+	return &schemaGuard{
+		enforcer:   enforcer,
+		detector:   detector,
+		brokers:    brokers,
+		detectedAt: clock.Now(),
+		clock:      clock,
+		logger:     logger,
+	}
+}
+
+func (g *schemaGuard) EnforceOnce() error {
+	err := g.enforcer.EnforceOnce()
+
+	schemaErr := err != nil && database.IsSchemaError(err)
+	if !schemaErr && g.clock.Now().Sub(g.detectedAt) < redetectInterval {
+		return err
+	}
+
+	changed, detectErr := g.changed()
+	if detectErr != nil {
+		g.logger.Error("Failed to detect the brokers again", detectErr)
+		return err
+	}
+	if !changed {
+		return err
+	}
+
+	if err != nil {
+		g.logger.Error("Enforcing Failed", err)
+	}
+	return ErrStale
+}
+
+// changed detects the brokers and tells whether any lacks other tables or
+// columns than when the enforcer started.
+func (g *schemaGuard) changed() (bool, error) {
+	g.detectedAt = g.clock.Now()
+
+	for _, broker := range g.brokers {
+		detected, err := g.detector.Detect(broker)
+		if err != nil {
+			return false, err
+		}
+		if detected.Missing != broker.Missing {
+			g.logger.Info("Broker schema changed", lager.Data{
+				"DBName":   broker.DBName,
+				"Disabled": detected.DisabledFeatures(true),
+			})
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,119 @@
+package enforcer_test
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/clock/clockfakes"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database/databasefakes"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/enforcer"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/enforcer/enforcerfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SchemaGuard", func() {
+	var (
+		guard        Enforcer
+		fakeEnforcer *enforcerfakes.FakeEnforcer
+		fakeDetector *databasefakes.FakeBrokerDetector
+		fakeClock    *clockfakes.FakeClock
+		logger       *lagertest.TestLogger
+		broker       database.Broker
+		now          time.Time
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("SchemaGuard test")
+		fakeEnforcer = &enforcerfakes.FakeEnforcer{}
+		fakeDetector = &databasefakes.FakeBrokerDetector{}
+
+		broker = database.Broker{DBName: "fake_broker_db_name", Missing: database.Missing{ReadOnlyUsers: true}}
+		fakeDetector.DetectReturns(broker, nil)
+
+		now = time.Date(2016, 5, 4, 3, 0, 0, 0, time.UTC)
+		fakeClock = &clockfakes.FakeClock{}
+		fakeClock.NowStub = func() time.Time { return now }
+	})
+
+	JustBeforeEach(func() {
+		guard = NewSchemaGuard(fakeEnforcer, fakeDetector, []database.Broker{broker}, fakeClock, logger)
+	})
+
+	It("enforces without detecting the brokers again within the interval", func() {
+		Expect(guard.EnforceOnce()).To(Succeed())
+		Expect(fakeEnforcer.EnforceOnceCallCount()).To(Equal(1))
+		Expect(fakeDetector.DetectCallCount()).To(Equal(0))
+	})
+
+	It("returns the error of enforcing", func() {
+		fakeEnforcer.EnforceOnceReturns(errors.New("fake-enforce-error"))
+		Expect(guard.EnforceOnce()).To(MatchError("fake-enforce-error"))
+		Expect(fakeDetector.DetectCallCount()).To(Equal(0))
+	})
+
+	Context("once the interval passed", func() {
+		JustBeforeEach(func() {
+			now = now.Add(10 * time.Minute)
+		})
+
+		It("detects the brokers again", func() {
+			Expect(guard.EnforceOnce()).To(Succeed())
+			Expect(fakeDetector.DetectCallCount()).To(Equal(1))
+			Expect(fakeDetector.DetectArgsForCall(0)).To(Equal(broker))
+		})
+
+		It("waits another interval before detecting them again", func() {
+			Expect(guard.EnforceOnce()).To(Succeed())
+			Expect(guard.EnforceOnce()).To(Succeed())
+			Expect(fakeDetector.DetectCallCount()).To(Equal(1))
+		})
+
+		Context("when a broker changed", func() {
+			BeforeEach(func() {
+				fakeDetector.DetectReturns(database.Broker{DBName: "fake_broker_db_name"}, nil)
+			})
+
+			It("returns ErrStale", func() {
+				Expect(guard.EnforceOnce()).To(Equal(ErrStale))
+				Expect(logger.LogMessages()).To(ContainElement(ContainSubstring("Broker schema changed")))
+			})
+		})
+
+		Context("when detecting fails", func() {
+			BeforeEach(func() {
+				fakeDetector.DetectReturns(database.Broker{}, errors.New("fake-detect-error"))
+			})
+
+			It("logs the error and returns that of enforcing", func() {
+				Expect(guard.EnforceOnce()).To(Succeed())
+				Expect(logger.LogMessages()).To(ContainElement(ContainSubstring("Failed to detect the brokers again")))
+			})
+		})
+	})
+
+	Context("when a cycle fails on a missing table or column", func() {
+		BeforeEach(func() {
+			fakeEnforcer.EnforceOnceReturns(errors.New("Error executing 'violator'.All: Error 1054: Unknown column 'max_tables'"))
+		})
+
+		It("detects the brokers again right away", func() {
+			Expect(guard.EnforceOnce()).To(MatchError(ContainSubstring("Error 1054")))
+			Expect(fakeDetector.DetectCallCount()).To(Equal(1))
+		})
+
+		Context("when a broker changed", func() {
+			BeforeEach(func() {
+				fakeDetector.DetectReturns(database.Broker{DBName: "fake_broker_db_name"}, nil)
+			})
+
+			It("logs the error and returns ErrStale", func() {
+				Expect(guard.EnforceOnce()).To(Equal(ErrStale))
+				Expect(logger.LogMessages()).To(ContainElement(ContainSubstring("Enforcing Failed")))
+			})
+		})
+	})
+})
@@ -0,0 +1,91 @@
+package enforcer
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+)
+
+// throttler lowers MAX_UPDATES_PER_HOUR of the writers of an instance in tiers
+// as it approaches its quota, and removes the limit again as usage falls.
+type throttler struct {
+	usageRepo database.UsageRepo
+	tiers     []config.ThrottleTier
+	logger    lager.Logger
+}
+
+func NewThrottler(usageRepo database.UsageRepo, tiers []config.ThrottleTier, logger lager.Logger) Reconciler {
+	return &throttler{
+		usageRepo: usageRepo,
+		tiers:     tiers,
+		logger:    logger,
+	}
+}
+
+func (t throttler) ReconcileOnce() error {
+	t.logger.Info("Looking for instances approaching their quota")
+
+	usages, err := t.usageRepo.All()
+	if err != nil {
+		return fmt.Errorf("Finding usage: %s", err.Error())
+	}
+
+	for _, usage := range usages {
+		if usage.QuotaMB <= 0 {
+			continue
+		}
+
+		limit := t.limitFor(usage.PercentOfQuota())
+		if usage.MaxUpdatesPerHour == limit {
+			continue
+		}
+
+		if !t.isManaged(usage.MaxUpdatesPerHour) {
+			t.logger.Info(fmt.Sprintf(
+				"Not throttling db '%s': max updates per hour is set to %d outside of the throttle tiers",
+				usage.Database.Name(),
+				usage.MaxUpdatesPerHour,
+			))
+			continue
+		}
+
+		err = usage.Database.SetMaxUpdatesPerHour(limit)
+		if err != nil {
+			return fmt.Errorf("Throttling '%s': %s", usage.Database.Name(), err.Error())
+		}
+	}
+
+	return nil
+}
+
+// limitFor returns the limit of the highest tier reached, or zero (no limit)
+// if no tier has been reached.
+func (t throttler) limitFor(percentOfQuota float64) int {
+	var reached *config.ThrottleTier
+	for i, tier := range t.tiers {
+		if percentOfQuota >= tier.PercentOfQuota && (reached == nil || tier.PercentOfQuota > reached.PercentOfQuota) {
+			reached = &t.tiers[i]
+		}
+	}
+
+	if reached == nil {
+		return 0
+	}
+	return reached.MaxUpdatesPerHour
+}
+
+// isManaged is true if the limit was set by the throttler, so limits set by
+// an operator are left alone.
+func (t throttler) isManaged(maxUpdatesPerHour int) bool {
+	if maxUpdatesPerHour == 0 {
+		return true
+	}
+	for _, tier := range t.tiers {
+		if tier.MaxUpdatesPerHour == maxUpdatesPerHour {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package enforcer_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database/databasefakes"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/enforcer"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Throttler", func() {
+	var (
+		throttler     Reconciler
+		fakeUsageRepo *databasefakes.FakeUsageRepo
+		fakeDB        *databasefakes.FakeDatabase
+		logger        *lagertest.TestLogger
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("Throttler test")
+		fakeUsageRepo = &databasefakes.FakeUsageRepo{}
+		fakeDB = &databasefakes.FakeDatabase{}
+		fakeDB.NameReturns("fake-db-name")
+
+		throttler = NewThrottler(fakeUsageRepo, []config.ThrottleTier{
+			{PercentOfQuota: 95, MaxUpdatesPerHour: 100},
+			{PercentOfQuota: 90, MaxUpdatesPerHour: 1000},
+		}, logger)
+	})
+
+	usage := func(usedMB float64, maxUpdatesPerHour int) []database.Usage {
+		return []database.Usage{{
+			Database:          fakeDB,
+			UsedMB:            usedMB,
+			QuotaMB:           100,
+			MaxUpdatesPerHour: maxUpdatesPerHour,
+		}}
+	}
+
+	Context("when an instance is below all tiers", func() {
+		BeforeEach(func() {
+			fakeUsageRepo.AllReturns(usage(50, 0), nil)
+		})
+
+		It("does not throttle it", func() {
+			Expect(throttler.ReconcileOnce()).To(Succeed())
+			Expect(fakeDB.SetMaxUpdatesPerHourCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when an instance reaches a tier", func() {
+		BeforeEach(func() {
+			fakeUsageRepo.AllReturns(usage(92, 0), nil)
+		})
+
+		It("applies the limit of that tier", func() {
+			Expect(throttler.ReconcileOnce()).To(Succeed())
+			Expect(fakeDB.SetMaxUpdatesPerHourCallCount()).To(Equal(1))
+			Expect(fakeDB.SetMaxUpdatesPerHourArgsForCall(0)).To(Equal(1000))
+		})
+	})
+
+	Context("when an instance reaches several tiers", func() {
+		BeforeEach(func() {
+			fakeUsageRepo.AllReturns(usage(97, 1000), nil)
+		})
+
+		It("applies the limit of the highest tier", func() {
+			Expect(throttler.ReconcileOnce()).To(Succeed())
+			Expect(fakeDB.SetMaxUpdatesPerHourArgsForCall(0)).To(Equal(100))
+		})
+	})
+
+	Context("when an instance already has the limit of its tier", func() {
+		BeforeEach(func() {
+			fakeUsageRepo.AllReturns(usage(92, 1000), nil)
+		})
+
+		It("does not change it", func() {
+			Expect(throttler.ReconcileOnce()).To(Succeed())
+			Expect(fakeDB.SetMaxUpdatesPerHourCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when usage falls below all tiers", func() {
+		BeforeEach(func() {
+			fakeUsageRepo.AllReturns(usage(80, 100), nil)
+		})
+
+		It("removes the limit", func() {
+			Expect(throttler.ReconcileOnce()).To(Succeed())
+			Expect(fakeDB.SetMaxUpdatesPerHourArgsForCall(0)).To(Equal(0))
+		})
+	})
+
+	Context("when the limit was set outside of the tiers", func() {
+		BeforeEach(func() {
+			fakeUsageRepo.AllReturns(usage(80, 42), nil)
+		})
+
+		It("leaves it alone", func() {
+			Expect(throttler.ReconcileOnce()).To(Succeed())
+			Expect(fakeDB.SetMaxUpdatesPerHourCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the instance has no quota", func() {
+		BeforeEach(func() {
+			fakeUsageRepo.AllReturns([]database.Usage{{Database: fakeDB, UsedMB: 10}}, nil)
+		})
+
+		It("does not throttle it", func() {
+			Expect(throttler.ReconcileOnce()).To(Succeed())
+			Expect(fakeDB.SetMaxUpdatesPerHourCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when finding usage fails", func() {
+		BeforeEach(func() {
+			fakeUsageRepo.AllReturns(nil, errors.New("fake-usage-error"))
+		})
+
+		It("returns an error", func() {
+			err := throttler.ReconcileOnce()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-usage-error"))
+		})
+	})
+
+	Context("when setting the limit fails", func() {
+		BeforeEach(func() {
+			fakeUsageRepo.AllReturns(usage(92, 0), nil)
+			fakeDB.SetMaxUpdatesPerHourReturns(errors.New("fake-alter-error"))
+		})
+
+		It("returns an error", func() {
+			err := throttler.ReconcileOnce()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-alter-error"))
+			Expect(err.Error()).To(ContainSubstring("fake-db-name"))
+		})
+	})
+})
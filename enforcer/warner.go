@@ -0,0 +1,91 @@
+package enforcer
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/metrics"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/notifier"
+)
+
+const (
+	usagePercentMetric  = "quota_enforcer_usage_percent"
+	warningsTotalMetric = "quota_enforcer_warnings_total"
+)
+
+// warner reports instances crossing a warning threshold below their quota.
+// Each crossing is reported once; the highest threshold reported is kept in
+// the warnings table, and cleared as usage falls again or once the instance
+// is no longer measured.
+type warner struct {
+	warningRepo database.WarningRepo
+	thresholds  []float64
+	notifier    notifier.Notifier
+	metrics     metrics.Metrics
+	gauges      *dbGauges
+	logger      lager.Logger
+}
+
+func NewWarner(warningRepo database.WarningRepo, thresholds []float64, notifier notifier.Notifier, metrics metrics.Metrics, logger lager.Logger) Reconciler {
+	return &warner{
+		warningRepo: warningRepo,
+		thresholds:  thresholds,
+		notifier:    notifier,
+		metrics:     metrics,
+		gauges:      newDBGauges(metrics, usagePercentMetric),
+		logger:      logger,
+	}
+}
+
+func (w warner) ReconcileOnce() error {
+	w.logger.Info("Looking for instances crossing a warning threshold")
+
+	states, err := w.warningRepo.All()
+	if err != nil {
+		return fmt.Errorf("Finding warning states: %s", err.Error())
+	}
+
+	for _, state := range states {
+		percent := state.PercentOfQuota()
+		w.gauges.set(usagePercentMetric, state.DBName, percent)
+
+		crossed := w.thresholdCrossed(percent)
+		if crossed == state.ReportedPercent {
+			continue
+		}
+
+		if crossed > state.ReportedPercent {
+			w.logger.Info(fmt.Sprintf(
+				"Database '%s' uses %.1f%% of its quota, crossing the %g%% warning threshold",
+				state.DBName, percent, crossed,
+			))
+			w.notifier.Notify(notifier.Event{
+				Type:             notifier.EventWarning,
+				DBName:           state.DBName,
+				ThresholdPercent: crossed,
+			})
+			w.metrics.IncrementCounter(warningsTotalMetric, metrics.Labels{"threshold": fmt.Sprintf("%g", crossed)})
+		}
+
+		err = w.warningRepo.Record(state.DBName, crossed)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Instances deleted, or left without a quota, are no longer measured.
+	w.gauges.sweep()
+	return w.warningRepo.Prune()
+}
+
+// thresholdCrossed returns the highest threshold reached, or zero if none was.
+func (w warner) thresholdCrossed(percentOfQuota float64) float64 {
+	var crossed float64
+	for _, threshold := range w.thresholds {
+		if percentOfQuota >= threshold && threshold > crossed {
+			crossed = threshold
+		}
+	}
+	return crossed
+}
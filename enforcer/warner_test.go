@@ -0,0 +1,196 @@
+package enforcer_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database/databasefakes"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/enforcer"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/metrics"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/metrics/metricsfakes"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/notifier"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/notifier/notifierfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Warner", func() {
+	var (
+		warner          Reconciler
+		fakeWarningRepo *databasefakes.FakeWarningRepo
+		fakeNotifier    *notifierfakes.FakeNotifier
+		fakeMetrics     *metricsfakes.FakeMetrics
+		logger          *lagertest.TestLogger
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("Warner test")
+		fakeWarningRepo = &databasefakes.FakeWarningRepo{}
+		fakeNotifier = &notifierfakes.FakeNotifier{}
+		fakeMetrics = &metricsfakes.FakeMetrics{}
+
+		warner = NewWarner(fakeWarningRepo, []float64{90, 80}, fakeNotifier, fakeMetrics, logger)
+	})
+
+	state := func(usedMB, reportedPercent float64) []database.WarningState {
+		return []database.WarningState{{
+			DBName:          "fake-db-name",
+			UsedMB:          usedMB,
+			QuotaMB:         100,
+			ReportedPercent: reportedPercent,
+		}}
+	}
+
+	It("records the usage of every instance as a metric", func() {
+		fakeWarningRepo.AllReturns(state(42, 0), nil)
+
+		Expect(warner.ReconcileOnce()).To(Succeed())
+
+		Expect(fakeMetrics.SetGaugeCallCount()).To(Equal(1))
+		name, labels, value := fakeMetrics.SetGaugeArgsForCall(0)
+		Expect(name).To(Equal("quota_enforcer_usage_percent"))
+		Expect(labels).To(Equal(metrics.Labels{"db_name": "fake-db-name"}))
+		Expect(value).To(BeNumerically("~", 42, 0.001))
+	})
+
+	It("deletes the metric of an instance no longer measured", func() {
+		fakeWarningRepo.AllReturns(state(42, 0), nil)
+		Expect(warner.ReconcileOnce()).To(Succeed())
+
+		fakeWarningRepo.AllReturns([]database.WarningState{}, nil)
+		Expect(warner.ReconcileOnce()).To(Succeed())
+
+		Expect(fakeMetrics.DeleteGaugeCallCount()).To(Equal(1))
+		name, labels := fakeMetrics.DeleteGaugeArgsForCall(0)
+		Expect(name).To(Equal("quota_enforcer_usage_percent"))
+		Expect(labels).To(Equal(metrics.Labels{"db_name": "fake-db-name"}))
+	})
+
+	It("keeps the metric of an instance still measured", func() {
+		fakeWarningRepo.AllReturns(state(42, 0), nil)
+		Expect(warner.ReconcileOnce()).To(Succeed())
+		Expect(warner.ReconcileOnce()).To(Succeed())
+
+		Expect(fakeMetrics.DeleteGaugeCallCount()).To(Equal(0))
+	})
+
+	It("prunes the thresholds of instances no longer measured", func() {
+		fakeWarningRepo.AllReturns(state(42, 0), nil)
+		Expect(warner.ReconcileOnce()).To(Succeed())
+
+		Expect(fakeWarningRepo.PruneCallCount()).To(Equal(1))
+	})
+
+	Context("when an instance crosses a threshold", func() {
+		BeforeEach(func() {
+			fakeWarningRepo.AllReturns(state(85, 0), nil)
+		})
+
+		It("reports it through logs, metrics and the notifier, and records it", func() {
+			Expect(warner.ReconcileOnce()).To(Succeed())
+
+			Expect(logger.LogMessages()).To(ContainElement(ContainSubstring("crossing the 80% warning threshold")))
+
+			Expect(fakeNotifier.NotifyCallCount()).To(Equal(1))
+			Expect(fakeNotifier.NotifyArgsForCall(0)).To(Equal(notifier.Event{
+				Type:             notifier.EventWarning,
+				DBName:           "fake-db-name",
+				ThresholdPercent: 80,
+			}))
+
+			Expect(fakeMetrics.IncrementCounterCallCount()).To(Equal(1))
+			name, labels := fakeMetrics.IncrementCounterArgsForCall(0)
+			Expect(name).To(Equal("quota_enforcer_warnings_total"))
+			Expect(labels).To(Equal(metrics.Labels{"threshold": "80"}))
+
+			Expect(fakeWarningRepo.RecordCallCount()).To(Equal(1))
+			dbName, threshold := fakeWarningRepo.RecordArgsForCall(0)
+			Expect(dbName).To(Equal("fake-db-name"))
+			Expect(threshold).To(Equal(80.0))
+		})
+	})
+
+	Context("when the crossing was already reported", func() {
+		BeforeEach(func() {
+			fakeWarningRepo.AllReturns(state(85, 80), nil)
+		})
+
+		It("does not report it again", func() {
+			Expect(warner.ReconcileOnce()).To(Succeed())
+
+			Expect(fakeNotifier.NotifyCallCount()).To(Equal(0))
+			Expect(fakeWarningRepo.RecordCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when an instance crosses a higher threshold", func() {
+		BeforeEach(func() {
+			fakeWarningRepo.AllReturns(state(95, 80), nil)
+		})
+
+		It("reports the higher threshold", func() {
+			Expect(warner.ReconcileOnce()).To(Succeed())
+
+			Expect(fakeNotifier.NotifyArgsForCall(0).ThresholdPercent).To(Equal(90.0))
+			_, threshold := fakeWarningRepo.RecordArgsForCall(0)
+			Expect(threshold).To(Equal(90.0))
+		})
+	})
+
+	Context("when usage falls below a reported threshold", func() {
+		BeforeEach(func() {
+			fakeWarningRepo.AllReturns(state(50, 90), nil)
+		})
+
+		It("clears the record without reporting", func() {
+			Expect(warner.ReconcileOnce()).To(Succeed())
+
+			Expect(fakeNotifier.NotifyCallCount()).To(Equal(0))
+			_, threshold := fakeWarningRepo.RecordArgsForCall(0)
+			Expect(threshold).To(Equal(0.0))
+		})
+	})
+
+	Context("when finding warning states fails", func() {
+		BeforeEach(func() {
+			fakeWarningRepo.AllReturns(nil, errors.New("fake-warning-error"))
+		})
+
+		It("returns an error", func() {
+			err := warner.ReconcileOnce()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-warning-error"))
+		})
+
+		It("prunes nothing", func() {
+			warner.ReconcileOnce()
+			Expect(fakeWarningRepo.PruneCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when recording fails", func() {
+		BeforeEach(func() {
+			fakeWarningRepo.AllReturns(state(85, 0), nil)
+			fakeWarningRepo.RecordReturns(errors.New("fake-record-error"))
+		})
+
+		It("returns an error", func() {
+			err := warner.ReconcileOnce()
+			Expect(err).To(MatchError("fake-record-error"))
+		})
+	})
+
+	Context("when pruning fails", func() {
+		BeforeEach(func() {
+			fakeWarningRepo.AllReturns(state(42, 0), nil)
+			fakeWarningRepo.PruneReturns(errors.New("fake-prune-error"))
+		})
+
+		It("returns an error", func() {
+			err := warner.ReconcileOnce()
+			Expect(err).To(MatchError("fake-prune-error"))
+		})
+	})
+})
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/report"
+	"github.com/pivotal-cf-experimental/service-config"
+)
+
+func explainCommand(args []string) int {
+	serviceConfig := service_config.New()
+
+	flags := flag.NewFlagSet("explain", flag.ContinueOnError)
+	targetName := flags.String("target", "", "Name of the target, if the config lists Targets")
+	if code := parseFlags(flags, serviceConfig, args); code != exitOK {
+		return code
+	}
+	logger := newStderrLogger()
+
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: explain [flags] <db>")
+		return exitUsage
+	}
+	dbName := flags.Arg(0)
+
+	config, code := readConfig(serviceConfig, logger)
+	if code != exitOK {
+		return code
+	}
+	config, code = selectTarget(config, *targetName)
+	if code != exitOK {
+		return code
+	}
+
+	// Only reads, so the measurement node is used if there is one.
+	db, server, code := connect(config.MeasurementConfig(), logger)
+	if db != nil {
+		defer db.Close()
+	}
+	if code != exitOK {
+		return code
+	}
+
+	strategy, err := database.NewStrategy(config.EnforcementStrategy, server, logger)
+	if err != nil {
+		return fail(logger, "Invalid enforcement strategy", err, exitInvalidConfig)
+	}
+
+	brokers, _, code := detectBrokers(config, db, logger)
+	if code != exitOK {
+		return code
+	}
+
+	// The instance is explained as seen by the first broker claiming it.
+	var diagnosis database.Diagnosis
+	for _, broker := range brokers {
+		diagnosis, err = database.NewDiagnosisRepo(config.DBName, broker, ignoredUsers(config), server, strategy, config.ObjectQuotas, db, logger).Diagnose(dbName)
+		if _, ok := err.(database.InstanceNotFoundError); !ok {
+			break
+		}
+	}
+	if _, ok := err.(database.InstanceNotFoundError); ok {
+		return fail(logger, "Failed to explain", err, exitNotFound)
+	}
+	if err != nil {
+		return fail(logger, "Failed to explain", err, exitConnectionFailed)
+	}
+
+	err = report.Explain(os.Stdout, diagnosis, config)
+	if err != nil {
+		return fail(logger, "Failed to write explanation", err, exitFailed)
+	}
+	return exitOK
+}
@@ -70,6 +70,13 @@ func adminCredentials() AdminCredentials {
 	return adminCreds
 }
 
+func adminConfig() config.Config {
+	cfg := initConfig
+	cfg.User = adminCreds.User
+	cfg.Password = adminCreds.Password
+	return cfg
+}
+
 var _ = BeforeSuite(func() {
 	initConfig = newConfig("")
 
@@ -78,14 +85,14 @@ var _ = BeforeSuite(func() {
 
 	adminCreds = adminCredentials()
 
-	adminDB, err := database.NewConnection(adminCreds.User, adminCreds.Password, initConfig.Host, initConfig.Port, initConfig.DBName)
+	adminDB, err := database.NewConnection(adminConfig())
 	Expect(err).ToNot(HaveOccurred())
 	defer adminDB.Close()
 
 	_, err = adminDB.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", c.DBName))
 	Expect(err).ToNot(HaveOccurred())
 
-	db, err := database.NewConnection(c.User, c.Password, c.Host, c.Port, c.DBName)
+	db, err := database.NewConnection(c)
 	Expect(err).ToNot(HaveOccurred())
 
 	for _, ignoredUser := range initConfig.IgnoredUsers {
@@ -147,14 +154,14 @@ var _ = AfterSuite(func() {
 		Expect(os.IsExist(err)).To(BeFalse())
 	}
 
-	db, err := database.NewConnection(c.User, c.Password, c.Host, c.Port, c.DBName)
+	db, err := database.NewConnection(c)
 	Expect(err).ToNot(HaveOccurred())
 	defer db.Close()
 
 	_, err = db.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s", brokerDBName))
 	Expect(err).ToNot(HaveOccurred())
 
-	adminDB, err := database.NewConnection(adminCreds.User, adminCreds.Password, initConfig.Host, initConfig.Port, initConfig.DBName)
+	adminDB, err := database.NewConnection(adminConfig())
 	Expect(err).ToNot(HaveOccurred())
 	defer adminDB.Close()
 
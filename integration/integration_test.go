@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -212,6 +213,83 @@ var _ = Describe("Enforcer Integration", func() {
 			unimpactedTableName = uuidWithUnderscores("unimpacted")
 		})
 
+		Context("when database and user names contain special characters", func() {
+			var (
+				db, userConnection *sql.DB
+				userConfig         config.Config
+			)
+
+			BeforeEach(func() {
+				suffix := uuidWithUnderscores("")[1:9]
+
+				userConfig = config.Config{
+					Host:           c.Host,
+					Port:           c.Port,
+					User:           fmt.Sprintf("u-'ü.%s", suffix),
+					Password:       uuidWithUnderscores("password"),
+					DBName:         fmt.Sprintf("cf-ü.d'b_%s", suffix),
+					PauseInSeconds: 1,
+				}
+
+				var err error
+				db, err = database.NewConnection(c)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = exec(db, fmt.Sprintf("CREATE DATABASE %s", quoteIdentifier(userConfig.DBName)))
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = exec(db,
+					"INSERT INTO service_instances (guid,plan_guid,max_storage_mb,db_name) VALUES(?,?,?,?)", userConfig.DBName, plan, maxStorageMB, userConfig.DBName)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = exec(db, fmt.Sprintf(
+					"CREATE USER %s IDENTIFIED BY '%s'", quoteString(userConfig.User), userConfig.Password))
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = exec(db, fmt.Sprintf(
+					"GRANT ALL PRIVILEGES ON %s.* TO %s", quoteIdentifier(userConfig.DBName), quoteString(userConfig.User)))
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = exec(db, "FLUSH PRIVILEGES")
+				Expect(err).NotTo(HaveOccurred())
+
+				userConnection, err = database.NewConnection(userConfig)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				defer db.Close()
+				defer userConnection.Close()
+
+				_, err := exec(db, fmt.Sprintf("DROP DATABASE IF EXISTS %s", quoteIdentifier(userConfig.DBName)))
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = exec(db, fmt.Sprintf("DROP USER %s", quoteString(userConfig.User)))
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("revokes and restores write access", func() {
+				By("Revoking write access when over the quota", func() {
+					createSizedTable(maxStorageMB, userConfig.DBName, dataTableName, userConnection)
+
+					runEnforcerOnce()
+
+					_, err := userConnection.Exec(fmt.Sprintf(
+						"INSERT INTO %s (data) VALUES (?)", dataTableName), []byte{'1'})
+					Expect(err).To(HaveOccurred())
+				})
+
+				By("Re-enabling write access when back under the quota", func() {
+					_, err := userConnection.Exec(fmt.Sprintf("DROP TABLE %s", dataTableName))
+					Expect(err).NotTo(HaveOccurred())
+
+					runEnforcerOnce()
+
+					createSizedTable(maxStorageMB/2, userConfig.DBName, dataTableName, userConnection)
+				})
+			})
+		})
+
 		Context("when multiple databases exist with multiple users", func() {
 			var (
 				db, user0Connection, user1Connection, user2Connection, readOnlyConnection *sql.DB
@@ -219,7 +297,7 @@ var _ = Describe("Enforcer Integration", func() {
 			)
 
 			BeforeEach(func() {
-				db, err = database.NewConnection(c.User, c.Password, c.Host, c.Port, c.DBName)
+				db, err = database.NewConnection(c)
 				Expect(err).NotTo(HaveOccurred())
 
 				for _, dbName := range dbNames {
@@ -260,19 +338,19 @@ var _ = Describe("Enforcer Integration", func() {
 				_, err = exec(db, "FLUSH PRIVILEGES")
 				Expect(err).NotTo(HaveOccurred())
 
-				user0Connection, err = database.NewConnection(userConfigs[0].User, userConfigs[0].Password, userConfigs[0].Host, userConfigs[0].Port, userConfigs[0].DBName)
+				user0Connection, err = database.NewConnection(userConfigs[0])
 				Expect(err).NotTo(HaveOccurred())
 
-				user1Connection, err = database.NewConnection(userConfigs[1].User, userConfigs[1].Password, userConfigs[1].Host, userConfigs[1].Port, userConfigs[1].DBName)
+				user1Connection, err = database.NewConnection(userConfigs[1])
 				Expect(err).NotTo(HaveOccurred())
 
-				user2Connection, err = database.NewConnection(userConfigs[2].User, userConfigs[2].Password, userConfigs[2].Host, userConfigs[2].Port, userConfigs[2].DBName)
+				user2Connection, err = database.NewConnection(userConfigs[2])
 				Expect(err).NotTo(HaveOccurred())
 
-				readOnlyConnection, err = database.NewConnection(readOnlyConfig.User, readOnlyConfig.Password, readOnlyConfig.Host, readOnlyConfig.Port, readOnlyConfig.DBName)
+				readOnlyConnection, err = database.NewConnection(readOnlyConfig)
 				Expect(err).NotTo(HaveOccurred())
 
-				adminDB, err = database.NewConnection(adminCreds.User, adminCreds.Password, initConfig.Host, initConfig.Port, initConfig.DBName)
+				adminDB, err = database.NewConnection(adminConfig())
 				Expect(err).ToNot(HaveOccurred())
 			})
 
@@ -362,7 +440,7 @@ var _ = Describe("Enforcer Integration", func() {
 			})
 
 			It("restores write access after dropping all tables", func() {
-				db, err := database.NewConnection(userConfigs[0].User, userConfigs[0].Password, userConfigs[0].Host, userConfigs[0].Port, userConfigs[0].DBName)
+				db, err := database.NewConnection(userConfigs[0])
 				Expect(err).NotTo(HaveOccurred())
 				defer db.Close()
 
@@ -393,7 +471,7 @@ var _ = Describe("Enforcer Integration", func() {
 
 			Context("ignored users", func() {
 				BeforeEach(func() {
-					db, err := database.NewConnection(userConfigs[0].User, userConfigs[0].Password, userConfigs[0].Host, userConfigs[0].Port, userConfigs[0].DBName)
+					db, err := database.NewConnection(userConfigs[0])
 					Expect(err).NotTo(HaveOccurred())
 					defer db.Close()
 
@@ -434,6 +512,14 @@ var _ = Describe("Enforcer Integration", func() {
 	})
 })
 
+func quoteIdentifier(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+func quoteString(value string) string {
+	return "'" + strings.Replace(strings.Replace(value, `\`, `\\`, -1), "'", "''", -1) + "'"
+}
+
 func fileExists(filename string) bool {
 	_, err := os.Stat(filename)
 	return err == nil
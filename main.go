@@ -1,57 +1,168 @@
 package main
 
 import (
+	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"strconv"
-	"time"
-
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/tedsuo/ifrit"
+	"strings"
 
 	"code.cloudfoundry.org/cflager"
 	"code.cloudfoundry.org/lager"
-	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/clock"
 	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
 	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
-	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/enforcer"
 	"github.com/pivotal-cf-experimental/service-config"
 )
 
+// Exit codes, so automation can tell failures apart.
+const (
+	exitOK               = 0
+	exitFailed           = 1
+	exitUsage            = 2
+	exitInvalidConfig    = 3
+	exitConnectionFailed = 4
+	exitNotFound         = 5
+	exitPreflightFailed  = 6
+)
+
+const usage = `Usage: %[1]s [command] [flags]
+
+Commands:
+  enforce         Enforce quotas once (-runOnce) or continuously. This is the default.
+  report          Print usage versus quota of all instances (-format table, json or csv).
+  explain <db>    Show the grantees, tables, quota and thresholds of an instance,
+                  and what the enforcer would do.
+  check-config    Validate the config, check that the database can be reached
+                  and that the enforcer account has the privileges it needs.
+  acknowledge     Acknowledge a tripped circuit breaker, so restrictions resume.
+
+Every command accepts -config or -configPath, and -logLevel. If the config
+lists Targets, report, explain and acknowledge require -target, and
+check-config checks all targets unless -target is given.
+
+Exit codes:
+  0  success
+  1  the command failed
+  2  usage error
+  3  invalid config
+  4  the database cannot be reached or queried
+  5  no such instance
+  6  the enforcer account lacks privileges or broker tables are missing
+`
+
+type command func(args []string) int
+
+var commands = map[string]command{
+	"enforce":      enforceCommand,
+	"report":       reportCommand,
+	"explain":      explainCommand,
+	"check-config": checkConfigCommand,
+	"acknowledge":  acknowledgeCommand,
+}
+
 func main() {
-	serviceConfig := service_config.New()
+	os.Exit(run(os.Args[1:]))
+}
+
+// run dispatches to a command. Without a command, enforce runs, so existing
+// deployments passing only flags keep working.
+func run(args []string) int {
+	name := "enforce"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
 
-	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
-	runOnce := flags.Bool("runOnce", false, "Run only once instead of continuously")
-	pidFile := flags.String("pidFile", "", "Location of pid file")
+	if name == "help" {
+		fmt.Fprintf(os.Stdout, usage, os.Args[0])
+		return exitOK
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command '%s'\n\n", name)
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+		return exitUsage
+	}
+	return cmd(args)
+}
+
+// parseFlags adds the flags shared by all commands to flags and parses args.
+// It returns a non-zero exit code if the command should not continue.
+func parseFlags(flags *flag.FlagSet, serviceConfig *service_config.ServiceConfig, args []string) int {
 	serviceConfig.AddFlags(flags)
 	cflager.AddFlags(flags)
-	flags.Parse(os.Args[1:])
-	logger, _ := cflager.New("Quota Enforcer")
 
+	err := flags.Parse(args)
+	if err != nil {
+		return exitUsage
+	}
+	return exitOK
+}
+
+// newStderrLogger returns a logger like cflager.New, but writing to stderr, for
+// commands whose output on stdout is meant to be read.
+func newStderrLogger() lager.Logger {
+	_, stdoutSink := cflager.New("Quota Enforcer")
+
+	logger := lager.NewLogger("Quota Enforcer")
+	logger.RegisterSink(lager.NewReconfigurableSink(lager.NewWriterSink(os.Stderr, lager.DEBUG), stdoutSink.GetMinLevel()))
+	return logger
+}
+
+// fail logs err and repeats it on stderr, so it is seen even when logs are
+// shipped elsewhere, and returns code.
+func fail(logger lager.Logger, message string, err error, code int) int {
+	logger.Error(message, err)
+	fmt.Fprintf(os.Stderr, "%s: %s\n", message, err.Error())
+	return code
+}
+
+func readConfig(serviceConfig *service_config.ServiceConfig, logger lager.Logger) (config.Config, int) {
 	var config config.Config
 	err := serviceConfig.Read(&config)
 	if err != nil {
-		logger.Fatal("Failed to read config", err)
+		return config, fail(logger, "Failed to read config", err, exitInvalidConfig)
 	}
 
 	err = config.Validate()
 	if err != nil {
-		logger.Fatal("Invalid config", err)
+		return config, fail(logger, "Invalid config", err, exitInvalidConfig)
 	}
+	return config, exitOK
+}
 
-	adminUser := config.User
-	brokerDBName := config.DBName
+// selectTarget returns the config of the target named by the -target flag.
+// Commands acting on a single target require one to be named if the config
+// lists Targets.
+func selectTarget(cfg config.Config, name string) (config.Config, int) {
+	if len(cfg.Targets) == 0 {
+		if name != "" {
+			fmt.Fprintf(os.Stderr, "Unknown target '%s', the config lists no Targets\n", name)
+			return cfg, exitUsage
+		}
+		return cfg, exitOK
+	}
 
-	db, err := database.NewConnection(adminUser, config.Password, config.Host, config.Port, brokerDBName)
-	if db != nil {
-		defer db.Close()
+	targets := strings.Join(cfg.TargetNames(), ", ")
+	if name == "" {
+		fmt.Fprintf(os.Stderr, "The config lists several targets, choose one with -target: %s\n", targets)
+		return cfg, exitUsage
+	}
+	target, ok := cfg.TargetConfig(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown target '%s', must be one of %s\n", name, targets)
+		return cfg, exitUsage
 	}
+	return target, exitOK
+}
 
+// connect opens the connection to the database and detects the server. The
+// connection must be closed by the caller if it is not nil.
+func connect(config config.Config, logger lager.Logger) (*sql.DB, database.Server, int) {
+	db, err := database.NewConnection(config)
 	if err != nil {
-		logger.Fatal("Failed to open database connection", err)
+		return db, database.Server{}, fail(logger, "Failed to open database connection", err, exitConnectionFailed)
 	}
 
 	logger.Info(
@@ -59,51 +170,136 @@ func main() {
 		lager.Data{
 			"Host":         config.Host,
 			"Port":         config.Port,
-			"User":         adminUser,
-			"DatabaseName": brokerDBName,
+			"Socket":       config.Socket,
+			"User":         config.User,
+			"DatabaseName": config.DBName,
+			"TLSMode":      config.TLS.Mode,
 		})
-	ignoredUsers := []string{adminUser}
-	ignoredUsers = append(ignoredUsers, config.IgnoredUsers...)
 
-	violatorRepo := database.NewViolatorRepo(brokerDBName, ignoredUsers, db, logger)
-	reformerRepo := database.NewReformerRepo(brokerDBName, ignoredUsers, db, logger)
+	server, err := database.DetectServer(db)
+	if err != nil {
+		return db, server, fail(logger, "Failed to detect server version", err, exitConnectionFailed)
+	}
+	logger.Info("Detected server", lager.Data{"Flavor": server.Flavor, "Version": server.Version})
+
+	// The session variables a server needs are only known once it is
+	// detected, and are set by the driver on each new connection.
+	if params, changed := server.SessionParams(config.DSNParams); changed {
+		db.Close()
+		config.DSNParams = params
+		db, err = database.NewConnection(config)
+		if err != nil {
+			return db, server, fail(logger, "Failed to open database connection", err, exitConnectionFailed)
+		}
+	}
 
-	e := enforcer.NewEnforcer(violatorRepo, reformerRepo, logger)
-	r := enforcer.NewRunner(
-		e,
-		clock.DefaultClock(),
-		time.Duration(config.PauseInSeconds)*time.Second,
-		logger,
-	)
+	return db, server, exitOK
+}
 
-	if *runOnce {
-		logger.Info("Running once")
+func ignoredUsers(config config.Config) []string {
+	return append([]string{config.User}, config.IgnoredUsers...)
+}
 
-		err := e.EnforceOnce()
+// brokers returns the broker databases of a config, each with its schema.
+func brokers(config config.Config) []database.Broker {
+	var brokers []database.Broker
+	for _, name := range config.BrokerDBs() {
+		brokers = append(brokers, database.Broker{DBName: name, Schema: config.SchemaOf(name)})
+	}
+	return brokers
+}
+
+// detectBrokers returns the brokers of a config with the optional tables and
+// columns each lacks. The features these are needed for are disabled, which
+// is logged once for all brokers.
+func detectBrokers(cfg config.Config, db *sql.DB, logger lager.Logger) ([]database.Broker, []string, int) {
+	detector := database.NewBrokerDetector(db, logger)
+
+	var detected []database.Broker
+	disabled := []string{}
+	for _, broker := range brokers(cfg) {
+		broker, err := detector.Detect(broker)
 		if err != nil {
-			logger.Info(fmt.Sprintf("Quota Enforcing Failed: %s", err.Error()))
-		}
-	} else {
-		process := ifrit.Invoke(r)
-		logger.Info("Running continuously")
-
-		// Write pid file once we are running continuously
-		if *pidFile != "" {
-			pid := os.Getpid()
-			err = writePidFile(pid, *pidFile)
-			if err != nil {
-				logger.Fatal("Cannot write pid to file", err, lager.Data{"pidFile": pidFile, "pid": pid})
-			}
-			logger.Info("Wrote pid to file", lager.Data{"pidFile": pidFile, "pid": pid})
+			return nil, nil, fail(logger, "Failed to inspect the broker database", err, exitConnectionFailed)
 		}
+		detected = append(detected, broker)
+		disabled = append(disabled, broker.DisabledFeatures(cfg.ObjectQuotas)...)
+	}
 
-		err := <-process.Wait()
+	if len(disabled) > 0 {
+		logger.Info("Disabling features the broker schema has no tables or columns for", lager.Data{"Disabled": disabled})
+	}
+	return detected, disabled, exitOK
+}
+
+// connectMeasurement opens the connection to the node usage and privileges are
+// measured on, or returns db and server if there is none. The connection must
+// be closed by the caller if it is not nil and not db.
+func connectMeasurement(cfg config.Config, db *sql.DB, server database.Server, logger lager.Logger) (*sql.DB, database.Server, int) {
+	if cfg.Measurement.IsEmpty() {
+		return db, server, exitOK
+	}
+
+	measurementDB, measurementServer, code := connect(cfg.MeasurementConfig(), logger)
+	if code != exitOK {
+		return measurementDB, measurementServer, code
+	}
+
+	// Queries are built for the server being enforced.
+	if measurementServer.Flavor != server.Flavor || measurementServer.Major != server.Major || measurementServer.Minor != server.Minor {
+		logger.Error(
+			"Measurement node runs a different server version",
+			fmt.Errorf("%s differs from %s", measurementServer, server),
+		)
+	}
+	return measurementDB, measurementServer, exitOK
+}
+
+// connectClusterNodes opens a connection to each of the ClusterNodes. The
+// connections are opened lazily, so an unreachable node does not prevent
+// starting. They must be closed by the caller.
+func connectClusterNodes(cfg config.Config, logger lager.Logger) ([]database.Node, int) {
+	var nodes []database.Node
+	for i, nodeConfig := range cfg.ClusterNodeConfigs() {
+		db, err := database.NewConnection(nodeConfig)
 		if err != nil {
-			logger.Fatal("Quota Enforcing Failed", err)
+			return nodes, fail(logger, fmt.Sprintf("Failed to open connection to cluster node '%s'", cfg.ClusterNodes[i]), err, exitConnectionFailed)
 		}
+		nodes = append(nodes, database.Node{Address: cfg.ClusterNodes[i], DB: db})
 	}
+	if len(nodes) > 0 {
+		logger.Info("Killing connections on cluster nodes", lager.Data{"ClusterNodes": cfg.ClusterNodes})
+	}
+	return nodes, exitOK
 }
 
-func writePidFile(pid int, pidFile string) error {
-	return ioutil.WriteFile(pidFile, []byte(strconv.Itoa(pid)), 0644)
+// preflight checks the privileges of the enforcer account. Missing privileges
+// are logged as errors, and refuse to start in the refuse mode.
+func preflight(cfg config.Config, brokers []database.Broker, server database.Server, db *sql.DB, logger lager.Logger) ([]string, int) {
+	if cfg.Preflight == config.PreflightSkip {
+		return nil, exitOK
+	}
+
+	alterUsers := cfg.EnforcementStrategy == config.StrategyAccountLock ||
+		cfg.EnforcementStrategy == config.StrategyMaxConnections ||
+		len(cfg.ThrottleTiers) > 0 ||
+		!cfg.ConnectionLimits.IsEmpty()
+
+	missing, err := database.NewPreflight(brokers, server, alterUsers, cfg.Reclaim.Enabled, db, logger).Check()
+	if err != nil {
+		return nil, fail(logger, "Preflight check failed", err, exitConnectionFailed)
+	}
+	if len(missing) == 0 {
+		logger.Info("Preflight check passed")
+		return nil, exitOK
+	}
+
+	logger.Error("Preflight check failed", errors.New(strings.Join(missing, "; ")), lager.Data{"Missing": missing})
+	for _, m := range missing {
+		fmt.Fprintf(os.Stderr, "Preflight: %s\n", m)
+	}
+	if cfg.Preflight == config.PreflightRefuse {
+		return missing, exitPreflightFailed
+	}
+	return missing, exitOK
 }
@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	typeGauge   = "gauge"
+	typeCounter = "counter"
+)
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Labels identify a time series of a metric, e.g. the database it describes.
+type Labels map[string]string
+
+// Metrics records gauges and counters, and serves their current values over
+// HTTP in the Prometheus text format.
+type Metrics interface {
+	SetGauge(name string, labels Labels, value float64)
+	DeleteGauge(name string, labels Labels)
+	IncrementCounter(name string, labels Labels)
+	http.Handler
+}
+
+type series struct {
+	labels Labels
+	value  float64
+}
+
+type family struct {
+	metricType string
+	series     map[string]*series
+}
+
+type metrics struct {
+	mutex    sync.Mutex
+	families map[string]*family
+}
+
+func New() Metrics {
+	return &metrics{
+		families: map[string]*family{},
+	}
+}
+
+func (m *metrics) SetGauge(name string, labels Labels, value float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.seriesFor(name, typeGauge, labels).value = value
+}
+
+// DeleteGauge removes a time series of a gauge, e.g. of a database which was
+// deleted, so that it is no longer served.
+func (m *metrics) DeleteGauge(name string, labels Labels) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	f, ok := m.families[name]
+	if !ok {
+		return
+	}
+	delete(f.series, formatLabels(labels))
+}
+
+func (m *metrics) IncrementCounter(name string, labels Labels) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.seriesFor(name, typeCounter, labels).value++
+}
+
+func (m *metrics) seriesFor(name, metricType string, labels Labels) *series {
+	f, ok := m.families[name]
+	if !ok {
+		f = &family{metricType: metricType, series: map[string]*series{}}
+		m.families[name] = f
+	}
+
+	key := formatLabels(labels)
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: labels}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	names := make([]string, 0, len(m.families))
+	for name := range m.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := m.families[name]
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, f.metricType)
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s%s %g\n", name, key, f.series[key].value)
+		}
+	}
+}
+
+// formatLabels returns the labels in the text format, sorted by name, so the
+// result can also be used as a key.
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, labelValueEscaper.Replace(labels[name]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+type labeled struct {
+	Metrics
+	labels Labels
+}
+
+// WithLabels returns metrics which add labels to every time series recorded,
+// e.g. to tell apart the targets sharing one registry.
+func WithLabels(m Metrics, labels Labels) Metrics {
+	return &labeled{Metrics: m, labels: labels}
+}
+
+func (l *labeled) SetGauge(name string, labels Labels, value float64) {
+	l.Metrics.SetGauge(name, l.merge(labels), value)
+}
+
+func (l *labeled) DeleteGauge(name string, labels Labels) {
+	l.Metrics.DeleteGauge(name, l.merge(labels))
+}
+
+func (l *labeled) IncrementCounter(name string, labels Labels) {
+	l.Metrics.IncrementCounter(name, l.merge(labels))
+}
+
+func (l *labeled) merge(labels Labels) Labels {
+	merged := Labels{}
+	for name, value := range labels {
+		merged[name] = value
+	}
+	for name, value := range l.labels {
+		merged[name] = value
+	}
+	return merged
+}
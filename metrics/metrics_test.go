@@ -0,0 +1,88 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/metrics"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metrics", func() {
+	var m Metrics
+
+	BeforeEach(func() {
+		m = New()
+	})
+
+	serve := func() string {
+		recorder := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/metrics", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		m.ServeHTTP(recorder, req)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		return recorder.Body.String()
+	}
+
+	It("serves gauges and counters in the Prometheus text format", func() {
+		m.SetGauge("fake_gauge", Labels{"db_name": "fake-db-2"}, 2)
+		m.SetGauge("fake_gauge", Labels{"db_name": "fake-db-1"}, 1.5)
+		m.SetGauge("fake_gauge", Labels{"db_name": "fake-db-1"}, 42)
+		m.IncrementCounter("fake_counter_total", nil)
+		m.IncrementCounter("fake_counter_total", nil)
+
+		Expect(serve()).To(Equal(`# TYPE fake_counter_total counter
+fake_counter_total 2
+# TYPE fake_gauge gauge
+fake_gauge{db_name="fake-db-1"} 42
+fake_gauge{db_name="fake-db-2"} 2
+`))
+	})
+
+	It("sorts and escapes labels", func() {
+		m.SetGauge("fake_gauge", Labels{"z": "last", "a": `quote" and \ backslash`}, 1)
+
+		Expect(serve()).To(ContainSubstring(`fake_gauge{a="quote\" and \\ backslash",z="last"} 1`))
+	})
+
+	It("deletes a time series of a gauge", func() {
+		m.SetGauge("fake_gauge", Labels{"db_name": "fake-db-1"}, 1)
+		m.SetGauge("fake_gauge", Labels{"db_name": "fake-db-2"}, 2)
+		m.DeleteGauge("fake_gauge", Labels{"db_name": "fake-db-1"})
+		m.DeleteGauge("fake_unknown_gauge", nil)
+
+		Expect(serve()).To(Equal(`# TYPE fake_gauge gauge
+fake_gauge{db_name="fake-db-2"} 2
+`))
+	})
+
+	Describe("WithLabels", func() {
+		It("adds the labels to every time series", func() {
+			target := WithLabels(m, Labels{"target": "fake-target"})
+			target.SetGauge("fake_gauge", Labels{"db_name": "fake-db"}, 1)
+			target.IncrementCounter("fake_counter_total", nil)
+			m.IncrementCounter("fake_counter_total", nil)
+
+			Expect(serve()).To(Equal(`# TYPE fake_counter_total counter
+fake_counter_total 1
+fake_counter_total{target="fake-target"} 1
+# TYPE fake_gauge gauge
+fake_gauge{db_name="fake-db",target="fake-target"} 1
+`))
+		})
+
+		It("deletes only the time series with the labels", func() {
+			target := WithLabels(m, Labels{"target": "fake-target"})
+			target.SetGauge("fake_gauge", Labels{"db_name": "fake-db"}, 1)
+			m.SetGauge("fake_gauge", Labels{"db_name": "fake-db"}, 2)
+			target.DeleteGauge("fake_gauge", Labels{"db_name": "fake-db"})
+
+			Expect(serve()).To(Equal(`# TYPE fake_gauge gauge
+fake_gauge{db_name="fake-db"} 2
+`))
+		})
+	})
+})
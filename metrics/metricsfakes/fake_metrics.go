@@ -0,0 +1,168 @@
+// This file was generated by counterfeiter
+package metricsfakes
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/metrics"
+)
+
+type FakeMetrics struct {
+	SetGaugeStub        func(string, metrics.Labels, float64)
+	setGaugeMutex       sync.RWMutex
+	setGaugeArgsForCall []struct {
+		arg1 string
+		arg2 metrics.Labels
+		arg3 float64
+	}
+	DeleteGaugeStub        func(string, metrics.Labels)
+	deleteGaugeMutex       sync.RWMutex
+	deleteGaugeArgsForCall []struct {
+		arg1 string
+		arg2 metrics.Labels
+	}
+	IncrementCounterStub        func(string, metrics.Labels)
+	incrementCounterMutex       sync.RWMutex
+	incrementCounterArgsForCall []struct {
+		arg1 string
+		arg2 metrics.Labels
+	}
+	ServeHTTPStub        func(http.ResponseWriter, *http.Request)
+	serveHTTPMutex       sync.RWMutex
+	serveHTTPArgsForCall []struct {
+		arg1 http.ResponseWriter
+		arg2 *http.Request
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeMetrics) SetGauge(arg1 string, arg2 metrics.Labels, arg3 float64) {
+	fake.setGaugeMutex.Lock()
+	fake.setGaugeArgsForCall = append(fake.setGaugeArgsForCall, struct {
+		arg1 string
+		arg2 metrics.Labels
+		arg3 float64
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("SetGauge", []interface{}{arg1, arg2, arg3})
+	fake.setGaugeMutex.Unlock()
+	if fake.SetGaugeStub != nil {
+		fake.SetGaugeStub(arg1, arg2, arg3)
+	}
+}
+
+func (fake *FakeMetrics) SetGaugeCallCount() int {
+	fake.setGaugeMutex.RLock()
+	defer fake.setGaugeMutex.RUnlock()
+	return len(fake.setGaugeArgsForCall)
+}
+
+func (fake *FakeMetrics) SetGaugeArgsForCall(i int) (string, metrics.Labels, float64) {
+	fake.setGaugeMutex.RLock()
+	defer fake.setGaugeMutex.RUnlock()
+	return fake.setGaugeArgsForCall[i].arg1, fake.setGaugeArgsForCall[i].arg2, fake.setGaugeArgsForCall[i].arg3
+}
+
+func (fake *FakeMetrics) DeleteGauge(arg1 string, arg2 metrics.Labels) {
+	fake.deleteGaugeMutex.Lock()
+	fake.deleteGaugeArgsForCall = append(fake.deleteGaugeArgsForCall, struct {
+		arg1 string
+		arg2 metrics.Labels
+	}{arg1, arg2})
+	fake.recordInvocation("DeleteGauge", []interface{}{arg1, arg2})
+	fake.deleteGaugeMutex.Unlock()
+	if fake.DeleteGaugeStub != nil {
+		fake.DeleteGaugeStub(arg1, arg2)
+	}
+}
+
+func (fake *FakeMetrics) DeleteGaugeCallCount() int {
+	fake.deleteGaugeMutex.RLock()
+	defer fake.deleteGaugeMutex.RUnlock()
+	return len(fake.deleteGaugeArgsForCall)
+}
+
+func (fake *FakeMetrics) DeleteGaugeArgsForCall(i int) (string, metrics.Labels) {
+	fake.deleteGaugeMutex.RLock()
+	defer fake.deleteGaugeMutex.RUnlock()
+	return fake.deleteGaugeArgsForCall[i].arg1, fake.deleteGaugeArgsForCall[i].arg2
+}
+
+func (fake *FakeMetrics) IncrementCounter(arg1 string, arg2 metrics.Labels) {
+	fake.incrementCounterMutex.Lock()
+	fake.incrementCounterArgsForCall = append(fake.incrementCounterArgsForCall, struct {
+		arg1 string
+		arg2 metrics.Labels
+	}{arg1, arg2})
+	fake.recordInvocation("IncrementCounter", []interface{}{arg1, arg2})
+	fake.incrementCounterMutex.Unlock()
+	if fake.IncrementCounterStub != nil {
+		fake.IncrementCounterStub(arg1, arg2)
+	}
+}
+
+func (fake *FakeMetrics) IncrementCounterCallCount() int {
+	fake.incrementCounterMutex.RLock()
+	defer fake.incrementCounterMutex.RUnlock()
+	return len(fake.incrementCounterArgsForCall)
+}
+
+func (fake *FakeMetrics) IncrementCounterArgsForCall(i int) (string, metrics.Labels) {
+	fake.incrementCounterMutex.RLock()
+	defer fake.incrementCounterMutex.RUnlock()
+	return fake.incrementCounterArgsForCall[i].arg1, fake.incrementCounterArgsForCall[i].arg2
+}
+
+func (fake *FakeMetrics) ServeHTTP(arg1 http.ResponseWriter, arg2 *http.Request) {
+	fake.serveHTTPMutex.Lock()
+	fake.serveHTTPArgsForCall = append(fake.serveHTTPArgsForCall, struct {
+		arg1 http.ResponseWriter
+		arg2 *http.Request
+	}{arg1, arg2})
+	fake.recordInvocation("ServeHTTP", []interface{}{arg1, arg2})
+	fake.serveHTTPMutex.Unlock()
+	if fake.ServeHTTPStub != nil {
+		fake.ServeHTTPStub(arg1, arg2)
+	}
+}
+
+func (fake *FakeMetrics) ServeHTTPCallCount() int {
+	fake.serveHTTPMutex.RLock()
+	defer fake.serveHTTPMutex.RUnlock()
+	return len(fake.serveHTTPArgsForCall)
+}
+
+func (fake *FakeMetrics) ServeHTTPArgsForCall(i int) (http.ResponseWriter, *http.Request) {
+	fake.serveHTTPMutex.RLock()
+	defer fake.serveHTTPMutex.RUnlock()
+	return fake.serveHTTPArgsForCall[i].arg1, fake.serveHTTPArgsForCall[i].arg2
+}
+
+func (fake *FakeMetrics) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.setGaugeMutex.RLock()
+	defer fake.setGaugeMutex.RUnlock()
+	fake.deleteGaugeMutex.RLock()
+	defer fake.deleteGaugeMutex.RUnlock()
+	fake.incrementCounterMutex.RLock()
+	defer fake.incrementCounterMutex.RUnlock()
+	fake.serveHTTPMutex.RLock()
+	defer fake.serveHTTPMutex.RUnlock()
+	return fake.invocations
+}
+
+func (fake *FakeMetrics) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ metrics.Metrics = new(FakeMetrics)
@@ -0,0 +1,178 @@
+package notifier
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/clock"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+)
+
+const (
+	EventWarning  = "warning"
+	EventRevoked  = "revoked"
+	EventRestored = "restored"
+	EventError    = "error"
+
+	// EventOverQuota and EventUnderQuota take the place of EventRevoked and
+	// EventRestored under notify-only, which restricts nothing.
+	EventOverQuota  = "over-quota"
+	EventUnderQuota = "under-quota"
+
+	// EventCircuitBreakerTripped is critical: restrictions stop until an
+	// operator acknowledges. It concerns no single instance.
+	EventCircuitBreakerTripped = "circuit-breaker-tripped"
+)
+
+var (
+	errClosed    = errors.New("The notifier is closed")
+	errQueueFull = errors.New("The webhook queue is full")
+)
+
+// Event describes an enforcement transition of an instance. Callers set Type,
+// DBName and, where relevant, Error, ThresholdPercent and LargestTables; the
+// notifier fills in the rest. Target is the name of the target the instance
+// belongs to, if the config lists Targets. UsageError is why the GUID and usage
+// of the instance are missing, if looking them up failed.
+type Event struct {
+	Type             string               `json:"type"`
+	Target           string               `json:"target,omitempty"`
+	InstanceGUID     string               `json:"instance_guid"`
+	DBName           string               `json:"db_name"`
+	UsedMB           float64              `json:"used_mb"`
+	QuotaMB          float64              `json:"quota_mb"`
+	UsageError       string               `json:"usage_error,omitempty"`
+	ThresholdPercent float64              `json:"threshold_percent,omitempty"`
+	Error            string               `json:"error,omitempty"`
+	LargestTables    []database.TableSize `json:"largest_tables,omitempty"`
+	Timestamp        time.Time            `json:"timestamp"`
+}
+
+// Notifier tells the outside world about enforcement transitions. Events are
+// delivered in the background, and delivery failures are logged, so they never
+// stop enforcement. Close waits for the events queued to be delivered, and
+// must be called before exiting.
+type Notifier interface {
+	Notify(event Event)
+	Close() error
+}
+
+// notifier queues events without blocking, and looks up the usage of their
+// instance on its own goroutine, so that the lookup never holds up
+// enforcement either. The events are then queued for each webhook.
+type notifier struct {
+	webhooks     []*webhook
+	target       string
+	instanceRepo database.InstanceRepo
+	clock        clock.Clock
+	logger       lager.Logger
+
+	events chan Event
+	done   chan struct{}
+	mutex  *sync.Mutex
+	closed bool
+}
+
+// New returns a notifier for the instances of the named target, or of the only
+// deployment if target is empty. Events wait for their usage to be looked up
+// in a queue as large as the largest queue of the webhooks.
+func New(webhooks []config.Webhook, target string, instanceRepo database.InstanceRepo, clock clock.Clock, logger lager.Logger) Notifier {
+	n := &notifier{
+		target:       target,
+		instanceRepo: instanceRepo,
+		clock:        clock,
+		logger:       logger,
+		done:         make(chan struct{}),
+		mutex:        &sync.Mutex{},
+	}
+
+	queueSize := 0
+	for _, w := range webhooks {
+		webhook := newWebhook(w, clock, logger)
+		n.webhooks = append(n.webhooks, webhook)
+		if cap(webhook.queue) > queueSize {
+			queueSize = cap(webhook.queue)
+		}
+	}
+	n.events = make(chan Event, queueSize)
+
+	go n.dispatch()
+	return n
+}
+
+func (n *notifier) Notify(event Event) {
+	if len(n.webhooks) == 0 {
+		return
+	}
+
+	event.Target = n.target
+	event.Timestamp = n.clock.Now().UTC()
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.closed {
+		n.logger.Error(fmt.Sprintf("Dropping '%s' event of db '%s'", event.Type, event.DBName), errClosed)
+		return
+	}
+
+	select {
+	case n.events <- event:
+	default:
+		n.logger.Error(fmt.Sprintf("Dropping '%s' event of db '%s'", event.Type, event.DBName), errQueueFull)
+	}
+}
+
+// dispatch looks up the usage of the instance of each event, and queues the
+// event for each webhook.
+func (n *notifier) dispatch() {
+	defer close(n.done)
+
+	for event := range n.events {
+		if event.DBName != "" {
+			instance, err := n.instanceRepo.Find(event.DBName)
+			if err != nil {
+				n.logger.Error(fmt.Sprintf("Failed to look up usage for '%s' event of db '%s'", event.Type, event.DBName), err)
+				event.UsageError = err.Error()
+			}
+
+			event.InstanceGUID = instance.GUID
+			event.UsedMB = instance.UsedMB
+			event.QuotaMB = instance.QuotaMB
+		}
+
+		body, err := json.Marshal(event)
+		if err != nil {
+			n.logger.Error(fmt.Sprintf("Failed to encode '%s' event of db '%s'", event.Type, event.DBName), err)
+			continue
+		}
+
+		for _, w := range n.webhooks {
+			if !w.enqueue(delivery{eventType: event.Type, dbName: event.DBName, body: body}) {
+				n.logger.Error(fmt.Sprintf("Dropping '%s' event of db '%s'", event.Type, event.DBName), errQueueFull, lager.Data{"URL": w.url})
+			}
+		}
+	}
+}
+
+func (n *notifier) Close() error {
+	n.mutex.Lock()
+	if n.closed {
+		n.mutex.Unlock()
+		return nil
+	}
+	n.closed = true
+	close(n.events)
+	n.mutex.Unlock()
+
+	<-n.done
+	for _, w := range n.webhooks {
+		w.close()
+	}
+	return nil
+}
@@ -0,0 +1,331 @@
+package notifier_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/clock/clockfakes"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database/databasefakes"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/notifier"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Notifier", func() {
+	var (
+		n                Notifier
+		server           *ghttp.Server
+		webhook          config.Webhook
+		fakeInstanceRepo *databasefakes.FakeInstanceRepo
+		fakeClock        *clockfakes.FakeClock
+		logger           *lagertest.TestLogger
+		now              time.Time
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		webhook = config.Webhook{URL: server.URL() + "/events", Secret: "fake-secret"}
+
+		fakeInstanceRepo = &databasefakes.FakeInstanceRepo{}
+		fakeInstanceRepo.FindReturns(database.Instance{
+			GUID:    "fake-instance-guid",
+			DBName:  "fake-db-name",
+			UsedMB:  12.5,
+			QuotaMB: 10,
+		}, nil)
+
+		now = time.Date(2016, 5, 4, 3, 2, 1, 0, time.UTC)
+		fakeClock = &clockfakes.FakeClock{}
+		fakeClock.NowReturns(now)
+		fakeClock.AfterStub = func(time.Duration) <-chan time.Time {
+			c := make(chan time.Time, 1)
+			c <- now
+			return c
+		}
+
+		logger = lagertest.NewTestLogger("Notifier test")
+	})
+
+	JustBeforeEach(func() {
+		n = New([]config.Webhook{webhook}, "", fakeInstanceRepo, fakeClock, logger)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	verifySignedEvent := func(expected Event) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			body, err := ioutil.ReadAll(req.Body)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(req.Header.Get("Content-Type")).To(Equal("application/json"))
+			Expect(req.Header.Get(EventHeader)).To(Equal(expected.Type))
+			Expect(req.Header.Get(SignatureHeader)).To(Equal(Sign([]byte("fake-secret"), body)))
+
+			var event Event
+			Expect(json.Unmarshal(body, &event)).To(Succeed())
+			Expect(event).To(Equal(expected))
+		}
+	}
+
+	It("posts a signed event with the usage of the instance", func() {
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyRequest("POST", "/events"),
+			verifySignedEvent(Event{
+				Type:         EventRevoked,
+				InstanceGUID: "fake-instance-guid",
+				DBName:       "fake-db-name",
+				UsedMB:       12.5,
+				QuotaMB:      10,
+				Timestamp:    now,
+			}),
+		))
+
+		n.Notify(Event{Type: EventRevoked, DBName: "fake-db-name"})
+		Expect(n.Close()).To(Succeed())
+
+		Expect(server.ReceivedRequests()).To(HaveLen(1))
+		Expect(fakeInstanceRepo.FindArgsForCall(0)).To(Equal("fake-db-name"))
+	})
+
+	It("includes the cause of an error", func() {
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyRequest("POST", "/events"),
+			verifySignedEvent(Event{
+				Type:         EventError,
+				InstanceGUID: "fake-instance-guid",
+				DBName:       "fake-db-name",
+				UsedMB:       12.5,
+				QuotaMB:      10,
+				Error:        "fake-cause",
+				Timestamp:    now,
+			}),
+		))
+
+		n.Notify(Event{Type: EventError, DBName: "fake-db-name", Error: "fake-cause"})
+		Expect(n.Close()).To(Succeed())
+
+		Expect(server.ReceivedRequests()).To(HaveLen(1))
+	})
+
+	It("does not look up an instance for an event concerning none", func() {
+		server.AppendHandlers(verifySignedEvent(Event{
+			Type:      EventCircuitBreakerTripped,
+			Error:     "fake-reason",
+			Timestamp: now,
+		}))
+
+		n.Notify(Event{Type: EventCircuitBreakerTripped, Error: "fake-reason"})
+		Expect(n.Close()).To(Succeed())
+
+		Expect(server.ReceivedRequests()).To(HaveLen(1))
+		Expect(fakeInstanceRepo.FindCallCount()).To(Equal(0))
+	})
+
+	Context("when the instance cannot be looked up", func() {
+		BeforeEach(func() {
+			fakeInstanceRepo.FindReturns(database.Instance{DBName: "fake-db-name"}, errors.New("fake-find-error"))
+		})
+
+		It("still sends the event with the error, and logs it", func() {
+			server.AppendHandlers(verifySignedEvent(Event{
+				Type:       EventRestored,
+				DBName:     "fake-db-name",
+				UsageError: "fake-find-error",
+				Timestamp:  now,
+			}))
+
+			n.Notify(Event{Type: EventRestored, DBName: "fake-db-name"})
+			Expect(n.Close()).To(Succeed())
+
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+			Expect(logger.LogMessages()).To(ContainElement(ContainSubstring("Failed to look up usage")))
+		})
+	})
+
+	Context("when no secret is configured", func() {
+		BeforeEach(func() {
+			webhook.Secret = ""
+		})
+
+		It("does not sign the request", func() {
+			server.AppendHandlers(func(w http.ResponseWriter, req *http.Request) {
+				Expect(req.Header.Get(SignatureHeader)).To(BeEmpty())
+			})
+
+			n.Notify(Event{Type: EventRevoked, DBName: "fake-db-name"})
+			Expect(n.Close()).To(Succeed())
+
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	Context("when the webhook fails temporarily", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.RespondWith(http.StatusServiceUnavailable, nil),
+				ghttp.RespondWith(http.StatusTooManyRequests, nil),
+				ghttp.RespondWith(http.StatusOK, nil),
+			)
+		})
+
+		It("retries with exponential backoff", func() {
+			n.Notify(Event{Type: EventRevoked, DBName: "fake-db-name"})
+			Expect(n.Close()).To(Succeed())
+
+			Expect(server.ReceivedRequests()).To(HaveLen(3))
+			Expect(fakeClock.AfterCallCount()).To(Equal(2))
+			Expect(fakeClock.AfterArgsForCall(0)).To(Equal(1 * time.Second))
+			Expect(fakeClock.AfterArgsForCall(1)).To(Equal(2 * time.Second))
+		})
+	})
+
+	Context("when the webhook keeps failing", func() {
+		BeforeEach(func() {
+			webhook.MaxAttempts = 2
+			server.AppendHandlers(
+				ghttp.RespondWith(http.StatusInternalServerError, nil),
+				ghttp.RespondWith(http.StatusInternalServerError, nil),
+			)
+		})
+
+		It("gives up after MaxAttempts and logs the error", func() {
+			n.Notify(Event{Type: EventRevoked, DBName: "fake-db-name"})
+			Expect(n.Close()).To(Succeed())
+
+			Expect(server.ReceivedRequests()).To(HaveLen(2))
+			Expect(logger.LogMessages()).To(ContainElement(ContainSubstring("Failed to send 'revoked' event of db 'fake-db-name'")))
+		})
+	})
+
+	Context("when the webhook rejects the event", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusBadRequest, nil))
+		})
+
+		It("does not retry", func() {
+			n.Notify(Event{Type: EventRevoked, DBName: "fake-db-name"})
+			Expect(n.Close()).To(Succeed())
+
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+			Expect(fakeClock.AfterCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the notifier is for one of several targets", func() {
+		JustBeforeEach(func() {
+			n = New([]config.Webhook{webhook}, "fake-target", fakeInstanceRepo, fakeClock, logger)
+		})
+
+		It("names the target in the event", func() {
+			server.AppendHandlers(verifySignedEvent(Event{
+				Type:         EventRevoked,
+				Target:       "fake-target",
+				InstanceGUID: "fake-instance-guid",
+				DBName:       "fake-db-name",
+				UsedMB:       12.5,
+				QuotaMB:      10,
+				Timestamp:    now,
+			}))
+
+			n.Notify(Event{Type: EventRevoked, DBName: "fake-db-name"})
+			Expect(n.Close()).To(Succeed())
+
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	It("does not wait for the event to be delivered", func() {
+		delivered := make(chan struct{})
+		server.AppendHandlers(func(w http.ResponseWriter, req *http.Request) {
+			<-delivered
+		})
+
+		n.Notify(Event{Type: EventRevoked, DBName: "fake-db-name"})
+		close(delivered)
+
+		Expect(n.Close()).To(Succeed())
+		Expect(server.ReceivedRequests()).To(HaveLen(1))
+	})
+
+	It("does not wait for the usage of the instance to be looked up", func() {
+		found := make(chan struct{})
+		fakeInstanceRepo.FindStub = func(string) (database.Instance, error) {
+			<-found
+			return database.Instance{GUID: "fake-instance-guid"}, nil
+		}
+		server.AppendHandlers(ghttp.RespondWith(http.StatusOK, nil))
+
+		n.Notify(Event{Type: EventRevoked, DBName: "fake-db-name"})
+		close(found)
+
+		Expect(n.Close()).To(Succeed())
+		Expect(server.ReceivedRequests()).To(HaveLen(1))
+	})
+
+	Context("when the queue of the webhook is full", func() {
+		var (
+			received  chan struct{}
+			delivered chan struct{}
+		)
+
+		BeforeEach(func() {
+			webhook.QueueSize = 1
+			received = make(chan struct{}, 1)
+			delivered = make(chan struct{})
+			server.AppendHandlers(
+				func(w http.ResponseWriter, req *http.Request) {
+					received <- struct{}{}
+					<-delivered
+				},
+				ghttp.RespondWith(http.StatusOK, nil),
+			)
+		})
+
+		It("drops further events and logs them", func() {
+			n.Notify(Event{Type: EventRevoked, DBName: "fake-db-1"})
+			Eventually(received).Should(Receive())
+			n.Notify(Event{Type: EventRevoked, DBName: "fake-db-2"})
+			n.Notify(Event{Type: EventRevoked, DBName: "fake-db-3"})
+			close(delivered)
+			Expect(n.Close()).To(Succeed())
+
+			Expect(server.ReceivedRequests()).To(HaveLen(2))
+			Expect(logger.LogMessages()).To(ContainElement(ContainSubstring("Dropping 'revoked' event of db 'fake-db-3'")))
+		})
+	})
+
+	Context("when the notifier is closed", func() {
+		It("drops further events and logs them", func() {
+			Expect(n.Close()).To(Succeed())
+
+			n.Notify(Event{Type: EventRevoked, DBName: "fake-db-name"})
+
+			Expect(server.ReceivedRequests()).To(BeEmpty())
+			Expect(logger.LogMessages()).To(ContainElement(ContainSubstring("Dropping 'revoked' event of db 'fake-db-name'")))
+		})
+	})
+
+	Context("when no webhooks are configured", func() {
+		JustBeforeEach(func() {
+			n = New(nil, "", fakeInstanceRepo, fakeClock, logger)
+		})
+
+		It("does nothing", func() {
+			n.Notify(Event{Type: EventRevoked, DBName: "fake-db-name"})
+			Expect(n.Close()).To(Succeed())
+
+			Expect(fakeInstanceRepo.FindCallCount()).To(Equal(0))
+			Expect(server.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,161 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/clock"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+)
+
+const (
+	EventHeader     = "X-Quota-Enforcer-Event"
+	SignatureHeader = "X-Quota-Enforcer-Signature"
+
+	defaultMaxAttempts = 3
+	defaultTimeout     = 10 * time.Second
+	defaultQueueSize   = 100
+	initialBackoff     = 1 * time.Second
+)
+
+// delivery is an encoded event waiting in the queue of a webhook.
+type delivery struct {
+	eventType string
+	dbName    string
+	body      []byte
+}
+
+// webhook delivers the events queued for it one at a time on its own
+// goroutine, so that a slow or failing receiver never holds up enforcement.
+type webhook struct {
+	url         string
+	secret      []byte
+	maxAttempts int
+	client      *http.Client
+	queue       chan delivery
+	done        chan struct{}
+	clock       clock.Clock
+	logger      lager.Logger
+}
+
+func newWebhook(cfg config.Webhook, clock clock.Clock, logger lager.Logger) *webhook {
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+
+	timeout := time.Duration(cfg.TimeoutInSeconds) * time.Second
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	queueSize := cfg.QueueSize
+	if queueSize == 0 {
+		queueSize = defaultQueueSize
+	}
+
+	w := &webhook{
+		url:         cfg.URL,
+		secret:      []byte(cfg.Secret),
+		maxAttempts: maxAttempts,
+		client:      &http.Client{Timeout: timeout},
+		queue:       make(chan delivery, queueSize),
+		done:        make(chan struct{}),
+		clock:       clock,
+		logger:      logger,
+	}
+	go w.deliver()
+	return w
+}
+
+// enqueue queues an event without blocking. It is false if the queue is full.
+func (w *webhook) enqueue(d delivery) bool {
+	select {
+	case w.queue <- d:
+		return true
+	default:
+		return false
+	}
+}
+
+// close waits for the queued events to be delivered. No event may be queued
+// afterwards.
+func (w *webhook) close() {
+	close(w.queue)
+	<-w.done
+}
+
+func (w *webhook) deliver() {
+	defer close(w.done)
+
+	for d := range w.queue {
+		err := w.send(d.eventType, d.body)
+		if err != nil {
+			w.logger.Error(fmt.Sprintf("Failed to send '%s' event of db '%s'", d.eventType, d.dbName), err, lager.Data{"URL": w.url})
+		}
+	}
+}
+
+// Sign returns the signature of body sent in SignatureHeader, so receivers
+// can verify that an event was sent by the enforcer.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// send posts body, retrying connection errors, 429 and 5xx responses with
+// exponential backoff.
+func (w *webhook) send(eventType string, body []byte) error {
+	backoff := initialBackoff
+	var err error
+	for attempt := 1; attempt <= w.maxAttempts; attempt++ {
+		if attempt > 1 {
+			w.logger.Info(fmt.Sprintf("Retrying webhook in %s: %s", backoff, err.Error()), lager.Data{"URL": w.url})
+			<-w.clock.After(backoff)
+			backoff *= 2
+		}
+
+		var retry bool
+		retry, err = w.post(eventType, body)
+		if err == nil || !retry {
+			return err
+		}
+	}
+	return fmt.Errorf("Giving up after %d attempts: %s", w.maxAttempts, err.Error())
+}
+
+func (w *webhook) post(eventType string, body []byte) (bool, error) {
+	req, err := http.NewRequest("POST", w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("Creating request: %s", err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, eventType)
+	if len(w.secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(w.secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("Posting event: %s", err.Error())
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("Posting event: %s", resp.Status)
+	}
+	return false, fmt.Errorf("Posting event: %s", resp.Status)
+}
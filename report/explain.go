@@ -0,0 +1,164 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+)
+
+// Explain writes a diagnosis of one instance, with the thresholds of the
+// config in effect and the decision the enforcer would make.
+func Explain(w io.Writer, diagnosis database.Diagnosis, cfg config.Config) error {
+	instance := diagnosis.Instance
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Database:\t%s\n", instance.DBName)
+	fmt.Fprintf(tw, "Instance GUID:\t%s\n", instance.GUID)
+	fmt.Fprintf(tw, "Used:\t%.1f MB (%.1f%% of quota)\n", instance.UsedMB, instance.PercentOfQuota())
+	fmt.Fprintf(tw, "Quota:\t%.1f MB\n", instance.QuotaMB)
+	fmt.Fprintf(tw, "State:\t%s\n", instance.State(cfg.LowestWarningThreshold()))
+	fmt.Fprintf(tw, "Strategy:\t%s\n", diagnosis.Strategy)
+	fmt.Fprintf(tw, "Warning thresholds:\t%s\n", warningThresholds(cfg.WarningThresholds))
+	fmt.Fprintf(tw, "Throttle tiers:\t%s\n", throttleTiers(cfg.ThrottleTiers))
+	if diagnosis.ObjectQuotas {
+		fmt.Fprintf(tw, "Object quota:\t%s\n", objectQuota(diagnosis.ObjectQuotaExceeded))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Grantees:")
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	header := []string{"  user", "host", "privileges", "ignored", "read_only", "decision"}
+	if diagnosis.ObjectQuotas {
+		header = append(header, "object_quota_decision")
+	}
+	writeTableLine(tw, header)
+	for _, grantee := range diagnosis.Grantees {
+		line := []string{
+			"  " + grantee.User,
+			grantee.Host,
+			strings.Join(grantee.Privileges, ","),
+			yesNo(grantee.Ignored),
+			yesNo(grantee.ReadOnly),
+			grantee.Decision,
+		}
+		if diagnosis.ObjectQuotas {
+			line = append(line, grantee.ObjectQuotaDecision)
+		}
+		writeTableLine(tw, line)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Tables:")
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	writeTableLine(tw, []string{"  table", "size_mb"})
+	for _, table := range diagnosis.Tables {
+		writeTableLine(tw, []string{"  " + table.Name, strconv.FormatFloat(table.SizeMB, 'f', 1, 64)})
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	_, err := fmt.Fprintf(w, "Decision: %s\n", decision(diagnosis))
+	if err != nil || !diagnosis.ObjectQuotas {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "Object quota decision: %s\n", objectQuotaDecision(diagnosis))
+	return err
+}
+
+func objectQuotaDecision(diagnosis database.Diagnosis) string {
+	var restrict, lift int
+	for _, grantee := range diagnosis.Grantees {
+		switch grantee.ObjectQuotaDecision {
+		case database.DecisionRestrict:
+			restrict++
+		case database.DecisionLift:
+			lift++
+		}
+	}
+
+	if diagnosis.ObjectQuotaExceeded {
+		if restrict == 0 {
+			return "exceeded, no grantee holds CREATE or every one is ignored"
+		}
+		return fmt.Sprintf("exceeded, CREATE would be revoked from %d grantee(s)", restrict)
+	}
+	if lift == 0 {
+		return "within limits, nothing to change"
+	}
+	return fmt.Sprintf("within limits, CREATE would be granted to %d grantee(s)", lift)
+}
+
+func objectQuota(exceeded bool) string {
+	if exceeded {
+		return "exceeded"
+	}
+	return "within limits"
+}
+
+func decision(diagnosis database.Diagnosis) string {
+	var restrict, lift int
+	for _, grantee := range diagnosis.Grantees {
+		switch grantee.Decision {
+		case database.DecisionRestrict:
+			restrict++
+		case database.DecisionLift:
+			lift++
+		}
+	}
+
+	if diagnosis.Instance.QuotaMB <= 0 {
+		return "the quota is invalid, nothing is enforced"
+	}
+	if diagnosis.Instance.OverQuota() {
+		if restrict == 0 {
+			return "over quota, every grantee is already restricted or ignored"
+		}
+		return fmt.Sprintf("over quota, '%s' would be applied to %d grantee(s)", diagnosis.Strategy, restrict)
+	}
+	if lift == 0 {
+		return "under quota, nothing to change"
+	}
+	return fmt.Sprintf("under quota, '%s' would be reversed for %d grantee(s)", diagnosis.Strategy, lift)
+}
+
+func warningThresholds(thresholds []float64) string {
+	if len(thresholds) == 0 {
+		return "none"
+	}
+	formatted := make([]string, len(thresholds))
+	for i, threshold := range thresholds {
+		formatted[i] = fmt.Sprintf("%g%%", threshold)
+	}
+	return strings.Join(formatted, ", ")
+}
+
+func throttleTiers(tiers []config.ThrottleTier) string {
+	if len(tiers) == 0 {
+		return "none"
+	}
+	formatted := make([]string, len(tiers))
+	for i, tier := range tiers {
+		formatted[i] = fmt.Sprintf("%g%% -> %d updates/hour", tier.PercentOfQuota, tier.MaxUpdatesPerHour)
+	}
+	return strings.Join(formatted, ", ")
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,112 @@
+package report_test
+
+import (
+	"bytes"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/config"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/report"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Explain", func() {
+	var (
+		diagnosis database.Diagnosis
+		cfg       config.Config
+		out       *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		diagnosis = database.Diagnosis{
+			Instance: database.Instance{GUID: "fake-guid", DBName: "fake-db", UsedMB: 12.5, QuotaMB: 10},
+			Strategy: "revoke-writes",
+			Grantees: []database.Grantee{
+				{User: "cf_writer", Host: "%", Privileges: []string{"INSERT", "SELECT"}, Decision: database.DecisionRestrict},
+				{User: "admin", Host: "localhost", Privileges: []string{"SELECT"}, Ignored: true, Decision: database.DecisionIgnored},
+			},
+			Tables: []database.TableSize{
+				{Name: "big_table", SizeMB: 12.5},
+			},
+		}
+		cfg = config.Config{
+			WarningThresholds: []float64{80, 95},
+			ThrottleTiers:     []config.ThrottleTier{{PercentOfQuota: 90, MaxUpdatesPerHour: 1000}},
+		}
+		out = &bytes.Buffer{}
+	})
+
+	It("writes the instance, thresholds, grantees, tables and decision", func() {
+		Expect(Explain(out, diagnosis, cfg)).To(Succeed())
+		Expect(out.String()).To(Equal(
+			"Database:            fake-db\n" +
+				"Instance GUID:       fake-guid\n" +
+				"Used:                12.5 MB (125.0% of quota)\n" +
+				"Quota:               10.0 MB\n" +
+				"State:               over-quota\n" +
+				"Strategy:            revoke-writes\n" +
+				"Warning thresholds:  80%, 95%\n" +
+				"Throttle tiers:      90% -> 1000 updates/hour\n" +
+				"\n" +
+				"Grantees:\n" +
+				"  user       host       privileges     ignored  read_only  decision\n" +
+				"  cf_writer  %          INSERT,SELECT  no       no         restrict\n" +
+				"  admin      localhost  SELECT         yes      no         ignored\n" +
+				"\n" +
+				"Tables:\n" +
+				"  table      size_mb\n" +
+				"  big_table  12.5\n" +
+				"\n" +
+				"Decision: over quota, 'revoke-writes' would be applied to 1 grantee(s)\n"))
+	})
+
+	It("reports when the thresholds are not configured", func() {
+		Expect(Explain(out, diagnosis, config.Config{})).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("Warning thresholds:  none\n"))
+		Expect(out.String()).To(ContainSubstring("Throttle tiers:      none\n"))
+	})
+
+	It("reports when nothing would change", func() {
+		diagnosis.Instance.UsedMB = 5
+		diagnosis.Grantees[0].Decision = database.DecisionNone
+
+		Expect(Explain(out, diagnosis, cfg)).To(Succeed())
+		Expect(out.String()).To(HaveSuffix("Decision: under quota, nothing to change\n"))
+	})
+
+	It("reports restrictions which would be lifted", func() {
+		diagnosis.Instance.UsedMB = 5
+		diagnosis.Grantees[0].Decision = database.DecisionLift
+
+		Expect(Explain(out, diagnosis, cfg)).To(Succeed())
+		Expect(out.String()).To(HaveSuffix("Decision: under quota, 'revoke-writes' would be reversed for 1 grantee(s)\n"))
+	})
+
+	Context("when object quotas are enforced", func() {
+		BeforeEach(func() {
+			diagnosis.ObjectQuotas = true
+			diagnosis.ObjectQuotaExceeded = true
+			diagnosis.Grantees[0].ObjectQuotaDecision = database.DecisionRestrict
+			diagnosis.Grantees[1].ObjectQuotaDecision = database.DecisionIgnored
+		})
+
+		It("writes the object quota and the decisions on CREATE", func() {
+			Expect(Explain(out, diagnosis, cfg)).To(Succeed())
+			Expect(out.String()).To(ContainSubstring("Object quota:        exceeded\n"))
+			Expect(out.String()).To(ContainSubstring(
+				"  user       host       privileges     ignored  read_only  decision  object_quota_decision\n" +
+					"  cf_writer  %          INSERT,SELECT  no       no         restrict  restrict\n" +
+					"  admin      localhost  SELECT         yes      no         ignored   ignored\n"))
+			Expect(out.String()).To(HaveSuffix("Object quota decision: exceeded, CREATE would be revoked from 1 grantee(s)\n"))
+		})
+
+		It("reports CREATE which would be granted again", func() {
+			diagnosis.ObjectQuotaExceeded = false
+			diagnosis.Grantees[0].ObjectQuotaDecision = database.DecisionLift
+
+			Expect(Explain(out, diagnosis, cfg)).To(Succeed())
+			Expect(out.String()).To(HaveSuffix("Object quota decision: within limits, CREATE would be granted to 1 grantee(s)\n"))
+		})
+	})
+})
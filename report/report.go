@@ -0,0 +1,137 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+)
+
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+)
+
+var Formats = []string{FormatTable, FormatJSON, FormatCSV}
+
+var header = []string{"db_name", "instance_guid", "used_mb", "quota_mb", "percent_of_quota", "state", "growth_mb_per_day", "days_until_quota"}
+
+// Row is the usage of one instance versus its quota. The growth and forecast
+// are nil without usage history.
+type Row struct {
+	DBName         string   `json:"db_name"`
+	InstanceGUID   string   `json:"instance_guid"`
+	UsedMB         float64  `json:"used_mb"`
+	QuotaMB        float64  `json:"quota_mb"`
+	PercentOfQuota float64  `json:"percent_of_quota"`
+	State          string   `json:"state"`
+	GrowthMBPerDay *float64 `json:"growth_mb_per_day"`
+	DaysUntilQuota *float64 `json:"days_until_quota"`
+}
+
+// fields formats the row, with missing in place of a nil value.
+func (r Row) fields(missing string) []string {
+	return []string{
+		r.DBName,
+		r.InstanceGUID,
+		strconv.FormatFloat(r.UsedMB, 'f', 1, 64),
+		strconv.FormatFloat(r.QuotaMB, 'f', 1, 64),
+		strconv.FormatFloat(r.PercentOfQuota, 'f', 1, 64),
+		r.State,
+		formatOptional(r.GrowthMBPerDay, missing),
+		formatOptional(r.DaysUntilQuota, missing),
+	}
+}
+
+func formatOptional(value *float64, missing string) string {
+	if value == nil {
+		return missing
+	}
+	return strconv.FormatFloat(*value, 'f', 1, 64)
+}
+
+// Rows returns a row for each instance. Instances using at least
+// warningPercent of their quota are in the warning state. Growths, if any,
+// add the growth of an instance and when it is projected to reach its quota.
+func Rows(instances []database.Instance, growths []database.Growth, warningPercent float64) []Row {
+	growthByDBName := map[string]database.Growth{}
+	for _, growth := range growths {
+		growthByDBName[growth.DBName] = growth
+	}
+
+	rows := make([]Row, len(instances))
+	for i, instance := range instances {
+		rows[i] = Row{
+			DBName:         instance.DBName,
+			InstanceGUID:   instance.GUID,
+			UsedMB:         instance.UsedMB,
+			QuotaMB:        instance.QuotaMB,
+			PercentOfQuota: instance.PercentOfQuota(),
+			State:          instance.State(warningPercent),
+		}
+
+		growth, ok := growthByDBName[instance.DBName]
+		if !ok {
+			continue
+		}
+		mbPerDay := growth.MBPerDay
+		rows[i].GrowthMBPerDay = &mbPerDay
+		if timeUntilQuota, ok := growth.TimeUntilQuota(); ok {
+			days := timeUntilQuota.Hours() / 24
+			rows[i].DaysUntilQuota = &days
+		}
+	}
+	return rows
+}
+
+func Write(w io.Writer, format string, rows []Row) error {
+	switch format {
+	case FormatTable:
+		return writeTable(w, rows)
+	case FormatJSON:
+		return writeJSON(w, rows)
+	case FormatCSV:
+		return writeCSV(w, rows)
+	}
+	return fmt.Errorf("Unknown report format '%s'", format)
+}
+
+func writeTable(w io.Writer, rows []Row) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	writeTableLine(tw, header)
+	for _, row := range rows {
+		writeTableLine(tw, row.fields("-"))
+	}
+	return tw.Flush()
+}
+
+func writeTableLine(w io.Writer, fields []string) {
+	for i, field := range fields {
+		if i > 0 {
+			fmt.Fprint(w, "\t")
+		}
+		fmt.Fprint(w, field)
+	}
+	fmt.Fprintln(w)
+}
+
+func writeJSON(w io.Writer, rows []Row) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(rows)
+}
+
+func writeCSV(w io.Writer, rows []Row) error {
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	for _, row := range rows {
+		cw.Write(row.fields(""))
+	}
+	cw.Flush()
+	return cw.Error()
+}
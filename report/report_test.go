@@ -0,0 +1,84 @@
+package report_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	. "github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/report"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Report", func() {
+	var (
+		rows []Row
+		out  *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		rows = Rows([]database.Instance{
+			{GUID: "fake-guid-1", DBName: "fake-db-1", UsedMB: 12.5, QuotaMB: 10},
+			{GUID: "fake-guid-2", DBName: "fake-db-2", UsedMB: 9, QuotaMB: 10},
+			{GUID: "fake-guid-3", DBName: "fake-db-3", UsedMB: 1, QuotaMB: 10},
+		}, []database.Growth{
+			{DBName: "fake-db-2", UsedMB: 9, QuotaMB: 10, MBPerDay: 0.5},
+			{DBName: "fake-db-3", UsedMB: 1, QuotaMB: 10, MBPerDay: -0.5},
+		}, 80)
+		out = &bytes.Buffer{}
+	})
+
+	Describe("Rows", func() {
+		It("includes the percent of quota and state of each instance", func() {
+			Expect(rows).To(HaveLen(3))
+			Expect(rows[0]).To(Equal(Row{DBName: "fake-db-1", InstanceGUID: "fake-guid-1", UsedMB: 12.5, QuotaMB: 10, PercentOfQuota: 125, State: database.StateOverQuota}))
+			Expect(rows[1].State).To(Equal(database.StateWarning))
+			Expect(rows[2].State).To(Equal(database.StateOK))
+		})
+
+		It("includes the growth and forecast of instances with usage history", func() {
+			Expect(*rows[1].GrowthMBPerDay).To(Equal(0.5))
+			Expect(*rows[1].DaysUntilQuota).To(BeNumerically("~", 2, 0.001))
+
+			Expect(*rows[2].GrowthMBPerDay).To(Equal(-0.5))
+			Expect(rows[2].DaysUntilQuota).To(BeNil())
+		})
+	})
+
+	Describe("Write", func() {
+		It("writes an aligned table", func() {
+			Expect(Write(out, FormatTable, rows)).To(Succeed())
+			Expect(out.String()).To(Equal(
+				"db_name    instance_guid  used_mb  quota_mb  percent_of_quota  state       growth_mb_per_day  days_until_quota\n" +
+					"fake-db-1  fake-guid-1    12.5     10.0      125.0             over-quota  -                  -\n" +
+					"fake-db-2  fake-guid-2    9.0      10.0      90.0              warning     0.5                2.0\n" +
+					"fake-db-3  fake-guid-3    1.0      10.0      10.0              ok          -0.5               -\n"))
+		})
+
+		It("writes csv with a header", func() {
+			Expect(Write(out, FormatCSV, rows[:2])).To(Succeed())
+			Expect(out.String()).To(Equal(
+				"db_name,instance_guid,used_mb,quota_mb,percent_of_quota,state,growth_mb_per_day,days_until_quota\n" +
+					"fake-db-1,fake-guid-1,12.5,10.0,125.0,over-quota,,\n" +
+					"fake-db-2,fake-guid-2,9.0,10.0,90.0,warning,0.5,2.0\n"))
+		})
+
+		It("writes a json array", func() {
+			Expect(Write(out, FormatJSON, rows)).To(Succeed())
+
+			var decoded []Row
+			Expect(json.Unmarshal(out.Bytes(), &decoded)).To(Succeed())
+			Expect(decoded).To(Equal(rows))
+		})
+
+		It("writes an empty json array without instances", func() {
+			Expect(Write(out, FormatJSON, []Row{})).To(Succeed())
+			Expect(out.String()).To(Equal("[]\n"))
+		})
+
+		It("rejects unknown formats", func() {
+			Expect(Write(out, "xml", rows)).To(MatchError("Unknown report format 'xml'"))
+		})
+	})
+})
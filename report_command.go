@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/database"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/enforcer"
+	"github.com/pivotal-cf-experimental/cf-mysql-quota-enforcer/report"
+	"github.com/pivotal-cf-experimental/service-config"
+)
+
+func reportCommand(args []string) int {
+	serviceConfig := service_config.New()
+
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	format := flags.String("format", report.FormatTable, fmt.Sprintf("Output format: %s", strings.Join(report.Formats, ", ")))
+	targetName := flags.String("target", "", "Name of the target, if the config lists Targets")
+	if code := parseFlags(flags, serviceConfig, args); code != exitOK {
+		return code
+	}
+	logger := newStderrLogger()
+
+	if !isKnownFormat(*format) {
+		fmt.Fprintf(os.Stderr, "Unknown format '%s', must be one of %s\n", *format, strings.Join(report.Formats, ", "))
+		return exitUsage
+	}
+
+	config, code := readConfig(serviceConfig, logger)
+	if code != exitOK {
+		return code
+	}
+	config, code = selectTarget(config, *targetName)
+	if code != exitOK {
+		return code
+	}
+
+	// Only reads, so the measurement node is used if there is one.
+	db, server, code := connect(config.MeasurementConfig(), logger)
+	if db != nil {
+		defer db.Close()
+	}
+	if code != exitOK {
+		return code
+	}
+
+	var instanceRepos []database.InstanceRepo
+	for _, broker := range brokers(config) {
+		instanceRepos = append(instanceRepos, database.NewInstanceRepo(broker, server, db, logger))
+	}
+	instances, err := database.NewMultiBrokerInstanceRepo(instanceRepos).All()
+	if err != nil {
+		return fail(logger, "Failed to measure usage", err, exitConnectionFailed)
+	}
+
+	var growths []database.Growth
+	if config.UsageHistory.Enabled {
+		for _, broker := range brokers(config) {
+			brokerGrowths, err := database.NewUsageHistoryRepo(broker, server, db, db, logger).Growth(enforcer.ForecastWindow)
+			if err != nil {
+				return fail(logger, "Failed to forecast usage", err, exitConnectionFailed)
+			}
+			growths = append(growths, brokerGrowths...)
+		}
+	}
+
+	err = report.Write(os.Stdout, *format, report.Rows(instances, growths, config.LowestWarningThreshold()))
+	if err != nil {
+		return fail(logger, "Failed to write report", err, exitFailed)
+	}
+	return exitOK
+}
+
+func isKnownFormat(format string) bool {
+	for _, known := range report.Formats {
+		if format == known {
+			return true
+		}
+	}
+	return false
+}